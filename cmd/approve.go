@@ -0,0 +1,55 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/awslabs/ssosync/internal"
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var approveCmd = &cobra.Command{
+	Use:   "approve [change...]",
+	Short: "Approves the deletions held back by --require-approval",
+	Long: `Lists the deletions and member removals held back by --require-approval
+when no change is given, and approves the given changes, or all of them with
+--all, which are then applied by the next sync still finding them. The
+changes are recorded in the --state file or s3://bucket/key object of the
+sync.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if all, _ := cmd.Flags().GetBool("all"); all && len(args) > 0 {
+			return cobra.NoArgs(cmd, args)
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		by, _ := cmd.Flags().GetString("by")
+
+		return internal.DoApprove(context.Background(), cfg, os.Stdout, args, all, by)
+	},
+}
+
+// addApproveCommand adds the approve subcommand to cmd
+func addApproveCommand(cmd *cobra.Command, cfg *config.Config) {
+	approveCmd.Flags().StringVar(&cfg.State, "state", "", "file or s3://bucket/key of the state of the sync")
+	approveCmd.Flags().Bool("all", false, "approve all the pending changes")
+	approveCmd.Flags().String("by", os.Getenv("USER"), "operator recorded as approver")
+
+	cmd.AddCommand(approveCmd)
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/awslabs/ssosync/internal"
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:     "sync",
+	Short:   "Syncs the Google Workspace users and groups to AWS, as the bare command",
+	Example: strings.ReplaceAll(syncExamples, "ssosync ", "ssosync sync "),
+	RunE:    runSync,
+}
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Shows the changes a sync would apply, without applying them",
+	Long: `Runs a sync without applying any change, the changes are logged and
+counted in the summary. Unlike audit, the drift is not published, and the run
+is neither notified nor recorded in the state.`,
+	Example: `  # preview the changes of a new group query
+  ssosync plan -g 'email:aws-*' --debug`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg.Plan = true
+		return runSync(cmd, args)
+	},
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Counts the drift between Google and AWS without applying it, as --audit",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg.Audit = true
+		return runSync(cmd, args)
+	},
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports the users, groups and group members of the identity store as JSON",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+
+		w, closer, err := reportOutput(output)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		return internal.DoExport(context.Background(), cfg, w)
+	},
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Adopts the AWS users and groups created by earlier versions, without recreating them",
+	Long: `Adopts the AWS users and groups created by earlier versions of ssosync or
+by the automatic provisioning (SCIM): the users matching a Google user by user
+name, email or Google external id get the user name, display name, name and
+email the sync creates, and the Google user id recorded in their provenance,
+and the groups matching a Google group get the provenance in their description.
+Once migrated, the entities are managed with --managed-only.`,
+	Example: `  # review the changes, then apply them
+  ssosync migrate --dry-run
+  ssosync migrate`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		return internal.DoMigrate(context.Background(), cfg, os.Stdout, dryRun)
+	},
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validates the configuration and the Google credentials, without syncing",
+	Long: `Validates the flags and environment variables of a sync and reads the
+Google credentials, and with --online also checks that the Google API and the
+identity store are accessible, e.g. in a deployment pipeline.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		online, _ := cmd.Flags().GetBool("online")
+
+		return internal.DoValidate(context.Background(), cfg, os.Stdout, online)
+	},
+}
+
+// addSyncCommands adds the sync, plan, audit, export, migrate and
+// validate subcommands to cmd, the commands reading Google accept the
+// sync flags
+func addSyncCommands(cmd *cobra.Command, cfg *config.Config) {
+	for _, c := range []*cobra.Command{syncCmd, planCmd, auditCmd, migrateCmd, validateCmd} {
+		addGoogleFlags(c.Flags(), cfg)
+		addSyncFlags(c.Flags(), cfg)
+	}
+	validateCmd.Flags().Bool("online", false, "also check that the Google API and the identity store are accessible")
+	exportCmd.Flags().StringP("output", "o", "", "file the export is written to, stdout when not set")
+	migrateCmd.Flags().Bool("dry-run", false, "log the changes without applying them")
+
+	cmd.AddCommand(syncCmd, planCmd, auditCmd, exportCmd, migrateCmd, validateCmd)
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/username"
+	"github.com/spf13/cobra"
+)
+
+// syncExamples are the examples of the commands running a sync
+const syncExamples = `  # sync the users and groups of a local run, with the AWS CLI profile sandbox
+  ssosync --profile sandbox -c credentials.json -u admin@example.com -i d-1234567890
+
+  # sync the users of two departments and the groups whose email starts with aws-
+  ssosync -m 'orgDepartment=Engineering' -m 'orgDepartment=Finance' -g 'email:aws-*'
+
+  # sync the users who are not admins, and the groups named Admin* with an email starting with aws-
+  ssosync -m 'isAdmin=false' -g 'name:Admin* email:aws-*'
+
+  # drop the contractors from the synced users
+  ssosync --user-exclude-match 'orgUnitPath=/Contractors'
+
+  # map jane.doe@example.com to the AWS user name jane.doe
+  ssosync --user-name-template '{{.LocalPart}}'`
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generates the shell completion script",
+	Long: `Writes the completion script of the shell to stdout, e.g.
+
+  # bash, in ~/.bashrc
+  source <(ssosync completion bash)
+
+  # zsh, in a directory of $fpath
+  ssosync completion zsh > "${fpath[1]}/_ssosync"
+
+  # fish
+  ssosync completion fish > ~/.config/fish/completions/ssosync.fish
+
+  # powershell
+  ssosync completion powershell | Out-String | Invoke-Expression`,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.ExactValidArgs(1),
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return cmd.Root().GenBashCompletion(os.Stdout)
+		case "zsh":
+			return cmd.Root().GenZshCompletion(os.Stdout)
+		case "fish":
+			return cmd.Root().GenFishCompletion(os.Stdout, true)
+		default:
+			return cmd.Root().GenPowerShellCompletionWithDesc(os.Stdout)
+		}
+	},
+}
+
+// flagValues are the values completed for the flags taking one of a list
+var flagValues = map[string][]string{
+	"log-format":                    {"text", "json"},
+	"group-labels":                  {"security", "dynamic", "discussion"},
+	"log-level":                     {"panic", "fatal", "error", "warn", "change", "info", "debug", "trace"},
+	"email-policy":                  {"skip", "truncate", "alias"},
+	"group-name-policy":             {"transform", "skip"},
+	"target":                        {config.TargetIdentityStore, config.TargetKeycloak, config.TargetManagedAD, config.TargetMemory},
+	"targets":                       {config.TargetIdentityStore, config.TargetKeycloak, config.TargetManagedAD, config.TargetMemory},
+	"notify-on":                     {"always", "changes", "errors"},
+	"user-name-collision":           {username.CollisionFail, username.CollisionSkip, username.CollisionSuffix},
+	"secrets-backend":               {config.DefaultSecretsBackend, config.SecretsVault},
+	"google-credentials-encryption": {config.EncryptionKMS, config.EncryptionAge},
+	"evidence-lock-mode":            {"GOVERNANCE", "COMPLIANCE"},
+	"format":                        {"csv", "html", "text", "json"},
+	"google-scopes": {
+		"admin.directory.user.readonly",
+		"admin.directory.group.readonly",
+		"admin.directory.group.member.readonly",
+	},
+}
+
+// flagFiles are the flags taking a file, with its extensions if any
+var flagFiles = map[string][]string{
+	"google-credentials": {"json", "age", "enc"},
+	"age-identity":       nil,
+	"welcome-template":   nil,
+	"freeze-calendar":    {"ics"},
+	"hook-plugin":        {"so"},
+}
+
+// addCompletion adds the completion command to root, and registers the
+// completions of the flags of all its commands
+func addCompletion(root *cobra.Command) {
+	root.AddCommand(completionCmd)
+
+	var register func(c *cobra.Command)
+	register = func(c *cobra.Command) {
+		for name, values := range flagValues {
+			if c.Flag(name) == nil {
+				continue
+			}
+			values := values
+			_ = c.RegisterFlagCompletionFunc(name, func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				var res []string
+				for _, v := range values {
+					if strings.HasPrefix(v, toComplete) {
+						res = append(res, v)
+					}
+				}
+				return res, cobra.ShellCompDirectiveNoFileComp
+			})
+		}
+		for name, extensions := range flagFiles {
+			if c.Flag(name) != nil {
+				_ = c.MarkFlagFilename(name, extensions...)
+			}
+		}
+		for _, sub := range c.Commands() {
+			register(sub)
+		}
+	}
+	register(root)
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/awslabs/ssosync/internal"
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/health"
+	log "github.com/sirupsen/logrus"
+)
+
+// runDaemon syncs every cfg.Interval until interrupted. The health
+// endpoints turn unhealthy when no sync succeeded for three intervals,
+// so that an orchestrator can restart the process.
+func runDaemon(ctx context.Context, cfg *config.Config) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	status := health.NewStatus(3 * cfg.Interval)
+	mux := status.Handler()
+	if cfg.Pprof {
+		health.RegisterPprof(mux)
+	}
+	dumpHeapOnSignal(ctx, cfg.HeapProfileDir)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- health.Serve(ctx, cfg.HealthAddr, mux)
+	}()
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := internal.DoSync(ctx, cfg)
+		if err != nil {
+			log.WithError(err).Error("sync failed")
+		}
+		status.Record(err)
+
+		select {
+		case <-ctx.Done():
+			log.Info("shutting down")
+			return <-errs
+		case err := <-errs:
+			return err
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// dumpHeapOnSignal is not supported on Windows, which has no SIGUSR1,
+// the heap profile is available from /debug/pprof/heap instead
+func dumpHeapOnSignal(ctx context.Context, dir string) {
+	log.Debug("heap profile on SIGUSR1 is not supported on windows")
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/pkg/report"
+)
+
+// lastReport is the report of the last sync run by the root command
+var lastReport *report.Report
+
+// lambdaEvent is the input of a Lambda invocation, e.g. the constant
+// input of a schedule
+type lambdaEvent struct {
+	// Audit overrides the audit mode of the configuration, so that one
+	// function can be scheduled to audit often and to apply less often
+	Audit *bool `json:"audit"`
+	// Shard overrides the shard of the configuration, so that one
+	// function can be invoked once per shard
+	Shard *string `json:"shard"`
+}
+
+// eventAudit is the audit mode of the current invocation, if set
+var eventAudit *bool
+
+// eventShard is the shard of the current invocation, if set
+var eventShard *string
+
+// execute runs the root command, tests replace it to skip the sync
+var execute = func() error { return rootCmd.Execute() }
+
+// applyEvent applies the overrides of the current invocation to the config
+func applyEvent(cfg *config.Config) {
+	if eventAudit != nil {
+		cfg.Audit = *eventAudit
+	}
+	if eventShard != nil {
+		cfg.Shard = *eventShard
+	}
+}
+
+// handleLambda runs the command for a Lambda invocation and returns the
+// result of the sync, so that the invoker, e.g. a Step Functions state
+// machine, can inspect the outcome. Aborted runs still fail the
+// invocation with their error.
+func handleLambda(ev lambdaEvent) (report.Result, error) {
+	lastReport = nil
+	eventAudit = ev.Audit
+	eventShard = ev.Shard
+	// the config outlives the invocation in a warm function, so the
+	// override must not become the default of the next invocation
+	audit, shard := cfg.Audit, cfg.Shard
+	defer func() { cfg.Audit, cfg.Shard = audit, shard }()
+	err := execute()
+	if lastReport == nil {
+		return report.Result{Status: report.ResultError, Error: errorString(err), Version: version}, err
+	}
+	res := lastReport.Summary()
+	res.Version = version
+	return res, err
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
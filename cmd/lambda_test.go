@@ -0,0 +1,44 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleLambdaWarm(t *testing.T) {
+	assert := assert.New(t)
+
+	var audits []bool
+	var shards []string
+	defer func(e func() error) { execute = e }(execute)
+	execute = func() error {
+		applyEvent(cfg)
+		audits = append(audits, cfg.Audit)
+		shards = append(shards, cfg.Shard)
+		return nil
+	}
+
+	audit, shard := true, "2/4"
+	_, _ = handleLambda(lambdaEvent{Audit: &audit, Shard: &shard})
+	_, _ = handleLambda(lambdaEvent{})
+
+	assert.Equal([]bool{true, false}, audits)
+	assert.Equal([]string{"2/4", ""}, shards)
+	assert.False(cfg.Audit)
+	assert.Empty(cfg.Shard)
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/awslabs/ssosync/internal"
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Reports on the current state of the identity store",
+}
+
+var reportAccessCmd = &cobra.Command{
+	Use:   "access",
+	Short: "Lists the groups and permission sets of every user, e.g. for access reviews",
+	Long: `Lists the groups of every user of the identity store, and with
+--permission-sets the accounts and permission sets assigned to the user
+directly or through a group, as CSV or HTML for quarterly access reviews.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		permissionSets, _ := cmd.Flags().GetBool("permission-sets")
+
+		w, closer, err := reportOutput(output)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		return internal.DoAccessReport(context.Background(), cfg, w, format, permissionSets)
+	},
+}
+
+var reportOrphansCmd = &cobra.Command{
+	Use:   "orphans",
+	Short: "Lists the AWS users and groups without a synced Google counterpart",
+	Long: `Lists the AWS users and groups which have no Google counterpart matching
+the configured queries, classified as unmanaged (never created by ssosync and
+never deleted), orphaned (no longer in Google) or pending deletion (deleted by
+the next sync), to clean up drift accumulated before ssosync was adopted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+
+		w, closer, err := reportOutput(output)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		return internal.DoOrphanReport(context.Background(), cfg, w, format)
+	},
+}
+
+var reportSCIMCmd = &cobra.Command{
+	Use:   "scim",
+	Short: "Compares what the SCIM endpoint and the Identity Store API report",
+	Long: `Lists the users and groups which the SCIM endpoint of the automatic
+provisioning and the Identity Store API report differently: missing from one of
+them, with different attributes, or disabled over SCIM. The entities without
+ssosync provenance, e.g. provisioned over SCIM by ssosync before v2, are marked
+pre_v2. With --interval the comparison runs continuously during a migration and
+every new or resolved discrepancy is logged.`,
+	Example: `  # compare once
+  ssosync report scim --scim-endpoint https://scim.us-east-1.amazonaws.com/xxxx/scim/v2
+
+  # compare every 10 minutes, with the token in SSOSYNC_SCIM_ACCESS_TOKEN
+  ssosync report scim --interval 10m`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		interval, _ := cmd.Flags().GetDuration("interval")
+
+		w, closer, err := reportOutput(output)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return internal.DoSCIMCompare(ctx, cfg, w, format, interval)
+	},
+}
+
+// reportOutput opens the file a report is written to, stdout when empty
+func reportOutput(output string) (io.Writer, func(), error) {
+	if output == "" || output == "-" {
+		return os.Stdout, func() {}, nil
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot create report")
+	}
+	return f, func() { f.Close() }, nil
+}
+
+// addReportCommands adds the report subcommands to cmd
+func addReportCommands(cmd *cobra.Command, cfg *config.Config) {
+	reportAccessCmd.Flags().String("format", "csv", "format of the report (csv|html)")
+	reportAccessCmd.Flags().StringP("output", "o", "", "file the report is written to, stdout when not set")
+	reportAccessCmd.Flags().Bool("permission-sets", false, "include the account assignments, requires sso:ListPermissionSets, sso:DescribePermissionSet, sso:ListAccountsForProvisionedPermissionSet and sso:ListAccountAssignments")
+
+	reportOrphansCmd.Flags().String("format", "text", "format of the report (text|csv)")
+	reportOrphansCmd.Flags().StringP("output", "o", "", "file the report is written to, stdout when not set")
+	addGoogleFlags(reportOrphansCmd.Flags(), cfg)
+
+	reportSCIMCmd.Flags().String("format", "text", "format of the report (text|csv|json)")
+	reportSCIMCmd.Flags().StringP("output", "o", "", "file the report is written to, stdout when not set")
+	reportSCIMCmd.Flags().Duration("interval", 0, "repeat the comparison at this interval until interrupted, logging the new and resolved discrepancies")
+	reportSCIMCmd.Flags().StringVar(&cfg.SCIMEndpoint, "scim-endpoint", "", "SCIM endpoint of the IAM Identity Center automatic provisioning")
+	reportSCIMCmd.Flags().StringVar(&cfg.SCIMAccessToken, "scim-access-token", "", "access token of the SCIM endpoint, prefer SSOSYNC_SCIM_ACCESS_TOKEN")
+
+	reportCmd.AddCommand(reportAccessCmd)
+	reportCmd.AddCommand(reportOrphansCmd)
+	reportCmd.AddCommand(reportSCIMCmd)
+	cmd.AddCommand(reportCmd)
+}
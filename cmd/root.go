@@ -20,17 +20,29 @@ import (
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/awslabs/ssosync/internal"
+	awsclient "github.com/awslabs/ssosync/internal/aws"
 	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/logging"
+	"github.com/awslabs/ssosync/internal/transport"
+	"github.com/awslabs/ssosync/internal/username"
+	"github.com/awslabs/ssosync/pkg/ssosync"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -43,6 +55,9 @@ var (
 
 var cfg *config.Config
 
+// vault is the secrets backend when --secrets-backend vault is used
+var vault *config.Vault
+
 var rootCmd = &cobra.Command{
 	Version: "dev",
 	Use:     "ssosync",
@@ -50,17 +65,27 @@ var rootCmd = &cobra.Command{
 	Long: `A command line tool to enable you to synchronise your Google
 Apps (Google Workspace) users to AWS Single Sign-on (AWS SSO)
 Complete documentation is available at https://github.com/awslabs/ssosync`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
+	Example: syncExamples,
+	// the bare invocation syncs, as existing deployments rely on it
+	RunE: runSync,
+}
 
-		err := internal.DoSync(ctx, cfg)
-		if err != nil {
-			return err
-		}
+// runSync runs a sync, or keeps syncing in daemon mode
+func runSync(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if cfg.Daemon {
+		return runDaemon(ctx, cfg)
+	}
 
-		return nil
-	},
+	rpt, err := internal.Sync(ctx, cfg)
+	lastReport = rpt
+	if err != nil {
+		return err
+	}
+
+	return nil
 }
 
 // Execute is the entry point of the command. If we are
@@ -68,7 +93,7 @@ Complete documentation is available at https://github.com/awslabs/ssosync`,
 // execution path.
 func Execute() {
 	if cfg.IsLambda {
-		lambda.Start(rootCmd.Execute)
+		lambda.Start(handleLambda)
 	}
 
 	if err := rootCmd.Execute(); err != nil {
@@ -81,22 +106,18 @@ func init() {
 	cfg = config.New()
 	cfg.IsLambda = len(os.Getenv("_LAMBDA_SERVER_PORT")) > 0
 
-	awscfg, err := awsconfig.LoadDefaultConfig(context.TODO(),
-		awsconfig.WithRetryer(func() aws.Retryer {
-			return retry.AddWithMaxAttempts(retry.NewStandard(), 5)
-		}),
-		awsconfig.WithRetryer(func() aws.Retryer {
-			return retry.AddWithMaxBackoffDelay(retry.NewStandard(), time.Second*5)
-		}),
-	)
-	if err != nil {
-		log.Fatalf("unable to load SDK config, %v", err)
-	}
-	cfg.AWSConfig = awscfg
 	// initialize cobra
 	cobra.OnInitialize(initConfig)
 	addFlags(rootCmd, cfg)
+	addReportCommands(rootCmd, cfg)
+	addApproveCommand(rootCmd, cfg)
+	addSyncCommands(rootCmd, cfg)
+	addVersionCommand(rootCmd)
+	// last, as it registers the completions of the flags of all commands
+	addCompletion(rootCmd)
 
+	rootCmd.Version = version
+	cfg.BuildVersion = version
 	rootCmd.SetVersionTemplate(fmt.Sprintf("%s, commit %s, built at %s by %s\n", version, commit, date, builtBy))
 
 	// silence on the root cmd
@@ -106,43 +127,330 @@ func init() {
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
-	// allow to read in from environment
-	viper.SetEnvPrefix("ssosync")
-	viper.AutomaticEnv()
-
 	appEnvVars := []string{
 		"google_admin",
 		"google_credentials",
+		"google_scopes",
+		"google_credentials_encryption",
+		"age_identity",
 		"log_level",
+		"quiet",
 		"log_format",
+		"audit_format",
+		"log_redact",
 		"ignore_users",
 		"ignore_groups",
+		"ignore_users_group",
+		"ignore_groups_group",
+		"group_description",
+		"provenance",
+		"managed_only",
+		"exclude_system_groups",
+		"system_groups",
 		"user_match",
 		"group_match",
+		"derived_membership",
+		"licenses",
+		"license_customer",
+		"group_labels",
+		"group_settings",
+		"privileged_groups",
+		"google_customer_id",
+		"user_exclude_match",
+		"group_exclude_match",
+		"unmanaged_membership_groups",
+		"protected_users",
+		"protected_groups",
+		"skip_deleted_users",
+		"delete_absent_users",
+		"group_description_tags",
+		"user_name_template",
+		"user_name_collision",
+		"email_policy",
+		"group_name_policy",
+		"group_key",
+		"group_name_max_length",
+		"group_member_limit",
+		"overflow_groups",
+		"target",
+		"targets",
+		"keycloak_url",
+		"keycloak_realm",
+		"keycloak_token_realm",
+		"keycloak_client_id",
+		"keycloak_client_secret",
+		"directory_id",
 		"identity_store_id",
+		"profile",
+		"secrets_region",
+		"secrets_profile",
+		"secrets_role_arn",
+		"identity_store_region",
+		"identity_store_profile",
+		"identity_store_role_arn",
+		"timeout",
+		"google_timeout",
+		"google_retries",
+		"target_retries",
+		"aws_timeout",
+		"proxy",
+		"google_proxy",
+		"aws_proxy",
+		"identity_store_endpoint",
+		"secrets_manager_endpoint",
+		"sso_admin_endpoint",
+		"skip_preflight",
+		"discover_identity_store",
+		"daemon",
+		"interval",
+		"member_cache",
+		"health_addr",
+		"pprof",
+		"heap_profile_dir",
+		"hook_command",
+		"hook_webhook",
+		"event_stream",
+		"hook_plugin",
+		"hook_timeout",
+		"slack_webhook",
+		"slack_webhook_secret",
+		"teams_webhook",
+		"notify_webhook",
+		"notify_webhook_template",
+		"email_from",
+		"email_to",
+		"evidence",
+		"evidence_retention_days",
+		"evidence_lock_mode",
+		"welcome_email_from",
+		"welcome_subject",
+		"welcome_template",
+		"welcome_portal_url",
+		"welcome_queue",
+		"welcome_webhook",
+		"notify_on",
+		"state",
+		"pagerduty_routing_key",
+		"opsgenie_api_key",
+		"alert_after",
+		"audit",
+		"update_check",
+		"freeze_windows",
+		"freeze_calendar",
+		"pause_flag",
+		"lock",
+		"lock_ttl",
+		"inventory",
+		"inventory_refresh",
+		"defer_deletions",
+		"deletion_delay",
+		"removal_chunk_size",
+		"scim_endpoint",
+		"scim_access_token",
+		"removal_retries",
+		"retry_failed",
+		"retry_delay",
+		"verify",
+		"heartbeat",
+		"max_runtime",
+		"shard",
+		"require_approval",
+		"config_uri",
+		"anomaly_factor",
+		"anomaly_min_changes",
+		"force",
+		"drift_threshold",
+		"drift_topic",
+		"drift_metric_namespace",
+		"emf_namespace",
+		"secrets_backend",
+		"vault_addr",
+		"vault_token",
+		"vault_mount",
+		"vault_path",
+		"vault_aws_mount",
+		"vault_aws_role",
 	}
 
-	for _, e := range appEnvVars {
-		if err := viper.BindEnv(e); err != nil {
-			log.Fatalf(errors.Wrap(err, "cannot bind environment variable").Error())
-		}
+	// allow to read in from environment, including nested and file variants
+	if err := config.Load(viper.GetViper(), "ssosync", appEnvVars, cfg); err != nil {
+		log.Fatalf(errors.Wrap(err, "cannot load config").Error())
 	}
 
-	if err := viper.Unmarshal(&cfg); err != nil {
-		log.Fatalf(errors.Wrap(err, "cannot unmarshal config").Error())
+	// the configuration stored in S3 or SSM is overridden by the
+	// environment variables and flags
+	if cfg.ConfigURI != "" {
+		configAWS()
+		b, err := config.ReadURI(context.TODO(), cfg.AWSConfig, cfg.ConfigURI)
+		if err != nil {
+			log.Fatalf(errors.Wrap(err, "cannot load config").Error())
+		}
+		if err := config.Merge(viper.GetViper(), "ssosync", b, flagChanged, cfg); err != nil {
+			log.Fatalf(errors.Wrap(err, "cannot load config "+cfg.ConfigURI).Error())
+		}
 	}
 
+	applyEvent(cfg)
+
 	// config logger
 	logConfig(cfg)
 
-	if cfg.IsLambda {
-		configLambda()
+	// the AWS config depends on the flags, e.g. the profile
+	configAWS()
+
+	if cfg.SecretsBackend == config.SecretsVault {
+		configVault()
+	}
+
+	configAccounts()
+
+	if cfg.IsLambda || cfg.SecretsBackend == config.SecretsVault {
+		configSecrets()
+	}
+
+	if cfg.SlackWebhookSecret != "" {
+		unwrap, err := newSecrets().Secret(cfg.SlackWebhookSecret)
+		if err != nil {
+			log.Fatalf(errors.Wrap(err, "cannot read slack webhook").Error())
+		}
+		cfg.SlackWebhook = unwrap
 	}
 }
 
-func configLambda() {
-	svc := secretsmanager.NewFromConfig(cfg.AWSConfig)
-	secrets := config.NewSecrets(svc)
+// flagChanged reports whether the flag of the configuration key, e.g.
+// --ignore-users for ignore_users, is set on the command line
+func flagChanged(key string) bool {
+	name := strings.ReplaceAll(key, "_", "-")
+	var changed func(*cobra.Command) bool
+	changed = func(c *cobra.Command) bool {
+		for _, fs := range []*pflag.FlagSet{c.Flags(), c.PersistentFlags()} {
+			if f := fs.Lookup(name); f != nil && f.Changed {
+				return true
+			}
+		}
+		for _, sub := range c.Commands() {
+			if changed(sub) {
+				return true
+			}
+		}
+		return false
+	}
+	return changed(rootCmd)
+}
+
+// configAWS loads the AWS SDK config. The default credential chain is used,
+// which also resolves profiles configured with `aws configure sso` from the
+// token cached by `aws sso login`.
+func configAWS() {
+	awscfg, err := loadAWS()
+	if err != nil {
+		log.Fatalf("unable to load SDK config, %v", err)
+	}
+	cfg.AWSConfig = awscfg
+}
+
+// loadAWS loads an AWS SDK config with the HTTP client, retries and
+// endpoints of ssosync and the given options, e.g. another profile
+func loadAWS(extra ...func(*awsconfig.LoadOptions) error) (aws.Config, error) {
+	hc, err := transport.NewClient("aws", awshttp.NewBuildableClient().GetTransport(), transport.Options{
+		Timeout: cfg.AWSTimeout,
+		Proxy:   cfg.ProxyFor(cfg.AWSProxy),
+	})
+	if err != nil {
+		log.Fatalf(errors.Wrap(err, "cannot configure AWS HTTP client").Error())
+	}
+
+	opts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRetryer(func() aws.Retryer {
+			return retry.AddWithMaxAttempts(retry.NewStandard(), 5)
+		}),
+		awsconfig.WithRetryer(func() aws.Retryer {
+			return retry.AddWithMaxBackoffDelay(retry.NewStandard(), time.Second*5)
+		}),
+		awsconfig.WithHTTPClient(hc),
+		awsconfig.WithEndpointResolverWithOptions(awsclient.EndpointResolver(map[string]string{
+			identitystore.ServiceID:  cfg.IdentityStoreEndpoint,
+			secretsmanager.ServiceID: cfg.SecretsManagerEndpoint,
+			ssoadmin.ServiceID:       cfg.SSOAdminEndpoint,
+		})),
+	}
+	if cfg.Profile != "" {
+		opts = append(opts, awsconfig.WithSharedConfigProfile(cfg.Profile))
+	}
+
+	return awsconfig.LoadDefaultConfig(context.TODO(), append(opts, extra...)...)
+}
+
+// configAccounts derives the AWS configs of the secrets and of the
+// Identity Store, which are often in different accounts with a delegated
+// administration, e.g. the secrets in an ops account and the store in
+// the management account
+func configAccounts() {
+	var err error
+	cfg.SecretsAWSConfig, err = accountAWS(cfg.SecretsProfile, cfg.SecretsRegion, cfg.SecretsRoleArn)
+	if err != nil {
+		log.Fatalf(errors.Wrap(err, "cannot configure AWS for the secrets").Error())
+	}
+	cfg.IdentityStoreAWSConfig, err = accountAWS(cfg.IdentityStoreProfile, cfg.IdentityStoreRegion, cfg.IdentityStoreRoleArn)
+	if err != nil {
+		log.Fatalf(errors.Wrap(err, "cannot configure AWS for the identity store").Error())
+	}
+}
+
+// accountAWS returns the AWS config with the given profile, region and
+// role assumed with its credentials, the AWS config when none is given
+func accountAWS(profile, region, role string) (aws.Config, error) {
+	c := cfg.AWSConfig
+	if profile != "" {
+		var err error
+		if c, err = loadAWS(awsconfig.WithSharedConfigProfile(profile)); err != nil {
+			return c, err
+		}
+	}
+	if region != "" {
+		c.Region = region
+	}
+	if role != "" {
+		c.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(c), role, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = "ssosync"
+		}))
+	}
+	return c, nil
+}
+
+// configVault connects to Vault and, when a role is configured, uses
+// the AWS credentials generated by Vault
+func configVault() {
+	hc, err := transport.NewClient("vault", nil, transport.Options{
+		Timeout: cfg.AWSTimeout,
+		Proxy:   cfg.ProxyFor(""),
+	})
+	if err != nil {
+		log.Fatalf(errors.Wrap(err, "cannot configure Vault HTTP client").Error())
+	}
+
+	vault, err = config.NewVault(cfg.VaultAddr, cfg.VaultToken, cfg.VaultMount, cfg.VaultPath, hc)
+	if err != nil {
+		log.Fatalf(errors.Wrap(err, "cannot configure Vault").Error())
+	}
+
+	if cfg.VaultAWSRole != "" {
+		cfg.AWSConfig.Credentials = aws.NewCredentialsCache(vault.AWSCredentials(cfg.VaultAWSMount, cfg.VaultAWSRole))
+	}
+}
+
+// newSecrets returns the secrets of the configured backend
+func newSecrets() *config.Secrets {
+	if vault != nil {
+		return config.NewVaultSecrets(vault)
+	}
+	return config.NewSecrets(secretsmanager.NewFromConfig(cfg.SecretsAWSConfig))
+}
+
+// configSecrets reads the Google admin and credentials from the secrets
+// backend, Secrets Manager in Lambda or Vault
+func configSecrets() {
+	secrets := newSecrets()
 
 	unwrap, err := secrets.GoogleAdminEmail()
 	if err != nil {
@@ -155,20 +463,163 @@ func configLambda() {
 		log.Fatalf(errors.Wrap(err, "cannot read config").Error())
 	}
 	cfg.GoogleCredentials = unwrap
+	cfg.GoogleCredentialsInline = true
 }
 
 func addFlags(cmd *cobra.Command, cfg *config.Config) {
 	rootCmd.PersistentFlags().StringVarP(&cfg.GoogleCredentials, "google-admin", "a", config.DefaultGoogleCredentials, "path to find credentials file for Google Workspace")
 	rootCmd.PersistentFlags().BoolVarP(&cfg.Debug, "debug", "d", config.DefaultDebug, "enable verbose / debug logging")
 	rootCmd.PersistentFlags().StringVarP(&cfg.LogFormat, "log-format", "", config.DefaultLogFormat, "log format")
-	rootCmd.PersistentFlags().StringVarP(&cfg.LogLevel, "log-level", "", config.DefaultLogLevel, "log level")
-	rootCmd.Flags().StringVarP(&cfg.GoogleCredentials, "google-credentials", "c", config.DefaultGoogleCredentials, "path to Google Workspace credentials file")
-	rootCmd.Flags().StringVarP(&cfg.GoogleAdmin, "google-admin", "u", "", "Google Workspace admin user email")
-	rootCmd.Flags().StringSliceVar(&cfg.IgnoreUsers, "ignore-users", []string{}, "ignores these Google Workspace users")
-	rootCmd.Flags().StringSliceVar(&cfg.IgnoreGroups, "ignore-groups", []string{}, "ignores these Google Workspace groups")
-	rootCmd.Flags().StringVarP(&cfg.UserMatch, "user-match", "m", "", "Google Workspace Users filter query parameter, example: 'name:John* email:admin*', see: https://developers.google.com/admin-sdk/directory/v1/guides/search-users")
-	rootCmd.Flags().StringVarP(&cfg.GroupMatch, "group-match", "g", "", "Google Workspace Groups filter query parameter, example: 'name:Admin* email:aws-*', see: https://developers.google.com/admin-sdk/directory/v1/guides/search-groups")
-	rootCmd.Flags().StringVarP(&cfg.IdentityStoreId, "identity-store-id", "i", "", "Identity Store Id in AWS")
+	rootCmd.PersistentFlags().StringVar(&cfg.AuditFormat, "audit-format", "", "format of the change log entries for SIEM pipelines (json|ocsf|cef), the log format when empty")
+	rootCmd.PersistentFlags().StringVarP(&cfg.LogLevel, "log-level", "", config.DefaultLogLevel, "log level (panic|fatal|error|warn|change|info|debug|trace), change logs only the changes, warnings and errors, trace logs sanitized API payloads")
+	rootCmd.PersistentFlags().BoolVarP(&cfg.Quiet, "quiet", "q", false, "log only the changes, warnings and errors, like --log-level change")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.LogRedact, "log-redact", []string{config.DefaultLogRedact}, "additional regular expressions scrubbed from the log output, credentials are always scrubbed")
+	addGoogleFlags(rootCmd.Flags(), cfg)
+	addSyncFlags(rootCmd.Flags(), cfg)
+	rootCmd.PersistentFlags().StringVar(&cfg.Target, "target", config.TargetIdentityStore, "target of the sync, identitystore, keycloak, managed-ad (experimental) or memory to try a configuration without writing to AWS")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.Targets, "targets", nil, "targets the run is applied to concurrently instead of --target, e.g. identitystore,keycloak, a failing target does not block the others")
+	rootCmd.PersistentFlags().StringVar(&cfg.KeycloakURL, "keycloak-url", "", "base URL of the Keycloak of --target keycloak")
+	rootCmd.PersistentFlags().StringVar(&cfg.KeycloakRealm, "keycloak-realm", "", "Keycloak realm the users and groups are synced to")
+	rootCmd.PersistentFlags().StringVar(&cfg.KeycloakTokenRealm, "keycloak-token-realm", "", "Keycloak realm of the client, --keycloak-realm when empty")
+	rootCmd.PersistentFlags().StringVar(&cfg.KeycloakClientID, "keycloak-client-id", "", "Keycloak client whose service account has the manage-users role of the realm")
+	rootCmd.PersistentFlags().StringVar(&cfg.KeycloakClientSecret, "keycloak-client-secret", "", "secret of the Keycloak client, prefer SSOSYNC_KEYCLOAK_CLIENT_SECRET")
+	rootCmd.PersistentFlags().StringVarP(&cfg.IdentityStoreId, "identity-store-id", "i", "", "Identity Store Id in AWS, discovered when not set")
+	rootCmd.PersistentFlags().BoolVar(&cfg.DiscoverIdentityStore, "discover-identity-store", config.DefaultDiscoverIdentityStore, "discover the identity store id with sso:ListInstances when --identity-store-id is not set or is an instance ARN")
+	rootCmd.PersistentFlags().StringVar(&cfg.SecretsBackend, "secrets-backend", config.DefaultSecretsBackend, "backend the Google admin and credentials are read from (secretsmanager|vault), Secrets Manager is only used in AWS Lambda")
+	rootCmd.PersistentFlags().StringVar(&cfg.VaultAddr, "vault-addr", "", "address of the Vault for --secrets-backend vault, VAULT_ADDR when empty")
+	rootCmd.PersistentFlags().StringVar(&cfg.VaultToken, "vault-token", "", "Vault token, prefer VAULT_TOKEN or ~/.vault-token")
+	rootCmd.PersistentFlags().StringVar(&cfg.VaultMount, "vault-mount", config.DefaultVaultMount, "mount path of the Vault KV version 2 secrets engine")
+	rootCmd.PersistentFlags().StringVar(&cfg.VaultPath, "vault-path", config.DefaultVaultPath, "path of the Vault secret with the SSOSyncGoogleAdminEmail and SSOSyncGoogleCredentials keys")
+	rootCmd.PersistentFlags().StringVar(&cfg.VaultAWSMount, "vault-aws-mount", config.DefaultVaultAWSMount, "mount path of the Vault AWS secrets engine")
+	rootCmd.PersistentFlags().StringVar(&cfg.VaultAWSRole, "vault-aws-role", "", "role of the Vault AWS secrets engine the AWS credentials are generated for, the default AWS credentials when empty")
+	rootCmd.PersistentFlags().BoolVar(&cfg.UpdateCheck, "update-check", false, "check at startup, at most once a day, whether a newer release is available on GitHub and log it")
+	rootCmd.PersistentFlags().StringVar(&cfg.ConfigURI, "config-uri", "", "s3://bucket/key or ssm:<parameter name> of a YAML configuration, e.g. ssm:/ssosync/config, overridden by the environment variables and flags")
+	rootCmd.PersistentFlags().StringVar(&cfg.Profile, "profile", "", "AWS shared config profile to use, e.g. a profile set up with 'aws configure sso'")
+	rootCmd.PersistentFlags().StringVar(&cfg.SecretsRegion, "secrets-region", "", "AWS region of the Secrets Manager secrets and of the KMS key of the credentials, the AWS region when empty")
+	rootCmd.PersistentFlags().StringVar(&cfg.SecretsProfile, "secrets-profile", "", "AWS shared config profile the secrets are read with, --profile when empty")
+	rootCmd.PersistentFlags().StringVar(&cfg.SecretsRoleArn, "secrets-role-arn", "", "IAM role assumed to read the secrets, e.g. in an ops account")
+	rootCmd.PersistentFlags().StringVar(&cfg.IdentityStoreRegion, "identity-store-region", "", "AWS region of the Identity Store and of the IAM Identity Center instance, the AWS region when empty")
+	rootCmd.PersistentFlags().StringVar(&cfg.IdentityStoreProfile, "identity-store-profile", "", "AWS shared config profile the Identity Store is read and changed with, --profile when empty")
+	rootCmd.PersistentFlags().StringVar(&cfg.IdentityStoreRoleArn, "identity-store-role-arn", "", "IAM role assumed to read and change the Identity Store, e.g. in the management account")
+	rootCmd.PersistentFlags().DurationVar(&cfg.Timeout, "timeout", 0, "maximum duration of a sync, 0 for no limit")
+	rootCmd.PersistentFlags().DurationVar(&cfg.GoogleTimeout, "google-timeout", config.DefaultAPITimeout, "maximum duration of a single Google API call, of each attempt when retried")
+	rootCmd.PersistentFlags().IntVar(&cfg.GoogleRetries, "google-retries", config.DefaultGoogleRetries, "retries of the Google API calls failing with a rate limit or a server error, with an exponential backoff honoring Retry-After, 0 disables them")
+	rootCmd.PersistentFlags().IntVar(&cfg.TargetRetries, "target-retries", config.DefaultTargetRetries, "retries of the Keycloak and managed AD API calls failing with a rate limit or a server error, with an exponential backoff honoring Retry-After, 0 disables them")
+	rootCmd.PersistentFlags().DurationVar(&cfg.AWSTimeout, "aws-timeout", config.DefaultAPITimeout, "maximum duration of a single AWS API call")
+	rootCmd.PersistentFlags().StringVar(&cfg.Proxy, "proxy", "", "http, https or socks5 proxy URL for all API calls, 'direct' to ignore HTTPS_PROXY")
+	rootCmd.PersistentFlags().StringVar(&cfg.GoogleProxy, "google-proxy", "", "proxy URL for Google API calls, overrides --proxy")
+	rootCmd.PersistentFlags().StringVar(&cfg.AWSProxy, "aws-proxy", "", "proxy URL for AWS API calls, overrides --proxy")
+	rootCmd.PersistentFlags().StringVar(&cfg.IdentityStoreEndpoint, "identity-store-endpoint", "", "endpoint URL of the Identity Store API, e.g. of a VPC interface endpoint")
+	rootCmd.PersistentFlags().StringVar(&cfg.SecretsManagerEndpoint, "secrets-manager-endpoint", "", "endpoint URL of the Secrets Manager API, e.g. of a VPC interface endpoint")
+	rootCmd.PersistentFlags().StringVar(&cfg.SSOAdminEndpoint, "sso-admin-endpoint", "", "endpoint URL of the SSO Admin API, e.g. of a VPC interface endpoint")
+}
+
+// addSyncFlags adds the flags of the sync, shared by the bare command and
+// the sync, plan, audit and validate commands
+func addSyncFlags(flags *pflag.FlagSet, cfg *config.Config) {
+	flags.BoolVar(&cfg.SkipDeletedUsers, "skip-deleted-users", false, "do not fetch the deleted Google Workspace users, which is slow for large tenants, only suspended users are then deleted in AWS")
+	flags.BoolVar(&cfg.DeleteAbsentUsers, "delete-absent-users", false, "delete the AWS users with a Google external id which are not among the Google users matching --user-match, in addition to the deleted and suspended users")
+	flags.BoolVar(&cfg.Audit, "audit", false, "count the changes between Google and AWS without applying them, hooks and notifications are skipped")
+	flags.StringSliceVar(&cfg.FreezeWindows, "freeze-windows", []string{}, "recurring change freezes deferring the deletions, each a cron expression of the start and a duration, e.g. '0 0 25 3,6,9,12 * 168h'")
+	flags.StringVar(&cfg.FreezeCalendar, "freeze-calendar", "", "URL or path of an iCalendar whose events are change freezes deferring the deletions")
+	flags.StringVar(&cfg.PauseFlag, "pause-flag", "", "SSM parameter (ssm:/ssosync/pause) or DynamoDB item (dynamodb://table/key) which, when set, makes the runs exit without syncing")
+	flags.StringVar(&cfg.Lock, "lock", "", "DynamoDB item (dynamodb://table/key) locking the runs, a run exits without syncing while another run holds it")
+	flags.StringVar(&cfg.Inventory, "inventory", "", "DynamoDB mirror (dynamodb://table/prefix) of the Identity Store users and groups, read instead of listing the Identity Store")
+	flags.DurationVar(&cfg.InventoryRefresh, "inventory-refresh", config.DefaultInventoryRefresh, "interval of the full listings of the Identity Store reconciling --inventory, e.g. with the changes made outside of ssosync")
+	flags.DurationVar(&cfg.LockTTL, "lock-ttl", config.DefaultLockTTL, "expiry of the lock of a crashed run, the lock of a running run is renewed")
+	flags.BoolVar(&cfg.DeferDeletions, "defer-deletions", false, "apply the creations right away but defer the deletions and member removals to a later run still finding them, recorded in --state")
+	flags.DurationVar(&cfg.Heartbeat, "heartbeat", config.DefaultHeartbeat, "interval at which the phase, progress and estimated time left of a run are logged, 0 disables it")
+	flags.StringVar(&cfg.Shard, "shard", "", "slice i/n of the directory synced by the run, e.g. 1/4, the users and groups are assigned to the n shards by the hash of their email")
+	flags.DurationVar(&cfg.MaxRuntime, "max-runtime", 0, "duration after which a sync starts no further change, completes the changes in flight and records the first change not started in --state, 0 for no limit")
+	flags.DurationVar(&cfg.DeletionDelay, "deletion-delay", 0, "minimum delay of the deletions deferred by --defer-deletions, applied by the next run when 0")
+	flags.IntVar(&cfg.RemovalChunkSize, "removal-chunk-size", config.DefaultRemovalChunkSize, "number of users deleted between two progress lines of the removal")
+	flags.IntVar(&cfg.RemovalRetries, "removal-retries", config.DefaultRemovalRetries, "retries of a user deletion failing with a transient error, e.g. throttling, with an exponential backoff, 0 disables them")
+	flags.BoolVar(&cfg.RetryFailed, "retry-failed", config.DefaultRetryFailed, "retry the creations, updates and deletions which failed with a transient error, e.g. throttling, once at the end of the run")
+	flags.DurationVar(&cfg.RetryDelay, "retry-delay", config.DefaultRetryDelay, "delay before retrying the operations which failed with --retry-failed")
+	flags.BoolVar(&cfg.Verify, "verify", false, "re-read AWS at the end of the run and report the applied changes it does not reflect, e.g. missing members, as errors")
+	flags.BoolVar(&cfg.RequireApproval, "require-approval", false, "defer the deletions and member removals until approved with 'ssosync approve', recorded in --state")
+	flags.Float64Var(&cfg.AnomalyFactor, "anomaly-factor", 0, "hold back runs planning more changes than this factor times the average of the previous runs in --state, disabled when 0")
+	flags.IntVar(&cfg.AnomalyMinChanges, "anomaly-min-changes", config.DefaultAnomalyMinChanges, "number of changes which are applied regardless of --anomaly-factor")
+	flags.BoolVar(&cfg.Force, "force", false, "apply the changes of a run held back by --anomaly-factor")
+	flags.IntVar(&cfg.DriftThreshold, "drift-threshold", 0, "number of changes found by --audit above which the drift is published to --drift-topic")
+	flags.StringVar(&cfg.DriftTopic, "drift-topic", "", "ARN of the SNS topic the drift found by --audit is published to")
+	flags.StringVar(&cfg.DriftMetricNamespace, "drift-metric-namespace", "", "CloudWatch namespace the Drift metric of --audit runs is published to, e.g. for an alarm")
+	flags.StringVar(&cfg.EMFNamespace, "emf-namespace", "", "CloudWatch namespace of the metrics of the run summary, logged in the Embedded Metric Format, e.g. for a dashboard")
+	flags.BoolVar(&cfg.SkipPreflight, "skip-preflight", false, "skip checking that the identity store exists and is accessible before syncing")
+	flags.StringVar(&cfg.HookCommand, "hook-command", "", "shell command run before and after every change with the event as JSON on stdin, failing before a change skips it")
+	flags.StringVar(&cfg.EventStream, "event-stream", "", "EventBridge bus (eventbridge://bus) or Kinesis stream (kinesis://stream) every change applied is published to")
+	flags.StringVar(&cfg.HookWebhook, "hook-webhook", "", "URL every change is posted to as JSON before and after, a non-2xx response before a change skips it")
+	flags.StringVar(&cfg.HookPlugin, "hook-plugin", "", "path of a Go plugin exporting a Hook variable implementing ssosync.Hook")
+	flags.DurationVar(&cfg.HookTimeout, "hook-timeout", config.DefaultHookTimeout, "maximum duration of a hook command or webhook call")
+	flags.StringVar(&cfg.SlackWebhook, "slack-webhook", "", "Slack incoming webhook URL the summary of each run is posted to, prefer SSOSYNC_SLACK_WEBHOOK_FILE or --slack-webhook-secret")
+	flags.StringVar(&cfg.SlackWebhookSecret, "slack-webhook-secret", "", "name or ARN of the Secrets Manager secret holding the Slack incoming webhook URL")
+	flags.StringVar(&cfg.TeamsWebhook, "teams-webhook", "", "Microsoft Teams webhook URL the summary of each run is posted to as adaptive card")
+	flags.StringVar(&cfg.NotifyWebhook, "notify-webhook", "", "URL the summary of each run is posted to as JSON")
+	flags.StringVar(&cfg.NotifyWebhookTemplate, "notify-webhook-template", "", "Go template of the --notify-webhook body, rendered with the run report, e.g. '{\"text\":{{json .String}}}'")
+	flags.StringVar(&cfg.EmailFrom, "email-from", "", "SES verified sender address of the run summary emails")
+	flags.StringSliceVar(&cfg.EmailTo, "email-to", []string{}, "addresses the summary of each run is emailed to with Amazon SES, e.g. a distribution list")
+	flags.StringVar(&cfg.Evidence, "evidence", "", "s3://bucket/prefix an evidence record of each user deleted is written to, e.g. for offboarding audits")
+	flags.IntVar(&cfg.EvidenceRetentionDays, "evidence-retention-days", 0, "days the evidence records are locked with S3 Object Lock, not locked when 0")
+	flags.StringVar(&cfg.EvidenceLockMode, "evidence-lock-mode", config.DefaultEvidenceLockMode, "S3 Object Lock mode of the evidence records (GOVERNANCE|COMPLIANCE)")
+	flags.StringVar(&cfg.WelcomeEmailFrom, "welcome-email-from", "", "SES verified sender address the welcome message is emailed from to each user created")
+	flags.StringVar(&cfg.WelcomeSubject, "welcome-subject", config.DefaultWelcomeSubject, "subject of the welcome message")
+	flags.StringVar(&cfg.WelcomeTemplate, "welcome-template", "", "path of the Go template of the welcome message, rendered with .UserName, .Email and .PortalURL")
+	flags.StringVar(&cfg.WelcomePortalURL, "welcome-portal-url", "", "AWS access portal URL of the welcome message, e.g. https://d-1234567890.awsapps.com/start")
+	flags.StringVar(&cfg.WelcomeQueue, "welcome-queue", "", "URL of an SQS queue the welcome message of each user created is sent to as JSON")
+	flags.StringVar(&cfg.WelcomeWebhook, "welcome-webhook", "", "URL the welcome message of each user created is posted to as JSON")
+	flags.StringVar(&cfg.NotifyOn, "notify-on", config.DefaultNotifyOn, "runs which are notified (always|changes|errors), changes also notifies failed runs")
+	flags.StringVar(&cfg.State, "state", "", "file or s3://bucket/key the state between runs is saved to, e.g. the consecutive failures, kept in memory when not set")
+	flags.StringVar(&cfg.PagerDutyRoutingKey, "pagerduty-routing-key", "", "integration key of a PagerDuty Events API v2 integration alerted after --alert-after failed runs")
+	flags.StringVar(&cfg.OpsgenieAPIKey, "opsgenie-api-key", "", "key of an Opsgenie API integration alerted after --alert-after failed runs")
+	flags.IntVar(&cfg.AlertAfter, "alert-after", config.DefaultAlertAfter, "number of consecutive failed runs triggering an alert, resolved by the next successful run")
+	flags.BoolVar(&cfg.Daemon, "daemon", false, "keep running and sync every --interval, serving health endpoints")
+	flags.DurationVar(&cfg.Interval, "interval", config.DefaultInterval, "time between two syncs in daemon mode")
+	flags.BoolVar(&cfg.MemberCache, "member-cache", true, "keep the members of the Google groups between the syncs in daemon mode, refetching them only when the etag of the group changed")
+	flags.BoolVar(&cfg.Pprof, "pprof", false, "serve the pprof endpoints below /debug/pprof/ on --health-addr in daemon mode")
+	flags.StringVar(&cfg.HeapProfileDir, "heap-profile-dir", os.TempDir(), "directory a heap profile is written to on SIGUSR1 in daemon mode")
+	flags.StringVar(&cfg.HealthAddr, "health-addr", config.DefaultHealthAddr, "listen address of the /healthz and /readyz endpoints in daemon mode")
+}
+
+// addGoogleFlags adds the flags selecting and mapping the Google users
+// and groups, shared by the commands reading from Google
+func addGoogleFlags(flags *pflag.FlagSet, cfg *config.Config) {
+	flags.StringVarP(&cfg.GoogleCredentials, "google-credentials", "c", config.DefaultGoogleCredentials, "path to Google Workspace credentials file")
+	flags.StringVarP(&cfg.GoogleAdmin, "google-admin", "u", "", "Google Workspace admin user email")
+	flags.StringVar(&cfg.GoogleCredentialsEncryption, "google-credentials-encryption", "", "encryption of the Google Workspace credentials file (kms|age), decrypted at startup")
+	flags.StringVar(&cfg.AgeIdentity, "age-identity", "", "path to the age identity file decrypting the Google Workspace credentials")
+	flags.StringSliceVar(&cfg.GoogleScopes, "google-scopes", []string{}, "OAuth scopes requested for the Google service account, full URLs or short names like admin.directory.user.readonly, defaults to the read-only directory scopes")
+	flags.StringSliceVar(&cfg.IgnoreUsers, "ignore-users", []string{}, "ignores these Google Workspace users")
+	flags.StringSliceVar(&cfg.IgnoreGroups, "ignore-groups", []string{}, "ignores these Google Workspace groups")
+	flags.StringVar(&cfg.IgnoreUsersGroup, "ignore-users-group", "", "ignores the user members of this Google Workspace group, in addition to --ignore-users")
+	flags.StringVar(&cfg.IgnoreGroupsGroup, "ignore-groups-group", "", "ignores the member groups of this Google Workspace group, and the group itself, in addition to --ignore-groups")
+	flags.StringVar(&cfg.GroupDescription, "group-description", ssosync.DefaultGroupDescription, "Go template of the descriptions of the AWS groups without a Google description, with .Name, .Email and .Id, empty for none")
+	flags.BoolVar(&cfg.Provenance, "provenance", config.DefaultProvenance, "records the ssosync version, the Google id and the sync time in the user type of the created AWS users and at the end of the AWS group descriptions")
+	flags.BoolVar(&cfg.ManagedOnly, "managed-only", config.DefaultManagedOnly, "deletes, updates and removes members only from the AWS users and groups created by ssosync, i.e. with its provenance")
+	flags.BoolVar(&cfg.ExcludeSystemGroups, "exclude-system-groups", config.DefaultExcludeSystemGroups, "excludes the Google Workspace groups which look managed for the whole organization, e.g. all-staff target audiences")
+	flags.StringSliceVar(&cfg.SystemGroups, "system-groups", []string{}, "syncs these Google Workspace groups although they look system managed")
+	flags.StringArrayVarP(&cfg.UserMatch, "user-match", "m", []string{}, "Google Workspace Users filter query parameter, example: 'name:John* email:admin*', see: https://developers.google.com/admin-sdk/directory/v1/guides/search-users, repeat to sync the users matching any of the queries")
+	flags.StringArrayVarP(&cfg.GroupMatch, "group-match", "g", []string{}, "Google Workspace Groups filter query parameter, example: 'name:Admin* email:aws-*', see: https://developers.google.com/admin-sdk/directory/v1/guides/search-groups, repeat to sync the groups matching any of the queries")
+	flags.BoolVar(&cfg.DerivedMembership, "derived-membership", false, "sync the members of the nested Google groups, and of dynamic groups, as members of the group, listed with includeDerivedMembership in a single call per group")
+	flags.StringSliceVar(&cfg.Licenses, "licenses", []string{}, "sync only the users holding one of these Google licenses, as productId/skuId, e.g. Google-Apps/1010020020, requires the apps.licensing scope")
+	flags.StringVar(&cfg.LicenseCustomer, "license-customer", "", "primary domain or customer id the --licenses are listed for, defaults to the domain of --google-admin")
+	flags.StringSliceVar(&cfg.GroupLabels, "group-labels", []string{}, "sync only the Google groups with one of these Cloud Identity labels (security|dynamic|discussion), e.g. security to leave out the mailing lists, requires the cloud-identity.groups.readonly scope")
+	flags.StringVar(&cfg.GoogleCustomerId, "google-customer-id", "", "Google Workspace customer id of the --group-labels search, e.g. C01234567, looked up with the admin.directory.customer.readonly scope when not set")
+	flags.BoolVar(&cfg.GroupSettings, "group-settings", false, "read the Google Groups settings (allowExternalMembers, whoCanJoin) of the synced groups into the report, e.g. of an audit, requires the apps.groups.settings scope")
+	flags.StringSliceVar(&cfg.PrivilegedGroups, "privileged-groups", []string{}, "AWS groups (names or patterns, e.g. 'aws-admin*') flagged by --group-settings when their Google settings let anyone, the whole domain or external users join")
+	flags.StringArrayVar(&cfg.UserExcludeMatch, "user-exclude-match", []string{}, "Google Workspace Users filter query parameter, users matching it are not synced, can be repeated")
+	flags.StringArrayVar(&cfg.GroupExcludeMatch, "group-exclude-match", []string{}, "Google Workspace Groups filter query parameter, groups matching it are not synced, can be repeated")
+	flags.StringSliceVar(&cfg.UnmanagedMembershipGroups, "unmanaged-membership-groups", []string{}, "AWS groups (names or patterns, e.g. 'breakglass-*') whose members added in AWS are never removed")
+	flags.StringSliceVar(&cfg.ProtectedUsers, "protected-users", []string{}, "AWS users (names or patterns) never deleted nor removed from groups, e.g. break-glass admins, overriding everything else")
+	flags.StringSliceVar(&cfg.ProtectedGroups, "protected-groups", []string{}, "AWS groups (names or patterns) never deleted nor having members removed, overriding everything else")
+	flags.BoolVar(&cfg.GroupDescriptionTags, "group-description-tags", false, "honor the [ssosync:skip], [ssosync:membership-only] and [ssosync:name=Name] tags of the Google group descriptions")
+	flags.StringVar(&cfg.UserNameTemplate, "user-name-template", username.DefaultTemplate, "Go template of the AWS user names, with .Email, .LocalPart, .Domain, .GivenName, .FamilyName and the lower, upper and replace functions")
+	flags.StringVar(&cfg.UserNameCollision, "user-name-collision", username.CollisionFail, "policy when the user name template maps several users to one name (fail|skip|suffix), the oldest Google account always keeps the name")
+	flags.StringVar(&cfg.GroupKey, "group-key", config.DefaultGroupKey, "key matching the AWS groups to the Google groups (name|email), by email the Google email is recorded in the group descriptions and a group renamed in Google is renamed in AWS rather than recreated")
+	flags.StringVar(&cfg.GroupNamePolicy, "group-name-policy", config.DefaultGroupNamePolicy, "policy for the Google groups whose name is not a valid AWS group name (transform|skip), synced with the emojis and the characters not allowed stripped and too long names truncated with a hash suffix, or skipped and reported")
+	flags.IntVar(&cfg.GroupNameMaxLength, "group-name-max-length", 0, "maximum length of the AWS group names, longer names are handled by --group-name-policy, the Identity Store limit of 1024 when 0")
+	flags.StringVar(&cfg.EmailPolicy, "email-policy", config.DefaultEmailPolicy, "policy for the Google users whose primary email is too long or not ASCII (skip|truncate|alias), skipped and reported, also truncating too long names, or synced with their first valid alias")
+	flags.IntVar(&cfg.GroupMemberLimit, "group-member-limit", 0, "membership count limit of the AWS groups, warning from 90% of it and adding only the first members by user name above it, 0 for none")
+	flags.BoolVar(&cfg.OverflowGroups, "overflow-groups", false, "add the members above the group member limit to the numbered overflow groups of the group, e.g. group-2, instead of dropping them")
 }
 
 func logConfig(cfg *config.Config) {
@@ -176,13 +627,32 @@ func logConfig(cfg *config.Config) {
 	if cfg.LogFormat == "json" {
 		log.SetFormatter(&log.JSONFormatter{})
 	}
+	if cfg.AuditFormat != "" {
+		log.SetFormatter(&logging.AuditFormatter{Formatter: log.StandardLogger().Formatter, Audit: cfg.AuditFormat, Version: cfg.BuildVersion})
+	}
 
 	if cfg.Debug {
 		cfg.LogLevel = "debug"
+	} else if cfg.Quiet {
+		cfg.LogLevel = logging.LevelChange
+	}
+
+	// scrub credentials from all log output, the hook replaces the one of
+	// the previous invocation of a warm Lambda function
+	hook, err := logging.NewRedactHook(cfg.LogRedact)
+	if err != nil {
+		log.Fatalf(errors.Wrap(err, "cannot configure logging").Error())
 	}
+	hooks := make(log.LevelHooks)
+	hooks.Add(hook)
+	log.StandardLogger().ReplaceHooks(hooks)
 
-	// set the configured log level
-	if level, err := log.ParseLevel(cfg.LogLevel); err == nil {
+	// set the configured log level, the change level filters the info
+	// entries in the formatter
+	if cfg.LogLevel == logging.LevelChange {
+		log.SetLevel(log.InfoLevel)
+		log.SetFormatter(&logging.ChangesFormatter{Formatter: log.StandardLogger().Formatter})
+	} else if level, err := log.ParseLevel(cfg.LogLevel); err == nil {
 		log.SetLevel(level)
 	}
 }
@@ -0,0 +1,88 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/spf13/cobra"
+)
+
+// buildInfo is the build metadata printed by the version command
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	BuiltBy   string `json:"builtBy"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+	// Providers are the supported integrations, by kind
+	Providers map[string][]string `json:"providers"`
+}
+
+// currentBuild returns the metadata of the running build
+func currentBuild() buildInfo {
+	return buildInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		BuiltBy:   builtBy,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Providers: map[string][]string{
+			"source":   {"google"},
+			"target":   {"aws-identity-store"},
+			"secrets":  {"secretsmanager", "vault"},
+			"notify":   {"slack", "teams", "webhook", "email"},
+			"alert":    {"pagerduty", "opsgenie"},
+			"state":    {"file", "s3"},
+			"hooks":    {"command", "webhook", "plugin", "welcome", "evidence"},
+			"schedule": {"cron", "ical"},
+		},
+	}
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Prints the version and build metadata",
+	Long: `Prints the version, git commit, build date, Go version and supported
+providers of the build, as JSON by default, e.g. for fleet management tools.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+
+		info := currentBuild()
+		switch format {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(info)
+		case "text":
+			fmt.Printf("%s, commit %s, built at %s by %s with %s for %s\n", info.Version, info.Commit, info.Date, info.BuiltBy, info.GoVersion, info.Platform)
+			return nil
+		default:
+			return fmt.Errorf("version format %q is not one of json, text", format)
+		}
+	},
+}
+
+// addVersionCommand adds the version subcommand to cmd
+func addVersionCommand(cmd *cobra.Command) {
+	versionCmd.Flags().String("format", "json", "output format (json|text)")
+	cmd.AddCommand(versionCmd)
+}
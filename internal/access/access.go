@@ -0,0 +1,152 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package access builds the access review report, listing the groups
+// and permission sets of every user of the identity store
+package access
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/internal/aws"
+)
+
+// Entry is the access of a user
+type Entry struct {
+	UserName    string
+	DisplayName string
+	Email       string
+	Groups      []string
+	// PermissionSets are the account/permission set assignments of the
+	// user, the group granting them in brackets when not direct
+	PermissionSets []string
+}
+
+// Build returns the access of all users of the identity store sorted by
+// user name, the assignments by principal id may be nil
+func Build(client aws.Client, assignments map[string][]aws.Assignment) ([]Entry, error) {
+	users, err := client.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := client.GetGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	// the groups of each user id
+	memberOf := make(map[string][]types.Group)
+	for _, g := range groups {
+		grp := g
+		members, err := client.GetGroupMembers(&grp)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			if id, ok := m.MemberId.(*types.MemberIdMemberUserId); ok {
+				memberOf[id.Value] = append(memberOf[id.Value], grp)
+			}
+		}
+	}
+
+	entries := make([]Entry, 0, len(users))
+	for _, u := range users {
+		e := Entry{
+			UserName:    awsutils.ToString(u.UserName),
+			DisplayName: awsutils.ToString(u.DisplayName),
+		}
+		for _, m := range u.Emails {
+			if m.Primary || e.Email == "" {
+				e.Email = awsutils.ToString(m.Value)
+			}
+		}
+
+		userId := awsutils.ToString(u.UserId)
+		for _, a := range assignments[userId] {
+			e.PermissionSets = append(e.PermissionSets, a.String())
+		}
+		for _, g := range memberOf[userId] {
+			name := awsutils.ToString(g.DisplayName)
+			e.Groups = append(e.Groups, name)
+			for _, a := range assignments[awsutils.ToString(g.GroupId)] {
+				e.PermissionSets = append(e.PermissionSets, fmt.Sprintf("%s (%s)", a, name))
+			}
+		}
+		sort.Strings(e.Groups)
+		sort.Strings(e.PermissionSets)
+
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].UserName < entries[j].UserName })
+
+	return entries, nil
+}
+
+// WriteCSV writes the entries as CSV, the groups and permission sets
+// separated by semicolons
+func WriteCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"user_name", "display_name", "email", "groups", "permission_sets"}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		err := cw.Write([]string{e.UserName, e.DisplayName, e.Email, strings.Join(e.Groups, ";"), strings.Join(e.PermissionSets, ";")})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var page = template.Must(template.New("access").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Access review {{.Date}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>Access review {{.Date}}</h1>
+<p>{{len .Entries}} users</p>
+<table>
+<tr><th>User name</th><th>Display name</th><th>Email</th><th>Groups</th><th>Permission sets</th></tr>
+{{range .Entries}}<tr><td>{{.UserName}}</td><td>{{.DisplayName}}</td><td>{{.Email}}</td><td>{{range .Groups}}{{.}}<br>{{end}}</td><td>{{range .PermissionSets}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// WriteHTML writes the entries as HTML page
+func WriteHTML(w io.Writer, entries []Entry) error {
+	return page.Execute(w, struct {
+		Date    string
+		Entries []Entry
+	}{
+		Date:    time.Now().UTC().Format("2006-01-02"),
+		Entries: entries,
+	})
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package access_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/awslabs/ssosync/internal/access"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteCSV(t *testing.T) {
+	assert := assert.New(t)
+
+	var b bytes.Buffer
+	assert.NoError(WriteCSV(&b, []Entry{{
+		UserName:       "jane@example.com",
+		DisplayName:    "Jane Doe",
+		Email:          "jane@example.com",
+		Groups:         []string{"admins", "developers"},
+		PermissionSets: []string{"123456789012/AdministratorAccess (admins)"},
+	}}))
+
+	assert.Equal("user_name,display_name,email,groups,permission_sets\n"+
+		"jane@example.com,Jane Doe,jane@example.com,admins;developers,123456789012/AdministratorAccess (admins)\n", b.String())
+}
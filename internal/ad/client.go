@@ -0,0 +1,515 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ad is the experimental sync target of an AWS Managed Microsoft
+// AD directory, through the Directory Service Data API
+package ad
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/internal/paging"
+)
+
+const (
+	// ServiceID is the service id of the Directory Service Data API in
+	// the endpoint resolver of the AWS configuration
+	ServiceID = "Directory Service Data"
+	// signingName is the SigV4 signing name of the API
+	signingName = "ds-data"
+
+	// maxUserName is the length limit of the SAM account name of a user
+	maxUserName = 20
+
+	// attributeUserPrincipalName holds the user name of the synced user,
+	// attributeDisplayName the display name and attributeDescription the
+	// user type of the users and the description of the groups
+	attributeUserPrincipalName = "userPrincipalName"
+	attributeDisplayName       = "displayName"
+	attributeDescription       = "description"
+)
+
+var (
+	// ErrUserNotFound is returned by FindUserByUserName for a missing user
+	ErrUserNotFound = errors.New("user not found")
+	// ErrGroupNotFound is returned by FindGroupByDisplayName for a missing
+	// group
+	ErrGroupNotFound = errors.New("group not found")
+
+	// errNotFound and errConflict are the errors of the
+	// ResourceNotFoundException and ConflictException responses
+	errNotFound = errors.New("not found")
+	errConflict = errors.New("conflict")
+
+	// invalidName matches the characters not allowed in a SAM account name
+	invalidName = regexp.MustCompile(`["/\\\[\]:;|=,+*?<>@\s]+`)
+
+	// attributes are the attributes described with the users and groups
+	attributes = []string{attributeDisplayName, attributeDescription}
+)
+
+// Client is a Target writing the users, groups and memberships of an AWS
+// Managed Microsoft AD directory. The users and groups are keyed by SAM
+// account name, derived from the user name and the group display name;
+// the user name is the user principal name of the AD user.
+type Client struct {
+	ctx         context.Context
+	config      aws.Config
+	http        *http.Client
+	endpoint    string
+	directoryId string
+	signer      *v4.Signer
+}
+
+// New returns the Client of the directory, hc is the transport
+func New(ctx context.Context, config aws.Config, hc *http.Client, directoryId string) (*Client, error) {
+	if directoryId == "" {
+		return nil, errors.New("directory id is required")
+	}
+	endpoint := "https://ds-data." + config.Region + ".amazonaws.com"
+	if config.EndpointResolverWithOptions != nil {
+		e, err := config.EndpointResolverWithOptions.ResolveEndpoint(ServiceID, config.Region)
+		var notFound *aws.EndpointNotFoundError
+		switch {
+		case errors.As(err, &notFound):
+		case err != nil:
+			return nil, err
+		default:
+			endpoint = e.URL
+		}
+	}
+	return &Client{
+		ctx:         ctx,
+		config:      config,
+		http:        hc,
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		directoryId: directoryId,
+		signer:      v4.NewSigner(),
+	}, nil
+}
+
+// attributeValue is the value of an LDAP attribute
+type attributeValue struct {
+	S *string `json:"S,omitempty"`
+}
+
+// user is the user of the API
+type user struct {
+	SAMAccountName    string                    `json:"SAMAccountName"`
+	UserPrincipalName string                    `json:"UserPrincipalName,omitempty"`
+	EmailAddress      string                    `json:"EmailAddress,omitempty"`
+	GivenName         string                    `json:"GivenName,omitempty"`
+	Surname           string                    `json:"Surname,omitempty"`
+	OtherAttributes   map[string]attributeValue `json:"OtherAttributes,omitempty"`
+}
+
+// group is the group of the API
+type group struct {
+	SAMAccountName  string                    `json:"SAMAccountName"`
+	GroupScope      string                    `json:"GroupScope,omitempty"`
+	GroupType       string                    `json:"GroupType,omitempty"`
+	OtherAttributes map[string]attributeValue `json:"OtherAttributes,omitempty"`
+}
+
+// member is the group member of the API
+type member struct {
+	SAMAccountName string `json:"SAMAccountName"`
+	MemberType     string `json:"MemberType"`
+}
+
+// CreateUser creates the enabled user, adopting an existing user with
+// the same user principal name on conflict
+func (c *Client) CreateUser(u *types.User) (*types.User, error) {
+	in := user{
+		SAMAccountName:  userAccountName(aws.ToString(u.UserName)),
+		OtherAttributes: map[string]attributeValue{attributeUserPrincipalName: {S: u.UserName}},
+	}
+	if u.Name != nil {
+		in.GivenName = aws.ToString(u.Name.GivenName)
+		in.Surname = aws.ToString(u.Name.FamilyName)
+	}
+	for _, e := range u.Emails {
+		if e.Primary || in.EmailAddress == "" {
+			in.EmailAddress = aws.ToString(e.Value)
+		}
+	}
+	if u.DisplayName != nil {
+		in.OtherAttributes[attributeDisplayName] = attributeValue{S: u.DisplayName}
+	}
+	if u.UserType != nil {
+		in.OtherAttributes[attributeDescription] = attributeValue{S: u.UserType}
+	}
+
+	err := c.call("/Users/CreateUser", in, nil)
+	if errors.Is(err, errConflict) {
+		existing, findErr := c.FindUserByUserName(aws.ToString(u.UserName))
+		if findErr != nil {
+			return nil, fmt.Errorf("%w, SAM account name %s is used by another user", err, in.SAMAccountName)
+		}
+		u.UserId = existing.UserId
+		return u, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.UserId = aws.String(in.SAMAccountName)
+	return u, nil
+}
+
+// DeleteUser deletes the user
+func (c *Client) DeleteUser(u *types.User) error {
+	return c.call("/Users/DeleteUser", map[string]string{"SAMAccountName": aws.ToString(u.UserId)}, nil)
+}
+
+// UpdateUserType replaces the description of the user, which holds the
+// user type
+func (c *Client) UpdateUserType(u *types.User, userType *string) error {
+	return c.call("/Users/UpdateUser", map[string]interface{}{
+		"SAMAccountName":  aws.ToString(u.UserId),
+		"OtherAttributes": map[string]attributeValue{attributeDescription: {S: userType}},
+		"UpdateType":      "REPLACE",
+	}, nil)
+}
+
+// CreateGroup creates the global security group, adopting an existing
+// group with the same SAM account name on conflict
+func (c *Client) CreateGroup(name *string, description *string) (*types.Group, error) {
+	in := group{
+		SAMAccountName:  groupAccountName(aws.ToString(name)),
+		GroupScope:      "Global",
+		GroupType:       "Security",
+		OtherAttributes: map[string]attributeValue{attributeDisplayName: {S: name}},
+	}
+	if description != nil {
+		in.OtherAttributes[attributeDescription] = attributeValue{S: description}
+	}
+	err := c.call("/Groups/CreateGroup", in, nil)
+	if errors.Is(err, errConflict) {
+		existing, findErr := c.FindGroupByDisplayName(aws.ToString(name))
+		if findErr != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &types.Group{GroupId: aws.String(in.SAMAccountName), DisplayName: name, Description: description}, nil
+}
+
+// UpdateGroup replaces the description of the group
+func (c *Client) UpdateGroup(g *types.Group, description *string) error {
+	return c.call("/Groups/UpdateGroup", map[string]interface{}{
+		"SAMAccountName":  aws.ToString(g.GroupId),
+		"OtherAttributes": map[string]attributeValue{attributeDescription: {S: description}},
+		"UpdateType":      "REPLACE",
+	}, nil)
+}
+
+// DeleteGroup deletes the group
+func (c *Client) DeleteGroup(g *types.Group) error {
+	return c.call("/Groups/DeleteGroup", map[string]string{"SAMAccountName": aws.ToString(g.GroupId)}, nil)
+}
+
+// AddUserToGroup adds the user to the group
+func (c *Client) AddUserToGroup(u *types.User, g *types.Group) (*types.GroupMembership, error) {
+	groupId, userId := aws.ToString(g.GroupId), aws.ToString(u.UserId)
+	err := c.call("/GroupMemberships/AddGroupMember", map[string]string{"GroupName": groupId, "MemberName": userId}, nil)
+	if err != nil && !errors.Is(err, errConflict) {
+		return nil, err
+	}
+	return membership(groupId, userId), nil
+}
+
+// RemoveGroupMembership removes the user from the group
+func (c *Client) RemoveGroupMembership(m *types.GroupMembership) error {
+	groupId, userId, ok := strings.Cut(aws.ToString(m.MembershipId), "/")
+	if !ok {
+		return fmt.Errorf("invalid directory membership id %q", aws.ToString(m.MembershipId))
+	}
+	return c.call("/GroupMemberships/RemoveGroupMember", map[string]string{"GroupName": groupId, "MemberName": userId}, nil)
+}
+
+// GetGroupMembers returns the user memberships of the group
+func (c *Client) GetGroupMembers(g *types.Group) ([]types.GroupMembership, error) {
+	groupId := aws.ToString(g.GroupId)
+	var res []types.GroupMembership
+	err := c.list("/GroupMemberships/ListGroupMembers", map[string]interface{}{"SAMAccountName": groupId}, "ListGroupMembers", func(b []byte) (int, error) {
+		var page struct{ Members []member }
+		if err := json.Unmarshal(b, &page); err != nil {
+			return 0, err
+		}
+		for _, m := range page.Members {
+			if m.MemberType == "USER" {
+				res = append(res, *membership(groupId, m.SAMAccountName))
+			}
+		}
+		return len(page.Members), nil
+	})
+	return res, err
+}
+
+// GetGroups returns the groups of the directory, as ListGroups has no
+// attributes, each group is described
+func (c *Client) GetGroups() ([]types.Group, error) {
+	var names []string
+	err := c.list("/Groups/ListGroups", map[string]interface{}{}, "ListGroups", func(b []byte) (int, error) {
+		var page struct{ Groups []group }
+		if err := json.Unmarshal(b, &page); err != nil {
+			return 0, err
+		}
+		for _, g := range page.Groups {
+			names = append(names, g.SAMAccountName)
+		}
+		return len(page.Groups), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]types.Group, 0, len(names))
+	for _, name := range names {
+		g, err := c.describeGroup(name)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, toGroup(g))
+	}
+	return res, nil
+}
+
+// GetUsers returns the users of the directory, as ListUsers has no user
+// principal name, each user is described
+func (c *Client) GetUsers() ([]types.User, error) {
+	var names []string
+	err := c.list("/Users/ListUsers", map[string]interface{}{}, "ListUsers", func(b []byte) (int, error) {
+		var page struct{ Users []user }
+		if err := json.Unmarshal(b, &page); err != nil {
+			return 0, err
+		}
+		for _, u := range page.Users {
+			names = append(names, u.SAMAccountName)
+		}
+		return len(page.Users), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]types.User, 0, len(names))
+	for _, name := range names {
+		u, err := c.describeUser(name)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, toUser(u))
+	}
+	return res, nil
+}
+
+// FindUserByUserName returns the user with the user principal name
+func (c *Client) FindUserByUserName(userName string) (*types.User, error) {
+	u, err := c.describeUser(userAccountName(userName))
+	if errors.Is(err, errNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(u.UserPrincipalName, userName) {
+		return nil, ErrUserNotFound
+	}
+	res := toUser(u)
+	return &res, nil
+}
+
+// FindGroupByDisplayName returns the group with the display name
+func (c *Client) FindGroupByDisplayName(displayName string) (*types.Group, error) {
+	g, err := c.describeGroup(groupAccountName(displayName))
+	if errors.Is(err, errNotFound) {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := toGroup(g)
+	if aws.ToString(res.DisplayName) != displayName {
+		return nil, ErrGroupNotFound
+	}
+	return &res, nil
+}
+
+// describeUser returns the user with its attributes
+func (c *Client) describeUser(name string) (user, error) {
+	var u user
+	err := c.call("/Users/DescribeUser", map[string]interface{}{"SAMAccountName": name, "OtherAttributes": attributes}, &u)
+	return u, err
+}
+
+// describeGroup returns the group with its attributes
+func (c *Client) describeGroup(name string) (group, error) {
+	var g group
+	err := c.call("/Groups/DescribeGroup", map[string]interface{}{"SAMAccountName": name, "OtherAttributes": attributes}, &g)
+	return g, err
+}
+
+// list calls the list operation for each page, decoded by add which
+// returns the number of items of the page
+func (c *Client) list(p string, in map[string]interface{}, operation string, add func([]byte) (int, error)) error {
+	pages := paging.Start("ds-data", operation)
+	defer pages.Done()
+	for {
+		var out json.RawMessage
+		if err := c.call(p, in, &out); err != nil {
+			return err
+		}
+		n, err := add(out)
+		if err != nil {
+			return fmt.Errorf("invalid directory response of %s: %w", p, err)
+		}
+		var next struct{ NextToken string }
+		if err := json.Unmarshal(out, &next); err != nil {
+			return fmt.Errorf("invalid directory response of %s: %w", p, err)
+		}
+		pages.Page(n, next.NextToken)
+		if next.NextToken == "" {
+			return nil
+		}
+		in["NextToken"] = next.NextToken
+	}
+}
+
+// call posts the signed request of the operation at p and decodes the
+// response into out, if not nil
+func (c *Client) call(p string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.endpoint+p+"?DirectoryId="+url.QueryEscape(c.directoryId), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	creds, err := c.config.Credentials.Retrieve(c.ctx)
+	if err != nil {
+		return fmt.Errorf("cannot retrieve the AWS credentials: %w", err)
+	}
+	hash := sha256.Sum256(body)
+	if err := c.signer.SignHTTP(c.ctx, creds, req, hex.EncodeToString(hash[:]), signingName, c.config.Region, time.Now()); err != nil {
+		return err
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		if out == nil || len(b) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(b, out); err != nil {
+			return fmt.Errorf("invalid directory response of %s: %w", p, err)
+		}
+		return nil
+	}
+
+	code, _, _ := strings.Cut(res.Header.Get("X-Amzn-Errortype"), ":")
+	var msg struct{ Message string }
+	json.Unmarshal(b, &msg)
+	switch code {
+	case "ResourceNotFoundException":
+		return fmt.Errorf("ds-data %s: %w: %s", p, errNotFound, msg.Message)
+	case "ConflictException":
+		return fmt.Errorf("ds-data %s: %w: %s", p, errConflict, msg.Message)
+	}
+	return fmt.Errorf("ds-data %s: %s %s: %s", p, res.Status, code, msg.Message)
+}
+
+// userAccountName returns the SAM account name of the user name, its
+// local part without the invalid characters cut to the limit
+func userAccountName(userName string) string {
+	name, _, _ := strings.Cut(userName, "@")
+	name = invalidName.ReplaceAllString(name, "-")
+	if len(name) > maxUserName {
+		name = name[:maxUserName]
+	}
+	return name
+}
+
+// groupAccountName returns the SAM account name of the group display
+// name, without the invalid characters
+func groupAccountName(displayName string) string {
+	return invalidName.ReplaceAllString(displayName, "-")
+}
+
+// membership is the membership of the user in the group, its id is the
+// group and the user SAM account names
+func membership(groupId, userId string) *types.GroupMembership {
+	return &types.GroupMembership{
+		MembershipId: aws.String(groupId + "/" + userId),
+		GroupId:      aws.String(groupId),
+		MemberId:     &types.MemberIdMemberUserId{Value: userId},
+	}
+}
+
+// toUser returns the user of the directory user
+func toUser(u user) types.User {
+	res := types.User{
+		UserId:      aws.String(u.SAMAccountName),
+		UserName:    aws.String(u.SAMAccountName),
+		Name:        &types.Name{GivenName: aws.String(u.GivenName), FamilyName: aws.String(u.Surname)},
+		DisplayName: u.OtherAttributes[attributeDisplayName].S,
+		UserType:    u.OtherAttributes[attributeDescription].S,
+	}
+	if u.UserPrincipalName != "" {
+		res.UserName = aws.String(u.UserPrincipalName)
+	}
+	if u.EmailAddress != "" {
+		res.Emails = []types.Email{{Primary: true, Type: aws.String("work"), Value: aws.String(u.EmailAddress)}}
+	}
+	return res
+}
+
+// toGroup returns the group of the directory group
+func toGroup(g group) types.Group {
+	res := types.Group{
+		GroupId:     aws.String(g.SAMAccountName),
+		DisplayName: aws.String(g.SAMAccountName),
+		Description: g.OtherAttributes[attributeDescription].S,
+	}
+	if name := g.OtherAttributes[attributeDisplayName].S; name != nil {
+		res.DisplayName = name
+	}
+	return res
+}
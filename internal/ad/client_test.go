@@ -0,0 +1,185 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ad_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	. "github.com/awslabs/ssosync/internal/ad"
+	awsclient "github.com/awslabs/ssosync/internal/aws"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// directory is a fake Directory Service Data API
+type directory struct {
+	mu      sync.Mutex
+	users   map[string]map[string]interface{}
+	groups  map[string]map[string]interface{}
+	members map[string]map[string]bool
+}
+
+func (d *directory) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !strings.Contains(req.Header.Get("Authorization"), "/ds-data/aws4_request") || req.URL.Query().Get("DirectoryId") != "d-1234567890" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	var in map[string]interface{}
+	json.NewDecoder(req.Body).Decode(&in)
+	name, _ := in["SAMAccountName"].(string)
+	fail := func(code string) {
+		w.Header().Set("X-Amzn-Errortype", code)
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	store := d.users
+	if strings.HasPrefix(req.URL.Path, "/Groups/") {
+		store = d.groups
+	}
+	var out interface{}
+	switch req.URL.Path {
+	case "/Users/CreateUser", "/Groups/CreateGroup":
+		if store[name] != nil {
+			fail("ConflictException")
+			return
+		}
+		if attrs, ok := in["OtherAttributes"].(map[string]interface{}); ok {
+			if upn, ok := attrs["userPrincipalName"].(map[string]interface{}); ok {
+				in["UserPrincipalName"] = upn["S"]
+			}
+		}
+		store[name] = in
+	case "/Users/DescribeUser", "/Groups/DescribeGroup":
+		if store[name] == nil {
+			fail("ResourceNotFoundException")
+			return
+		}
+		out = store[name]
+	case "/Users/UpdateUser", "/Groups/UpdateGroup":
+		attrs := store[name]["OtherAttributes"].(map[string]interface{})
+		for k, v := range in["OtherAttributes"].(map[string]interface{}) {
+			attrs[k] = v
+		}
+	case "/Users/DeleteUser", "/Groups/DeleteGroup":
+		delete(store, name)
+	case "/Users/ListUsers":
+		var users []map[string]interface{}
+		for n := range d.users {
+			users = append(users, map[string]interface{}{"SAMAccountName": n})
+		}
+		out = map[string]interface{}{"Users": users}
+	case "/Groups/ListGroups":
+		var groups []map[string]interface{}
+		for n := range d.groups {
+			groups = append(groups, map[string]interface{}{"SAMAccountName": n})
+		}
+		out = map[string]interface{}{"Groups": groups}
+	case "/GroupMemberships/AddGroupMember":
+		g := in["GroupName"].(string)
+		if d.members[g] == nil {
+			d.members[g] = make(map[string]bool)
+		}
+		d.members[g][in["MemberName"].(string)] = true
+	case "/GroupMemberships/RemoveGroupMember":
+		delete(d.members[in["GroupName"].(string)], in["MemberName"].(string))
+	case "/GroupMemberships/ListGroupMembers":
+		var members []map[string]interface{}
+		for m := range d.members[name] {
+			members = append(members, map[string]interface{}{"SAMAccountName": m, "MemberType": "USER"})
+		}
+		out = map[string]interface{}{"Members": members}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+func TestClient(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := httptest.NewServer(&directory{
+		users:   make(map[string]map[string]interface{}),
+		groups:  make(map[string]map[string]interface{}),
+		members: make(map[string]map[string]bool),
+	})
+	defer srv.Close()
+
+	config := aws.Config{
+		Region:                      "us-east-1",
+		Credentials:                 credentials.NewStaticCredentialsProvider("id", "secret", ""),
+		EndpointResolverWithOptions: awsclient.EndpointResolver(map[string]string{ServiceID: srv.URL}),
+	}
+	c, err := New(context.Background(), config, srv.Client(), "d-1234567890")
+	assert.NoError(err)
+
+	u, err := c.CreateUser(&types.User{
+		UserName:    aws.String("jane.doe@example.com"),
+		DisplayName: aws.String("Jane Doe"),
+		Name:        &types.Name{GivenName: aws.String("Jane"), FamilyName: aws.String("Doe")},
+		Emails:      []types.Email{{Primary: true, Value: aws.String("jane.doe@example.com")}},
+	})
+	assert.NoError(err)
+	assert.Equal("jane.doe", aws.ToString(u.UserId))
+	assert.NoError(c.UpdateUserType(u, aws.String("[managed-by=ssosync]")))
+
+	found, err := c.FindUserByUserName("jane.doe@example.com")
+	assert.NoError(err)
+	assert.Equal("Jane Doe", aws.ToString(found.DisplayName))
+	assert.Equal("[managed-by=ssosync]", aws.ToString(found.UserType))
+
+	// the SAM account name of another user name is not the user
+	_, err = c.FindUserByUserName("jane.doe@example.org")
+	assert.ErrorIs(err, ErrUserNotFound)
+	_, err = c.CreateUser(&types.User{UserName: aws.String("jane.doe@example.org")})
+	assert.Error(err)
+
+	g, err := c.CreateGroup(aws.String("AWS Admins"), nil)
+	assert.NoError(err)
+	assert.Equal("AWS-Admins", aws.ToString(g.GroupId))
+	assert.NoError(c.UpdateGroup(g, aws.String("Admins")))
+
+	groups, err := c.GetGroups()
+	assert.NoError(err)
+	assert.Len(groups, 1)
+	assert.Equal("AWS Admins", aws.ToString(groups[0].DisplayName))
+	assert.Equal("Admins", aws.ToString(groups[0].Description))
+
+	m, err := c.AddUserToGroup(u, g)
+	assert.NoError(err)
+	members, err := c.GetGroupMembers(g)
+	assert.NoError(err)
+	assert.Equal([]types.GroupMembership{*m}, members)
+	assert.NoError(c.RemoveGroupMembership(m))
+
+	users, err := c.GetUsers()
+	assert.NoError(err)
+	assert.Len(users, 1)
+	assert.Equal("jane.doe@example.com", aws.ToString(users[0].UserName))
+	assert.NoError(c.DeleteUser(u))
+	_, err = c.FindUserByUserName("jane.doe@example.com")
+	assert.ErrorIs(err, ErrUserNotFound)
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package alert pages the on-call after consecutive failed runs, and
+// resolves the alert after the next successful run
+package alert
+
+import (
+	"context"
+	"time"
+
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/state"
+	"github.com/awslabs/ssosync/internal/transport"
+	"github.com/awslabs/ssosync/pkg/report"
+	log "github.com/sirupsen/logrus"
+)
+
+// timeout is the maximum duration of tracking a run, including the
+// state and the alerting API calls
+const timeout = 30 * time.Second
+
+// Alerter opens and resolves an alert in an incident management tool
+type Alerter interface {
+	Trigger(ctx context.Context, r *report.Report, failures int) error
+	Resolve(ctx context.Context) error
+}
+
+// New returns the alerters of the configuration
+func New(cfg *config.Config) ([]Alerter, error) {
+	var alerters []Alerter
+
+	hc, err := transport.NewClient("alert", nil, transport.Options{
+		Timeout: timeout,
+		Proxy:   cfg.ProxyFor(""),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the alerts of a directory are deduplicated by its identity store
+	key := "ssosync-" + cfg.IdentityStoreId
+
+	if cfg.PagerDutyRoutingKey != "" {
+		alerters = append(alerters, NewPagerDuty(cfg.PagerDutyRoutingKey, key, hc))
+	}
+	if cfg.OpsgenieAPIKey != "" {
+		alerters = append(alerters, NewOpsgenie(cfg.OpsgenieAPIKey, key, hc))
+	}
+
+	return alerters, nil
+}
+
+// Track records the result of the run in the state store, triggers the
+// alerts after cfg.AlertAfter consecutive failed runs and resolves them
+// after a successful run. A partial run is not a failure. Errors are
+// logged but never fail the run.
+func Track(cfg *config.Config, r *report.Report) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	store, err := state.Open(cfg.AWSConfig, cfg.State)
+	if err != nil {
+		log.WithError(err).Error("cannot open state")
+		return
+	}
+	s, err := store.Load(ctx)
+	if err != nil {
+		log.WithError(err).Error("cannot load state")
+		return
+	}
+
+	Update(s, r)
+
+	alerters, err := New(cfg)
+	if err != nil {
+		log.WithError(err).Error("cannot configure alerting")
+	}
+	switch {
+	case len(alerters) == 0:
+	case s.ConsecutiveFailures >= cfg.AlertAfter && cfg.AlertAfter > 0:
+		log.WithField("failures", s.ConsecutiveFailures).Warn("Consecutive sync failures, triggering alert")
+		s.AlertOpen = true
+		for _, a := range alerters {
+			if err := a.Trigger(ctx, r, s.ConsecutiveFailures); err != nil {
+				log.WithError(err).Error("cannot trigger alert")
+			}
+		}
+	case s.ConsecutiveFailures == 0 && s.AlertOpen:
+		log.Info("Sync recovered, resolving alert")
+		s.AlertOpen = false
+		for _, a := range alerters {
+			if err := a.Resolve(ctx); err != nil {
+				log.WithError(err).Error("cannot resolve alert")
+				s.AlertOpen = true
+			}
+		}
+	}
+
+	if err := store.Save(ctx, s); err != nil {
+		log.WithError(err).Error("cannot save state")
+	}
+}
+
+// Update records the result of the run r in the state s
+func Update(s *state.State, r *report.Report) {
+	s.LastRun = r.Start
+	s.LastResult = r.Result
+	if r.Result == report.ResultError {
+		s.ConsecutiveFailures++
+	} else {
+		s.ConsecutiveFailures = 0
+		s.LastSuccess = r.Start
+	}
+	// the baseline of the anomaly detection only counts applied changes
+	if r.Result != report.ResultError && !r.DryRun {
+		s.RecordChanges(r.Changes())
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/awslabs/ssosync/internal/alert"
+	"github.com/awslabs/ssosync/internal/state"
+	"github.com/awslabs/ssosync/pkg/report"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &state.State{}
+	start := time.Now()
+
+	Update(s, &report.Report{Start: start, Result: report.ResultError})
+	Update(s, &report.Report{Start: start, Result: report.ResultError})
+	assert.Equal(2, s.ConsecutiveFailures)
+	assert.True(s.LastSuccess.IsZero())
+
+	Update(s, &report.Report{Start: start, Result: report.ResultPartial})
+	assert.Equal(0, s.ConsecutiveFailures)
+	assert.Equal(start, s.LastSuccess)
+	assert.Equal(report.ResultPartial, s.LastResult)
+}
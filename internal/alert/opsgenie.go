@@ -0,0 +1,75 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/awslabs/ssosync/pkg/report"
+)
+
+// opsgenieURL is the endpoint of the Opsgenie Alert API
+const opsgenieURL = "https://api.opsgenie.com/v2/alerts"
+
+// Opsgenie creates and closes Opsgenie alerts
+type Opsgenie struct {
+	apiKey string
+	alias  string
+	hc     *http.Client
+}
+
+// NewOpsgenie returns an alerter using the API integration key apiKey,
+// the alerts are deduplicated by alias
+func NewOpsgenie(apiKey string, alias string, hc *http.Client) *Opsgenie {
+	return &Opsgenie{apiKey: apiKey, alias: alias, hc: hc}
+}
+
+// Trigger implements Alerter
+func (o *Opsgenie) Trigger(ctx context.Context, r *report.Report, failures int) error {
+	return o.send(ctx, opsgenieURL, map[string]interface{}{
+		"message":     fmt.Sprintf("ssosync failed %d times in a row", failures),
+		"alias":       o.alias,
+		"description": fmt.Sprintf("%s\n\n%s", r.Error, r.String()),
+		"source":      "ssosync",
+		"priority":    "P2",
+	})
+}
+
+// Resolve implements Alerter
+func (o *Opsgenie) Resolve(ctx context.Context) error {
+	u := fmt.Sprintf("%s/%s/close?identifierType=alias", opsgenieURL, url.PathEscape(o.alias))
+	return o.send(ctx, u, map[string]interface{}{
+		"source": "ssosync",
+	})
+}
+
+func (o *Opsgenie) send(ctx context.Context, u string, payload map[string]interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+o.apiKey)
+	return do(o.hc, req)
+}
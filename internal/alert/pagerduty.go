@@ -0,0 +1,95 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/awslabs/ssosync/pkg/report"
+)
+
+// pagerDutyURL is the endpoint of the PagerDuty Events API v2
+const pagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty sends events to a PagerDuty service integration
+type PagerDuty struct {
+	routingKey string
+	dedupKey   string
+	hc         *http.Client
+}
+
+// NewPagerDuty returns an alerter sending events with the integration
+// key routingKey, deduplicated by dedupKey
+func NewPagerDuty(routingKey string, dedupKey string, hc *http.Client) *PagerDuty {
+	return &PagerDuty{routingKey: routingKey, dedupKey: dedupKey, hc: hc}
+}
+
+// Trigger implements Alerter
+func (p *PagerDuty) Trigger(ctx context.Context, r *report.Report, failures int) error {
+	return p.send(ctx, map[string]interface{}{
+		"routing_key":  p.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    p.dedupKey,
+		"payload": map[string]interface{}{
+			"summary":  fmt.Sprintf("ssosync failed %d times in a row: %s", failures, r.Error),
+			"source":   "ssosync",
+			"severity": "error",
+			"custom_details": map[string]interface{}{
+				"error":   r.Error,
+				"summary": r.String(),
+			},
+		},
+	})
+}
+
+// Resolve implements Alerter
+func (p *PagerDuty) Resolve(ctx context.Context) error {
+	return p.send(ctx, map[string]interface{}{
+		"routing_key":  p.routingKey,
+		"event_action": "resolve",
+		"dedup_key":    p.dedupKey,
+	})
+}
+
+func (p *PagerDuty) send(ctx context.Context, event map[string]interface{}) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pagerDutyURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return do(p.hc, req)
+}
+
+// do sends the request and checks the response status
+func do(hc *http.Client, req *http.Request) error {
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
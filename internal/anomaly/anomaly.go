@@ -0,0 +1,59 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package anomaly holds back runs whose number of changes is far above
+// the rolling average of the previous runs, e.g. after a bulk edit in
+// Google Workspace gone wrong
+package anomaly
+
+import (
+	"fmt"
+
+	"github.com/awslabs/ssosync/internal/state"
+)
+
+// MinRuns is the number of previous runs required before a run can be
+// anomalous, the first runs of a new deployment are never held back
+const MinRuns = 3
+
+// Error is returned for a run with an anomalous number of changes
+type Error struct {
+	Changes int
+	Average float64
+	Factor  float64
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d changes planned, more than %g times the average of %.1f changes of the previous runs, review them with --audit and rerun with --force to apply them",
+		e.Changes, e.Factor, e.Average)
+}
+
+// Check returns an *Error when the number of changes planned exceeds
+// factor times the average of the previous runs in s. Runs with at most
+// min changes are never anomalous, so that a quiet directory does not
+// block a handful of changes.
+func Check(s *state.State, changes int, factor float64, min int) error {
+	if factor <= 0 || changes <= min {
+		return nil
+	}
+	avg, runs := s.AverageChanges()
+	if runs < MinRuns {
+		return nil
+	}
+	if float64(changes) > factor*avg {
+		return &Error{Changes: changes, Average: avg, Factor: factor}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package anomaly_test
+
+import (
+	"testing"
+
+	. "github.com/awslabs/ssosync/internal/anomaly"
+	"github.com/awslabs/ssosync/internal/state"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &state.State{}
+	assert.NoError(Check(s, 500, 3, 10))
+
+	for i := 0; i < 12; i++ {
+		s.RecordChanges(4)
+	}
+	assert.Len(s.RecentChanges, state.ChangesWindow)
+
+	assert.NoError(Check(s, 10, 3, 10))
+	assert.NoError(Check(s, 500, 0, 10))
+
+	err := Check(s, 500, 3, 10)
+	assert.IsType(&Error{}, err)
+	assert.Contains(err.Error(), "--force")
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/state"
+)
+
+// DoApprove approves the pending changes of the state, all of them with
+// all, and lists the pending changes to w when none is given
+func DoApprove(ctx context.Context, cfg *config.Config, w io.Writer, keys []string, all bool, by string) error {
+	store, err := state.Open(cfg.AWSConfig, cfg.State)
+	if err != nil {
+		return err
+	}
+	s, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	if all {
+		keys = keys[:0]
+		for key := range s.Pending {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		listPending(w, s)
+		return nil
+	}
+
+	if s.Approvals == nil {
+		s.Approvals = make(map[string]state.Approval)
+	}
+	for _, key := range keys {
+		if _, ok := s.Pending[key]; !ok {
+			return fmt.Errorf("no pending change %q", key)
+		}
+		s.Approvals[key] = state.Approval{By: by, At: time.Now().UTC()}
+	}
+	if err := store.Save(ctx, s); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%d changes approved, they are applied by the next run\n", len(keys))
+	return nil
+}
+
+// listPending writes the pending changes of s, the oldest first
+func listPending(w io.Writer, s *state.State) {
+	keys := make([]string, 0, len(s.Pending))
+	for key := range s.Pending {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !s.Pending[keys[i]].Equal(s.Pending[keys[j]]) {
+			return s.Pending[keys[i]].Before(s.Pending[keys[j]])
+		}
+		return keys[i] < keys[j]
+	})
+
+	if len(keys) == 0 {
+		fmt.Fprintln(w, "no pending changes")
+		return
+	}
+	for _, key := range keys {
+		approval := "pending approval"
+		if a, ok := s.Approvals[key]; ok {
+			approval = fmt.Sprintf("approved by %s at %s", a.By, a.At.Format(time.RFC3339))
+		}
+		fmt.Fprintf(w, "%s\tsince %s\t%s\n", key, s.Pending[key].Format(time.RFC3339), approval)
+	}
+}
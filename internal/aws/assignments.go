@@ -0,0 +1,94 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+)
+
+// Assignment is a permission set assigned to a user or group in an account
+type Assignment struct {
+	AccountId     string
+	PermissionSet string
+}
+
+// String returns the assignment as account/permission set
+func (a Assignment) String() string {
+	return a.AccountId + "/" + a.PermissionSet
+}
+
+// ListAssignments returns the account assignments of the IAM Identity
+// Center instance by principal id, the id of the user or group. This
+// requires a call per permission set and account it is provisioned to.
+func ListAssignments(ctx context.Context, config aws.Config, instanceArn string) (map[string][]Assignment, error) {
+	svc := ssoadmin.NewFromConfig(config)
+	res := make(map[string][]Assignment)
+
+	sets := ssoadmin.NewListPermissionSetsPaginator(svc, &ssoadmin.ListPermissionSetsInput{
+		InstanceArn: aws.String(instanceArn),
+	})
+	for sets.HasMorePages() {
+		page, err := sets.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list permission sets: %w", err)
+		}
+
+		for _, arn := range page.PermissionSets {
+			ps, err := svc.DescribePermissionSet(ctx, &ssoadmin.DescribePermissionSetInput{
+				InstanceArn:      aws.String(instanceArn),
+				PermissionSetArn: aws.String(arn),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("cannot describe permission set %s: %w", arn, err)
+			}
+			name := aws.ToString(ps.PermissionSet.Name)
+
+			accounts := ssoadmin.NewListAccountsForProvisionedPermissionSetPaginator(svc, &ssoadmin.ListAccountsForProvisionedPermissionSetInput{
+				InstanceArn:      aws.String(instanceArn),
+				PermissionSetArn: aws.String(arn),
+			})
+			for accounts.HasMorePages() {
+				page, err := accounts.NextPage(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("cannot list accounts of permission set %s: %w", name, err)
+				}
+
+				for _, account := range page.AccountIds {
+					assignments := ssoadmin.NewListAccountAssignmentsPaginator(svc, &ssoadmin.ListAccountAssignmentsInput{
+						InstanceArn:      aws.String(instanceArn),
+						PermissionSetArn: aws.String(arn),
+						AccountId:        aws.String(account),
+					})
+					for assignments.HasMorePages() {
+						page, err := assignments.NextPage(ctx)
+						if err != nil {
+							return nil, fmt.Errorf("cannot list assignments of permission set %s in account %s: %w", name, account, err)
+						}
+						for _, a := range page.AccountAssignments {
+							id := aws.ToString(a.PrincipalId)
+							res[id] = append(res[id], Assignment{AccountId: account, PermissionSet: name})
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return res, nil
+}
@@ -17,9 +17,25 @@ package aws
 import (
 	"context"
 	"errors"
+	"sync"
+	"time"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	store "github.com/aws/aws-sdk-go-v2/service/identitystore"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/document"
 	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/internal/paging"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// consistencyWait bounds the retries of adding a member when the user
+	// or the group was just created, which the Identity Store may not
+	// find yet
+	consistencyWait = 10 * time.Second
+	// consistencyDelay is the delay before the first of these retries,
+	// doubled by each following retry
+	consistencyDelay = 250 * time.Millisecond
 )
 
 var (
@@ -30,6 +46,8 @@ var (
 	ErrGroupNotSpecified = errors.New("group not specified")
 )
 
+//go:generate go run github.com/golang/mock/mockgen@v1.5.0 -source=client.go -destination=mock/mock_client.go -package=mock
+
 // Client represents an interface of methods used
 // to communicate with AWS SSO
 type Client interface {
@@ -37,117 +55,294 @@ type Client interface {
 	DeleteUser(*types.User) error
 	DeleteGroup(*types.Group) error
 	CreateGroup(name *string, description *string) (*types.Group, error)
+	UpdateGroup(g *types.Group, description *string) error
+	RenameGroup(g *types.Group, name *string) error
+	UpdateUserType(u *types.User, userType *string) error
+	UpdateUser(u *types.User, attrs *types.User) error
 	AddUserToGroup(*types.User, *types.Group) (*types.GroupMembership, error)
 	RemoveGroupMembership(membership *types.GroupMembership) error
 	GetGroupMembers(*types.Group) ([]types.GroupMembership, error)
 	GetGroups() ([]types.Group, error)
 	GetUsers() ([]types.User, error)
+	FindUserByUserName(string) (*types.User, error)
+	FindGroupByDisplayName(string) (*types.Group, error)
 }
 
 type client struct {
+	ctx             context.Context
 	identityStore   *store.Client
 	identityStoreId *string
+
+	mu sync.Mutex
+	// created are the ids of the users and groups created by the client
+	created map[string]bool
 }
 
 // NewClient creates a new client to talk with AWS SSO's Identity Store.
-func NewClient(config aws.Config, identityStoreId string) Client {
+func NewClient(ctx context.Context, config aws.Config, identityStoreId string) Client {
 	return &client{
+		ctx:             ctx,
 		identityStore:   store.NewFromConfig(config),
 		identityStoreId: &identityStoreId,
+		created:         make(map[string]bool),
 	}
 }
 
-// CreateUser will create the user specified
+// CreateUser will create the user specified. The Identity Store API does not
+// accept client tokens, so if a previous (retried) invocation already created
+// the user the resulting conflict is resolved by adopting the existing user.
 func (c *client) CreateUser(u *types.User) (*types.User, error) {
-	res, err := c.identityStore.CreateUser(context.TODO(),
+	res, err := c.identityStore.CreateUser(c.ctx,
 		&store.CreateUserInput{
 			IdentityStoreId: c.identityStoreId,
 			DisplayName:     u.DisplayName,
 			UserName:        u.UserName,
 			Name:            u.Name,
 			Emails:          u.Emails,
+			UserType:        u.UserType,
 		})
 
+	if isConflict(err) {
+		userId, lookupErr := c.findUserId(u.UserName)
+		if lookupErr != nil {
+			return nil, operationError("CreateUser", u.UserName, err)
+		}
+		u.UserId = userId
+		return u, nil
+	}
+
 	if err != nil {
-		return nil, err
+		return nil, operationError("CreateUser", u.UserName, err)
 	}
 
 	u.UserId = res.UserId
+	c.markCreated(res.UserId)
 	return u, err
 }
 
+// UpdateUserType replaces the user type of the user
+func (c *client) UpdateUserType(u *types.User, userType *string) error {
+	_, err := c.identityStore.UpdateUser(c.ctx,
+		&store.UpdateUserInput{
+			IdentityStoreId: c.identityStoreId,
+			UserId:          u.UserId,
+			Operations: []types.AttributeOperation{{
+				AttributePath:  aws.String("userType"),
+				AttributeValue: document.NewLazyDocument(aws.ToString(userType)),
+			}},
+		})
+	return operationError("UpdateUser", u.UserName, err)
+}
+
+// UpdateUser replaces the user name, display name, name and emails of
+// the user by the ones set in attrs
+func (c *client) UpdateUser(u *types.User, attrs *types.User) error {
+	var ops []types.AttributeOperation
+	set := func(path string, value interface{}) {
+		ops = append(ops, types.AttributeOperation{
+			AttributePath:  aws.String(path),
+			AttributeValue: document.NewLazyDocument(value),
+		})
+	}
+	if attrs.UserName != nil {
+		set("userName", aws.ToString(attrs.UserName))
+	}
+	if attrs.DisplayName != nil {
+		set("displayName", aws.ToString(attrs.DisplayName))
+	}
+	if attrs.Name != nil {
+		set("name.givenName", aws.ToString(attrs.Name.GivenName))
+		set("name.familyName", aws.ToString(attrs.Name.FamilyName))
+	}
+	if attrs.Emails != nil {
+		emails := make([]map[string]interface{}, 0, len(attrs.Emails))
+		for _, e := range attrs.Emails {
+			emails = append(emails, map[string]interface{}{
+				"value":   aws.ToString(e.Value),
+				"type":    aws.ToString(e.Type),
+				"primary": e.Primary,
+			})
+		}
+		set("emails", emails)
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	_, err := c.identityStore.UpdateUser(c.ctx,
+		&store.UpdateUserInput{
+			IdentityStoreId: c.identityStoreId,
+			UserId:          u.UserId,
+			Operations:      ops,
+		})
+	return operationError("UpdateUser", u.UserName, err)
+}
+
 // DeleteUser will remove the current user from the directory
 func (c *client) DeleteUser(u *types.User) error {
-	_, err := c.identityStore.DeleteUser(context.TODO(),
+	_, err := c.identityStore.DeleteUser(c.ctx,
 		&store.DeleteUserInput{
 			IdentityStoreId: c.identityStoreId,
 			UserId:          u.UserId,
 		})
-	return err
+	return operationError("DeleteUser", u.UserName, err)
 }
 
 // DeleteGroup will delete the group specified
 func (c *client) DeleteGroup(g *types.Group) error {
-	_, err := c.identityStore.DeleteGroup(context.TODO(),
+	_, err := c.identityStore.DeleteGroup(c.ctx,
 		&store.DeleteGroupInput{
 			GroupId:         g.GroupId,
 			IdentityStoreId: c.identityStoreId,
 		})
 
-	return err
+	return operationError("DeleteGroup", g.DisplayName, err)
 }
 
-// CreateGroup will create a group given
+// CreateGroup will create a group given, adopting an existing group
+// with the same display name on conflict
 func (c *client) CreateGroup(name *string, description *string) (*types.Group, error) {
-	res, err := c.identityStore.CreateGroup(context.TODO(),
+	var groupId *string
+	res, err := c.identityStore.CreateGroup(c.ctx,
 		&store.CreateGroupInput{
 			IdentityStoreId: c.identityStoreId,
 			DisplayName:     name,
 			Description:     description,
 		})
-	if err != nil {
-		return nil, err
+	if isConflict(err) {
+		id, lookupErr := c.findGroupId(name)
+		if lookupErr != nil {
+			return nil, operationError("CreateGroup", name, err)
+		}
+		groupId = id
+	} else if err != nil {
+		return nil, operationError("CreateGroup", name, err)
+	} else {
+		groupId = res.GroupId
+		c.markCreated(groupId)
 	}
 	group := &types.Group{
-		GroupId:     res.GroupId,
+		GroupId:     groupId,
 		DisplayName: name,
 		Description: description,
 	}
-	return group, err
+	return group, nil
+}
+
+// UpdateGroup replaces the description of the group
+func (c *client) UpdateGroup(g *types.Group, description *string) error {
+	_, err := c.identityStore.UpdateGroup(c.ctx,
+		&store.UpdateGroupInput{
+			GroupId:         g.GroupId,
+			IdentityStoreId: c.identityStoreId,
+			Operations: []types.AttributeOperation{{
+				AttributePath:  aws.String("description"),
+				AttributeValue: document.NewLazyDocument(aws.ToString(description)),
+			}},
+		})
+	return operationError("UpdateGroup", g.DisplayName, err)
+}
+
+// RenameGroup replaces the display name of the group
+func (c *client) RenameGroup(g *types.Group, name *string) error {
+	_, err := c.identityStore.UpdateGroup(c.ctx,
+		&store.UpdateGroupInput{
+			IdentityStoreId: c.identityStoreId,
+			GroupId:         g.GroupId,
+			Operations: []types.AttributeOperation{{
+				AttributePath:  aws.String("displayName"),
+				AttributeValue: document.NewLazyDocument(aws.ToString(name)),
+			}},
+		})
+	return operationError("UpdateGroup", name, err)
 }
 
-// AddUserToGroup will add the user specified to the group specified
+// AddUserToGroup will add the user specified to the group specified,
+// adopting an existing membership on conflict
 func (c *client) AddUserToGroup(u *types.User, g *types.Group) (*types.GroupMembership, error) {
+	var membershipId *string
 	memberId := &types.MemberIdMemberUserId{
 		Value: aws.ToString(u.UserId),
 	}
-	res, err := c.identityStore.CreateGroupMembership(context.TODO(),
-		&store.CreateGroupMembershipInput{
-			GroupId:         g.GroupId,
-			MemberId:        memberId,
-			IdentityStoreId: c.identityStoreId,
-		})
-	if err != nil {
-		return nil, err
+	input := &store.CreateGroupMembershipInput{
+		GroupId:         g.GroupId,
+		MemberId:        memberId,
+		IdentityStoreId: c.identityStoreId,
+	}
+	res, err := c.identityStore.CreateGroupMembership(c.ctx, input)
+	if isNotFound(err) && (c.isCreated(u.UserId) || c.isCreated(g.GroupId)) {
+		res, err = c.retryMembership(input, err)
+	}
+	if isConflict(err) {
+		existing, lookupErr := c.identityStore.GetGroupMembershipId(c.ctx,
+			&store.GetGroupMembershipIdInput{
+				GroupId:         g.GroupId,
+				MemberId:        memberId,
+				IdentityStoreId: c.identityStoreId,
+			})
+		if lookupErr != nil {
+			return nil, operationError("CreateGroupMembership", u.UserName, err)
+		}
+		membershipId = existing.MembershipId
+	} else if err != nil {
+		return nil, operationError("CreateGroupMembership", u.UserName, err)
+	} else {
+		membershipId = res.MembershipId
 	}
 
 	result := &types.GroupMembership{
 		IdentityStoreId: c.identityStoreId,
 		GroupId:         g.GroupId,
 		MemberId:        memberId,
-		MembershipId:    res.MembershipId,
+		MembershipId:    membershipId,
 	}
-	return result, err
+	return result, nil
+}
+
+// retryMembership retries adding the member of input, whose user or group
+// was just created, while it is not found and for at most consistencyWait
+func (c *client) retryMembership(input *store.CreateGroupMembershipInput, err error) (*store.CreateGroupMembershipOutput, error) {
+	ll := log.WithField("groupId", aws.ToString(input.GroupId))
+	deadline := time.Now().Add(consistencyWait)
+	for delay := consistencyDelay; time.Now().Add(delay).Before(deadline); delay *= 2 {
+		ll.WithField("delay", delay).Debug("User or group just created not found yet, retrying")
+		select {
+		case <-time.After(delay):
+		case <-c.ctx.Done():
+			return nil, err
+		}
+		res, retryErr := c.identityStore.CreateGroupMembership(c.ctx, input)
+		if !isNotFound(retryErr) {
+			return res, retryErr
+		}
+		err = retryErr
+	}
+	return nil, err
+}
+
+// markCreated records the id of a user or group created by the client
+func (c *client) markCreated(id *string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.created[aws.ToString(id)] = true
+}
+
+// isCreated reports whether the user or group id was created by the
+// client
+func (c *client) isCreated(id *string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.created[aws.ToString(id)]
 }
 
 // RemoveGroupMembership will remove the user specified from the group specified
 func (c *client) RemoveGroupMembership(membership *types.GroupMembership) error {
-	_, err := c.identityStore.DeleteGroupMembership(context.TODO(),
+	_, err := c.identityStore.DeleteGroupMembership(c.ctx,
 		&store.DeleteGroupMembershipInput{
 			IdentityStoreId: c.identityStoreId,
 			MembershipId:    membership.MembershipId,
 		})
-	return err
+	return operationError("DeleteGroupMembership", membership.MembershipId, err)
 }
 
 // GetGroupMembers will return existing groups
@@ -159,11 +354,14 @@ func (c *client) GetGroupMembers(g *types.Group) ([]types.GroupMembership, error
 			MaxResults:      aws.Int32(50),
 			GroupId:         g.GroupId,
 		})
+	pages := paging.Start("aws", "ListGroupMemberships")
+	defer pages.Done()
 	for paginator.HasMorePages() {
-		output, err := paginator.NextPage(context.TODO())
+		output, err := paginator.NextPage(c.ctx)
 		if err != nil {
-			return res, err
+			return res, operationError("ListGroupMemberships", g.DisplayName, err)
 		}
+		pages.Page(len(output.GroupMemberships), aws.ToString(output.NextToken))
 		res = append(res, output.GroupMemberships...)
 	}
 	return res, nil
@@ -177,11 +375,14 @@ func (c *client) GetGroups() ([]types.Group, error) {
 			IdentityStoreId: c.identityStoreId,
 			MaxResults:      aws.Int32(50),
 		})
+	pages := paging.Start("aws", "ListGroups")
+	defer pages.Done()
 	for paginator.HasMorePages() {
-		output, err := paginator.NextPage(context.TODO())
+		output, err := paginator.NextPage(c.ctx)
 		if err != nil {
-			return res, err
+			return res, operationError("ListGroups", nil, err)
 		}
+		pages.Page(len(output.Groups), aws.ToString(output.NextToken))
 		res = append(res, output.Groups...)
 	}
 	return res, nil
@@ -196,12 +397,128 @@ func (c *client) GetUsers() ([]types.User, error) {
 			MaxResults:      aws.Int32(50),
 			NextToken:       nil,
 		})
+	pages := paging.Start("aws", "ListUsers")
+	defer pages.Done()
 	for paginator.HasMorePages() {
-		output, err := paginator.NextPage(context.TODO())
+		output, err := paginator.NextPage(c.ctx)
 		if err != nil {
-			return res, err
+			return res, operationError("ListUsers", nil, err)
 		}
+		pages.Page(len(output.Users), aws.ToString(output.NextToken))
 		res = append(res, output.Users...)
 	}
 	return res, nil
 }
+
+// FindUserByUserName will return the user with the given user name
+func (c *client) FindUserByUserName(userName string) (*types.User, error) {
+	userId, err := c.findUserId(aws.String(userName))
+	if isNotFound(err) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, operationError("DescribeUser", &userName, err)
+	}
+
+	res, err := c.identityStore.DescribeUser(c.ctx,
+		&store.DescribeUserInput{
+			IdentityStoreId: c.identityStoreId,
+			UserId:          userId,
+		})
+	if isNotFound(err) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, operationError("DescribeUser", &userName, err)
+	}
+
+	return &types.User{
+		IdentityStoreId: res.IdentityStoreId,
+		UserId:          res.UserId,
+		UserName:        res.UserName,
+		DisplayName:     res.DisplayName,
+		Name:            res.Name,
+		Emails:          res.Emails,
+		ExternalIds:     res.ExternalIds,
+		UserType:        res.UserType,
+	}, nil
+}
+
+// FindGroupByDisplayName will return the group with the given display name
+func (c *client) FindGroupByDisplayName(displayName string) (*types.Group, error) {
+	groupId, err := c.findGroupId(aws.String(displayName))
+	if isNotFound(err) {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, operationError("DescribeGroup", &displayName, err)
+	}
+
+	res, err := c.identityStore.DescribeGroup(c.ctx,
+		&store.DescribeGroupInput{
+			IdentityStoreId: c.identityStoreId,
+			GroupId:         groupId,
+		})
+	if isNotFound(err) {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, operationError("DescribeGroup", &displayName, err)
+	}
+
+	return &types.Group{
+		IdentityStoreId: res.IdentityStoreId,
+		GroupId:         res.GroupId,
+		DisplayName:     res.DisplayName,
+		Description:     res.Description,
+		ExternalIds:     res.ExternalIds,
+	}, nil
+}
+
+// findUserId looks up the id of the user with the given user name
+func (c *client) findUserId(userName *string) (*string, error) {
+	res, err := c.identityStore.GetUserId(c.ctx,
+		&store.GetUserIdInput{
+			IdentityStoreId:     c.identityStoreId,
+			AlternateIdentifier: uniqueAttribute("userName", userName),
+		})
+	if err != nil {
+		return nil, err
+	}
+	return res.UserId, nil
+}
+
+// findGroupId looks up the id of the group with the given display name
+func (c *client) findGroupId(displayName *string) (*string, error) {
+	res, err := c.identityStore.GetGroupId(c.ctx,
+		&store.GetGroupIdInput{
+			IdentityStoreId:     c.identityStoreId,
+			AlternateIdentifier: uniqueAttribute("displayName", displayName),
+		})
+	if err != nil {
+		return nil, err
+	}
+	return res.GroupId, nil
+}
+
+func uniqueAttribute(path string, value *string) types.AlternateIdentifier {
+	return &types.AlternateIdentifierMemberUniqueAttribute{
+		Value: types.UniqueAttribute{
+			AttributePath:  aws.String(path),
+			AttributeValue: document.NewLazyDocument(aws.ToString(value)),
+		},
+	}
+}
+
+// isConflict reports whether err is an Identity Store ConflictException,
+// which is returned when the entity being created already exists
+func isConflict(err error) bool {
+	var conflict *types.ConflictException
+	return errors.As(err, &conflict)
+}
+
+// isNotFound reports whether err is an Identity Store ResourceNotFoundException
+func isNotFound(err error) bool {
+	var notFound *types.ResourceNotFoundException
+	return errors.As(err, &notFound)
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	store "github.com/aws/aws-sdk-go-v2/service/identitystore"
+	. "github.com/awslabs/ssosync/internal/aws"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// identityStore is a fake Identity Store API holding groups by display name
+type identityStore struct {
+	mu     sync.Mutex
+	groups map[string]string
+}
+
+func (s *identityStore) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var in struct {
+		DisplayName         string
+		AlternateIdentifier struct {
+			UniqueAttribute struct {
+				AttributeValue string
+			}
+		}
+	}
+	json.NewDecoder(req.Body).Decode(&in)
+	fail := func(code string) {
+		w.Header().Set("X-Amzn-Errortype", code)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"Message":"` + code + `"}`))
+	}
+	reply := func(v interface{}) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(v)
+	}
+
+	switch strings.TrimPrefix(req.Header.Get("X-Amz-Target"), "AWSIdentityStore.") {
+	case "CreateGroup":
+		if _, ok := s.groups[in.DisplayName]; ok {
+			fail("ConflictException")
+			return
+		}
+		id := "g-" + in.DisplayName
+		s.groups[in.DisplayName] = id
+		reply(map[string]string{"GroupId": id, "IdentityStoreId": "d-1234567890"})
+	case "GetGroupId":
+		id, ok := s.groups[in.AlternateIdentifier.UniqueAttribute.AttributeValue]
+		if !ok {
+			fail("ResourceNotFoundException")
+			return
+		}
+		reply(map[string]string{"GroupId": id, "IdentityStoreId": "d-1234567890"})
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestCreateGroupConflict(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := httptest.NewServer(&identityStore{groups: map[string]string{"Platform": "g-existing"}})
+	defer srv.Close()
+
+	config := aws.Config{
+		Region:                      "us-east-1",
+		Credentials:                 credentials.NewStaticCredentialsProvider("id", "secret", ""),
+		EndpointResolverWithOptions: EndpointResolver(map[string]string{store.ServiceID: srv.URL}),
+	}
+	c := NewClient(context.Background(), config, "d-1234567890")
+
+	// a new group is created
+	g, err := c.CreateGroup(aws.String("Sales"), nil)
+	assert.NoError(err)
+	assert.Equal("g-Sales", aws.ToString(g.GroupId))
+
+	// an existing group is adopted
+	g, err = c.CreateGroup(aws.String("Platform"), aws.String("The platform team"))
+	assert.NoError(err)
+	assert.Equal("g-existing", aws.ToString(g.GroupId))
+	assert.Equal("The platform team", aws.ToString(g.Description))
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// EndpointResolver returns a resolver using the given endpoint URLs, keyed
+// by service id, e.g. the DNS names of VPC interface endpoints. Services
+// without an override use the default endpoint of the region.
+func EndpointResolver(endpoints map[string]string) aws.EndpointResolverWithOptions {
+	return aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+		url, ok := endpoints[service]
+		if !ok || url == "" {
+			return aws.Endpoint{}, &aws.EndpointNotFoundError{}
+		}
+
+		return aws.Endpoint{
+			URL:           url,
+			SigningRegion: region,
+			Source:        aws.EndpointSourceCustom,
+		}, nil
+	})
+}
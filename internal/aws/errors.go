@@ -0,0 +1,80 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+	"github.com/awslabs/ssosync/pkg/report"
+)
+
+// OperationError is returned when an Identity Store operation on an entity
+// fails, it wraps the error of the SDK
+type OperationError struct {
+	// Op is the Identity Store operation, e.g. CreateUser
+	Op string
+	// Entity is the user name, group display name or id the operation
+	// was made on, empty for the list operations
+	Entity string
+	Err    error
+}
+
+// Error implements error
+func (e *OperationError) Error() string {
+	if e.Entity == "" {
+		return fmt.Sprintf("identitystore %s failed: %s", e.Op, e.Err)
+	}
+	return fmt.Sprintf("identitystore %s of %s failed: %s", e.Op, e.Entity, e.Err)
+}
+
+// Unwrap returns the error of the SDK
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+// Kind returns the cause of the failure, from the error code of the
+// service
+func (e *OperationError) Kind() string {
+	var ae smithy.APIError
+	if !errors.As(e.Err, &ae) {
+		return ""
+	}
+	switch ae.ErrorCode() {
+	case "ThrottlingException", "TooManyRequestsException":
+		return report.CauseThrottling
+	case "ServiceQuotaExceededException":
+		return report.CauseQuota
+	case "AccessDeniedException":
+		return report.CauseAccessDenied
+	case "ResourceNotFoundException":
+		return report.CauseNotFound
+	case "ConflictException":
+		return report.CauseConflict
+	case "ValidationException":
+		return report.CauseValidation
+	}
+	return ""
+}
+
+// operationError wraps err, when not nil, in an *OperationError
+func operationError(op string, entity *string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &OperationError{Op: op, Entity: aws.ToString(entity), Err: err}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	store "github.com/aws/aws-sdk-go-v2/service/identitystore"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+	"github.com/aws/smithy-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// Preflight verifies that the identity store exists and is accessible
+// before any change is made, turning opaque AccessDenied errors in the
+// middle of a run into a clear error at startup.
+func Preflight(ctx context.Context, config aws.Config, identityStoreId string) error {
+	if identityStoreId == "" {
+		return errors.New("identity store id is not configured")
+	}
+	if strings.HasPrefix(identityStoreId, "arn:") {
+		return fmt.Errorf("identity store id %q is an ARN, use the id of the identity store (d-xxxxxxxxxx) shown in the IAM Identity Center settings", identityStoreId)
+	}
+
+	instances, err := ListInstances(ctx, config)
+	switch {
+	case isAccessDenied(err):
+		log.Debug("no permission to list the IAM Identity Center instances, skipping instance check")
+	case err != nil:
+		return fmt.Errorf("cannot list IAM Identity Center instances: %w", err)
+	default:
+		if !containsStore(instances, identityStoreId) {
+			ids := make([]string, 0, len(instances))
+			for _, i := range instances {
+				ids = append(ids, aws.ToString(i.IdentityStoreId))
+			}
+			return fmt.Errorf("identity store %s not found in region %s, accessible identity stores: [%s]",
+				identityStoreId, config.Region, strings.Join(ids, ", "))
+		}
+	}
+
+	_, err = store.NewFromConfig(config).ListUsers(ctx, &store.ListUsersInput{
+		IdentityStoreId: aws.String(identityStoreId),
+		MaxResults:      aws.Int32(1),
+	})
+	switch {
+	case isAccessDenied(err):
+		return fmt.Errorf("no permission to read identity store %s, identitystore:* is required: %w", identityStoreId, err)
+	case isNotFound(err):
+		return fmt.Errorf("identity store %s not found in region %s: %w", identityStoreId, config.Region, err)
+	case err != nil:
+		return fmt.Errorf("cannot access identity store %s: %w", identityStoreId, err)
+	}
+
+	return nil
+}
+
+// Instance describes an IAM Identity Center instance
+type Instance struct {
+	InstanceArn     *string
+	IdentityStoreId *string
+}
+
+// ListInstances returns the IAM Identity Center instances of the account
+func ListInstances(ctx context.Context, config aws.Config) ([]Instance, error) {
+	var res []Instance
+	paginator := ssoadmin.NewListInstancesPaginator(ssoadmin.NewFromConfig(config), &ssoadmin.ListInstancesInput{})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return res, err
+		}
+		for _, i := range output.Instances {
+			res = append(res, Instance{
+				InstanceArn:     i.InstanceArn,
+				IdentityStoreId: i.IdentityStoreId,
+			})
+		}
+	}
+	return res, nil
+}
+
+// DiscoverInstance returns the IAM Identity Center instance for the given
+// identity store id or instance ARN. When neither is given, the only
+// instance of the account is returned.
+func DiscoverInstance(ctx context.Context, config aws.Config, idOrArn string) (Instance, error) {
+	instances, err := ListInstances(ctx, config)
+	switch {
+	case isAccessDenied(err):
+		return Instance{}, fmt.Errorf("cannot discover the identity store, the sso:ListInstances permission is missing, grant it or configure the identity store id: %w", err)
+	case err != nil:
+		return Instance{}, fmt.Errorf("cannot discover the identity store, sso:ListInstances failed: %w", err)
+	}
+
+	if idOrArn == "" {
+		if len(instances) != 1 {
+			return Instance{}, fmt.Errorf("cannot discover the identity store, found %d IAM Identity Center instances in region %s, configure --identity-store-id", len(instances), config.Region)
+		}
+		return instances[0], nil
+	}
+
+	for _, i := range instances {
+		if aws.ToString(i.InstanceArn) == idOrArn || aws.ToString(i.IdentityStoreId) == idOrArn {
+			return i, nil
+		}
+	}
+	return Instance{}, fmt.Errorf("no IAM Identity Center instance found for %s in region %s", idOrArn, config.Region)
+}
+
+func containsStore(instances []Instance, identityStoreId string) bool {
+	for _, i := range instances {
+		if aws.ToString(i.IdentityStoreId) == identityStoreId {
+			return true
+		}
+	}
+	return false
+}
+
+// isAccessDenied reports whether err is an AccessDeniedException of any service
+func isAccessDenied(err error) bool {
+	var ae smithy.APIError
+	return errors.As(err, &ae) && ae.ErrorCode() == "AccessDeniedException"
+}
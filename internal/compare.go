@@ -0,0 +1,163 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/awslabs/ssosync/internal/aws"
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/scim"
+	"github.com/awslabs/ssosync/internal/transport"
+	log "github.com/sirupsen/logrus"
+)
+
+// DoSCIMCompare compares the users and groups the SCIM endpoint reports
+// with those the Identity Store API reports and writes the discrepancies
+// to w in the format, e.g. to verify a migration from the SCIM based
+// versions of ssosync. With an interval the comparison is repeated until
+// ctx is done, the new and resolved discrepancies are logged instead.
+func DoSCIMCompare(ctx context.Context, cfg *config.Config, w io.Writer, format string, interval time.Duration) error {
+	if format != "text" && format != "csv" && format != "json" {
+		return fmt.Errorf("report format %q is not one of text, csv, json", format)
+	}
+	if !cfg.IdentityStoreTarget() {
+		return fmt.Errorf("the scim comparison requires the %s target", config.TargetIdentityStore)
+	}
+	if err := resolveIdentityStore(ctx, cfg); err != nil {
+		return err
+	}
+	hc, err := transport.NewClient("scim", nil, transport.Options{
+		Timeout: cfg.AWSTimeout,
+		Proxy:   cfg.ProxyFor(""),
+	})
+	if err != nil {
+		return err
+	}
+	sc, err := scim.New(ctx, hc, cfg.SCIMEndpoint, cfg.SCIMAccessToken)
+	if err != nil {
+		return err
+	}
+	store := aws.NewClient(ctx, cfg.IdentityStoreAWSConfig, cfg.IdentityStoreId)
+
+	compare := func() ([]scim.Discrepancy, error) {
+		log.Info("Comparing the SCIM users and groups with the identity store")
+		users, err := sc.Users()
+		if err != nil {
+			return nil, err
+		}
+		groups, err := sc.Groups()
+		if err != nil {
+			return nil, err
+		}
+		storeUsers, err := store.GetUsers()
+		if err != nil {
+			return nil, err
+		}
+		storeGroups, err := store.GetGroups()
+		if err != nil {
+			return nil, err
+		}
+		return scim.Compare(users, groups, storeUsers, storeGroups), nil
+	}
+
+	if interval <= 0 {
+		ds, err := compare()
+		if err != nil {
+			return err
+		}
+		return writeDiscrepancies(w, format, ds)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	previous := make(map[string]scim.Discrepancy)
+	for {
+		ds, err := compare()
+		if err != nil {
+			log.WithError(err).Error("Can't compare SCIM with the identity store")
+		} else {
+			previous = logDiscrepancies(ds, previous)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// logDiscrepancies logs the discrepancies which are not in previous and
+// those of previous which were resolved, and returns the discrepancies
+// by key
+func logDiscrepancies(ds []scim.Discrepancy, previous map[string]scim.Discrepancy) map[string]scim.Discrepancy {
+	current := make(map[string]scim.Discrepancy, len(ds))
+	preV2 := 0
+	for _, d := range ds {
+		key := d.Kind + "/" + d.ID + "/" + d.Problem
+		current[key] = d
+		if d.PreV2 {
+			preV2++
+		}
+		if _, ok := previous[key]; !ok {
+			log.WithFields(log.Fields{"kind": d.Kind, "id": d.ID, "name": d.Name, "problem": d.Problem, "detail": d.Detail, "preV2": d.PreV2}).Warn("SCIM and the identity store disagree")
+		}
+	}
+	for key, d := range previous {
+		if _, ok := current[key]; !ok {
+			log.WithFields(log.Fields{"kind": d.Kind, "id": d.ID, "name": d.Name, "problem": d.Problem}).Info("SCIM and the identity store agree again")
+		}
+	}
+	log.WithField("discrepancies", len(ds)).WithField("preV2", preV2).Info("Compared SCIM with the identity store")
+	return current
+}
+
+// writeDiscrepancies writes the discrepancies to w in the format
+func writeDiscrepancies(w io.Writer, format string, ds []scim.Discrepancy) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if ds == nil {
+			ds = []scim.Discrepancy{}
+		}
+		return enc.Encode(ds)
+	}
+
+	rows := [][]string{{"kind", "id", "name", "problem", "detail", "pre_v2", "created"}}
+	for _, d := range ds {
+		created := ""
+		if d.Created != nil {
+			created = d.Created.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{d.Kind, d.ID, d.Name, d.Problem, d.Detail, strconv.FormatBool(d.PreV2), created})
+	}
+	if format == "csv" {
+		return csv.NewWriter(w).WriteAll(rows)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, r := range rows {
+		fmt.Fprintln(tw, strings.Join(r, "\t"))
+	}
+	return tw.Flush()
+}
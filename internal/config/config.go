@@ -1,7 +1,12 @@
 // Package config ...
 package config
 
-import "github.com/aws/aws-sdk-go-v2/aws"
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
 
 // Config ...
 type Config struct {
@@ -9,26 +14,381 @@ type Config struct {
 	Debug bool
 	// LogLevel is the level with with to log for this config
 	LogLevel string `mapstructure:"log_level"`
+	// Quiet logs only the changes, warnings and errors, like the change
+	// log level
+	Quiet bool `mapstructure:"quiet"`
 	// LogFormat is the format that is used for logging
 	LogFormat string `mapstructure:"log_format"`
+	// AuditFormat is the format of the change log entries, the audit
+	// trail, json, ocsf or cef, the log format when empty
+	AuditFormat string `mapstructure:"audit_format"`
+	// LogRedact are additional patterns scrubbed from the log output
+	LogRedact []string `mapstructure:"log_redact"`
 	// GoogleCredentials ...
 	GoogleCredentials string `mapstructure:"google_credentials"`
 	// GoogleAdmin ...
 	GoogleAdmin string `mapstructure:"google_admin"`
-	// UserMatch ...
-	UserMatch string `mapstructure:"user_match"`
-	// GroupFilter ...
-	GroupMatch string `mapstructure:"group_match"`
+	// GoogleCredentialsEncryption is how the Google credentials are
+	// encrypted: kms, age or empty when in plaintext
+	GoogleCredentialsEncryption string `mapstructure:"google_credentials_encryption"`
+	// AgeIdentity is the path of the age identity file decrypting the
+	// Google credentials
+	AgeIdentity string `mapstructure:"age_identity"`
+	// GoogleScopes are the OAuth scopes requested for the Google service
+	// account, the read-only directory scopes when empty
+	GoogleScopes []string `mapstructure:"google_scopes"`
+	// UserMatch are the user queries, users matching any of them are synced
+	UserMatch []string `mapstructure:"user_match"`
+	// GroupMatch are the group queries, groups matching any of them are synced
+	GroupMatch []string `mapstructure:"group_match"`
+	// UserExcludeMatch are user queries whose results are not synced
+	UserExcludeMatch []string `mapstructure:"user_exclude_match"`
+	// GroupExcludeMatch are group queries whose results are not synced
+	GroupExcludeMatch []string `mapstructure:"group_exclude_match"`
+	// UnmanagedMembershipGroups are AWS group names or shell patterns of groups
+	// whose members are never removed by ssosync
+	UnmanagedMembershipGroups []string `mapstructure:"unmanaged_membership_groups"`
+	// ProtectedUsers are AWS user names or shell patterns of users which
+	// are never deleted nor removed from groups
+	ProtectedUsers []string `mapstructure:"protected_users"`
+	// ProtectedGroups are AWS group names or shell patterns of groups which
+	// are never deleted nor have members removed
+	ProtectedGroups []string `mapstructure:"protected_groups"`
+	// SkipDeletedUsers disables fetching the deleted Google users, only
+	// the users suspended in Google are then deleted in AWS
+	SkipDeletedUsers bool `mapstructure:"skip_deleted_users"`
+	// DeleteAbsentUsers deletes the AWS users with a Google external id
+	// missing from the Google users matching the user queries
+	DeleteAbsentUsers bool `mapstructure:"delete_absent_users"`
+	// GroupDescriptionTags honors the [ssosync:...] tags of the Google
+	// group descriptions
+	GroupDescriptionTags bool `mapstructure:"group_description_tags"`
+	// UserNameTemplate renders the AWS user names of the Google users
+	UserNameTemplate string `mapstructure:"user_name_template"`
+	// UserNameCollision is the policy applied when the user name template
+	// maps several users to the same name: fail, skip or suffix
+	UserNameCollision string `mapstructure:"user_name_collision"`
+	// EmailPolicy is the policy applied to the Google users with an
+	// invalid primary email: skip, truncate or alias
+	EmailPolicy string `mapstructure:"email_policy"`
+	// GroupNamePolicy is the policy applied to the Google groups whose
+	// name is not a valid AWS group name: transform or skip
+	GroupNamePolicy string `mapstructure:"group_name_policy"`
+	// GroupKey matches the AWS groups to the Google groups by display
+	// name or by the email recorded in their description: name or email
+	GroupKey string `mapstructure:"group_key"`
+	// GroupNameMaxLength is the maximum length of the AWS group names,
+	// the Identity Store limit when 0
+	GroupNameMaxLength int `mapstructure:"group_name_max_length"`
+	// GroupMemberLimit is the membership count limit of the AWS groups,
+	// none when 0
+	GroupMemberLimit int `mapstructure:"group_member_limit"`
+	// OverflowGroups adds the members above the GroupMemberLimit to the
+	// numbered overflow groups of the group
+	OverflowGroups bool `mapstructure:"overflow_groups"`
+	// Target is the name of the target of the sync, the Identity Store or
+	// an alternate target, see targets.New
+	Target string `mapstructure:"target"`
+	// Targets are the targets a run is applied to concurrently, Target
+	// when empty
+	Targets []string `mapstructure:"targets"`
+	// KeycloakURL is the base URL of the Keycloak of the keycloak target
+	KeycloakURL string `mapstructure:"keycloak_url"`
+	// KeycloakRealm is the realm the keycloak target syncs to
+	KeycloakRealm string `mapstructure:"keycloak_realm"`
+	// KeycloakTokenRealm is the realm of the Keycloak client,
+	// KeycloakRealm when empty
+	KeycloakTokenRealm string `mapstructure:"keycloak_token_realm"`
+	// SCIMEndpoint and SCIMAccessToken are the SCIM endpoint of the
+	// automatic provisioning and its access token, read by the SCIM
+	// comparison
+	SCIMEndpoint    string `mapstructure:"scim_endpoint"`
+	SCIMAccessToken string `mapstructure:"scim_access_token"`
+	// KeycloakClientID and KeycloakClientSecret are the credentials of
+	// the Keycloak client, whose service account manages the users
+	KeycloakClientID     string `mapstructure:"keycloak_client_id"`
+	KeycloakClientSecret string `mapstructure:"keycloak_client_secret"`
+	// DirectoryId is the AWS Managed Microsoft AD directory of the
+	// managed-ad target
+	DirectoryId string `mapstructure:"directory_id"`
 	// IdentityStoreId ...
 	IdentityStoreId string `mapstructure:"identity_store_id"`
+	// DiscoverIdentityStore looks up the identity store id, when not
+	// configured or configured as instance ARN
+	DiscoverIdentityStore bool `mapstructure:"discover_identity_store"`
+	// InstanceArn is the ARN of the IAM Identity Center instance,
+	// set when the identity store was discovered
+	InstanceArn string `mapstructure:"instance_arn"`
+	// SkipPreflight disables the identity store checks at startup
+	SkipPreflight bool `mapstructure:"skip_preflight"`
 	// IsLambda ...
 	IsLambda bool
+	// Daemon keeps ssosync running, syncing every Interval
+	Daemon bool `mapstructure:"daemon"`
+	// Interval is the time between two syncs in daemon mode
+	Interval time.Duration `mapstructure:"interval"`
+	// MemberCache keeps the members of the Google groups between the
+	// syncs of daemon mode, refetched when the etag of a group changes
+	MemberCache bool `mapstructure:"member_cache"`
+	// HealthAddr is the listen address of the health endpoints in daemon mode
+	HealthAddr string `mapstructure:"health_addr"`
+	// Pprof serves the pprof endpoints next to the health endpoints
+	Pprof bool `mapstructure:"pprof"`
+	// HeapProfileDir is where heap profiles are written on SIGUSR1
+	HeapProfileDir string `mapstructure:"heap_profile_dir"`
+	// Timeout is the maximum duration of a sync, 0 for no limit
+	Timeout time.Duration `mapstructure:"timeout"`
+	// MaxRuntime is the duration after which a sync starts no further
+	// change and exits cleanly, 0 for no limit
+	MaxRuntime time.Duration `mapstructure:"max_runtime"`
+	// Shard is the slice of the directory synced by the run, i/n, the
+	// whole directory when empty
+	Shard string `mapstructure:"shard"`
+	// GoogleTimeout is the maximum duration of a Google API call
+	GoogleTimeout time.Duration `mapstructure:"google_timeout"`
+	// DerivedMembership lists the members of the Google groups with the
+	// members of their nested groups
+	DerivedMembership bool `mapstructure:"derived_membership"`
+	// Licenses limits the synced users to the holders of one of the
+	// licenses, each a productId/skuId of the License Manager API
+	Licenses []string `mapstructure:"licenses"`
+	// LicenseCustomer is the primary domain or customer id the licenses
+	// are listed for, the domain of the Google admin when empty
+	LicenseCustomer string `mapstructure:"license_customer"`
+	// GroupLabels limits the synced groups to the groups with one of the
+	// Cloud Identity labels, e.g. security
+	GroupLabels []string `mapstructure:"group_labels"`
+	// GroupSettings reads the Google Groups settings of the synced groups
+	// into the report of the run
+	GroupSettings bool `mapstructure:"group_settings"`
+	// PrivilegedGroups are AWS group names or shell patterns of groups
+	// flagged when their Google settings let unvetted users join
+	PrivilegedGroups []string `mapstructure:"privileged_groups"`
+	// GoogleCustomerId is the Google Workspace customer id, e.g.
+	// C01234567, looked up when empty
+	GoogleCustomerId string `mapstructure:"google_customer_id"`
+	// GoogleRetries is the number of retries of the Google API calls
+	// failing with a rate limit or a server error
+	GoogleRetries int `mapstructure:"google_retries"`
+	// TargetRetries is the number of retries of the API calls to the
+	// Keycloak and managed AD targets failing with a rate limit or a
+	// server error
+	TargetRetries int `mapstructure:"target_retries"`
+	// AWSTimeout is the maximum duration of an AWS API call
+	AWSTimeout time.Duration `mapstructure:"aws_timeout"`
+	// Proxy is the proxy for all API calls, see transport.Options
+	Proxy string `mapstructure:"proxy"`
+	// GoogleProxy overrides Proxy for Google API calls
+	GoogleProxy string `mapstructure:"google_proxy"`
+	// AWSProxy overrides Proxy for AWS API calls
+	AWSProxy string `mapstructure:"aws_proxy"`
+	// IdentityStoreEndpoint overrides the endpoint of the Identity Store API
+	IdentityStoreEndpoint string `mapstructure:"identity_store_endpoint"`
+	// SecretsManagerEndpoint overrides the endpoint of the Secrets Manager API
+	SecretsManagerEndpoint string `mapstructure:"secrets_manager_endpoint"`
+	// SSOAdminEndpoint overrides the endpoint of the SSO Admin API
+	SSOAdminEndpoint string `mapstructure:"sso_admin_endpoint"`
+	// HookCommand is a shell command run before and after every change
+	HookCommand string `mapstructure:"hook_command"`
+	// HookWebhook is a URL the changes are posted to before and after
+	HookWebhook string `mapstructure:"hook_webhook"`
+	// EventStream is the EventBridge bus, eventbridge://bus, or the
+	// Kinesis stream, kinesis://stream, the applied changes are
+	// published to
+	EventStream string `mapstructure:"event_stream"`
+	// HookPlugin is the path of a Go plugin exporting a Hook
+	HookPlugin string `mapstructure:"hook_plugin"`
+	// HookTimeout is the maximum duration of a hook command or webhook call
+	HookTimeout time.Duration `mapstructure:"hook_timeout"`
+	// Evidence is the s3://bucket/prefix an evidence record of each user
+	// deleted is written to
+	Evidence string `mapstructure:"evidence"`
+	// EvidenceRetentionDays locks the evidence records with S3 Object Lock
+	// for the number of days, not locked when zero
+	EvidenceRetentionDays int `mapstructure:"evidence_retention_days"`
+	// EvidenceLockMode is the S3 Object Lock mode: GOVERNANCE or COMPLIANCE
+	EvidenceLockMode string `mapstructure:"evidence_lock_mode"`
+	// WelcomeEmailFrom is the SES identity the welcome message is emailed
+	// from to the users created, not emailed when empty
+	WelcomeEmailFrom string `mapstructure:"welcome_email_from"`
+	// WelcomeSubject is the subject of the welcome message
+	WelcomeSubject string `mapstructure:"welcome_subject"`
+	// WelcomeTemplate is the path of the text/template of the welcome
+	// message, DefaultWelcomeTemplate of the hooks when empty
+	WelcomeTemplate string `mapstructure:"welcome_template"`
+	// WelcomePortalURL is the AWS access portal URL of the welcome message
+	WelcomePortalURL string `mapstructure:"welcome_portal_url"`
+	// WelcomeQueue is the URL of the SQS queue the welcome message is sent to
+	WelcomeQueue string `mapstructure:"welcome_queue"`
+	// WelcomeWebhook is the URL the welcome message is posted to
+	WelcomeWebhook string `mapstructure:"welcome_webhook"`
+	// SlackWebhook is the incoming webhook URL the run summaries are posted to
+	SlackWebhook string `mapstructure:"slack_webhook"`
+	// SlackWebhookSecret is the Secrets Manager secret holding SlackWebhook
+	SlackWebhookSecret string `mapstructure:"slack_webhook_secret"`
+	// TeamsWebhook is the Microsoft Teams webhook URL the run summaries are posted to
+	TeamsWebhook string `mapstructure:"teams_webhook"`
+	// NotifyWebhook is a URL the run summaries are posted to as JSON
+	NotifyWebhook string `mapstructure:"notify_webhook"`
+	// NotifyWebhookTemplate is a Go template of the NotifyWebhook body
+	NotifyWebhookTemplate string `mapstructure:"notify_webhook_template"`
+	// EmailFrom is the SES verified sender of the run summaries
+	EmailFrom string `mapstructure:"email_from"`
+	// EmailTo are the recipients of the run summaries
+	EmailTo []string `mapstructure:"email_to"`
+	// NotifyOn selects the runs which are notified: always, changes or errors
+	NotifyOn string `mapstructure:"notify_on"`
+	// State is the file or s3://bucket/key the state between runs is
+	// saved to, kept in memory when empty
+	State string `mapstructure:"state"`
+	// PagerDutyRoutingKey is the integration key of the PagerDuty service alerted
+	PagerDutyRoutingKey string `mapstructure:"pagerduty_routing_key"`
+	// OpsgenieAPIKey is the key of the Opsgenie API integration alerted
+	OpsgenieAPIKey string `mapstructure:"opsgenie_api_key"`
+	// AlertAfter is the number of consecutive failed runs triggering an alert
+	AlertAfter int `mapstructure:"alert_after"`
+	// SecretsBackend is where the secrets are read from: secretsmanager
+	// or vault
+	SecretsBackend string `mapstructure:"secrets_backend"`
+	// VaultAddr is the address of the Vault, VAULT_ADDR when empty
+	VaultAddr string `mapstructure:"vault_addr"`
+	// VaultToken authenticates to the Vault, VAULT_TOKEN or
+	// ~/.vault-token when empty
+	VaultToken string `mapstructure:"vault_token"`
+	// VaultMount is the mount path of the KV version 2 secrets engine
+	VaultMount string `mapstructure:"vault_mount"`
+	// VaultPath is the path of the secret holding the ssosync secrets
+	VaultPath string `mapstructure:"vault_path"`
+	// VaultAWSMount is the mount path of the AWS secrets engine
+	VaultAWSMount string `mapstructure:"vault_aws_mount"`
+	// VaultAWSRole is the role of the AWS secrets engine the AWS
+	// credentials are generated for, the default credentials when empty
+	VaultAWSRole string `mapstructure:"vault_aws_role"`
+	// GoogleCredentialsInline is set when GoogleCredentials holds the
+	// content of the credentials instead of a path, e.g. read from Vault
+	GoogleCredentialsInline bool
+	// Audit runs the sync without applying any change, the changes found
+	// are the drift between Google and AWS
+	Audit bool `mapstructure:"audit"`
+	// UpdateCheck logs when a newer release is available, at most once a day
+	UpdateCheck bool `mapstructure:"update_check"`
+	// BuildVersion is the version of the running build
+	BuildVersion string
+	// Plan runs the sync without applying any change, nor publishing the
+	// drift, notifying or tracking the run
+	Plan bool
+	// DriftThreshold is the number of changes found by an audit run
+	// above which the drift is published to DriftTopic
+	DriftThreshold int `mapstructure:"drift_threshold"`
+	// DriftTopic is the ARN of the SNS topic the drift is published to
+	DriftTopic string `mapstructure:"drift_topic"`
+	// EMFNamespace is the CloudWatch namespace of the metrics of the run
+	// summary logged in the Embedded Metric Format, disabled when empty
+	EMFNamespace string `mapstructure:"emf_namespace"`
+	// DriftMetricNamespace is the CloudWatch namespace of the drift
+	// metric of the audit runs, not published when empty
+	DriftMetricNamespace string `mapstructure:"drift_metric_namespace"`
+	// FreezeWindows are the recurring change freezes, each a cron
+	// expression of the start followed by the duration
+	FreezeWindows []string `mapstructure:"freeze_windows"`
+	// FreezeCalendar is the URL or path of an iCalendar whose events are
+	// change freezes
+	FreezeCalendar string `mapstructure:"freeze_calendar"`
+	// PauseFlag is the location of the flag pausing the syncs, an SSM
+	// parameter or a DynamoDB item, see pause.Parse
+	PauseFlag string `mapstructure:"pause_flag"`
+	// Lock is the DynamoDB item of the lock preventing concurrent runs,
+	// dynamodb://table/key, none when empty
+	Lock string `mapstructure:"lock"`
+	// LockTTL is the expiry of the lock of a crashed run
+	LockTTL time.Duration `mapstructure:"lock_ttl"`
+	// Inventory is the DynamoDB mirror of the users and groups of the
+	// Identity Store, dynamodb://table/prefix, none when empty
+	Inventory string `mapstructure:"inventory"`
+	// InventoryRefresh is the interval of the full listings reconciling
+	// the inventory with the Identity Store
+	InventoryRefresh time.Duration `mapstructure:"inventory_refresh"`
+	// Heartbeat is the interval at which the progress of a run is logged,
+	// disabled when zero
+	Heartbeat time.Duration `mapstructure:"heartbeat"`
+	// DeferDeletions defers the deletions to a later run confirming them
+	DeferDeletions bool `mapstructure:"defer_deletions"`
+	// DeletionDelay is the minimum delay of the deferred deletions
+	DeletionDelay time.Duration `mapstructure:"deletion_delay"`
+	// RemovalChunkSize is the number of users deleted between two
+	// progress lines of the removal
+	RemovalChunkSize int `mapstructure:"removal_chunk_size"`
+	// RemovalRetries is the number of retries of a user deletion failing
+	// with a transient error
+	RemovalRetries int `mapstructure:"removal_retries"`
+	// RetryFailed retries the operations which failed transiently once
+	// at the end of the run
+	RetryFailed bool `mapstructure:"retry_failed"`
+	// RetryDelay is the delay before the final retry pass
+	RetryDelay time.Duration `mapstructure:"retry_delay"`
+	// Verify re-reads the target at the end of the run and reports the
+	// applied changes it does not reflect
+	Verify bool `mapstructure:"verify"`
+	// RequireApproval defers the deletions until approved with the
+	// approve command
+	RequireApproval bool `mapstructure:"require_approval"`
+	// AnomalyFactor holds back a run planning more changes than the factor
+	// times the average of the previous runs in the state, disabled when zero
+	AnomalyFactor float64 `mapstructure:"anomaly_factor"`
+	// AnomalyMinChanges is the number of changes a run always applies,
+	// regardless of the average
+	AnomalyMinChanges int `mapstructure:"anomaly_min_changes"`
+	// Force applies the changes of an anomalous run
+	Force bool `mapstructure:"force"`
+	// ConfigURI is the s3://bucket/key object or ssm:<parameter name>
+	// SSM parameter of a YAML configuration, overridden by the environment
+	// variables and flags
+	ConfigURI string `mapstructure:"config_uri"`
+	// Profile is the AWS shared config profile used for local runs
+	Profile string `mapstructure:"profile"`
+	// SecretsRegion, SecretsProfile and SecretsRoleArn select the AWS
+	// account and region the secrets are read from, with Secrets Manager
+	// and KMS, e.g. an ops account, the AWS config when empty
+	SecretsRegion  string `mapstructure:"secrets_region"`
+	SecretsProfile string `mapstructure:"secrets_profile"`
+	SecretsRoleArn string `mapstructure:"secrets_role_arn"`
+	// IdentityStoreRegion, IdentityStoreProfile and IdentityStoreRoleArn
+	// select the AWS account and region of the Identity Store and of the
+	// IAM Identity Center instance, e.g. the management account
+	IdentityStoreRegion  string `mapstructure:"identity_store_region"`
+	IdentityStoreProfile string `mapstructure:"identity_store_profile"`
+	IdentityStoreRoleArn string `mapstructure:"identity_store_role_arn"`
 	// AWS Configuration
 	AWSConfig aws.Config
+	// SecretsAWSConfig and IdentityStoreAWSConfig are the AWS configs of
+	// the secrets and of the Identity Store, derived from AWSConfig
+	SecretsAWSConfig       aws.Config
+	IdentityStoreAWSConfig aws.Config
 	// Ignore users ...
 	IgnoreUsers []string `mapstructure:"ignore_users"`
 	// Ignore groups ...
 	IgnoreGroups []string `mapstructure:"ignore_groups"`
+	// IgnoreUsersGroup is the email of a Google group whose user members
+	// are ignored, managed by the Google admins
+	IgnoreUsersGroup string `mapstructure:"ignore_users_group"`
+	// IgnoreGroupsGroup is the email of a Google group whose member
+	// groups, and itself, are ignored
+	IgnoreGroupsGroup string `mapstructure:"ignore_groups_group"`
+	// GroupDescription renders the descriptions of the AWS groups without
+	// a Google description, empty to create them without description
+	GroupDescription string `mapstructure:"group_description"`
+	// Provenance records how the AWS users and groups were synced in
+	// their user types and descriptions
+	Provenance bool `mapstructure:"provenance"`
+	// ManagedOnly restricts the deletions and updates to the AWS users
+	// and groups created by ssosync
+	ManagedOnly bool `mapstructure:"managed_only"`
+	// ExcludeSystemGroups excludes the Google groups which look managed
+	// for the whole organization, e.g. the all-staff target audiences
+	ExcludeSystemGroups bool `mapstructure:"exclude_system_groups"`
+	// SystemGroups are the emails of the Google groups synced although
+	// they look system managed
+	SystemGroups []string `mapstructure:"system_groups"`
 }
 
 const (
@@ -36,18 +396,177 @@ const (
 	DefaultLogLevel = "info"
 	// DefaultLogFormat is the default format of the logger
 	DefaultLogFormat = "text"
+	// DefaultLogRedact is the default redact pattern, matching identity store ids
+	DefaultLogRedact = `d-[0-9a-f]{10}`
 	// DefaultDebug is the default debug status.
 	DefaultDebug = false
 	// DefaultGoogleCredentials is the default credentials path
 	DefaultGoogleCredentials = "credentials.json"
+	// DefaultInterval is the default time between two syncs in daemon mode
+	DefaultInterval = 15 * time.Minute
+	// DefaultAPITimeout is the default maximum duration of an API call
+	DefaultAPITimeout = time.Minute
+	// DefaultDiscoverIdentityStore is the default of the identity store discovery
+	DefaultDiscoverIdentityStore = true
+	// DefaultHealthAddr is the default listen address of the health endpoints
+	DefaultHealthAddr = ":8080"
+	// DefaultGoogleRetries is the default number of retries of a Google
+	// API call
+	DefaultGoogleRetries = 5
+	// DefaultTargetRetries is the default number of retries of a target
+	// API call
+	DefaultTargetRetries = 5
+	// DefaultEmailPolicy is the default policy of the invalid emails
+	DefaultEmailPolicy = "skip"
+	// DefaultGroupNamePolicy is the default policy of the invalid group
+	// names
+	DefaultGroupNamePolicy = "transform"
+	// DefaultGroupKey is the default matching key of the groups
+	DefaultGroupKey = "name"
+	// DefaultExcludeSystemGroups is the default of the system groups
+	// exclusion
+	DefaultExcludeSystemGroups = true
+	// TargetIdentityStore is the target of the AWS Identity Store
+	TargetIdentityStore = "identitystore"
+	// TargetMemory is the in-memory target, e.g. to try a configuration
+	TargetMemory = "memory"
+	// TargetKeycloak is the target of a Keycloak realm
+	TargetKeycloak = "keycloak"
+	// TargetManagedAD is the experimental target of an AWS Managed
+	// Microsoft AD directory
+	TargetManagedAD = "managed-ad"
+	// DefaultLockTTL is the default expiry of the lock of a crashed run
+	DefaultLockTTL = 15 * time.Minute
+	// DefaultInventoryRefresh is the default interval of the full
+	// listings reconciling the inventory
+	DefaultInventoryRefresh = 24 * time.Hour
+	// DefaultRemovalChunkSize is the default number of users deleted
+	// between two progress lines
+	DefaultRemovalChunkSize = 50
+	// DefaultRemovalRetries is the default number of retries of a user
+	// deletion
+	DefaultRemovalRetries = 3
+	// DefaultRetryFailed is the default of the final retry pass
+	DefaultRetryFailed = true
+	// DefaultRetryDelay is the default delay before the final retry pass
+	DefaultRetryDelay = 5 * time.Second
+	// DefaultProvenance is the default of the provenance recording
+	DefaultProvenance = true
+	// DefaultManagedOnly is the default of the restriction to the
+	// entities created by ssosync
+	DefaultManagedOnly = true
+	// DefaultHeartbeat is the default interval of the progress log
+	DefaultHeartbeat = time.Minute
+	// DefaultHookTimeout is the default maximum duration of a hook
+	DefaultHookTimeout = 30 * time.Second
+	// DefaultNotifyOn is the default selection of the notified runs
+	DefaultNotifyOn = "changes"
+	// DefaultAlertAfter is the default number of failed runs triggering an alert
+	DefaultAlertAfter = 3
+	// DefaultAnomalyMinChanges is the default number of changes which are
+	// never anomalous
+	DefaultAnomalyMinChanges = 10
+	// DefaultEvidenceLockMode is the default S3 Object Lock mode of the
+	// evidence records
+	DefaultEvidenceLockMode = "GOVERNANCE"
+	// DefaultWelcomeSubject is the default subject of the welcome message
+	DefaultWelcomeSubject = "Your AWS access"
+	// DefaultSecretsBackend is the default secrets backend
+	DefaultSecretsBackend = "secretsmanager"
+	// DefaultVaultMount is the default mount path of the Vault KV engine
+	DefaultVaultMount = "secret"
+	// DefaultVaultPath is the default path of the ssosync Vault secret
+	DefaultVaultPath = "ssosync"
+	// DefaultVaultAWSMount is the default mount path of the Vault AWS engine
+	DefaultVaultAWSMount = "aws"
 )
 
+// ProxyFor returns the endpoint specific proxy, or the general one
+func (c *Config) ProxyFor(endpoint string) string {
+	if endpoint != "" {
+		return endpoint
+	}
+	return c.Proxy
+}
+
+// IdentityStoreTarget reports whether a target of the sync is the AWS
+// Identity Store
+func (c *Config) IdentityStoreTarget() bool {
+	return c.HasTarget(TargetIdentityStore)
+}
+
+// TargetNames returns the names of the targets of the sync
+func (c *Config) TargetNames() []string {
+	if len(c.Targets) > 0 {
+		return c.Targets
+	}
+	if c.Target == "" {
+		return []string{TargetIdentityStore}
+	}
+	return []string{c.Target}
+}
+
+// ShardOf returns the shard i of n synced by the run, 1 of 1 when Shard
+// is empty
+func (c *Config) ShardOf() (i, n int, err error) {
+	if c.Shard == "" {
+		return 1, 1, nil
+	}
+	if _, err := fmt.Sscanf(c.Shard, "%d/%d", &i, &n); err != nil || n < 1 || i < 1 || i > n || fmt.Sprintf("%d/%d", i, n) != c.Shard {
+		return 0, 0, fmt.Errorf("shard %q is not i/n with i between 1 and n, e.g. 1/4", c.Shard)
+	}
+	return i, n, nil
+}
+
+// HasTarget reports whether name is a target of the sync
+func (c *Config) HasTarget(name string) bool {
+	for _, t := range c.TargetNames() {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
 // New returns a new Config
 func New() *Config {
 	return &Config{
-		Debug:             DefaultDebug,
-		LogLevel:          DefaultLogLevel,
-		LogFormat:         DefaultLogFormat,
-		GoogleCredentials: DefaultGoogleCredentials,
+		Debug:                 DefaultDebug,
+		LogLevel:              DefaultLogLevel,
+		LogFormat:             DefaultLogFormat,
+		LogRedact:             []string{DefaultLogRedact},
+		GoogleCredentials:     DefaultGoogleCredentials,
+		Interval:              DefaultInterval,
+		MemberCache:           true,
+		GoogleTimeout:         DefaultAPITimeout,
+		GoogleRetries:         DefaultGoogleRetries,
+		TargetRetries:         DefaultTargetRetries,
+		AWSTimeout:            DefaultAPITimeout,
+		DiscoverIdentityStore: DefaultDiscoverIdentityStore,
+		HealthAddr:            DefaultHealthAddr,
+		HookTimeout:           DefaultHookTimeout,
+		Heartbeat:             DefaultHeartbeat,
+		EmailPolicy:           DefaultEmailPolicy,
+		GroupNamePolicy:       DefaultGroupNamePolicy,
+		GroupKey:              DefaultGroupKey,
+		ExcludeSystemGroups:   DefaultExcludeSystemGroups,
+		Provenance:            DefaultProvenance,
+		LockTTL:               DefaultLockTTL,
+		InventoryRefresh:      DefaultInventoryRefresh,
+		RemovalChunkSize:      DefaultRemovalChunkSize,
+		RemovalRetries:        DefaultRemovalRetries,
+		RetryFailed:           DefaultRetryFailed,
+		RetryDelay:            DefaultRetryDelay,
+		Target:                TargetIdentityStore,
+		ManagedOnly:           DefaultManagedOnly,
+		NotifyOn:              DefaultNotifyOn,
+		WelcomeSubject:        DefaultWelcomeSubject,
+		EvidenceLockMode:      DefaultEvidenceLockMode,
+		AlertAfter:            DefaultAlertAfter,
+		AnomalyMinChanges:     DefaultAnomalyMinChanges,
+		SecretsBackend:        DefaultSecretsBackend,
+		VaultMount:            DefaultVaultMount,
+		VaultPath:             DefaultVaultPath,
+		VaultAWSMount:         DefaultVaultAWSMount,
 	}
 }
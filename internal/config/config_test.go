@@ -20,3 +20,23 @@ func TestConfig(t *testing.T) {
 	assert.Equal(cfg.Debug, DefaultDebug)
 	assert.Equal(cfg.GoogleCredentials, DefaultGoogleCredentials)
 }
+
+func TestShardOf(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := New()
+	i, n, err := cfg.ShardOf()
+	assert.NoError(err)
+	assert.Equal([]int{1, 1}, []int{i, n})
+
+	cfg.Shard = "3/4"
+	i, n, err = cfg.ShardOf()
+	assert.NoError(err)
+	assert.Equal([]int{3, 4}, []int{i, n})
+
+	for _, s := range []string{"0/4", "5/4", "1/0", "1", "1/4x", "a/b"} {
+		cfg.Shard = s
+		_, _, err = cfg.ShardOf()
+		assert.Error(err, s)
+	}
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+const (
+	// EncryptionKMS is a credentials file encrypted with AWS KMS, either
+	// the binary ciphertext blob or its base64 encoding
+	EncryptionKMS = "kms"
+	// EncryptionAge is a credentials file encrypted with age, binary or
+	// armored, for the recipient of AgeIdentity
+	EncryptionAge = "age"
+)
+
+// ReadGoogleCredentials returns the Google credentials, read from the
+// file unless running in Lambda and decrypted when encrypted, so that
+// the key never needs to be stored in plaintext
+func (c *Config) ReadGoogleCredentials(ctx context.Context) ([]byte, error) {
+	b := []byte(c.GoogleCredentials)
+	if !c.IsLambda && !c.GoogleCredentialsInline {
+		var err error
+		if b, err = ioutil.ReadFile(c.GoogleCredentials); err != nil {
+			return nil, err
+		}
+	}
+
+	switch c.GoogleCredentialsEncryption {
+	case EncryptionKMS:
+		return decryptKMS(ctx, c.SecretsAWSConfig, b)
+	case EncryptionAge:
+		return decryptAge(c.AgeIdentity, b)
+	}
+	return b, nil
+}
+
+// decryptKMS decrypts a KMS ciphertext, the key is part of the blob
+func decryptKMS(ctx context.Context, cfg aws.Config, b []byte) ([]byte, error) {
+	if decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(b))); err == nil {
+		b = decoded
+	}
+	out, err := kms.NewFromConfig(cfg).Decrypt(ctx, &kms.DecryptInput{CiphertextBlob: b})
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt google credentials with KMS: %w", err)
+	}
+	return out.Plaintext, nil
+}
+
+// decryptAge decrypts an age file with the identities of identityFile
+func decryptAge(identityFile string, b []byte) ([]byte, error) {
+	f, err := os.Open(identityFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read age identity: %w", err)
+	}
+	defer f.Close()
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse age identity %s: %w", identityFile, err)
+	}
+
+	var in io.Reader = bytes.NewReader(b)
+	if bytes.HasPrefix(b, []byte(armor.Header)) {
+		in = armor.NewReader(in)
+	}
+	r, err := age.Decrypt(in, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt google credentials with age: %w", err)
+	}
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,43 @@
+package config_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/awslabs/ssosync/internal/config"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadGoogleCredentials(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	identity, err := age.GenerateX25519Identity()
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(filepath.Join(dir, "key.txt"), []byte(identity.String()+"\n"), 0600))
+
+	var buf bytes.Buffer
+	a := armor.NewWriter(&buf)
+	w, err := age.Encrypt(a, identity.Recipient())
+	assert.NoError(err)
+	_, err = w.Write([]byte(`{"type":"service_account"}`))
+	assert.NoError(err)
+	assert.NoError(w.Close())
+	assert.NoError(a.Close())
+	assert.NoError(os.WriteFile(filepath.Join(dir, "credentials.json.age"), buf.Bytes(), 0600))
+
+	cfg := New()
+	cfg.GoogleCredentials = filepath.Join(dir, "credentials.json.age")
+	cfg.GoogleCredentialsEncryption = EncryptionAge
+	cfg.AgeIdentity = filepath.Join(dir, "key.txt")
+
+	b, err := cfg.ReadGoogleCredentials(context.Background())
+	assert.NoError(err)
+	assert.Equal(`{"type":"service_account"}`, string(b))
+}
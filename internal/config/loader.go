@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// nestedKeys maps configuration keys to their nested form. Nested keys are
+// read from environment variables with a double underscore separator,
+// e.g. SSOSYNC_GOOGLE__ADMIN, as produced by Helm charts rendering
+// nested values.
+var nestedKeys = map[string]string{
+	"google_admin":       "google.admin",
+	"google_credentials": "google.credentials",
+	"user_match":         "google.user_match",
+	"group_match":        "google.group_match",
+	"ignore_users":       "google.ignore_users",
+	"ignore_groups":      "google.ignore_groups",
+	"identity_store_id":  "aws.identity_store_id",
+	"log_level":          "log.level",
+	"log_format":         "log.format",
+	"audit_format":       "log.audit_format",
+	"log_redact":         "log.redact",
+}
+
+// pathKeys are configuration keys holding a file path rather than a value,
+// their _FILE variant is used as the path itself
+var pathKeys = map[string]bool{
+	"google_credentials": true,
+}
+
+// queryKeys are configuration keys holding Google queries, which may
+// contain commas, e.g. name:'Doe, John', so that in the environment the
+// queries are separated by newlines rather than by commas
+var queryKeys = map[string]bool{
+	"user_match":          true,
+	"group_match":         true,
+	"user_exclude_match":  true,
+	"group_exclude_match": true,
+}
+
+// Load reads the given keys from the environment into cfg. Every key can be
+// provided as
+//
+//	SSOSYNC_GOOGLE_ADMIN           flat
+//	SSOSYNC_GOOGLE__ADMIN          nested
+//	SSOSYNC_GOOGLE_ADMIN_FILE      path of a file holding the value,
+//	SSOSYNC_GOOGLE__ADMIN_FILE     e.g. a mounted Kubernetes Secret
+//
+// the first one found wins.
+func Load(v *viper.Viper, prefix string, keys []string, cfg *Config) error {
+	v.SetEnvPrefix(prefix)
+	v.AutomaticEnv()
+
+	for _, k := range keys {
+		if err := v.BindEnv(k); err != nil {
+			return fmt.Errorf("cannot bind environment variable: %w", err)
+		}
+
+		if _, ok := os.LookupEnv(envName(prefix, k)); ok {
+			continue
+		}
+
+		val, ok, err := lookup(prefix, k)
+		if err != nil {
+			return err
+		}
+		if ok {
+			v.Set(k, val)
+		}
+	}
+	splitQueries(v)
+
+	if err := v.Unmarshal(cfg); err != nil {
+		return fmt.Errorf("cannot unmarshal config: %w", err)
+	}
+
+	return nil
+}
+
+// lookup returns the value of the key from its nested or file variants
+func lookup(prefix string, key string) (string, bool, error) {
+	names := []string{key}
+	if nested, ok := nestedKeys[key]; ok {
+		if val, ok := os.LookupEnv(envName(prefix, nested)); ok {
+			return val, true, nil
+		}
+		names = append(names, nested)
+	}
+
+	for _, n := range names {
+		path, ok := os.LookupEnv(envName(prefix, n) + "_FILE")
+		if !ok {
+			continue
+		}
+		if pathKeys[key] {
+			return path, true, nil
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("cannot read %s from file: %w", key, err)
+		}
+		return strings.TrimSpace(string(b)), true, nil
+	}
+
+	return "", false, nil
+}
+
+// splitQueries splits the string values of the query keys, e.g. read
+// from the environment, on newlines instead of commas
+func splitQueries(v *viper.Viper) {
+	for k := range queryKeys {
+		s, ok := v.Get(k).(string)
+		if !ok {
+			continue
+		}
+		var queries []string
+		for _, q := range strings.Split(s, "\n") {
+			if q = strings.TrimSpace(q); q != "" {
+				queries = append(queries, q)
+			}
+		}
+		v.Set(k, queries)
+	}
+}
+
+func envName(prefix string, key string) string {
+	return strings.ToUpper(prefix + "_" + strings.ReplaceAll(key, ".", "__"))
+}
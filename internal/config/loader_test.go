@@ -0,0 +1,47 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "github.com/awslabs/ssosync/internal/config"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	admin := filepath.Join(dir, "admin")
+	assert.NoError(ioutil.WriteFile(admin, []byte("admin@example.com\n"), 0600))
+
+	t.Setenv("SSOSYNC_AWS__IDENTITY_STORE_ID", "d-1234567890")
+	t.Setenv("SSOSYNC_GOOGLE_ADMIN_FILE", admin)
+	t.Setenv("SSOSYNC_GOOGLE__CREDENTIALS_FILE", "/secrets/credentials.json")
+	t.Setenv("SSOSYNC_LOG_LEVEL", "debug")
+	t.Setenv("SSOSYNC_LOG__LEVEL", "trace")
+
+	cfg := New()
+	err := Load(viper.New(), "ssosync", []string{"google_admin", "google_credentials", "identity_store_id", "log_level"}, cfg)
+	assert.NoError(err)
+
+	assert.Equal("d-1234567890", cfg.IdentityStoreId)
+	assert.Equal("admin@example.com", cfg.GoogleAdmin)
+	assert.Equal("/secrets/credentials.json", cfg.GoogleCredentials)
+	assert.Equal("debug", cfg.LogLevel)
+}
+
+func TestLoadQueries(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("SSOSYNC_USER_MATCH", "name:'Doe, John'\norgUnitPath=/Engineering")
+	t.Setenv("SSOSYNC_GROUP_MATCH", "name:'Ops, Platform'")
+
+	cfg := New()
+	assert.NoError(Load(viper.New(), "ssosync", []string{"user_match", "group_match"}, cfg))
+	assert.Equal([]string{"name:'Doe, John'", "orgUnitPath=/Engineering"}, cfg.UserMatch)
+	assert.Equal([]string{"name:'Ops, Platform'"}, cfg.GroupMatch)
+}
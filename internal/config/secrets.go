@@ -3,14 +3,26 @@ package config
 import (
 	"context"
 	"encoding/base64"
+	"sync/atomic"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 )
 
+// secretReads is the number of Secrets Manager calls since the last
+// TakeSecretReads
+var secretReads int64
+
+// TakeSecretReads returns the number of Secrets Manager calls since the
+// previous call, e.g. to estimate the cost of a run
+func TakeSecretReads() int {
+	return int(atomic.SwapInt64(&secretReads, 0))
+}
+
 // Secrets ...
 type Secrets struct {
-	svc *secretsmanager.Client
+	svc   *secretsmanager.Client
+	vault *Vault
 }
 
 // NewSecrets ...
@@ -20,6 +32,14 @@ func NewSecrets(svc *secretsmanager.Client) *Secrets {
 	}
 }
 
+// NewVaultSecrets returns the Secrets read from Vault instead of
+// Secrets Manager, the secret names are the keys of the Vault secret
+func NewVaultSecrets(vault *Vault) *Secrets {
+	return &Secrets{
+		vault: vault,
+	}
+}
+
 // GoogleAdminEmail ...
 func (s *Secrets) GoogleAdminEmail() (string, error) {
 	return s.getSecret("SSOSyncGoogleAdminEmail")
@@ -30,7 +50,17 @@ func (s *Secrets) GoogleCredentials() (string, error) {
 	return s.getSecret("SSOSyncGoogleCredentials")
 }
 
+// Secret returns the value of the named secret
+func (s *Secrets) Secret(name string) (string, error) {
+	return s.getSecret(name)
+}
+
 func (s *Secrets) getSecret(secretKey string) (string, error) {
+	if s.vault != nil {
+		return s.vault.Secret(secretKey)
+	}
+
+	atomic.AddInt64(&secretReads, 1)
 	r, err := s.svc.GetSecretValue(
 		context.TODO(),
 		&secretsmanager.GetSecretValueInput{
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/spf13/viper"
+)
+
+// ReadURI returns the YAML configuration stored at the uri, an
+// s3://bucket/key object or an SSM parameter, ssm:<parameter name>,
+// e.g. ssm:/ssosync/config, which may be a SecureString
+func ReadURI(ctx context.Context, cfg aws.Config, uri string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(uri, "ssm:"):
+		name := strings.TrimPrefix(uri, "ssm:")
+		if name == "" {
+			return nil, fmt.Errorf("invalid config uri %q, expected ssm:<parameter name>", uri)
+		}
+		out, err := ssm.NewFromConfig(cfg).GetParameter(ctx, &ssm.GetParameterInput{
+			Name:           aws.String(name),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot read config %s: %w", uri, err)
+		}
+		return []byte(aws.ToString(out.Parameter.Value)), nil
+	case strings.HasPrefix(uri, "s3://"):
+		u, err := url.Parse(uri)
+		if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			return nil, fmt.Errorf("invalid config uri %q, expected s3://bucket/key", uri)
+		}
+		out, err := s3.NewFromConfig(cfg).GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(u.Host),
+			Key:    aws.String(strings.TrimPrefix(u.Path, "/")),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot read config %s: %w", uri, err)
+		}
+		defer out.Body.Close()
+		return ioutil.ReadAll(out.Body)
+	default:
+		return nil, fmt.Errorf("invalid config uri %q, expected s3://bucket/key or ssm:<parameter name>", uri)
+	}
+}
+
+// Merge sets the keys of the YAML configuration b in cfg, with the flat
+// keys of the environment variables, e.g. ignore_users, or their nested
+// form, e.g. google: {admin: ...}. The keys set by an environment
+// variable, in any of the forms read by Load, or by a flag according to
+// changed keep their value. Unknown keys are an error, to catch typos.
+func Merge(v *viper.Viper, prefix string, b []byte, changed func(key string) bool, cfg *Config) error {
+	fv := viper.New()
+	fv.SetConfigType("yaml")
+	if err := fv.ReadConfig(bytes.NewReader(b)); err != nil {
+		return fmt.Errorf("cannot parse config: %w", err)
+	}
+
+	flat := make(map[string]string, len(nestedKeys))
+	for k, nested := range nestedKeys {
+		flat[nested] = k
+	}
+	known := configKeys()
+
+	var unknown []string
+	for _, k := range fv.AllKeys() {
+		key := k
+		if f, ok := flat[k]; ok {
+			key = f
+		}
+		if !known[key] {
+			unknown = append(unknown, k)
+			continue
+		}
+		if _, ok := os.LookupEnv(envName(prefix, key)); ok {
+			continue
+		}
+		if _, ok, _ := lookup(prefix, key); ok || changed(key) {
+			continue
+		}
+		v.Set(key, fv.Get(k))
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown config keys: %s", strings.Join(unknown, ", "))
+	}
+	splitQueries(v)
+
+	if err := v.Unmarshal(cfg); err != nil {
+		return fmt.Errorf("cannot unmarshal config: %w", err)
+	}
+	return nil
+}
+
+// configKeys returns the keys of the configuration, the mapstructure
+// tags of Config
+func configKeys() map[string]bool {
+	res := make(map[string]bool)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("mapstructure"); tag != "" {
+			res[tag] = true
+		}
+	}
+	return res
+}
@@ -0,0 +1,48 @@
+package config_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	. "github.com/awslabs/ssosync/internal/config"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("SSOSYNC_LOG_LEVEL", "debug")
+
+	file := []byte(`
+identity_store_id: d-1234567890
+log_level: trace
+user_match: ["email:aws-*"]
+retry_delay: 30s
+google:
+  ignore_users:
+    - contractor1@example.com
+    - contractor2@example.com
+`)
+	cfg := New()
+	v := viper.New()
+	assert.NoError(Load(v, "ssosync", []string{"identity_store_id", "log_level", "user_match", "ignore_users", "retry_delay"}, cfg))
+	cfg.UserMatch = []string{"email:admin*"}
+	changed := func(key string) bool { return key == "user_match" }
+	assert.NoError(Merge(v, "ssosync", file, changed, cfg))
+
+	assert.Equal("d-1234567890", cfg.IdentityStoreId)
+	assert.Equal("debug", cfg.LogLevel)
+	assert.Equal([]string{"email:admin*"}, cfg.UserMatch)
+	assert.Equal([]string{"contractor1@example.com", "contractor2@example.com"}, cfg.IgnoreUsers)
+	assert.Equal(30*time.Second, cfg.RetryDelay)
+
+	err := Merge(viper.New(), "ssosync", []byte("ignore_user: [a@example.com]\n"), changed, New())
+	assert.ErrorContains(err, "unknown config keys: ignore_user")
+
+	_, err = ReadURI(context.Background(), aws.Config{}, "https://example.com/config.yaml")
+	assert.ErrorContains(err, "expected s3://bucket/key or ssm:<parameter name>")
+}
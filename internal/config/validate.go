@@ -0,0 +1,443 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/mail"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/awslabs/ssosync/internal/freeze"
+	"github.com/awslabs/ssosync/internal/google"
+	"github.com/awslabs/ssosync/internal/inventory"
+	"github.com/awslabs/ssosync/internal/lock"
+	"github.com/awslabs/ssosync/internal/logging"
+	"github.com/awslabs/ssosync/internal/pause"
+	"github.com/awslabs/ssosync/internal/transport"
+	"github.com/awslabs/ssosync/internal/username"
+	log "github.com/sirupsen/logrus"
+)
+
+// identityStoreIdPattern is the format of identity store ids
+var identityStoreIdPattern = regexp.MustCompile(`^d-[0-9a-f]{10}$`)
+
+// roleArnPattern matches the ARNs of IAM roles, of any partition
+var roleArnPattern = regexp.MustCompile(`^arn:aws[a-z-]*:iam::[0-9]{12}:role/.+$`)
+
+// ValidationError lists all the problems found in a Config
+type ValidationError struct {
+	Problems []string
+}
+
+// Error implements error
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration:\n  - %s", strings.Join(e.Problems, "\n  - "))
+}
+
+// Validate checks the configuration before any API is called and returns
+// a *ValidationError listing every problem found, or nil
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	switch {
+	case !c.IdentityStoreTarget():
+	case c.IdentityStoreId == "" && !c.DiscoverIdentityStore:
+		add("identity store id is required when the discovery is disabled")
+	case strings.HasPrefix(c.IdentityStoreId, "arn:") && !c.DiscoverIdentityStore:
+		add("identity store id %q is an instance ARN, use the d-xxxxxxxxxx identity store id or enable the discovery", c.IdentityStoreId)
+	case c.IdentityStoreId != "" && !strings.HasPrefix(c.IdentityStoreId, "arn:") && !identityStoreIdPattern.MatchString(c.IdentityStoreId):
+		add("identity store id %q does not match the format d-xxxxxxxxxx", c.IdentityStoreId)
+	}
+
+	if c.HasTarget(TargetKeycloak) {
+		if c.KeycloakURL == "" || c.KeycloakRealm == "" || c.KeycloakClientID == "" {
+			add("keycloak url, realm and client id are required by the keycloak target")
+		} else if u, err := url.Parse(c.KeycloakURL); err != nil || u.Host == "" {
+			add("keycloak url %q is not a valid URL", c.KeycloakURL)
+		}
+	}
+
+	if c.HasTarget(TargetManagedAD) && !identityStoreIdPattern.MatchString(c.DirectoryId) {
+		add("directory id %q of the managed-ad target does not match the format d-xxxxxxxxxx", c.DirectoryId)
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range c.Targets {
+		if seen[t] {
+			add("target %q is listed twice", t)
+		}
+		seen[t] = true
+	}
+
+	if c.GoogleAdmin == "" {
+		add("google admin email is required")
+	} else if _, err := mail.ParseAddress(c.GoogleAdmin); err != nil {
+		add("google admin %q is not a valid email address", c.GoogleAdmin)
+	}
+
+	if problem := c.validateCredentials(); problem != "" {
+		add(problem)
+	}
+	for _, s := range c.GoogleScopes {
+		if strings.Contains(s, "://") && !strings.HasPrefix(s, "https://www.googleapis.com/auth/") {
+			add("google scope %q is not a Google OAuth scope", s)
+		}
+	}
+
+	for _, l := range c.GroupLabels {
+		if _, err := google.ExpandLabel(l); err != nil {
+			add(err.Error())
+		}
+	}
+	for _, l := range c.Licenses {
+		if _, err := google.ParseLicense(l); err != nil {
+			add(err.Error())
+		}
+	}
+
+	if _, err := log.ParseLevel(c.LogLevel); err != nil && c.LogLevel != logging.LevelChange {
+		add("log level %q is not one of panic, fatal, error, warn, change, info, debug, trace", c.LogLevel)
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		add("log format %q is not one of text, json", c.LogFormat)
+	}
+	switch c.AuditFormat {
+	case "", logging.AuditJSON, logging.AuditOCSF, logging.AuditCEF:
+	default:
+		add("audit format %q is not one of json, ocsf, cef", c.AuditFormat)
+	}
+	for _, p := range c.LogRedact {
+		if _, err := regexp.Compile(p); err != nil {
+			add("log redact pattern %q is not a valid regular expression: %s", p, err)
+		}
+	}
+
+	for _, p := range c.UnmanagedMembershipGroups {
+		if _, err := path.Match(p, ""); err != nil {
+			add("unmanaged membership group pattern %q is invalid: %s", p, err)
+		}
+	}
+	for _, p := range c.PrivilegedGroups {
+		if _, err := path.Match(p, ""); err != nil {
+			add("privileged group pattern %q is invalid: %s", p, err)
+		}
+	}
+	for _, p := range append(append([]string{}, c.ProtectedUsers...), c.ProtectedGroups...) {
+		if _, err := path.Match(p, ""); err != nil {
+			add("protected pattern %q is invalid: %s", p, err)
+		}
+	}
+
+	if _, err := username.New(c.UserNameTemplate); err != nil {
+		add(err.Error())
+	}
+	if tmpl, err := template.New("description").Option("missingkey=error").Parse(c.GroupDescription); err != nil {
+		add("group description template is invalid: %s", err)
+	} else if err := tmpl.Execute(ioutil.Discard, struct{ Name, Email, Id string }{}); err != nil {
+		add("group description template is invalid: %s", err)
+	}
+
+	switch c.UserNameCollision {
+	case "", username.CollisionFail, username.CollisionSkip, username.CollisionSuffix:
+	default:
+		add("user name collision policy %q is not one of fail, skip, suffix", c.UserNameCollision)
+	}
+
+	switch c.GroupNamePolicy {
+	case "", "transform", "skip":
+	default:
+		add("group name policy %q is not one of transform, skip", c.GroupNamePolicy)
+	}
+	switch c.GroupKey {
+	case "", "name", "email":
+	default:
+		add("group key %q is not one of name, email", c.GroupKey)
+	}
+	if c.GroupNameMaxLength != 0 && (c.GroupNameMaxLength < 16 || c.GroupNameMaxLength > 1024) {
+		add("group name max length %d is not between 16 and 1024", c.GroupNameMaxLength)
+	}
+
+	switch c.EmailPolicy {
+	case "", "skip", "truncate", "alias":
+	default:
+		add("email policy %q is not one of skip, truncate, alias", c.EmailPolicy)
+	}
+
+	if c.Timeout < 0 || c.GoogleTimeout < 0 || c.AWSTimeout < 0 || c.HookTimeout < 0 {
+		add("timeouts must not be negative")
+	}
+	if c.ManagedOnly && !c.Provenance {
+		add("managed only requires the provenance, which tells the entities created by ssosync, disable it with --managed-only=false")
+	}
+	if c.GroupMemberLimit < 0 {
+		add("group member limit must not be negative, got %d", c.GroupMemberLimit)
+	}
+	if c.OverflowGroups && c.GroupMemberLimit == 0 {
+		add("overflow groups require a group member limit")
+	}
+	if c.GoogleRetries < 0 {
+		add("google retries must not be negative, got %d", c.GoogleRetries)
+	}
+	if c.TargetRetries < 0 {
+		add("target retries must not be negative, got %d", c.TargetRetries)
+	}
+	if c.Heartbeat < 0 {
+		add("heartbeat must not be negative, got %s", c.Heartbeat)
+	}
+	if c.MaxRuntime < 0 {
+		add("max runtime must not be negative, got %s", c.MaxRuntime)
+	}
+	if _, _, err := c.ShardOf(); err != nil {
+		add("%s", err)
+	}
+
+	for _, p := range []string{c.Proxy, c.GoogleProxy, c.AWSProxy} {
+		if p == "" || p == transport.ProxyDirect {
+			continue
+		}
+		if _, err := transport.ParseProxy(p); err != nil {
+			add(err.Error())
+		}
+	}
+
+	for _, e := range []string{c.IdentityStoreEndpoint, c.SecretsManagerEndpoint, c.SSOAdminEndpoint} {
+		if e == "" {
+			continue
+		}
+		if u, err := url.Parse(e); err != nil || u.Scheme != "https" || u.Host == "" {
+			add("endpoint %q is not a valid https URL", e)
+		}
+	}
+	for _, r := range []string{c.SecretsRoleArn, c.IdentityStoreRoleArn} {
+		if r != "" && !roleArnPattern.MatchString(r) {
+			add("role %q is not an IAM role ARN", r)
+		}
+	}
+
+	if c.HookWebhook != "" {
+		if u, err := url.Parse(c.HookWebhook); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			add("hook webhook %q is not a valid http or https URL", c.HookWebhook)
+		}
+	}
+
+	for name, w := range map[string]string{"slack": c.SlackWebhook, "teams": c.TeamsWebhook, "notify": c.NotifyWebhook} {
+		if w == "" {
+			continue
+		}
+		if u, err := url.Parse(w); err != nil || u.Scheme != "https" || u.Host == "" {
+			add("%s webhook is not a valid https URL", name)
+		}
+	}
+	// parsing only checks that the functions exist, not what they do
+	if _, err := template.New("webhook").Funcs(template.FuncMap{"json": fmt.Sprint}).Parse(c.NotifyWebhookTemplate); err != nil {
+		add("notify webhook template is invalid: %s", err)
+	}
+	if len(c.EmailTo) > 0 && c.EmailFrom == "" {
+		add("email from is required to send emails")
+	}
+	for _, a := range append([]string{c.EmailFrom}, c.EmailTo...) {
+		if a == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(a); err != nil {
+			add("email address %q is invalid", a)
+		}
+	}
+	if c.EventStream != "" {
+		if u, err := url.Parse(c.EventStream); err != nil || (u.Scheme != "eventbridge" && u.Scheme != "kinesis") || u.Host == "" {
+			add("event stream %q is not eventbridge://<bus> or kinesis://<stream>", c.EventStream)
+		}
+	}
+	if c.Evidence != "" {
+		if u, err := url.Parse(c.Evidence); err != nil || u.Scheme != "s3" || u.Host == "" {
+			add("evidence %q is not a valid s3://bucket/prefix location", c.Evidence)
+		}
+	}
+	if c.EvidenceRetentionDays < 0 {
+		add("evidence retention must not be negative, got %d", c.EvidenceRetentionDays)
+	}
+	switch c.EvidenceLockMode {
+	case "GOVERNANCE", "COMPLIANCE":
+	default:
+		add("evidence lock mode %q is not one of GOVERNANCE, COMPLIANCE", c.EvidenceLockMode)
+	}
+	if c.WelcomeEmailFrom != "" {
+		if _, err := mail.ParseAddress(c.WelcomeEmailFrom); err != nil {
+			add("welcome email from %q is invalid", c.WelcomeEmailFrom)
+		}
+	}
+	if c.WelcomeTemplate != "" {
+		if b, err := ioutil.ReadFile(c.WelcomeTemplate); err != nil {
+			add("cannot read welcome template: %s", err)
+		} else if _, err := template.New("welcome").Parse(string(b)); err != nil {
+			add("welcome template is invalid: %s", err)
+		}
+	}
+	for name, u := range map[string]string{"welcome queue": c.WelcomeQueue, "welcome webhook": c.WelcomeWebhook, "welcome portal": c.WelcomePortalURL} {
+		if u == "" {
+			continue
+		}
+		if u, err := url.Parse(u); err != nil || u.Scheme != "https" || u.Host == "" {
+			add("%s URL is not a valid https URL", name)
+		}
+	}
+	switch c.NotifyOn {
+	case "", "always", "changes", "errors":
+	default:
+		add("notify on %q is not one of always, changes, errors", c.NotifyOn)
+	}
+
+	if c.AlertAfter < 1 {
+		add("alert after must be at least 1, got %d", c.AlertAfter)
+	}
+	if strings.HasPrefix(c.State, "s3://") {
+		if u, err := url.Parse(c.State); err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			add("state %q is not a valid s3://bucket/key location", c.State)
+		}
+	}
+
+	switch c.SecretsBackend {
+	case "", DefaultSecretsBackend, SecretsVault:
+	default:
+		add("secrets backend %q is not one of secretsmanager, vault", c.SecretsBackend)
+	}
+
+	if c.DriftThreshold < 0 {
+		add("drift threshold must not be negative, got %d", c.DriftThreshold)
+	}
+	if c.DriftTopic != "" && !strings.HasPrefix(c.DriftTopic, "arn:") {
+		add("drift topic %q is not an SNS topic ARN", c.DriftTopic)
+	}
+
+	for _, w := range c.FreezeWindows {
+		if _, err := freeze.ParseWindow(w); err != nil {
+			add(err.Error())
+		}
+	}
+
+	if c.PauseFlag != "" {
+		if _, err := pause.Parse(c.PauseFlag); err != nil {
+			add(err.Error())
+		}
+	}
+
+	if c.Lock != "" {
+		if _, err := lock.Parse(c.Lock); err != nil {
+			add(err.Error())
+		}
+	}
+	if c.LockTTL <= 0 {
+		add("lock ttl must be positive, got %s", c.LockTTL)
+	}
+	if c.Inventory != "" {
+		if _, err := inventory.Parse(c.Inventory); err != nil {
+			add(err.Error())
+		}
+		if c.InventoryRefresh <= 0 {
+			add("inventory refresh must be positive, got %s", c.InventoryRefresh)
+		}
+	}
+
+	if c.DeletionDelay < 0 {
+		add("deletion delay must not be negative, got %s", c.DeletionDelay)
+	}
+	if c.RemovalChunkSize <= 0 {
+		add("removal chunk size must be positive, got %d", c.RemovalChunkSize)
+	}
+	if c.RemovalRetries < 0 {
+		add("removal retries must not be negative, got %d", c.RemovalRetries)
+	}
+	if c.RetryDelay < 0 {
+		add("retry delay must not be negative, got %s", c.RetryDelay)
+	}
+	if (c.DeferDeletions || c.RequireApproval) && c.IsLambda && c.State == "" {
+		add("state is required to defer the deletions in AWS Lambda")
+	}
+
+	if c.AnomalyFactor < 0 || c.AnomalyFactor > 0 && c.AnomalyFactor <= 1 {
+		add("anomaly factor must be greater than 1, got %g", c.AnomalyFactor)
+	}
+	if c.AnomalyMinChanges < 0 {
+		add("anomaly min changes must not be negative, got %d", c.AnomalyMinChanges)
+	}
+
+	if c.Daemon && c.IsLambda {
+		add("daemon mode cannot be used in AWS Lambda")
+	}
+	if c.Daemon && c.Interval <= 0 {
+		add("interval must be positive in daemon mode, got %s", c.Interval)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateCredentials checks that the Google credentials are a service
+// account key or a list of keys, in Lambda they hold the content,
+// otherwise the file path
+func (c *Config) validateCredentials() string {
+	b := []byte(c.GoogleCredentials)
+	if !c.IsLambda && !c.GoogleCredentialsInline {
+		var err error
+		if b, err = ioutil.ReadFile(c.GoogleCredentials); err != nil {
+			return fmt.Sprintf("cannot read google credentials: %s", err)
+		}
+	}
+
+	// encrypted credentials are checked once decrypted, at startup
+	switch c.GoogleCredentialsEncryption {
+	case "":
+	case EncryptionKMS:
+		return ""
+	case EncryptionAge:
+		if c.AgeIdentity == "" {
+			return "age identity is required to decrypt the google credentials"
+		}
+		return ""
+	default:
+		return fmt.Sprintf("google credentials encryption %q is not one of kms, age", c.GoogleCredentialsEncryption)
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+		return validateKey(b)
+	}
+	var keys []json.RawMessage
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return fmt.Sprintf("google credentials are not valid JSON: %s", err)
+	}
+	if len(keys) == 0 {
+		return "google credentials are an empty list of keys"
+	}
+	for i, k := range keys {
+		if problem := validateKey(k); problem != "" {
+			return fmt.Sprintf("key %d: %s", i+1, problem)
+		}
+	}
+	return ""
+}
+
+// validateKey checks that b is a service account key
+func validateKey(b []byte) string {
+	var key struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+	}
+	if err := json.Unmarshal(b, &key); err != nil {
+		return fmt.Sprintf("google credentials are not valid JSON: %s", err)
+	}
+	if key.Type != "service_account" || key.ClientEmail == "" || key.PrivateKey == "" {
+		return "google credentials are not a service account key, expected type, client_email and private_key"
+	}
+	return ""
+}
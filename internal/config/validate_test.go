@@ -0,0 +1,34 @@
+package config_test
+
+import (
+	"testing"
+
+	. "github.com/awslabs/ssosync/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := New()
+	cfg.IsLambda = true
+	cfg.GoogleCredentials = `{"type":"service_account","client_email":"sync@project.iam.gserviceaccount.com","private_key":"key"}`
+	cfg.GoogleAdmin = "admin@example.com"
+	cfg.IdentityStoreId = "d-1234567890"
+	assert.NoError(cfg.Validate())
+
+	cfg.GoogleCredentials = "[" + cfg.GoogleCredentials + "," + cfg.GoogleCredentials + "]"
+	assert.NoError(cfg.Validate())
+
+	cfg.GoogleCredentials = "{"
+	cfg.GoogleAdmin = "admin"
+	cfg.IdentityStoreId = "ssoins-1234567890"
+	cfg.LogFormat = "xml"
+	cfg.Daemon = true
+	cfg.SecretsRoleArn = "ops"
+
+	err := cfg.Validate()
+	assert.IsType(&ValidationError{}, err)
+	assert.Len(err.(*ValidationError).Problems, 6)
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// SecretsVault selects HashiCorp Vault as secrets backend
+const SecretsVault = "vault"
+
+// ErrSecretNotFound is returned for a secret missing from Vault
+var ErrSecretNotFound = errors.New("secret not found")
+
+// Vault reads the secrets from the KV version 2 secrets engine of a
+// HashiCorp Vault, and AWS credentials from its AWS secrets engine
+type Vault struct {
+	addr  string
+	token string
+	mount string
+	path  string
+	hc    *http.Client
+}
+
+// NewVault returns a Vault reading the secrets below path of the KV
+// engine at mount, the token defaults to VAULT_TOKEN and ~/.vault-token
+func NewVault(addr, token, mount, path string, hc *http.Client) (*Vault, error) {
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if addr == "" {
+		return nil, errors.New("vault address is not configured, set --vault-addr or VAULT_ADDR")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if token == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			b, _ := ioutil.ReadFile(filepath.Join(home, ".vault-token"))
+			token = strings.TrimSpace(string(b))
+		}
+	}
+	if token == "" {
+		return nil, errors.New("vault token is not configured, set --vault-token, VAULT_TOKEN or ~/.vault-token")
+	}
+
+	return &Vault{
+		addr:  strings.TrimSuffix(addr, "/"),
+		token: token,
+		mount: strings.Trim(mount, "/"),
+		path:  strings.Trim(path, "/"),
+		hc:    hc,
+	}, nil
+}
+
+// Secret returns the value of the key name of the KV secret at the path
+// of the Vault. A name of the form path#key reads key of another secret.
+func (v *Vault) Secret(name string) (string, error) {
+	path, key := v.path, name
+	if i := strings.LastIndex(name, "#"); i >= 0 {
+		path, key = strings.Trim(name[:i], "/"), name[i+1:]
+	}
+
+	var res struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := v.get(context.TODO(), v.mount+"/data/"+path, &res); err != nil {
+		return "", fmt.Errorf("cannot read vault secret %s: %w", path, err)
+	}
+	value, ok := res.Data.Data[key]
+	if !ok {
+		return "", fmt.Errorf("key %s of vault secret %s: %w", key, path, ErrSecretNotFound)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	// e.g. a credentials file stored as JSON object
+	b, err := json.Marshal(value)
+	return string(b), err
+}
+
+// AWSCredentials returns the provider of the credentials generated by
+// the AWS secrets engine at mount for role, which should be cached
+// with aws.NewCredentialsCache
+func (v *Vault) AWSCredentials(mount, role string) aws.CredentialsProvider {
+	return aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		var res struct {
+			LeaseDuration int `json:"lease_duration"`
+			Data          struct {
+				AccessKey     string `json:"access_key"`
+				SecretKey     string `json:"secret_key"`
+				SecurityToken string `json:"security_token"`
+			} `json:"data"`
+		}
+		if err := v.get(ctx, strings.Trim(mount, "/")+"/creds/"+role, &res); err != nil {
+			return aws.Credentials{}, fmt.Errorf("cannot get AWS credentials of vault role %s: %w", role, err)
+		}
+
+		creds := aws.Credentials{
+			AccessKeyID:     res.Data.AccessKey,
+			SecretAccessKey: res.Data.SecretKey,
+			SessionToken:    res.Data.SecurityToken,
+			Source:          "Vault",
+		}
+		if res.LeaseDuration > 0 {
+			creds.CanExpire = true
+			creds.Expires = time.Now().Add(time.Duration(res.LeaseDuration) * time.Second)
+		}
+		return creds, nil
+	})
+}
+
+// get reads the Vault API path into res
+func (v *Vault) get(ctx context.Context, path string, res interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.addr+"/v1/"+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	resp, err := v.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrSecretNotFound
+	case resp.StatusCode != http.StatusOK:
+		var e struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(e.Errors, ", "))
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
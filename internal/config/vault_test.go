@@ -0,0 +1,56 @@
+package config_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/awslabs/ssosync/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVault(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/ssosync":
+			_, _ = w.Write([]byte(`{"data":{"data":{"SSOSyncGoogleAdminEmail":"admin@example.com","SSOSyncGoogleCredentials":{"type":"service_account"}}}}`))
+		case "/v1/aws/creds/ssosync":
+			_, _ = w.Write([]byte(`{"lease_duration":900,"data":{"access_key":"AKIA","secret_key":"secret","security_token":"session"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	vault, err := NewVault(srv.URL, "token", DefaultVaultMount, DefaultVaultPath, srv.Client())
+	assert.NoError(err)
+	secrets := NewVaultSecrets(vault)
+
+	admin, err := secrets.GoogleAdminEmail()
+	assert.NoError(err)
+	assert.Equal("admin@example.com", admin)
+
+	creds, err := secrets.GoogleCredentials()
+	assert.NoError(err)
+	assert.JSONEq(`{"type":"service_account"}`, creds)
+
+	_, err = secrets.Secret("slack")
+	assert.True(errors.Is(err, ErrSecretNotFound))
+	_, err = secrets.Secret("other#slack")
+	assert.True(errors.Is(err, ErrSecretNotFound))
+
+	aws, err := vault.AWSCredentials(DefaultVaultAWSMount, "ssosync").Retrieve(context.Background())
+	assert.NoError(err)
+	assert.Equal("AKIA", aws.AccessKeyID)
+	assert.Equal("session", aws.SessionToken)
+	assert.True(aws.CanExpire)
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package drift reports the changes found by an audit run, giving early
+// warning between the runs applying them
+package drift
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/notify"
+	"github.com/awslabs/ssosync/pkg/report"
+	log "github.com/sirupsen/logrus"
+)
+
+// timeout is the maximum duration of reporting the drift
+const timeout = 30 * time.Second
+
+// MetricName is the CloudWatch metric of the number of changes pending
+const MetricName = "Drift"
+
+// Check publishes the drift of the audit run r as CloudWatch metric and,
+// when it exceeds cfg.DriftThreshold, to the SNS topic. Errors are
+// logged but never fail the run.
+func Check(cfg *config.Config, r *report.Report) {
+	if r.Result == report.ResultError {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	drift := r.Changes()
+	ll := log.WithField("drift", drift).WithField("threshold", cfg.DriftThreshold)
+
+	if cfg.DriftMetricNamespace != "" {
+		if err := putMetric(ctx, cfg, drift); err != nil {
+			ll.WithError(err).Error("cannot publish drift metric")
+		}
+	}
+
+	if drift <= cfg.DriftThreshold {
+		ll.Info("Drift within threshold")
+		return
+	}
+	ll.Warn("Drift above threshold")
+	if cfg.DriftTopic == "" {
+		return
+	}
+	_, err := sns.NewFromConfig(cfg.AWSConfig).Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(cfg.DriftTopic),
+		Subject:  aws.String(fmt.Sprintf("ssosync drift of %d changes in %s", drift, cfg.IdentityStoreId)),
+		Message:  aws.String(messageOf(r, cfg.DriftThreshold)),
+	})
+	if err != nil {
+		ll.WithError(err).Error("cannot publish drift to SNS")
+	}
+}
+
+func putMetric(ctx context.Context, cfg *config.Config, drift int) error {
+	_, err := cloudwatch.NewFromConfig(cfg.AWSConfig).PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+		Namespace: aws.String(cfg.DriftMetricNamespace),
+		MetricData: []cwtypes.MetricDatum{
+			{
+				MetricName: aws.String(MetricName),
+				Dimensions: []cwtypes.Dimension{
+					{Name: aws.String("IdentityStoreId"), Value: aws.String(cfg.IdentityStoreId)},
+				},
+				Unit:  cwtypes.StandardUnitCount,
+				Value: aws.Float64(float64(drift)),
+			},
+		},
+	})
+	return err
+}
+
+// messageOf formats the drift as plain text
+func messageOf(r *report.Report, threshold int) string {
+	msg := fmt.Sprintf("The audit run found %d changes pending, above the threshold of %d.\n\n", r.Changes(), threshold)
+	for _, f := range notify.Fields(r) {
+		msg += fmt.Sprintf("%-20s %s\n", f.Title+":", f.Value)
+	}
+	for _, gs := range r.GroupSettings() {
+		if gs.Privileged && len(gs.Risks) > 0 {
+			msg += fmt.Sprintf("\nPrivileged group %s (%s) has risky Google settings: %s", gs.Group, gs.Email, strings.Join(gs.Risks, ", "))
+		}
+	}
+	return msg + "\n" + r.String() + "\n"
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package emf writes the summary of a run in the CloudWatch Embedded
+// Metric Format, so that the metrics are extracted from the logs without
+// any API call nor permission, e.g. in AWS Lambda
+package emf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/awslabs/ssosync/pkg/report"
+)
+
+// Dimension is the dimension of the metrics
+const Dimension = "IdentityStoreId"
+
+// metric is the definition of a metric in the EMF metadata
+type metric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+// directive tells CloudWatch which members of the record are metrics
+type directive struct {
+	Namespace  string     `json:"Namespace"`
+	Dimensions [][]string `json:"Dimensions"`
+	Metrics    []metric   `json:"Metrics"`
+}
+
+// metadata is the _aws member of an EMF record
+type metadata struct {
+	Timestamp         int64       `json:"Timestamp"`
+	CloudWatchMetrics []directive `json:"CloudWatchMetrics"`
+}
+
+// Record returns the EMF record of the finished run r, the counters of
+// the report and the composition of the Google directory are metrics,
+// the result and dry run flag properties
+func Record(r *report.Report, namespace, identityStoreId string) map[string]interface{} {
+	s := r.Summary()
+	failed := 0
+	if s.Status == report.ResultError {
+		failed = 1
+	}
+	values := []struct {
+		name  string
+		unit  string
+		value interface{}
+	}{
+		{"UsersCreated", "Count", s.UsersCreated},
+		{"UsersDeleted", "Count", s.UsersDeleted},
+		{"GroupsCreated", "Count", s.GroupsCreated},
+		{"GroupsDeleted", "Count", s.GroupsDeleted},
+		{"MembershipsAdded", "Count", s.MembershipsAdded},
+		{"MembershipsRemoved", "Count", s.MembershipsRemoved},
+		{"Changes", "Count", r.Changes()},
+		{"Errors", "Count", s.Errors},
+		{"Deferred", "Count", s.Deferred},
+		{"Failed", "Count", failed},
+		{"Duration", "Milliseconds", r.Duration.Milliseconds()},
+		{"GoogleUsers", "Count", s.Directory.Users},
+		{"GoogleSuspendedUsers", "Count", s.Directory.Suspended},
+		{"GoogleArchivedUsers", "Count", s.Directory.Archived},
+		{"GoogleGroups", "Count", s.Directory.Groups},
+		{"GoogleExternalMembers", "Count", s.Directory.ExternalMembers},
+	}
+
+	rec := map[string]interface{}{
+		Dimension: identityStoreId,
+		"Result":  s.Status,
+		"DryRun":  s.DryRun,
+	}
+	d := directive{Namespace: namespace, Dimensions: [][]string{{Dimension}}}
+	for _, v := range values {
+		d.Metrics = append(d.Metrics, metric{Name: v.name, Unit: v.unit})
+		rec[v.name] = v.value
+	}
+	rec["_aws"] = metadata{
+		Timestamp:         r.Start.Add(r.Duration).UnixNano() / int64(time.Millisecond),
+		CloudWatchMetrics: []directive{d},
+	}
+	return rec
+}
+
+// Write writes the EMF record of the finished run r to w as a single
+// JSON line, which CloudWatch Logs turns into metrics
+func Write(w io.Writer, r *report.Report, namespace, identityStoreId string) error {
+	b, err := json.Marshal(Record(r, namespace, identityStoreId))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
@@ -0,0 +1,48 @@
+package emf_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/awslabs/ssosync/internal/emf"
+	"github.com/awslabs/ssosync/pkg/report"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	assert := assert.New(t)
+
+	r := report.New()
+	r.Inc(&r.UsersCreated)
+	r.Inc(&r.MembershipsAdded)
+	r.Finish(nil)
+
+	var buf bytes.Buffer
+	assert.NoError(Write(&buf, r, "SSOSync", "d-1234567890"))
+
+	var rec struct {
+		AWS struct {
+			CloudWatchMetrics []struct {
+				Namespace  string
+				Dimensions [][]string
+				Metrics    []struct{ Name, Unit string }
+			}
+		} `json:"_aws"`
+		IdentityStoreId string
+		UsersCreated    int
+		Changes         int
+		Failed          int
+		Result          string
+	}
+	assert.NoError(json.Unmarshal(buf.Bytes(), &rec))
+	assert.Equal("SSOSync", rec.AWS.CloudWatchMetrics[0].Namespace)
+	assert.Equal([][]string{{"IdentityStoreId"}}, rec.AWS.CloudWatchMetrics[0].Dimensions)
+	assert.Len(rec.AWS.CloudWatchMetrics[0].Metrics, 16)
+	assert.Equal("d-1234567890", rec.IdentityStoreId)
+	assert.Equal(1, rec.UsersCreated)
+	assert.Equal(2, rec.Changes)
+	assert.Equal(0, rec.Failed)
+	assert.Equal(report.ResultOK, rec.Result)
+}
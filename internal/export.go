@@ -0,0 +1,153 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/internal/aws"
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/google"
+	log "github.com/sirupsen/logrus"
+)
+
+// Export is the content of the identity store written by DoExport
+type Export struct {
+	IdentityStoreId string        `json:"identityStoreId"`
+	Users           []ExportUser  `json:"users"`
+	Groups          []ExportGroup `json:"groups"`
+}
+
+// ExportUser is a user of the identity store
+type ExportUser struct {
+	UserId      string `json:"userId"`
+	UserName    string `json:"userName"`
+	DisplayName string `json:"displayName,omitempty"`
+	Email       string `json:"email,omitempty"`
+	// GoogleId is the Google external id of the user, if any
+	GoogleId string `json:"googleId,omitempty"`
+}
+
+// ExportGroup is a group of the identity store with its members
+type ExportGroup struct {
+	GroupId     string   `json:"groupId"`
+	DisplayName string   `json:"displayName"`
+	Description string   `json:"description,omitempty"`
+	Members     []string `json:"members"`
+}
+
+// DoExport writes the users, groups and group members of the identity
+// store to w as JSON, e.g. for a backup or a review before a migration
+func DoExport(ctx context.Context, cfg *config.Config, w io.Writer) error {
+	if err := resolveIdentityStore(ctx, cfg); err != nil {
+		return err
+	}
+	client := aws.NewClient(ctx, cfg.IdentityStoreAWSConfig, cfg.IdentityStoreId)
+
+	log.Info("Fetching users, groups and group members")
+	users, err := client.GetUsers()
+	if err != nil {
+		return err
+	}
+	groups, err := client.GetGroups()
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]string, len(users))
+	out := Export{IdentityStoreId: cfg.IdentityStoreId, Users: make([]ExportUser, 0, len(users)), Groups: make([]ExportGroup, 0, len(groups))}
+	for _, u := range users {
+		eu := ExportUser{
+			UserId:      awsutils.ToString(u.UserId),
+			UserName:    awsutils.ToString(u.UserName),
+			DisplayName: awsutils.ToString(u.DisplayName),
+		}
+		for _, m := range u.Emails {
+			if m.Primary || eu.Email == "" {
+				eu.Email = awsutils.ToString(m.Value)
+			}
+		}
+		for _, id := range u.ExternalIds {
+			if awsutils.ToString(id.Issuer) == "Google" {
+				eu.GoogleId = awsutils.ToString(id.Id)
+			}
+		}
+		names[eu.UserId] = eu.UserName
+		out.Users = append(out.Users, eu)
+	}
+
+	for i := range groups {
+		members, err := client.GetGroupMembers(&groups[i])
+		if err != nil {
+			return fmt.Errorf("cannot list the members of %s: %w", awsutils.ToString(groups[i].DisplayName), err)
+		}
+		eg := ExportGroup{
+			GroupId:     awsutils.ToString(groups[i].GroupId),
+			DisplayName: awsutils.ToString(groups[i].DisplayName),
+			Description: awsutils.ToString(groups[i].Description),
+			Members:     []string{},
+		}
+		for _, m := range members {
+			if id, ok := m.MemberId.(*types.MemberIdMemberUserId); ok {
+				eg.Members = append(eg.Members, names[id.Value])
+			}
+		}
+		sort.Strings(eg.Members)
+		out.Groups = append(out.Groups, eg)
+	}
+	sort.Slice(out.Users, func(i, j int) bool { return out.Users[i].UserName < out.Users[j].UserName })
+	sort.Slice(out.Groups, func(i, j int) bool { return out.Groups[i].DisplayName < out.Groups[j].DisplayName })
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// DoValidate checks the configuration and the Google credentials, and
+// with online that the Google API and the identity store are accessible,
+// without changing anything
+func DoValidate(ctx context.Context, cfg *config.Config, w io.Writer, online bool) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	creds, err := cfg.ReadGoogleCredentials(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := google.SplitCredentials(creds); err != nil {
+		return err
+	}
+
+	if online {
+		if _, err := newGoogleClient(ctx, cfg); err != nil {
+			return err
+		}
+		if err := resolveIdentityStore(ctx, cfg); err != nil {
+			return err
+		}
+		if err := aws.Preflight(ctx, cfg.IdentityStoreAWSConfig, cfg.IdentityStoreId); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w, "configuration is valid")
+	return nil
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package freeze tells whether a change freeze is in effect, from
+// recurring windows of cron expressions or from an iCalendar
+package freeze
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Window is a recurring freeze starting at each activation of a cron
+// schedule and lasting Duration
+type Window struct {
+	Spec     string
+	Duration time.Duration
+	schedule cron.Schedule
+}
+
+// ParseWindow parses a window in the form "<cron expression> <duration>",
+// e.g. "0 0 25 3,6,9,12 * 168h" for the last week of each quarter
+func ParseWindow(s string) (Window, error) {
+	s = strings.TrimSpace(s)
+	i := strings.LastIndex(s, " ")
+	if i < 0 {
+		return Window{}, fmt.Errorf("freeze window %q is not a cron expression followed by a duration", s)
+	}
+	d, err := time.ParseDuration(s[i+1:])
+	if err != nil || d <= 0 {
+		return Window{}, fmt.Errorf("freeze window %q does not end with a positive duration", s)
+	}
+	schedule, err := cron.ParseStandard(strings.TrimSpace(s[:i]))
+	if err != nil {
+		return Window{}, fmt.Errorf("freeze window %q has an invalid cron expression: %w", s, err)
+	}
+	return Window{Spec: s, Duration: d, schedule: schedule}, nil
+}
+
+// Active reports whether t is within the window, i.e. the schedule
+// started a freeze less than Duration before t
+func (w Window) Active(t time.Time) bool {
+	return !w.schedule.Next(t.Add(-w.Duration)).After(t)
+}
+
+// Event is a freeze period of a calendar
+type Event struct {
+	Summary string
+	Start   time.Time
+	End     time.Time
+}
+
+// ParseCalendar returns the events of an iCalendar. Recurrence rules are
+// not supported, each occurrence must be an event of its own.
+func ParseCalendar(r io.Reader) ([]Event, error) {
+	var (
+		events []Event
+		lines  []string
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// folded lines continue with a space or tab
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var (
+		e       *Event
+		allDay  bool
+		started bool
+	)
+	for _, line := range lines {
+		name, params, value := splitProperty(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			e, allDay, started = &Event{}, false, false
+		case e == nil:
+		case name == "END" && value == "VEVENT":
+			if !started {
+				return nil, fmt.Errorf("calendar event %q has no DTSTART", e.Summary)
+			}
+			if e.End.IsZero() {
+				e.End = e.Start
+				if allDay {
+					e.End = e.Start.AddDate(0, 0, 1)
+				}
+			}
+			events = append(events, *e)
+			e = nil
+		case name == "SUMMARY":
+			e.Summary = value
+		case name == "DTSTART" || name == "DTEND":
+			t, date, err := parseTime(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("calendar event %q: %w", e.Summary, err)
+			}
+			if name == "DTSTART" {
+				e.Start, allDay, started = t, date, true
+			} else {
+				e.End = t
+			}
+		}
+	}
+	return events, nil
+}
+
+// splitProperty splits a content line "NAME;PARAM=x:value"
+func splitProperty(line string) (name string, params map[string]string, value string) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return strings.ToUpper(line), nil, ""
+	}
+	parts := strings.Split(line[:i], ";")
+	params = make(map[string]string)
+	for _, p := range parts[1:] {
+		if kv := strings.SplitN(p, "=", 2); len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, line[i+1:]
+}
+
+// parseTime parses a DATE or DATE-TIME value, and reports whether it is
+// a date. Dates and floating times are in UTC.
+func parseTime(params map[string]string, value string) (time.Time, bool, error) {
+	loc := time.UTC
+	if tz, ok := params["TZID"]; ok {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown time zone %q", tz)
+		}
+		loc = l
+	}
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// Schedule is the freeze windows and calendar events
+type Schedule struct {
+	Windows []Window
+	Events  []Event
+}
+
+// Load returns the schedule of the windows and of the calendar, a URL
+// fetched with hc or a file path, if any
+func Load(ctx context.Context, windows []string, calendar string, hc *http.Client) (*Schedule, error) {
+	s := &Schedule{}
+	for _, w := range windows {
+		window, err := ParseWindow(w)
+		if err != nil {
+			return nil, err
+		}
+		s.Windows = append(s.Windows, window)
+	}
+	if calendar == "" {
+		return s, nil
+	}
+
+	var r io.ReadCloser
+	if strings.HasPrefix(calendar, "https://") || strings.HasPrefix(calendar, "http://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, calendar, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := hc.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch freeze calendar: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("cannot fetch freeze calendar: %s", resp.Status)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(calendar)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read freeze calendar: %w", err)
+		}
+		r = f
+	}
+	defer r.Close()
+
+	events, err := ParseCalendar(r)
+	if err != nil {
+		return nil, err
+	}
+	s.Events = events
+	return s, nil
+}
+
+// Frozen returns why t is within a freeze, empty when it is not
+func (s *Schedule) Frozen(t time.Time) string {
+	for _, w := range s.Windows {
+		if w.Active(t) {
+			return fmt.Sprintf("freeze window %q", w.Spec)
+		}
+	}
+	for _, e := range s.Events {
+		if !t.Before(e.Start) && t.Before(e.End) {
+			return fmt.Sprintf("freeze %q until %s", e.Summary, e.End.Format(time.RFC3339))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package freeze_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/awslabs/ssosync/internal/freeze"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindow(t *testing.T) {
+	assert := assert.New(t)
+
+	w, err := ParseWindow("0 0 25 3,6,9,12 * 168h")
+	assert.NoError(err)
+	assert.True(w.Active(time.Date(2022, 3, 28, 12, 0, 0, 0, time.Local)))
+	assert.False(w.Active(time.Date(2022, 4, 2, 0, 0, 0, 0, time.Local)))
+	assert.False(w.Active(time.Date(2022, 3, 24, 23, 0, 0, 0, time.Local)))
+
+	_, err = ParseWindow("0 0 25 3,6,9,12 *")
+	assert.Error(err)
+}
+
+const calendar = "BEGIN:VCALENDAR\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Quarter\r\n  close\r\n" +
+	"DTSTART;VALUE=DATE:20220925\r\n" +
+	"DTEND;VALUE=DATE:20221001\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Release\r\n" +
+	"DTSTART:20221010T080000Z\r\n" +
+	"DTEND:20221010T180000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestCalendar(t *testing.T) {
+	assert := assert.New(t)
+
+	events, err := ParseCalendar(strings.NewReader(calendar))
+	assert.NoError(err)
+	assert.Len(events, 2)
+	assert.Equal("Quarter close", events[0].Summary)
+
+	s := &Schedule{Events: events}
+	assert.Contains(s.Frozen(time.Date(2022, 9, 30, 23, 0, 0, 0, time.UTC)), "Quarter close")
+	assert.Empty(s.Frozen(time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Contains(s.Frozen(time.Date(2022, 10, 10, 9, 0, 0, 0, time.UTC)), "Release")
+}
@@ -17,40 +17,56 @@ package google
 
 import (
 	"context"
+	"net/http"
 
+	"github.com/awslabs/ssosync/internal/paging"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	admin "google.golang.org/api/admin/directory/v1"
 	"google.golang.org/api/option"
 )
 
+//go:generate go run github.com/golang/mock/mockgen@v1.5.0 -source=client.go -destination=mock/mock_client.go -package=mock
+
 // Client is the Interface for the Client
 type Client interface {
-	GetUsers(string) ([]*admin.User, error)
+	GetUsers(...string) ([]*admin.User, error)
 	GetDeletedUsers() ([]*admin.User, error)
-	GetGroups(string) ([]*admin.Group, error)
+	GetGroups(...string) ([]*admin.Group, error)
 	GetGroupMembers(*admin.Group) ([]*admin.Member, error)
 }
 
 type client struct {
 	ctx     context.Context
 	service *admin.Service
+	ts      oauth2.TokenSource
+	scopes  []string
 }
 
-// NewClient creates a new client for Google's Admin API
-func NewClient(ctx context.Context, adminEmail string, serviceAccountKey []byte) (Client, error) {
-	config, err := google.JWTConfigFromJSON(serviceAccountKey, admin.AdminDirectoryGroupReadonlyScope,
-		admin.AdminDirectoryGroupMemberReadonlyScope,
-		admin.AdminDirectoryUserReadonlyScope)
-
-	config.Subject = adminEmail
+// NewClient creates a new client for Google's Admin API, all
+// requests are sent with the given http.Client. The DefaultScopes
+// are requested when no scopes are given.
+func NewClient(ctx context.Context, adminEmail string, serviceAccountKey []byte, hc *http.Client, scopes ...string) (Client, error) {
+	if len(scopes) == 0 {
+		scopes = DefaultScopes
+	}
+	expanded := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		expanded = append(expanded, ExpandScope(s))
+	}
 
+	config, err := google.JWTConfigFromJSON(serviceAccountKey, expanded...)
 	if err != nil {
 		return nil, err
 	}
 
+	config.Subject = adminEmail
+
+	// all traffic, including the token exchange, goes through hc
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, hc)
 	ts := config.TokenSource(ctx)
 
-	srv, err := admin.NewService(ctx, option.WithTokenSource(ts))
+	srv, err := admin.NewService(ctx, option.WithHTTPClient(oauth2.NewClient(ctx, ts)))
 	if err != nil {
 		return nil, err
 	}
@@ -58,33 +74,74 @@ func NewClient(ctx context.Context, adminEmail string, serviceAccountKey []byte)
 	return &client{
 		ctx:     ctx,
 		service: srv,
+		ts:      ts,
+		scopes:  expanded,
 	}, nil
 }
 
 // GetDeletedUsers will get the deleted users from the Google's Admin API.
 func (c *client) GetDeletedUsers() ([]*admin.User, error) {
 	u := make([]*admin.User, 0)
+	pages := paging.Start("google", "users.list deleted")
+	defer pages.Done()
 	err := c.service.Users.List().Customer("my_customer").ShowDeleted("true").Pages(c.ctx, func(users *admin.Users) error {
+		pages.Page(len(users.Users), users.NextPageToken)
 		u = append(u, users.Users...)
 		return nil
 	})
 
-	return u, err
+	return u, callError("users.list", c.scopes, err)
 }
 
 // GetGroupMembers will get the members of the group specified
 func (c *client) GetGroupMembers(g *admin.Group) ([]*admin.Member, error) {
+	return c.members(g, false)
+}
+
+// DerivedMembership returns a Client listing the members of the groups
+// with includeDerivedMembership, i.e. also the members of the nested
+// groups, in a single list per group
+func DerivedMembership(c Client) Client {
+	if cl, ok := c.(*client); ok {
+		return &derivedClient{client: cl}
+	}
+	return c
+}
+
+// derivedClient is a client listing the derived members of the groups
+type derivedClient struct {
+	*client
+}
+
+// GetGroupMembers will get the direct and derived members of the group
+func (c *derivedClient) GetGroupMembers(g *admin.Group) ([]*admin.Member, error) {
+	return c.members(g, true)
+}
+
+// members lists the members of the group, with the members of its
+// nested groups when derived
+func (c *client) members(g *admin.Group, derived bool) ([]*admin.Member, error) {
 	m := make([]*admin.Member, 0)
-	err := c.service.Members.List(g.Id).Pages(context.TODO(), func(members *admin.Members) error {
+	pages := paging.Start("google", "members.list")
+	defer pages.Done()
+	call := c.service.Members.List(g.Id)
+	if derived {
+		call = call.IncludeDerivedMembership(true)
+	}
+	err := call.Pages(c.ctx, func(members *admin.Members) error {
+		pages.Page(len(members.Members), members.NextPageToken)
 		m = append(m, members.Members...)
 		return nil
 	})
 
-	return m, err
+	return m, callError("members.list", c.scopes, err)
 }
 
 // GetUsers will get the users from Google's Admin API
-// using the Method: users.list with parameter "query"
+// using the Method: users.list with parameter "query".
+// The Google query language has no OR across fields, so every
+// query given is fetched and the results are merged, users
+// matched by several queries are only returned once.
 // References:
 // * https://developers.google.com/admin-sdk/directory/reference/rest/v1/users/list
 // * https://developers.google.com/admin-sdk/directory/v1/guides/search-users
@@ -96,28 +153,45 @@ func (c *client) GetGroupMembers(g *admin.Group) ([]*admin.Member, error) {
 //  manager='janesmith@example.com'
 //  orgName=Engineering orgTitle:Manager
 //  EmploymentData.projects:'GeneGnomes'
-func (c *client) GetUsers(query string) ([]*admin.User, error) {
-	u := make([]*admin.User, 0)
-	var err error
-
-	if query != "" {
-		err = c.service.Users.List().Query(query).Customer("my_customer").Pages(c.ctx, func(users *admin.Users) error {
-			u = append(u, users.Users...)
-			return nil
-		})
+func (c *client) GetUsers(queries ...string) ([]*admin.User, error) {
+	if len(queries) == 0 {
+		queries = []string{""}
+	}
 
-	} else {
-		err = c.service.Users.List().Customer("my_customer").Pages(c.ctx, func(users *admin.Users) error {
-			u = append(u, users.Users...)
+	u := make([]*admin.User, 0)
+	seen := make(map[string]bool)
+
+	for _, query := range queries {
+		call := c.service.Users.List().Customer("my_customer")
+		if query != "" {
+			call = call.Query(query)
+		}
+
+		pages := paging.Start("google", "users.list")
+		err := call.Pages(c.ctx, func(users *admin.Users) error {
+			pages.Page(len(users.Users), users.NextPageToken)
+			for _, user := range users.Users {
+				if seen[user.Id] {
+					continue
+				}
+				seen[user.Id] = true
+				u = append(u, user)
+			}
 			return nil
 		})
+		pages.Done()
+		if err != nil {
+			return u, callError("users.list", c.scopes, err)
+		}
 	}
 
-	return u, err
+	return u, nil
 }
 
 // GetGroups will get the groups from Google's Admin API
-// using the Method: groups.list with parameter "query"
+// using the Method: groups.list with parameter "query".
+// Like GetUsers, the groups matching any of the queries
+// are returned once.
 // References:
 // * https://developers.google.com/admin-sdk/directory/reference/rest/v1/groups/list
 // * https://developers.google.com/admin-sdk/directory/v1/guides/search-groups
@@ -129,21 +203,37 @@ func (c *client) GetUsers(query string) ([]*admin.User, error) {
 //  name:contact* email:contact*
 //  name:Admin* email:aws-*
 //  email:aws-*
-func (c *client) GetGroups(query string) ([]*admin.Group, error) {
-	g := make([]*admin.Group, 0)
-	var err error
+func (c *client) GetGroups(queries ...string) ([]*admin.Group, error) {
+	if len(queries) == 0 {
+		queries = []string{""}
+	}
 
-	if query != "" {
-		err = c.service.Groups.List().Customer("my_customer").Query(query).Pages(context.TODO(), func(groups *admin.Groups) error {
-			g = append(g, groups.Groups...)
-			return nil
-		})
-	} else {
-		err = c.service.Groups.List().Customer("my_customer").Pages(context.TODO(), func(groups *admin.Groups) error {
-			g = append(g, groups.Groups...)
+	g := make([]*admin.Group, 0)
+	seen := make(map[string]bool)
+
+	for _, query := range queries {
+		call := c.service.Groups.List().Customer("my_customer")
+		if query != "" {
+			call = call.Query(query)
+		}
+
+		pages := paging.Start("google", "groups.list")
+		err := call.Pages(c.ctx, func(groups *admin.Groups) error {
+			pages.Page(len(groups.Groups), groups.NextPageToken)
+			for _, group := range groups.Groups {
+				if seen[group.Id] {
+					continue
+				}
+				seen[group.Id] = true
+				g = append(g, group)
+			}
 			return nil
 		})
-
+		pages.Done()
+		if err != nil {
+			return g, callError("groups.list", c.scopes, err)
+		}
 	}
-	return g, err
+
+	return g, nil
 }
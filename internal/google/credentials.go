@@ -0,0 +1,88 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SplitCredentials returns the service account keys of the credentials,
+// which are either a single key or a JSON array of keys
+func SplitCredentials(b []byte) ([][]byte, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+		return [][]byte{b}, nil
+	}
+
+	var keys []json.RawMessage
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, fmt.Errorf("google credentials are not a valid JSON array of keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("google credentials are an empty list of keys")
+	}
+	res := make([][]byte, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, k)
+	}
+	return res, nil
+}
+
+// NewClientFromKeys creates a client with the first of the service
+// account keys which obtains a token, so that the old and the new key can
+// coexist while a key is rotated. A single key is used without checking.
+func NewClientFromKeys(ctx context.Context, adminEmail string, keys [][]byte, hc *http.Client, scopes ...string) (Client, error) {
+	if len(keys) == 1 {
+		return NewClient(ctx, adminEmail, keys[0], hc, scopes...)
+	}
+
+	var errs []string
+	for i, key := range keys {
+		ll := log.WithField("key", i+1).WithField("keyId", keyId(key))
+		c, err := NewClient(ctx, adminEmail, key, hc, scopes...)
+		if err == nil {
+			err = c.(*client).verify()
+		}
+		if err == nil {
+			ll.Debug("Using Google credentials")
+			return c, nil
+		}
+		ll.WithError(err).Warn("Google credentials rejected, trying the next ones")
+		errs = append(errs, fmt.Sprintf("key %d: %s", i+1, err))
+	}
+	return nil, fmt.Errorf("none of the %d google credentials is valid: %s", len(keys), strings.Join(errs, "; "))
+}
+
+// verify obtains a token, which fails for deleted or disabled keys
+func (c *client) verify() error {
+	_, err := c.ts.Token()
+	return scopeError("token", c.scopes, err)
+}
+
+// keyId returns the id of a service account key, to tell the keys apart
+// in the logs without logging them
+func keyId(key []byte) string {
+	var k struct {
+		PrivateKeyId string `json:"private_key_id"`
+	}
+	_ = json.Unmarshal(key, &k)
+	return k.PrivateKeyId
+}
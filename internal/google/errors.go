@@ -0,0 +1,88 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/awslabs/ssosync/pkg/report"
+	"google.golang.org/api/googleapi"
+)
+
+// quotaReasons are the reasons of the Google API errors refusing a call
+// as a rate limit or a quota is exhausted
+var quotaReasons = map[string]bool{
+	"rateLimitExceeded":     true,
+	"userRateLimitExceeded": true,
+	"quotaExceeded":         true,
+	"dailyLimitExceeded":    true,
+	"limitExceeded":         true,
+}
+
+// QuotaError is returned when a Google call fails, after its retries,
+// because a rate limit or a quota of the project or of the domain is
+// exhausted
+type QuotaError struct {
+	// Call is the Google call which failed, e.g. users.list
+	Call string
+	// Reason is the reason given by Google, e.g. userRateLimitExceeded
+	Reason string
+	Err    error
+}
+
+// Error implements error
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("google %s failed, %s, lower the rate of the syncs or raise the quota in the Google Cloud console: %s",
+		e.Call, e.Reason, e.Err)
+}
+
+// Unwrap returns the error of the call
+func (e *QuotaError) Unwrap() error {
+	return e.Err
+}
+
+// Kind returns report.CauseQuota
+func (e *QuotaError) Kind() string {
+	return report.CauseQuota
+}
+
+// quotaError returns a *QuotaError for err when it was caused by a rate
+// limit or a quota, and nil otherwise
+func quotaError(call string, err error) *QuotaError {
+	var ge *googleapi.Error
+	if !errors.As(err, &ge) {
+		return nil
+	}
+	for _, e := range ge.Errors {
+		if quotaReasons[e.Reason] {
+			return &QuotaError{Call: call, Reason: e.Reason, Err: err}
+		}
+	}
+	if ge.Code == http.StatusTooManyRequests {
+		return &QuotaError{Call: call, Reason: "too many requests", Err: err}
+	}
+	return nil
+}
+
+// callError returns the typed error of a failed call, a *QuotaError or a
+// *ScopeError, or err when neither caused it
+func callError(call string, requested []string, err error) error {
+	if qe := quotaError(call, err); qe != nil {
+		return qe
+	}
+	return scopeError(call, requested, err)
+}
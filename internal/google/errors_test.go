@@ -0,0 +1,47 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/awslabs/ssosync/pkg/report"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+)
+
+func TestQuotaError(t *testing.T) {
+	assert := assert.New(t)
+
+	limited := &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "userRateLimitExceeded"}}}
+	err := callError("users.list", DefaultScopes, limited)
+	var qe *QuotaError
+	assert.True(errors.As(err, &qe))
+	assert.Equal("userRateLimitExceeded", qe.Reason)
+	assert.ErrorIs(err, limited)
+	assert.Equal(report.CauseQuota, report.Cause(err))
+
+	err = callError("members.list", DefaultScopes, &googleapi.Error{Code: 429})
+	assert.True(errors.As(err, &qe))
+
+	insufficient := &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "insufficientPermissions"}}}
+	assert.Equal(report.CauseScope, report.Cause(callError("members.list", DefaultScopes, insufficient)))
+
+	notFound := &googleapi.Error{Code: 404}
+	assert.Equal(notFound, callError("members.list", DefaultScopes, notFound))
+	assert.Equal(report.CauseOther, report.Cause(notFound))
+	assert.NoError(callError("users.list", DefaultScopes, nil))
+}
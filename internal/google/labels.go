@@ -0,0 +1,168 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/awslabs/ssosync/internal/paging"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/cloudidentity/v1"
+	"google.golang.org/api/option"
+)
+
+const (
+	// GroupsScope is the scope of the Cloud Identity groups search,
+	// requested in addition to the directory scopes to filter the groups
+	// by label
+	GroupsScope = cloudidentity.CloudIdentityGroupsReadonlyScope
+	// CustomerScope is the scope looking up the customer id of the
+	// Cloud Identity groups search when not configured
+	CustomerScope = admin.AdminDirectoryCustomerReadonlyScope
+
+	// labelPrefix is the prefix of the Cloud Identity group labels
+	labelPrefix = "cloudidentity.googleapis.com/groups."
+)
+
+// groupLabels are the short names of the Cloud Identity group labels
+var groupLabels = map[string]string{
+	"security":   labelPrefix + "security",
+	"dynamic":    labelPrefix + "dynamic",
+	"discussion": labelPrefix + "discussion_forum",
+}
+
+// ExpandLabel returns the Cloud Identity label of a group label given by
+// its short name, security, dynamic or discussion, labels are returned
+// as is
+func ExpandLabel(label string) (string, error) {
+	if strings.Contains(label, ".") {
+		return label, nil
+	}
+	if l, ok := groupLabels[label]; ok {
+		return l, nil
+	}
+	return "", fmt.Errorf("group label %q is not one of security, dynamic, discussion or a Cloud Identity label", label)
+}
+
+// Labeled returns a Client returning only the groups with one of the
+// Cloud Identity labels, of the customer id, e.g. C01234567, looked up
+// when empty
+func Labeled(c Client, customerId string, labels []string) (Client, error) {
+	base, ok := unwrap(c)
+	if !ok {
+		return nil, fmt.Errorf("cannot filter the groups of %T by label", c)
+	}
+	expanded := make([]string, 0, len(labels))
+	for _, l := range labels {
+		label, err := ExpandLabel(l)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, label)
+	}
+	srv, err := cloudidentity.NewService(base.ctx, option.WithHTTPClient(oauth2.NewClient(base.ctx, base.ts)))
+	if err != nil {
+		return nil, err
+	}
+	return &labeledClient{
+		Client:     c,
+		base:       base,
+		service:    srv,
+		customerId: customerId,
+		labels:     expanded,
+	}, nil
+}
+
+// labeledClient is a Client filtering the groups by label
+type labeledClient struct {
+	Client
+	base       *client
+	service    *cloudidentity.Service
+	customerId string
+	labels     []string
+
+	mu sync.Mutex
+	// labeled are the lowercase emails of the groups with a label,
+	// searched once
+	labeled map[string]bool
+}
+
+// GetGroups will get the groups matching the queries which have one of
+// the labels
+func (c *labeledClient) GetGroups(queries ...string) ([]*admin.Group, error) {
+	groups, err := c.Client.GetGroups(queries...)
+	if err != nil {
+		return nil, err
+	}
+	labeled, err := c.labeledGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*admin.Group, 0, len(groups))
+	for _, g := range groups {
+		if !labeled[strings.ToLower(g.Email)] {
+			log.WithField("group", g.Name).Debug("Group skipped, not labeled")
+			continue
+		}
+		res = append(res, g)
+	}
+	return res, nil
+}
+
+// labeledGroups searches the groups with one of the labels
+func (c *labeledClient) labeledGroups() (map[string]bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.labeled != nil {
+		return c.labeled, nil
+	}
+
+	if c.customerId == "" {
+		customer, err := c.base.service.Customers.Get("my_customer").Context(c.base.ctx).Do()
+		if err != nil {
+			return nil, callError("customers.get", c.base.scopes, err)
+		}
+		c.customerId = customer.Id
+	}
+
+	labeled := make(map[string]bool)
+	for _, l := range c.labels {
+		pages := paging.Start("google", "groups.search")
+		err := c.service.Groups.Search().
+			Query(fmt.Sprintf("parent == 'customers/%s' && '%s' in labels", c.customerId, l)).
+			View("BASIC").
+			Pages(c.base.ctx, func(res *cloudidentity.SearchGroupsResponse) error {
+				pages.Page(len(res.Groups), res.NextPageToken)
+				for _, g := range res.Groups {
+					if g.GroupKey != nil {
+						labeled[strings.ToLower(g.GroupKey.Id)] = true
+					}
+				}
+				return nil
+			})
+		pages.Done()
+		if err != nil {
+			return nil, callError("groups.search", c.base.scopes, err)
+		}
+	}
+	log.WithField("labels", c.labels).WithField("groups", len(labeled)).Debug("Searched the labeled groups")
+	c.labeled = labeled
+	return labeled, nil
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// groupsClient returns fixed groups
+type groupsClient struct {
+	Client
+	groups []*admin.Group
+}
+
+func (c *groupsClient) GetGroups(...string) ([]*admin.Group, error) {
+	return c.groups, nil
+}
+
+func TestLabeled(t *testing.T) {
+	assert := assert.New(t)
+
+	l, err := ExpandLabel("security")
+	assert.NoError(err)
+	assert.Equal("cloudidentity.googleapis.com/groups.security", l)
+	l, err = ExpandLabel("system/groups/external")
+	assert.Error(err)
+	l, err = ExpandLabel("cloudidentity.googleapis.com/groups.locked")
+	assert.NoError(err)
+	assert.Equal("cloudidentity.googleapis.com/groups.locked", l)
+
+	c := &labeledClient{
+		Client:  &groupsClient{groups: []*admin.Group{{Email: "Admins@example.com"}, {Email: "announce@example.com"}}},
+		labeled: map[string]bool{"admins@example.com": true},
+	}
+	groups, err := c.GetGroups()
+	assert.NoError(err)
+	assert.Len(groups, 1)
+	assert.Equal("Admins@example.com", groups[0].Email)
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/awslabs/ssosync/internal/paging"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/licensing/v1"
+	"google.golang.org/api/option"
+)
+
+// LicensingScope is the scope of the License Manager API, requested in
+// addition to the directory scopes to filter the users by license
+const LicensingScope = licensing.AppsLicensingScope
+
+// License is a product and SKU of the License Manager API, e.g.
+// Google-Apps/1010020020 for Google Workspace Enterprise Plus
+type License struct {
+	Product string
+	SKU     string
+}
+
+// ParseLicense parses a license given as productId/skuId
+func ParseLicense(s string) (License, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return License{}, fmt.Errorf("license %q is not a productId/skuId pair, e.g. Google-Apps/1010020020", s)
+	}
+	return License{Product: parts[0], SKU: parts[1]}, nil
+}
+
+// Licensed returns a Client returning only the users holding one of the
+// licenses, of the customer, i.e. the primary domain or the customer id
+func Licensed(c Client, customer string, licenses []License) (Client, error) {
+	base, ok := unwrap(c)
+	if !ok {
+		return nil, fmt.Errorf("cannot filter the users of %T by license", c)
+	}
+	srv, err := licensing.NewService(base.ctx, option.WithHTTPClient(oauth2.NewClient(base.ctx, base.ts)))
+	if err != nil {
+		return nil, err
+	}
+	return &licensedClient{
+		Client:   c,
+		base:     base,
+		service:  srv,
+		customer: customer,
+		licenses: licenses,
+	}, nil
+}
+
+// licensedClient is a Client filtering the users by license
+type licensedClient struct {
+	Client
+	base     *client
+	service  *licensing.Service
+	customer string
+	licenses []License
+
+	mu sync.Mutex
+	// holders are the lowercase emails of the users holding a license,
+	// listed once
+	holders map[string]bool
+}
+
+// GetUsers will get the users matching the queries which hold one of
+// the licenses
+func (c *licensedClient) GetUsers(queries ...string) ([]*admin.User, error) {
+	users, err := c.Client.GetUsers(queries...)
+	if err != nil {
+		return nil, err
+	}
+	holders, err := c.licenseHolders()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*admin.User, 0, len(users))
+	for _, u := range users {
+		if !holders[strings.ToLower(u.PrimaryEmail)] {
+			log.WithField("email", u.PrimaryEmail).Debug("User skipped, no license")
+			continue
+		}
+		res = append(res, u)
+	}
+	return res, nil
+}
+
+// licenseHolders lists the users holding one of the licenses
+func (c *licensedClient) licenseHolders() (map[string]bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.holders != nil {
+		return c.holders, nil
+	}
+
+	holders := make(map[string]bool)
+	for _, l := range c.licenses {
+		pages := paging.Start("google", "licenseAssignments.list")
+		err := c.service.LicenseAssignments.ListForProductAndSku(l.Product, l.SKU, c.customer).
+			Pages(c.base.ctx, func(list *licensing.LicenseAssignmentList) error {
+				pages.Page(len(list.Items), list.NextPageToken)
+				for _, a := range list.Items {
+					holders[strings.ToLower(a.UserId)] = true
+				}
+				return nil
+			})
+		pages.Done()
+		if err != nil {
+			return nil, callError("licenseAssignments.list", c.base.scopes, err)
+		}
+	}
+	log.WithField("licenses", len(c.licenses)).WithField("holders", len(holders)).Debug("Listed the license holders")
+	c.holders = holders
+	return holders, nil
+}
+
+// unwrap returns the client of the Admin API c is based on
+func unwrap(c Client) (*client, bool) {
+	switch t := c.(type) {
+	case *client:
+		return t, true
+	case *derivedClient:
+		return t.client, true
+	case *licensedClient:
+		return t.base, true
+	case *labeledClient:
+		return t.base, true
+	}
+	return nil, false
+}
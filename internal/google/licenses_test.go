@@ -0,0 +1,51 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// staticClient returns fixed users
+type staticClient struct {
+	Client
+	users []*admin.User
+}
+
+func (c *staticClient) GetUsers(...string) ([]*admin.User, error) {
+	return c.users, nil
+}
+
+func TestLicensed(t *testing.T) {
+	assert := assert.New(t)
+
+	l, err := ParseLicense("Google-Apps/1010020020")
+	assert.NoError(err)
+	assert.Equal(License{Product: "Google-Apps", SKU: "1010020020"}, l)
+	_, err = ParseLicense("1010020020")
+	assert.Error(err)
+
+	c := &licensedClient{
+		Client:  &staticClient{users: []*admin.User{{PrimaryEmail: "Licensed@example.com"}, {PrimaryEmail: "other@example.com"}}},
+		holders: map[string]bool{"licensed@example.com": true},
+	}
+	users, err := c.GetUsers()
+	assert.NoError(err)
+	assert.Len(users, 1)
+	assert.Equal("Licensed@example.com", users[0].PrimaryEmail)
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/awslabs/ssosync/pkg/report"
+	"golang.org/x/oauth2"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/cloudidentity/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// scopePrefix is the common prefix of the Google OAuth scopes
+const scopePrefix = "https://www.googleapis.com/auth/"
+
+// DefaultScopes are the read-only directory scopes requested when none
+// are configured, they cover all the calls made by ssosync
+var DefaultScopes = []string{
+	admin.AdminDirectoryGroupReadonlyScope,
+	admin.AdminDirectoryGroupMemberReadonlyScope,
+	admin.AdminDirectoryUserReadonlyScope,
+}
+
+// requiredScopes are the scopes granting each call, any of them suffices
+var requiredScopes = map[string][]string{
+	"users.list": {
+		admin.AdminDirectoryUserReadonlyScope,
+		admin.AdminDirectoryUserScope,
+	},
+	"groups.list": {
+		admin.AdminDirectoryGroupReadonlyScope,
+		admin.AdminDirectoryGroupScope,
+	},
+	"members.list": {
+		admin.AdminDirectoryGroupMemberReadonlyScope,
+		admin.AdminDirectoryGroupMemberScope,
+		admin.AdminDirectoryGroupReadonlyScope,
+		admin.AdminDirectoryGroupScope,
+	},
+	"licenseAssignments.list": {
+		LicensingScope,
+	},
+	"customers.get": {
+		CustomerScope,
+		admin.AdminDirectoryCustomerScope,
+	},
+	"groups.search": {
+		GroupsScope,
+		cloudidentity.CloudIdentityGroupsScope,
+	},
+}
+
+// ExpandScope returns the URL of a scope given by its short name, e.g.
+// admin.directory.user.readonly, scope URLs are returned as is
+func ExpandScope(scope string) string {
+	if strings.Contains(scope, "://") {
+		return scope
+	}
+	return scopePrefix + scope
+}
+
+// ScopeError is returned when a Google call fails because a scope is
+// either not requested or not authorized for the service account
+type ScopeError struct {
+	// Call is the Admin SDK call which failed, e.g. users.list
+	Call string
+	// Missing are the scopes of which one is needed, when the call was
+	// refused, empty when the token exchange was refused
+	Missing []string
+	// Requested are the scopes requested by ssosync
+	Requested []string
+	Err       error
+}
+
+// Error implements error
+func (e *ScopeError) Error() string {
+	if len(e.Missing) == 0 {
+		return fmt.Sprintf("google %s failed, the service account is not authorized for the scopes %s, authorize them in the domain-wide delegation of the Google Workspace admin console: %s",
+			e.Call, strings.Join(e.Requested, ","), e.Err)
+	}
+	return fmt.Sprintf("google %s failed, scope %s is missing, add it to --google-scopes and to the domain-wide delegation: %s",
+		e.Call, strings.Join(e.Missing, " or "), e.Err)
+}
+
+// Unwrap returns the error of the call
+func (e *ScopeError) Unwrap() error {
+	return e.Err
+}
+
+// Kind returns report.CauseScope
+func (e *ScopeError) Kind() string {
+	return report.CauseScope
+}
+
+// scopeError returns a *ScopeError for err when it was caused by the
+// scopes, and err otherwise
+func scopeError(call string, requested []string, err error) error {
+	var re *oauth2.RetrieveError
+	if errors.As(err, &re) && strings.Contains(string(re.Body), "unauthorized_client") {
+		return &ScopeError{Call: call, Requested: requested, Err: err}
+	}
+
+	var ge *googleapi.Error
+	if !errors.As(err, &ge) || ge.Code != http.StatusForbidden {
+		return err
+	}
+	insufficient := strings.Contains(strings.ToLower(ge.Message), "insufficient authentication scopes")
+	for _, e := range ge.Errors {
+		insufficient = insufficient || e.Reason == "insufficientPermissions" || e.Reason == "ACCESS_TOKEN_SCOPE_INSUFFICIENT"
+	}
+	if !insufficient {
+		return err
+	}
+
+	var missing []string
+	for _, s := range requiredScopes[call] {
+		if !contains(requested, s) {
+			missing = append(missing, s)
+		}
+	}
+	return &ScopeError{Call: call, Missing: missing, Requested: requested, Err: err}
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
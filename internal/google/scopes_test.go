@@ -0,0 +1,47 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func TestScopeError(t *testing.T) {
+	assert := assert.New(t)
+
+	insufficient := &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "insufficientPermissions"}}}
+	err := scopeError("members.list", []string{admin.AdminDirectoryUserReadonlyScope}, insufficient)
+	var se *ScopeError
+	assert.True(errors.As(err, &se))
+	assert.Contains(se.Missing, admin.AdminDirectoryGroupMemberReadonlyScope)
+	assert.Contains(err.Error(), "scope "+admin.AdminDirectoryGroupMemberReadonlyScope)
+	assert.ErrorIs(err, insufficient)
+
+	err = scopeError("users.list", DefaultScopes, &oauth2.RetrieveError{Body: []byte(`{"error":"unauthorized_client"}`)})
+	assert.True(errors.As(err, &se))
+	assert.Empty(se.Missing)
+
+	notAuthorized := &googleapi.Error{Code: 403, Message: "Not Authorized to access this resource/api"}
+	assert.Equal(notAuthorized, scopeError("users.list", DefaultScopes, notAuthorized))
+	assert.NoError(scopeError("users.list", DefaultScopes, nil))
+
+	assert.Equal(admin.AdminDirectoryUserScope, ExpandScope("admin.directory.user"))
+}
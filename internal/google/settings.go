@@ -0,0 +1,75 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package google
+
+import (
+	"fmt"
+
+	"github.com/awslabs/ssosync/internal/paging"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/groupssettings/v1"
+	"google.golang.org/api/option"
+)
+
+// GroupsSettingsScope is the scope of the Groups Settings API, requested
+// in addition to the directory scopes to report the group settings
+const GroupsSettingsScope = groupssettings.AppsGroupsSettingsScope
+
+// GroupSettings are the settings of a Google group controlling who can
+// become a member
+type GroupSettings struct {
+	// AllowExternalMembers is set when users outside of the organization
+	// can be members
+	AllowExternalMembers bool
+	// WhoCanJoin is ANYONE_CAN_JOIN, ALL_IN_DOMAIN_CAN_JOIN,
+	// INVITED_CAN_JOIN or CAN_REQUEST_TO_JOIN
+	WhoCanJoin string
+}
+
+// SettingsReader reads the settings of the groups
+type SettingsReader struct {
+	base    *client
+	service *groupssettings.Service
+}
+
+// NewSettingsReader returns the reader of the group settings with the
+// credentials of c
+func NewSettingsReader(c Client) (*SettingsReader, error) {
+	base, ok := unwrap(c)
+	if !ok {
+		return nil, fmt.Errorf("cannot read the group settings with %T", c)
+	}
+	srv, err := groupssettings.NewService(base.ctx, option.WithHTTPClient(oauth2.NewClient(base.ctx, base.ts)))
+	if err != nil {
+		return nil, err
+	}
+	return &SettingsReader{base: base, service: srv}, nil
+}
+
+// Get returns the settings of the group email
+func (r *SettingsReader) Get(email string) (GroupSettings, error) {
+	pages := paging.Start("google", "groupssettings.get")
+	defer pages.Done()
+
+	g, err := r.service.Groups.Get(email).Context(r.base.ctx).Do()
+	if err != nil {
+		return GroupSettings{}, callError("groupssettings.get", r.base.scopes, err)
+	}
+	pages.Page(1, "")
+	return GroupSettings{
+		AllowExternalMembers: g.AllowExternalMembers == "true",
+		WhoCanJoin:           g.WhoCanJoin,
+	}, nil
+}
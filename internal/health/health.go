@@ -0,0 +1,154 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package health ...
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Status tracks the outcome of the syncs run in daemon mode
+type Status struct {
+	mu          sync.RWMutex
+	maxAge      time.Duration
+	started     time.Time
+	lastRun     time.Time
+	lastSuccess time.Time
+	lastError   error
+}
+
+// report is the JSON body returned by the endpoints
+type report struct {
+	Status      string     `json:"status"`
+	LastRun     *time.Time `json:"lastRun,omitempty"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+}
+
+// NewStatus returns a Status which is considered unhealthy when no sync
+// succeeded within maxAge
+func NewStatus(maxAge time.Duration) *Status {
+	return &Status{
+		maxAge:  maxAge,
+		started: time.Now(),
+	}
+}
+
+// Record stores the result of a sync
+func (s *Status) Record(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastRun = time.Now()
+	s.lastError = err
+	if err == nil {
+		s.lastSuccess = s.lastRun
+	}
+}
+
+// Healthy reports whether a sync succeeded recently, a freshly started
+// daemon is given maxAge to complete its first sync
+func (s *Status) Healthy() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	since := s.lastSuccess
+	if since.IsZero() {
+		since = s.started
+	}
+	return time.Since(since) <= s.maxAge
+}
+
+// Ready reports whether at least one sync succeeded
+func (s *Status) Ready() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return !s.lastSuccess.IsZero()
+}
+
+func (s *Status) report(ok bool) report {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r := report{Status: "ok"}
+	if !ok {
+		r.Status = "failing"
+	}
+	if !s.lastRun.IsZero() {
+		t := s.lastRun
+		r.LastRun = &t
+	}
+	if !s.lastSuccess.IsZero() {
+		t := s.lastSuccess
+		r.LastSuccess = &t
+	}
+	if s.lastError != nil {
+		r.LastError = s.lastError.Error()
+	}
+	return r
+}
+
+// Handler returns the http.Handler serving /healthz and /readyz
+func (s *Status) Handler() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		s.write(w, s.Healthy())
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		s.write(w, s.Ready())
+	})
+	return mux
+}
+
+func (s *Status) write(w http.ResponseWriter, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(s.report(ok)); err != nil {
+		log.WithError(err).Warn("cannot write health status")
+	}
+}
+
+// Serve runs an HTTP server for handler on addr until ctx is done
+func Serve(ctx context.Context, addr string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdown); err != nil {
+			log.WithError(err).Warn("cannot shutdown health server")
+		}
+	}()
+
+	log.WithField("addr", addr).Info("serving health endpoints")
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"time"
+
+	"github.com/awslabs/ssosync/internal/paging"
+	log "github.com/sirupsen/logrus"
+)
+
+// RegisterPprof adds the net/http/pprof handlers below /debug/pprof/
+// and the paging stats of the last sync at /debug/paging
+func RegisterPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/paging", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(paging.Summary()); err != nil {
+			log.WithError(err).Warn("cannot write paging stats")
+		}
+	})
+}
+
+// WriteHeapProfile writes a heap profile to a timestamped file in dir
+// and returns its path
+func WriteHeapProfile(dir string) (string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("ssosync-heap-%s.pprof", time.Now().UTC().Format("20060102T150405Z")))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// collect garbage first, so the profile shows the live heap
+	runtime.GC()
+	if err := runtimepprof.WriteHeapProfile(f); err != nil {
+		return "", err
+	}
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	log.WithFields(log.Fields{
+		"path":      path,
+		"heapAlloc": m.HeapAlloc,
+		"heapSys":   m.HeapSys,
+	}).Info("wrote heap profile")
+	return path, nil
+}
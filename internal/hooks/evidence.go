@@ -0,0 +1,124 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/pkg/ssosync"
+	log "github.com/sirupsen/logrus"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// EvidenceRecord is the evidence of the deprovisioning of a user
+type EvidenceRecord struct {
+	UserName        string    `json:"userName"`
+	Email           string    `json:"email,omitempty"`
+	IdentityStoreId string    `json:"identityStoreId"`
+	DeletedAt       time.Time `json:"deletedAt"`
+	// DeletedBy is the AWS principal of the run
+	DeletedBy string `json:"deletedBy,omitempty"`
+	// Reason is why the user was deleted: deleted, suspended or absent
+	// in Google
+	Reason string `json:"reason,omitempty"`
+	// Groups are the groups the user was removed from
+	Groups []string `json:"groups"`
+	// Google is the Google user triggering the deletion, nil when absent
+	Google *admin.User `json:"google,omitempty"`
+}
+
+// Evidence returns a hook writing an EvidenceRecord per user deleted to
+// the s3://bucket/prefix of the configuration. With a retention the
+// records are locked with S3 Object Lock until they expire.
+func Evidence(ctx context.Context, cfg *config.Config) (ssosync.Hook, error) {
+	u, err := url.Parse(cfg.Evidence)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("invalid evidence location %q, expected s3://bucket/prefix", cfg.Evidence)
+	}
+	bucket, prefix := u.Host, strings.Trim(u.Path, "/")
+	client := s3.NewFromConfig(cfg.AWSConfig)
+
+	var (
+		once   sync.Once
+		caller string
+	)
+	return ssosync.HookFunc(func(e ssosync.Event) error {
+		if e.Type != ssosync.EventUserDelete || e.Phase != ssosync.PhasePost || e.Error != "" {
+			return nil
+		}
+
+		once.Do(func() {
+			out, err := sts.NewFromConfig(cfg.AWSConfig).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+			if err != nil {
+				log.WithError(err).Warn("Can't get the AWS caller identity of the evidence records")
+				return
+			}
+			caller = aws.ToString(out.Arn)
+		})
+
+		rec := EvidenceRecord{
+			UserName:        e.UserName,
+			Email:           e.Email,
+			IdentityStoreId: cfg.IdentityStoreId,
+			DeletedAt:       time.Now().UTC(),
+			DeletedBy:       caller,
+			Reason:          e.Reason,
+			Groups:          e.Groups,
+			Google:          e.Source,
+		}
+		if rec.Groups == nil {
+			rec.Groups = []string{}
+		}
+		b, err := json.MarshalIndent(rec, "", "  ")
+		if err != nil {
+			return err
+		}
+		sum := md5.Sum(b)
+
+		key := path.Join(prefix, rec.DeletedAt.Format("2006/01/02"), fmt.Sprintf("%s-%d.json", e.UserName, rec.DeletedAt.UnixNano()))
+		in := &s3.PutObjectInput{
+			Bucket:      aws.String(bucket),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(b),
+			ContentType: aws.String("application/json"),
+			// Content-MD5 is required by buckets with Object Lock
+			ContentMD5: aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+		}
+		if cfg.EvidenceRetentionDays > 0 {
+			in.ObjectLockMode = s3types.ObjectLockMode(cfg.EvidenceLockMode)
+			in.ObjectLockRetainUntilDate = aws.Time(rec.DeletedAt.AddDate(0, 0, cfg.EvidenceRetentionDays))
+		}
+		if _, err := client.PutObject(ctx, in); err != nil {
+			return fmt.Errorf("cannot write evidence to s3://%s/%s: %w", bucket, key, err)
+		}
+		log.WithField("userName", e.UserName).WithField("key", key).Info("Deprovisioning evidence written")
+		return nil
+	}), nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hooks provides the shell, webhook and Go plugin hooks
+// configured on the command line
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"plugin"
+	"time"
+
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/transport"
+	"github.com/awslabs/ssosync/pkg/ssosync"
+)
+
+// New returns the hooks of the configuration
+func New(ctx context.Context, cfg *config.Config) ([]ssosync.Hook, error) {
+	var hooks []ssosync.Hook
+
+	if cfg.HookPlugin != "" {
+		h, err := LoadPlugin(cfg.HookPlugin)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+
+	if cfg.HookCommand != "" {
+		hooks = append(hooks, Command(ctx, cfg.HookCommand, cfg.HookTimeout))
+	}
+
+	if cfg.HookWebhook != "" {
+		hc, err := transport.NewClient("hooks", nil, transport.Options{
+			Timeout: cfg.HookTimeout,
+			Proxy:   cfg.ProxyFor(""),
+		})
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, Webhook(ctx, cfg.HookWebhook, hc))
+	}
+
+	if cfg.EventStream != "" {
+		h, err := Stream(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+
+	if cfg.Evidence != "" {
+		h, err := Evidence(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+
+	if cfg.WelcomeEmailFrom != "" || cfg.WelcomeQueue != "" || cfg.WelcomeWebhook != "" {
+		h, err := Welcome(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+
+	return hooks, nil
+}
+
+// Command returns a hook running the shell command for every event. The
+// event is passed as JSON on stdin and as SSOSYNC_EVENT, SSOSYNC_PHASE,
+// SSOSYNC_USER_NAME, SSOSYNC_EMAIL and SSOSYNC_GROUP_NAME environment
+// variables, a non-zero exit status in the pre phase skips the change.
+func Command(ctx context.Context, command string, timeout time.Duration) ssosync.Hook {
+	return ssosync.HookFunc(func(e ssosync.Event) error {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+		cmd.Stdin = bytes.NewReader(body)
+		cmd.Env = append(os.Environ(),
+			"SSOSYNC_EVENT="+string(e.Type),
+			"SSOSYNC_PHASE="+string(e.Phase),
+			"SSOSYNC_USER_NAME="+e.UserName,
+			"SSOSYNC_EMAIL="+e.Email,
+			"SSOSYNC_GROUP_NAME="+e.GroupName,
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("hook command failed: %w: %s", err, bytes.TrimSpace(out))
+		}
+		return nil
+	})
+}
+
+// Webhook returns a hook posting every event as JSON to url, a response
+// status other than 2xx in the pre phase skips the change
+func Webhook(ctx context.Context, url string, hc *http.Client) ssosync.Hook {
+	return ssosync.HookFunc(func(e ssosync.Event) error {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := hc.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("hook webhook returned %s", resp.Status)
+		}
+		return nil
+	})
+}
+
+// LoadPlugin opens the Go plugin at path, which must export a variable
+// Hook implementing ssosync.Hook. The plugin must be built with the same
+// Go version and dependencies as ssosync, see the plugin package.
+func LoadPlugin(path string) (ssosync.Hook, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open hook plugin: %w", err)
+	}
+
+	sym, err := p.Lookup("Hook")
+	if err != nil {
+		return nil, fmt.Errorf("cannot open hook plugin: %w", err)
+	}
+
+	switch h := sym.(type) {
+	case *ssosync.Hook:
+		return *h, nil
+	case ssosync.Hook:
+		return h, nil
+	default:
+		return nil, fmt.Errorf("hook plugin %s: Hook is a %T, not an ssosync.Hook", path, sym)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/awslabs/ssosync/internal/config"
+	. "github.com/awslabs/ssosync/internal/hooks"
+	"github.com/awslabs/ssosync/pkg/ssosync"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	e := ssosync.Event{Type: ssosync.EventUserDelete, Phase: ssosync.PhasePre, UserName: "jane@example.com"}
+
+	h := Command(context.Background(), `test "$SSOSYNC_EVENT" = user_delete && grep -q jane@example.com`, time.Minute)
+	assert.NoError(h.OnUserDelete(e))
+
+	h = Command(context.Background(), "echo veto; exit 1", time.Minute)
+	err := h.OnUserDelete(e)
+	assert.Error(err)
+	assert.Contains(err.Error(), "veto")
+}
+
+func TestWebhook(t *testing.T) {
+	assert := assert.New(t)
+
+	var got ssosync.Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		if got.Phase == ssosync.PhasePre {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	h := Webhook(context.Background(), srv.URL, srv.Client())
+
+	e := ssosync.Event{Type: ssosync.EventMemberAdd, Phase: ssosync.PhasePost, UserName: "jane@example.com", GroupName: "admins"}
+	assert.NoError(h.OnGroupChange(e))
+	assert.Equal(e, got)
+
+	e.Phase = ssosync.PhasePre
+	assert.Error(h.OnGroupChange(e))
+}
+
+func TestWelcome(t *testing.T) {
+	assert := assert.New(t)
+
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.WelcomeWebhook = srv.URL
+	cfg.WelcomePortalURL = "https://d-1234567890.awsapps.com/start"
+	h, err := Welcome(context.Background(), cfg)
+	assert.NoError(err)
+
+	e := ssosync.Event{Type: ssosync.EventUserCreate, Phase: ssosync.PhasePre, UserName: "jane@example.com", Email: "jane@example.com"}
+	assert.NoError(h.OnUserCreate(e))
+	assert.Nil(got)
+
+	e.Phase = ssosync.PhasePost
+	assert.NoError(h.OnUserCreate(e))
+	assert.Equal("jane@example.com", got["userName"])
+	assert.Equal(config.DefaultWelcomeSubject, got["subject"])
+	assert.Contains(got["message"], "https://d-1234567890.awsapps.com/start")
+}
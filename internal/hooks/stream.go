@@ -0,0 +1,222 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/transport"
+	"github.com/awslabs/ssosync/pkg/ssosync"
+)
+
+const (
+	// EventBridgeServiceID and KinesisServiceID are the service ids of
+	// the APIs in the endpoint resolver of the AWS configuration
+	EventBridgeServiceID = "EventBridge"
+	KinesisServiceID     = "Kinesis"
+
+	// StreamSource and StreamDetailType are the source and detail type of
+	// the EventBridge events
+	StreamSource     = "ssosync"
+	StreamDetailType = "ssosync change"
+)
+
+// ChangeRecord is the record of a change applied by ssosync published to
+// the event stream
+type ChangeRecord struct {
+	ssosync.Event
+	IdentityStoreId string    `json:"identity_store_id,omitempty"`
+	Time            time.Time `json:"time"`
+}
+
+// parseStream parses the event stream of the configuration,
+// eventbridge://<bus> or kinesis://<stream>, and returns its service,
+// eventbridge or kinesis, and name
+func parseStream(uri string) (service, name string, err error) {
+	u, err := url.Parse(uri)
+	if err != nil || (u.Scheme != "eventbridge" && u.Scheme != "kinesis") || u.Host == "" {
+		return "", "", fmt.Errorf("invalid event stream %q, expected eventbridge://<bus> or kinesis://<stream>", uri)
+	}
+	return u.Scheme, u.Host, nil
+}
+
+// Stream returns a hook publishing every change applied as a
+// ChangeRecord to the EventBridge bus or the Kinesis stream of the
+// configuration. The failed and skipped changes are not published, the
+// Kinesis records are partitioned by user name, or group name, so that
+// the changes of an entity are read in order.
+func Stream(ctx context.Context, cfg *config.Config) (ssosync.Hook, error) {
+	service, name, err := parseStream(cfg.EventStream)
+	if err != nil {
+		return nil, err
+	}
+	hc, err := transport.NewClient("event-stream", nil, transport.Options{
+		Timeout: cfg.HookTimeout,
+		Proxy:   cfg.ProxyFor(""),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var c *jsonClient
+	if service == "eventbridge" {
+		c, err = newJSONClient(ctx, cfg.AWSConfig, hc, EventBridgeServiceID, "events", "AWSEvents")
+	} else {
+		c, err = newJSONClient(ctx, cfg.AWSConfig, hc, KinesisServiceID, "kinesis", "Kinesis_20131202")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ssosync.HookFunc(func(e ssosync.Event) error {
+		if e.Phase != ssosync.PhasePost || e.Error != "" {
+			return nil
+		}
+		detail, err := json.Marshal(ChangeRecord{Event: e, IdentityStoreId: cfg.IdentityStoreId, Time: time.Now().UTC()})
+		if err != nil {
+			return err
+		}
+
+		if service == "eventbridge" {
+			var out struct {
+				FailedEntryCount int
+				Entries          []struct{ ErrorCode, ErrorMessage string }
+			}
+			err := c.call("PutEvents", map[string]interface{}{
+				"Entries": []map[string]string{{
+					"EventBusName": name,
+					"Source":       StreamSource,
+					"DetailType":   StreamDetailType,
+					"Detail":       string(detail),
+				}},
+			}, &out)
+			if err != nil {
+				return err
+			}
+			if out.FailedEntryCount > 0 && len(out.Entries) > 0 {
+				return fmt.Errorf("cannot publish the change to %s: %s %s", name, out.Entries[0].ErrorCode, out.Entries[0].ErrorMessage)
+			}
+			return nil
+		}
+
+		key := e.UserName
+		if key == "" {
+			key = e.GroupName
+		}
+		return c.call("PutRecord", map[string]interface{}{"StreamName": name, "Data": detail, "PartitionKey": key}, nil)
+	}), nil
+}
+
+// jsonClient calls an AWS API of the JSON 1.1 protocol
+type jsonClient struct {
+	ctx         context.Context
+	config      aws.Config
+	http        *http.Client
+	endpoint    string
+	signingName string
+	target      string
+	signer      *v4.Signer
+}
+
+func newJSONClient(ctx context.Context, config aws.Config, hc *http.Client, serviceID, signingName, target string) (*jsonClient, error) {
+	endpoint := "https://" + signingName + "." + config.Region + ".amazonaws.com"
+	if config.EndpointResolverWithOptions != nil {
+		e, err := config.EndpointResolverWithOptions.ResolveEndpoint(serviceID, config.Region)
+		var notFound *aws.EndpointNotFoundError
+		switch {
+		case errors.As(err, &notFound):
+		case err != nil:
+			return nil, err
+		default:
+			endpoint = e.URL
+		}
+	}
+	return &jsonClient{
+		ctx:         ctx,
+		config:      config,
+		http:        hc,
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		signingName: signingName,
+		target:      target,
+		signer:      v4.NewSigner(),
+	}, nil
+}
+
+// call calls the operation op with in, the response is decoded into out
+func (c *jsonClient) call(op string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", c.target+"."+op)
+
+	creds, err := c.config.Credentials.Retrieve(c.ctx)
+	if err != nil {
+		return fmt.Errorf("cannot retrieve the AWS credentials: %w", err)
+	}
+	hash := sha256.Sum256(body)
+	if err := c.signer.SignHTTP(c.ctx, creds, req, hex.EncodeToString(hash[:]), c.signingName, c.config.Region, time.Now()); err != nil {
+		return err
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		if out == nil || len(b) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(b, out); err != nil {
+			return fmt.Errorf("invalid %s response of %s: %w", c.signingName, op, err)
+		}
+		return nil
+	}
+
+	var msg struct {
+		Type    string `json:"__type"`
+		Message string `json:"message"`
+	}
+	json.Unmarshal(b, &msg)
+	code := msg.Type
+	if code == "" {
+		code, _, _ = strings.Cut(res.Header.Get("X-Amzn-Errortype"), ":")
+	}
+	return fmt.Errorf("%s %s: %s %s: %s", c.signingName, op, res.Status, code, msg.Message)
+}
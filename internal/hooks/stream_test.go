@@ -0,0 +1,101 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	awsclient "github.com/awslabs/ssosync/internal/aws"
+	"github.com/awslabs/ssosync/internal/config"
+	. "github.com/awslabs/ssosync/internal/hooks"
+	"github.com/awslabs/ssosync/pkg/ssosync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		targets []string
+		bodies  []map[string]interface{}
+		fail    bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		targets = append(targets, r.Header.Get("X-Amz-Target"))
+		bodies = append(bodies, body)
+		assert.Contains(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256")
+		if fail {
+			w.Write([]byte(`{"FailedEntryCount":1,"Entries":[{"ErrorCode":"InternalFailure","ErrorMessage":"try again"}]}`))
+			return
+		}
+		w.Write([]byte(`{"FailedEntryCount":0,"Entries":[{"EventId":"1"}]}`))
+	}))
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.IdentityStoreId = "d-1234567890"
+	cfg.EventStream = "eventbridge://identity"
+	cfg.AWSConfig = aws.Config{
+		Region:                      "eu-west-1",
+		Credentials:                 credentials.NewStaticCredentialsProvider("id", "secret", ""),
+		EndpointResolverWithOptions: awsclient.EndpointResolver(map[string]string{EventBridgeServiceID: srv.URL, KinesisServiceID: srv.URL}),
+	}
+	h, err := Stream(context.Background(), cfg)
+	assert.NoError(err)
+
+	e := ssosync.Event{Type: ssosync.EventMemberAdd, Phase: ssosync.PhasePre, UserName: "jane@example.com", GroupName: "admins"}
+	assert.NoError(h.OnGroupChange(e))
+	e.Phase = ssosync.PhasePost
+	assert.NoError(h.OnGroupChange(ssosync.Event{Type: ssosync.EventMemberAdd, Phase: ssosync.PhasePost, Error: "throttled"}))
+	assert.NoError(h.OnGroupChange(e))
+
+	// only the applied change is published
+	assert.Equal([]string{"AWSEvents.PutEvents"}, targets)
+	entry := bodies[0]["Entries"].([]interface{})[0].(map[string]interface{})
+	assert.Equal("identity", entry["EventBusName"])
+	assert.Equal(StreamSource, entry["Source"])
+	var rec ChangeRecord
+	assert.NoError(json.Unmarshal([]byte(entry["Detail"].(string)), &rec))
+	assert.Equal("jane@example.com", rec.UserName)
+	assert.Equal("admins", rec.GroupName)
+	assert.Equal("d-1234567890", rec.IdentityStoreId)
+
+	fail = true
+	assert.ErrorContains(h.OnGroupChange(e), "try again")
+	fail = false
+
+	cfg.EventStream = "kinesis://changes"
+	h, err = Stream(context.Background(), cfg)
+	assert.NoError(err)
+	assert.NoError(h.OnUserCreate(ssosync.Event{Type: ssosync.EventUserCreate, Phase: ssosync.PhasePost, UserName: "jane@example.com"}))
+	assert.Equal("Kinesis_20131202.PutRecord", targets[len(targets)-1])
+	body := bodies[len(bodies)-1]
+	assert.Equal("changes", body["StreamName"])
+	assert.Equal("jane@example.com", body["PartitionKey"])
+	assert.NotEmpty(body["Data"])
+
+	cfg.EventStream = "sns://topic"
+	_, err = Stream(context.Background(), cfg)
+	assert.Error(err)
+}
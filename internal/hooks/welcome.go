@@ -0,0 +1,167 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	sestypes "github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/transport"
+	"github.com/awslabs/ssosync/pkg/ssosync"
+)
+
+// DefaultWelcomeTemplate is the welcome message when no template is configured
+const DefaultWelcomeTemplate = `Hello,
+
+an AWS account access was created for you with the user name {{.UserName}}.
+{{if .PortalURL}}
+Sign in with your Google account at {{.PortalURL}}
+{{end}}`
+
+// WelcomeData is the data of the welcome template
+type WelcomeData struct {
+	UserName  string
+	Email     string
+	PortalURL string
+}
+
+// welcomeMessage is the JSON sent to the welcome queue and webhook
+type welcomeMessage struct {
+	UserName string `json:"userName"`
+	Email    string `json:"email"`
+	Subject  string `json:"subject"`
+	Message  string `json:"message"`
+}
+
+// Welcome returns a hook onboarding the users once created: the message
+// rendered from the welcome template is emailed to the user with Amazon
+// SES, sent to an SQS queue and posted to a webhook, as configured
+func Welcome(ctx context.Context, cfg *config.Config) (ssosync.Hook, error) {
+	text := DefaultWelcomeTemplate
+	if cfg.WelcomeTemplate != "" {
+		b, err := ioutil.ReadFile(cfg.WelcomeTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read welcome template: %w", err)
+		}
+		text = string(b)
+	}
+	tmpl, err := template.New("welcome").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse welcome template: %w", err)
+	}
+
+	hc, err := transport.NewClient("welcome", nil, transport.Options{
+		Timeout: cfg.HookTimeout,
+		Proxy:   cfg.ProxyFor(""),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ssosync.HookFunc(func(e ssosync.Event) error {
+		if e.Type != ssosync.EventUserCreate || e.Phase != ssosync.PhasePost || e.Error != "" {
+			return nil
+		}
+
+		var body bytes.Buffer
+		if err := tmpl.Execute(&body, WelcomeData{UserName: e.UserName, Email: e.Email, PortalURL: cfg.WelcomePortalURL}); err != nil {
+			return fmt.Errorf("cannot render welcome message: %w", err)
+		}
+		msg := welcomeMessage{UserName: e.UserName, Email: e.Email, Subject: cfg.WelcomeSubject, Message: body.String()}
+
+		var errs []string
+		if cfg.WelcomeEmailFrom != "" && e.Email != "" {
+			if err := sendWelcomeEmail(ctx, cfg, msg); err != nil {
+				errs = append(errs, fmt.Sprintf("email: %s", err))
+			}
+		}
+		if cfg.WelcomeQueue != "" {
+			if err := sendWelcomeMessage(ctx, cfg, msg); err != nil {
+				errs = append(errs, fmt.Sprintf("queue: %s", err))
+			}
+		}
+		if cfg.WelcomeWebhook != "" {
+			if err := postWelcome(ctx, hc, cfg.WelcomeWebhook, msg); err != nil {
+				errs = append(errs, fmt.Sprintf("webhook: %s", err))
+			}
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("cannot welcome %s: %s", e.UserName, strings.Join(errs, "; "))
+		}
+		return nil
+	}), nil
+}
+
+func sendWelcomeEmail(ctx context.Context, cfg *config.Config, msg welcomeMessage) error {
+	_, err := sesv2.NewFromConfig(cfg.AWSConfig).SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(cfg.WelcomeEmailFrom),
+		Destination:      &sestypes.Destination{ToAddresses: []string{msg.Email}},
+		Content: &sestypes.EmailContent{
+			Simple: &sestypes.Message{
+				Subject: &sestypes.Content{Data: aws.String(msg.Subject)},
+				Body: &sestypes.Body{
+					Text: &sestypes.Content{Data: aws.String(msg.Message)},
+				},
+			},
+		},
+	})
+	return err
+}
+
+func sendWelcomeMessage(ctx context.Context, cfg *config.Config, msg welcomeMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = sqs.NewFromConfig(cfg.AWSConfig).SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(cfg.WelcomeQueue),
+		MessageBody: aws.String(string(body)),
+	})
+	return err
+}
+
+func postWelcome(ctx context.Context, hc *http.Client, url string, msg welcomeMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("welcome webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/pkg/ssosync"
+)
+
+// The changes are applied to the target first, the mirror is then
+// updated with the result. A failed change may have been applied anyway,
+// e.g. after a timeout, the mirror is then invalidated.
+
+// CreateUser creates the user in the target and the mirror
+func (m *Mirror) CreateUser(u *types.User) (*types.User, error) {
+	created, err := m.Target.CreateUser(u)
+	if err != nil {
+		m.invalidate(err)
+		return created, err
+	}
+	m.put(kindUser, aws.ToString(created.UserId), created)
+	return created, nil
+}
+
+// DeleteUser deletes the user from the target and the mirror
+func (m *Mirror) DeleteUser(u *types.User) error {
+	if err := m.Target.DeleteUser(u); err != nil {
+		m.invalidate(err)
+		return err
+	}
+	m.delete(kindUser, aws.ToString(u.UserId))
+	return nil
+}
+
+// UpdateUserType updates the user type in the target and the mirror, the
+// target must implement ssosync.UserTypeUpdater
+func (m *Mirror) UpdateUserType(u *types.User, userType *string) error {
+	updater, ok := m.Target.(ssosync.UserTypeUpdater)
+	if !ok {
+		return fmt.Errorf("target %T does not support updating user types", m.Target)
+	}
+	if err := updater.UpdateUserType(u, userType); err != nil {
+		m.invalidate(err)
+		return err
+	}
+	updated := *u
+	updated.UserType = userType
+	m.put(kindUser, aws.ToString(u.UserId), updated)
+	return nil
+}
+
+// UpdateUser updates the user in the target and the mirror, the target
+// must implement ssosync.UserUpdater
+func (m *Mirror) UpdateUser(u *types.User, attrs *types.User) error {
+	updater, ok := m.Target.(ssosync.UserUpdater)
+	if !ok {
+		return fmt.Errorf("target %T does not support updating users", m.Target)
+	}
+	if err := updater.UpdateUser(u, attrs); err != nil {
+		m.invalidate(err)
+		return err
+	}
+	updated := *u
+	if attrs.UserName != nil {
+		updated.UserName = attrs.UserName
+	}
+	if attrs.DisplayName != nil {
+		updated.DisplayName = attrs.DisplayName
+	}
+	if attrs.Name != nil {
+		updated.Name = attrs.Name
+	}
+	if attrs.Emails != nil {
+		updated.Emails = attrs.Emails
+	}
+	m.put(kindUser, aws.ToString(u.UserId), updated)
+	return nil
+}
+
+// CreateGroup creates the group in the target and the mirror
+func (m *Mirror) CreateGroup(name *string, description *string) (*types.Group, error) {
+	created, err := m.Target.CreateGroup(name, description)
+	if err != nil {
+		m.invalidate(err)
+		return created, err
+	}
+	m.put(kindGroup, aws.ToString(created.GroupId), created)
+	return created, nil
+}
+
+// UpdateGroup updates the group description in the target and the
+// mirror, the target must implement ssosync.GroupUpdater
+func (m *Mirror) UpdateGroup(g *types.Group, description *string) error {
+	updater, ok := m.Target.(ssosync.GroupUpdater)
+	if !ok {
+		return fmt.Errorf("target %T does not support updating groups", m.Target)
+	}
+	if err := updater.UpdateGroup(g, description); err != nil {
+		m.invalidate(err)
+		return err
+	}
+	updated := *g
+	updated.Description = description
+	m.put(kindGroup, aws.ToString(g.GroupId), updated)
+	return nil
+}
+
+// RenameGroup renames the group in the target and the mirror, the target
+// must implement ssosync.GroupRenamer
+func (m *Mirror) RenameGroup(g *types.Group, name *string) error {
+	renamer, ok := m.Target.(ssosync.GroupRenamer)
+	if !ok {
+		return fmt.Errorf("target %T does not support renaming groups", m.Target)
+	}
+	if err := renamer.RenameGroup(g, name); err != nil {
+		m.invalidate(err)
+		return err
+	}
+	updated := *g
+	updated.DisplayName = name
+	m.put(kindGroup, aws.ToString(g.GroupId), updated)
+	return nil
+}
+
+// DeleteGroup deletes the group from the target and the mirror
+func (m *Mirror) DeleteGroup(g *types.Group) error {
+	if err := m.Target.DeleteGroup(g); err != nil {
+		m.invalidate(err)
+		return err
+	}
+	m.delete(kindGroup, aws.ToString(g.GroupId))
+	return nil
+}
+
+// FindUserByUserName looks the user up in the target, e.g. after a
+// conflict, and records it in the mirror
+func (m *Mirror) FindUserByUserName(name string) (*types.User, error) {
+	u, err := m.Target.FindUserByUserName(name)
+	if err == nil {
+		m.put(kindUser, aws.ToString(u.UserId), u)
+	}
+	return u, err
+}
+
+// FindGroupByDisplayName looks the group up in the target, e.g. after a
+// conflict, and records it in the mirror
+func (m *Mirror) FindGroupByDisplayName(name string) (*types.Group, error) {
+	g, err := m.Target.FindGroupByDisplayName(name)
+	if err == nil {
+		m.put(kindGroup, aws.ToString(g.GroupId), g)
+	}
+	return g, err
+}
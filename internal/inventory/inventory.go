@@ -0,0 +1,371 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package inventory mirrors the users and groups of the target in
+// DynamoDB, so that a run reads them from the mirror instead of listing
+// the whole Identity Store, which is throttled to a few pages per second
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/pkg/ssosync"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultKeyAttribute is the partition key attribute of the items
+const DefaultKeyAttribute = "id"
+
+// batchSize is the maximum number of writes of a BatchWriteItem call
+const batchSize = 25
+
+// API is the part of the DynamoDB client used by the mirror
+type API interface {
+	GetItem(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(context.Context, *dynamodb.DeleteItemInput, ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	BatchWriteItem(context.Context, *dynamodb.BatchWriteItemInput, ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	Scan(context.Context, *dynamodb.ScanInput, ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// Location is the table and the key prefix of the items of a mirror
+type Location struct {
+	Table        string
+	KeyAttribute string
+	Prefix       string
+}
+
+// Parse parses the location of a mirror, dynamodb://<table>/<prefix>
+// with an optional ?key=<attribute> partition key attribute
+func Parse(uri string) (Location, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "dynamodb" || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return Location{}, fmt.Errorf("invalid inventory %q, expected dynamodb://<table>/<prefix>", uri)
+	}
+	l := Location{Table: u.Host, KeyAttribute: u.Query().Get("key"), Prefix: strings.Trim(u.Path, "/")}
+	if l.KeyAttribute == "" {
+		l.KeyAttribute = DefaultKeyAttribute
+	}
+	return l, nil
+}
+
+// Open returns the target mirrored at uri, the lists of the target are
+// read in full again once they are older than refresh
+func Open(ctx context.Context, cfg aws.Config, uri string, target ssosync.Target, refresh time.Duration) (ssosync.Target, error) {
+	l, err := Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	return New(ctx, dynamodb.NewFromConfig(cfg), l, target, refresh), nil
+}
+
+// Mirror is a target whose users and groups are read from DynamoDB, the
+// changes are applied to the target, then to the mirror. The mirror is
+// reconciled with a full listing of the target every refresh interval,
+// and after any change it may have missed, e.g. a failed write, so that
+// the changes made outside of ssosync are seen at the latest after the
+// refresh interval.
+type Mirror struct {
+	ssosync.Target
+
+	ctx     context.Context
+	api     API
+	loc     Location
+	refresh time.Duration
+
+	mu sync.Mutex
+	// invalid is set once a change may be missing from the mirror
+	invalid bool
+}
+
+// New returns the target mirrored in the table of api at loc
+func New(ctx context.Context, api API, loc Location, target ssosync.Target, refresh time.Duration) *Mirror {
+	return &Mirror{Target: target, ctx: ctx, api: api, loc: loc, refresh: refresh}
+}
+
+// the kinds of the mirrored items
+const (
+	kindUser  = "user"
+	kindGroup = "group"
+)
+
+// GetUsers returns the users of the mirror, or of the target when the
+// mirror is due for a refresh
+func (m *Mirror) GetUsers() ([]types.User, error) {
+	var res []types.User
+	err := m.list(kindUser, func() (map[string]interface{}, error) {
+		users, err := m.Target.GetUsers()
+		items := make(map[string]interface{}, len(users))
+		for _, u := range users {
+			items[aws.ToString(u.UserId)] = u
+		}
+		res = users
+		return items, err
+	}, func(data []byte) error {
+		var u types.User
+		if err := json.Unmarshal(data, &u); err != nil {
+			return err
+		}
+		res = append(res, u)
+		return nil
+	})
+	return res, err
+}
+
+// GetGroups returns the groups of the mirror, or of the target when the
+// mirror is due for a refresh
+func (m *Mirror) GetGroups() ([]types.Group, error) {
+	var res []types.Group
+	err := m.list(kindGroup, func() (map[string]interface{}, error) {
+		groups, err := m.Target.GetGroups()
+		items := make(map[string]interface{}, len(groups))
+		for _, g := range groups {
+			items[aws.ToString(g.GroupId)] = g
+		}
+		res = groups
+		return items, err
+	}, func(data []byte) error {
+		var g types.Group
+		if err := json.Unmarshal(data, &g); err != nil {
+			return err
+		}
+		res = append(res, g)
+		return nil
+	})
+	return res, err
+}
+
+// list reads the items of kind from the mirror with decode, unless the
+// mirror is due for a refresh, in which case they are listed from the
+// target with full and the mirror is reconciled with them
+func (m *Mirror) list(kind string, full func() (map[string]interface{}, error), decode func([]byte) error) error {
+	ll := log.WithField("kind", kind).WithField("inventory", m.loc.Table+"/"+m.loc.Prefix)
+	refreshed, err := m.refreshed(kind)
+	if err != nil {
+		return err
+	}
+	stored, err := m.scan(kind)
+	if err != nil {
+		return err
+	}
+
+	if !refreshed.IsZero() && time.Since(refreshed) < m.refresh && !m.isInvalid() {
+		ll.WithField("count", len(stored)).WithField("refreshed", refreshed.Format(time.RFC3339)).Debug("Reading the target from the inventory")
+		for _, data := range stored {
+			if err := decode([]byte(data)); err != nil {
+				return fmt.Errorf("cannot decode the inventory %s: %w", kind, err)
+			}
+		}
+		return nil
+	}
+
+	ll.Info("Inventory due for a refresh, listing the whole target")
+	items, err := full()
+	if err != nil {
+		return err
+	}
+	var writes []dbtypes.WriteRequest
+	for id, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if stored[id] != string(data) {
+			writes = append(writes, dbtypes.WriteRequest{PutRequest: &dbtypes.PutRequest{Item: m.item(kind, id, data)}})
+		}
+		delete(stored, id)
+	}
+	for id := range stored {
+		writes = append(writes, dbtypes.WriteRequest{DeleteRequest: &dbtypes.DeleteRequest{Key: m.key(kind, id)}})
+	}
+	if err := m.batchWrite(writes); err != nil {
+		ll.WithError(err).Error("Can't reconcile the inventory, it is refreshed again by the next run")
+		return nil
+	}
+	ll.WithField("count", len(items)).WithField("changed", len(writes)).Info("Inventory refreshed")
+	if m.isInvalid() {
+		// a change was missed during the refresh
+		return nil
+	}
+	_, err = m.api.PutItem(m.ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(m.loc.Table),
+		Item: map[string]dbtypes.AttributeValue{
+			m.loc.KeyAttribute: &dbtypes.AttributeValueMemberS{Value: m.metaId(kind)},
+			"refreshed":        &dbtypes.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+	})
+	if err != nil {
+		ll.WithError(err).Error("Can't record the refresh of the inventory, it is refreshed again by the next run")
+	}
+	return nil
+}
+
+// refreshed returns the time of the last refresh of kind, zero if never
+func (m *Mirror) refreshed(kind string) (time.Time, error) {
+	out, err := m.api.GetItem(m.ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(m.loc.Table),
+		Key:            map[string]dbtypes.AttributeValue{m.loc.KeyAttribute: &dbtypes.AttributeValueMemberS{Value: m.metaId(kind)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot read the inventory %s/%s: %w", m.loc.Table, m.loc.Prefix, err)
+	}
+	n, ok := out.Item["refreshed"].(*dbtypes.AttributeValueMemberN)
+	if !ok {
+		return time.Time{}, nil
+	}
+	sec, err := strconv.ParseInt(n.Value, 10, 64)
+	if err != nil {
+		return time.Time{}, nil
+	}
+	return time.Unix(sec, 0), nil
+}
+
+// scan returns the data of the items of kind, by id
+func (m *Mirror) scan(kind string) (map[string]string, error) {
+	prefix := m.itemPrefix(kind)
+	res := make(map[string]string)
+	paginator := dynamodb.NewScanPaginator(m.api, &dynamodb.ScanInput{
+		TableName:                aws.String(m.loc.Table),
+		FilterExpression:         aws.String("begins_with(#key, :prefix)"),
+		ExpressionAttributeNames: map[string]string{"#key": m.loc.KeyAttribute},
+		ExpressionAttributeValues: map[string]dbtypes.AttributeValue{
+			":prefix": &dbtypes.AttributeValueMemberS{Value: prefix},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(m.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the inventory %s/%s: %w", m.loc.Table, m.loc.Prefix, err)
+		}
+		for _, item := range out.Items {
+			id, _ := item[m.loc.KeyAttribute].(*dbtypes.AttributeValueMemberS)
+			data, _ := item["data"].(*dbtypes.AttributeValueMemberS)
+			if id != nil && data != nil {
+				res[strings.TrimPrefix(id.Value, prefix)] = data.Value
+			}
+		}
+	}
+	return res, nil
+}
+
+// batchWrite applies the writes, retrying the unprocessed ones
+func (m *Mirror) batchWrite(writes []dbtypes.WriteRequest) error {
+	for len(writes) > 0 {
+		n := len(writes)
+		if n > batchSize {
+			n = batchSize
+		}
+		batch := writes[:n]
+		writes = writes[n:]
+		for attempt := 0; len(batch) > 0; attempt++ {
+			if attempt == 5 {
+				return errors.New("writes left unprocessed by DynamoDB")
+			}
+			if attempt > 0 {
+				time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+			}
+			out, err := m.api.BatchWriteItem(m.ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]dbtypes.WriteRequest{m.loc.Table: batch},
+			})
+			if err != nil {
+				return err
+			}
+			batch = out.UnprocessedItems[m.loc.Table]
+		}
+	}
+	return nil
+}
+
+// put records the item of kind in the mirror
+func (m *Mirror) put(kind, id string, item interface{}) {
+	data, err := json.Marshal(item)
+	if err == nil {
+		_, err = m.api.PutItem(m.ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(m.loc.Table),
+			Item:      m.item(kind, id, data),
+		})
+	}
+	if err != nil {
+		m.invalidate(err)
+	}
+}
+
+// delete removes the item of kind from the mirror
+func (m *Mirror) delete(kind, id string) {
+	_, err := m.api.DeleteItem(m.ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(m.loc.Table),
+		Key:       m.key(kind, id),
+	})
+	if err != nil {
+		m.invalidate(err)
+	}
+}
+
+// invalidate forces a refresh by the next listing, as the mirror may
+// miss a change because of err
+func (m *Mirror) invalidate(err error) {
+	m.mu.Lock()
+	m.invalid = true
+	m.mu.Unlock()
+
+	ll := log.WithError(err).WithField("inventory", m.loc.Table+"/"+m.loc.Prefix)
+	ll.Warn("The inventory may miss a change, it is refreshed by the next run")
+	for _, kind := range []string{kindUser, kindGroup} {
+		_, err := m.api.DeleteItem(m.ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(m.loc.Table),
+			Key:       map[string]dbtypes.AttributeValue{m.loc.KeyAttribute: &dbtypes.AttributeValueMemberS{Value: m.metaId(kind)}},
+		})
+		if err != nil {
+			ll.WithError(err).Error("Can't invalidate the inventory, it may be stale until its refresh interval")
+		}
+	}
+}
+
+func (m *Mirror) isInvalid() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.invalid
+}
+
+func (m *Mirror) metaId(kind string) string {
+	return m.loc.Prefix + "/refreshed/" + kind
+}
+
+func (m *Mirror) itemPrefix(kind string) string {
+	return m.loc.Prefix + "/" + kind + "/"
+}
+
+func (m *Mirror) key(kind, id string) map[string]dbtypes.AttributeValue {
+	return map[string]dbtypes.AttributeValue{m.loc.KeyAttribute: &dbtypes.AttributeValueMemberS{Value: m.itemPrefix(kind) + id}}
+}
+
+func (m *Mirror) item(kind, id string, data []byte) map[string]dbtypes.AttributeValue {
+	item := m.key(kind, id)
+	item["data"] = &dbtypes.AttributeValueMemberS{Value: string(data)}
+	return item
+}
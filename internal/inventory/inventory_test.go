@@ -0,0 +1,205 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/awslabs/ssosync/internal/inventory"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/pkg/ssosync"
+	"github.com/stretchr/testify/assert"
+)
+
+// table is a DynamoDB table keyed by id, scanned by key prefix
+type table struct {
+	mu     sync.Mutex
+	items  map[string]map[string]dbtypes.AttributeValue
+	writes int
+	fail   bool
+}
+
+func id(key map[string]dbtypes.AttributeValue) string {
+	return key["id"].(*dbtypes.AttributeValueMemberS).Value
+}
+
+func (t *table) GetItem(_ context.Context, in *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &dynamodb.GetItemOutput{Item: t.items[id(in.Key)]}, nil
+}
+
+func (t *table) PutItem(_ context.Context, in *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.fail && strings.Contains(id(in.Item), "/user/") {
+		return nil, errors.New("throttled")
+	}
+	t.items[id(in.Item)] = in.Item
+	t.writes++
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (t *table) DeleteItem(_ context.Context, in *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.items, id(in.Key))
+	t.writes++
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (t *table) BatchWriteItem(_ context.Context, in *dynamodb.BatchWriteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, reqs := range in.RequestItems {
+		for _, r := range reqs {
+			if r.PutRequest != nil {
+				t.items[id(r.PutRequest.Item)] = r.PutRequest.Item
+			} else {
+				delete(t.items, id(r.DeleteRequest.Key))
+			}
+			t.writes++
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+func (t *table) Scan(_ context.Context, in *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	prefix := in.ExpressionAttributeValues[":prefix"].(*dbtypes.AttributeValueMemberS).Value
+	out := &dynamodb.ScanOutput{}
+	for k, item := range t.items {
+		if strings.HasPrefix(k, prefix) {
+			out.Items = append(out.Items, item)
+		}
+	}
+	return out, nil
+}
+
+// counting counts the listings of the target
+type counting struct {
+	*ssosync.MemoryTarget
+	lists int
+}
+
+func (c *counting) GetUsers() ([]types.User, error) {
+	c.lists++
+	return c.MemoryTarget.GetUsers()
+}
+
+func (c *counting) GetGroups() ([]types.Group, error) {
+	c.lists++
+	return c.MemoryTarget.GetGroups()
+}
+
+func names(users []types.User) []string {
+	var res []string
+	for _, u := range users {
+		res = append(res, aws.ToString(u.UserName))
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	l, err := Parse("dynamodb://ssosync/d-1234567890")
+	assert.NoError(err)
+	assert.Equal(Location{Table: "ssosync", KeyAttribute: "id", Prefix: "d-1234567890"}, l)
+
+	l, err = Parse("dynamodb://ssosync/inventory?key=pk")
+	assert.NoError(err)
+	assert.Equal("pk", l.KeyAttribute)
+
+	_, err = Parse("s3://bucket/key")
+	assert.Error(err)
+	_, err = Parse("dynamodb://ssosync")
+	assert.Error(err)
+}
+
+func TestMirror(t *testing.T) {
+	assert := assert.New(t)
+
+	db := &table{items: make(map[string]map[string]dbtypes.AttributeValue)}
+	loc := Location{Table: "ssosync", KeyAttribute: "id", Prefix: "d-1"}
+	target := &counting{MemoryTarget: ssosync.NewMemoryTarget()}
+	_, err := target.CreateUser(&types.User{UserName: aws.String("outside@example.com")})
+	assert.NoError(err)
+
+	// the first run lists the target and fills the mirror
+	m := New(context.Background(), db, loc, target, time.Hour)
+	users, err := m.GetUsers()
+	assert.NoError(err)
+	assert.Equal([]string{"outside@example.com"}, names(users))
+	_, err = m.GetGroups()
+	assert.NoError(err)
+	assert.Equal(2, target.lists)
+
+	created, err := m.CreateUser(&types.User{UserName: aws.String("ana@example.com")})
+	assert.NoError(err)
+	assert.NoError(m.UpdateUserType(created, aws.String("[managed-by=ssosync]")))
+	g, err := m.CreateGroup(aws.String("Platform"), nil)
+	assert.NoError(err)
+	assert.NoError(m.RenameGroup(g, aws.String("Engineering")))
+
+	// the next runs read the mirror
+	m = New(context.Background(), db, loc, target, time.Hour)
+	users, err = m.GetUsers()
+	assert.NoError(err)
+	assert.Equal([]string{"ana@example.com", "outside@example.com"}, names(users))
+	for _, u := range users {
+		if aws.ToString(u.UserName) == "ana@example.com" {
+			assert.Equal("[managed-by=ssosync]", aws.ToString(u.UserType))
+		}
+	}
+	groups, err := m.GetGroups()
+	assert.NoError(err)
+	assert.Len(groups, 1)
+	assert.Equal("Engineering", aws.ToString(groups[0].DisplayName))
+	assert.Equal(2, target.lists)
+
+	assert.NoError(m.DeleteUser(created))
+	users, _ = New(context.Background(), db, loc, target, time.Hour).GetUsers()
+	assert.Equal([]string{"outside@example.com"}, names(users))
+
+	// a failed write of the mirror forces a full listing
+	db.fail = true
+	_, err = m.CreateUser(&types.User{UserName: aws.String("bo@example.com")})
+	assert.NoError(err)
+	db.fail = false
+	m = New(context.Background(), db, loc, target, time.Hour)
+	users, _ = m.GetUsers()
+	assert.Equal([]string{"bo@example.com", "outside@example.com"}, names(users))
+	assert.Equal(3, target.lists)
+
+	// a refresh writes only the changed items
+	db.writes = 0
+	users, _ = New(context.Background(), db, loc, target, 0).GetUsers()
+	assert.Len(users, 2)
+	assert.Equal(1, db.writes)
+	assert.Equal(4, target.lists)
+}
@@ -0,0 +1,477 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keycloak is the sync target of a Keycloak realm, through the
+// Keycloak admin REST API
+package keycloak
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/internal/paging"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	// pageSize is the number of users, groups or members per page
+	pageSize = 100
+
+	// attributeDisplayName, attributeUserType and attributeDescription
+	// hold the Identity Store attributes Keycloak has no field for
+	attributeDisplayName = "displayName"
+	attributeUserType    = "userType"
+	attributeDescription = "description"
+	// attributeExternalId prefixes the attributes of the external ids,
+	// by issuer, e.g. externalId.Google
+	attributeExternalId = "externalId."
+)
+
+var (
+	// ErrUserNotFound is returned by FindUserByUserName for a missing user
+	ErrUserNotFound = errors.New("user not found")
+	// ErrGroupNotFound is returned by FindGroupByDisplayName for a missing
+	// group
+	ErrGroupNotFound = errors.New("group not found")
+
+	// errConflict is the error of a create conflicting with an existing
+	// user or group
+	errConflict = errors.New("conflict")
+)
+
+// Options configure the Client
+type Options struct {
+	// URL is the base URL of Keycloak, e.g. https://sso.example.com
+	URL string
+	// Realm is the realm the users and groups are synced to
+	Realm string
+	// TokenRealm is the realm of the client, Realm when empty
+	TokenRealm string
+	// ClientID and ClientSecret are the credentials of a client with a
+	// service account holding the manage-users role of the realm
+	ClientID     string
+	ClientSecret string
+}
+
+// Client is a Target writing the users, groups and memberships of a
+// Keycloak realm. The Identity Store attributes without Keycloak field,
+// e.g. the display name or the external ids, are user attributes.
+type Client struct {
+	ctx  context.Context
+	http *http.Client
+	base string
+}
+
+// New returns the Client of the realm, authenticated with the client
+// credentials grant, hc is the transport
+func New(ctx context.Context, hc *http.Client, opts Options) (*Client, error) {
+	if opts.URL == "" || opts.Realm == "" || opts.ClientID == "" {
+		return nil, errors.New("keycloak url, realm and client id are required")
+	}
+	tokenRealm := opts.TokenRealm
+	if tokenRealm == "" {
+		tokenRealm = opts.Realm
+	}
+	base := strings.TrimSuffix(opts.URL, "/")
+	cc := clientcredentials.Config{
+		ClientID:     opts.ClientID,
+		ClientSecret: opts.ClientSecret,
+		TokenURL:     base + "/realms/" + url.PathEscape(tokenRealm) + "/protocol/openid-connect/token",
+	}
+	return &Client{
+		ctx:  ctx,
+		http: cc.Client(context.WithValue(ctx, oauth2.HTTPClient, hc)),
+		base: base + "/admin/realms/" + url.PathEscape(opts.Realm),
+	}, nil
+}
+
+// user is the Keycloak user representation
+type user struct {
+	ID         string              `json:"id,omitempty"`
+	Username   string              `json:"username"`
+	Email      string              `json:"email,omitempty"`
+	FirstName  string              `json:"firstName,omitempty"`
+	LastName   string              `json:"lastName,omitempty"`
+	Enabled    bool                `json:"enabled"`
+	Attributes map[string][]string `json:"attributes,omitempty"`
+}
+
+// group is the Keycloak group representation
+type group struct {
+	ID         string              `json:"id,omitempty"`
+	Name       string              `json:"name"`
+	Attributes map[string][]string `json:"attributes,omitempty"`
+}
+
+// CreateUser creates the user, adopting an existing user with the same
+// user name on conflict
+func (c *Client) CreateUser(u *types.User) (*types.User, error) {
+	id, err := c.create("/users", fromUser(u))
+	if errors.Is(err, errConflict) {
+		existing, findErr := c.FindUserByUserName(aws.ToString(u.UserName))
+		if findErr != nil {
+			return nil, err
+		}
+		u.UserId = existing.UserId
+		return u, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.UserId = aws.String(id)
+	return u, nil
+}
+
+// DeleteUser deletes the user
+func (c *Client) DeleteUser(u *types.User) error {
+	return c.do(http.MethodDelete, "/users/"+url.PathEscape(aws.ToString(u.UserId)), nil, nil)
+}
+
+// UpdateUserType replaces the user type attribute of the user
+func (c *Client) UpdateUserType(u *types.User, userType *string) error {
+	var kc user
+	p := "/users/" + url.PathEscape(aws.ToString(u.UserId))
+	if err := c.do(http.MethodGet, p, nil, &kc); err != nil {
+		return err
+	}
+	if kc.Attributes == nil {
+		kc.Attributes = make(map[string][]string)
+	}
+	kc.Attributes[attributeUserType] = []string{aws.ToString(userType)}
+	return c.do(http.MethodPut, p, kc, nil)
+}
+
+// CreateGroup creates the top level group, adopting an existing group
+// with the same name on conflict
+func (c *Client) CreateGroup(name *string, description *string) (*types.Group, error) {
+	g := group{Name: aws.ToString(name)}
+	if description != nil {
+		g.Attributes = map[string][]string{attributeDescription: {aws.ToString(description)}}
+	}
+	id, err := c.create("/groups", g)
+	if errors.Is(err, errConflict) {
+		existing, findErr := c.FindGroupByDisplayName(aws.ToString(name))
+		if findErr != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &types.Group{GroupId: aws.String(id), DisplayName: name, Description: description}, nil
+}
+
+// UpdateGroup replaces the description attribute of the group
+func (c *Client) UpdateGroup(g *types.Group, description *string) error {
+	var kc group
+	p := "/groups/" + url.PathEscape(aws.ToString(g.GroupId))
+	if err := c.do(http.MethodGet, p, nil, &kc); err != nil {
+		return err
+	}
+	if kc.Attributes == nil {
+		kc.Attributes = make(map[string][]string)
+	}
+	kc.Attributes[attributeDescription] = []string{aws.ToString(description)}
+	return c.do(http.MethodPut, p, kc, nil)
+}
+
+// DeleteGroup deletes the group
+func (c *Client) DeleteGroup(g *types.Group) error {
+	return c.do(http.MethodDelete, "/groups/"+url.PathEscape(aws.ToString(g.GroupId)), nil, nil)
+}
+
+// AddUserToGroup adds the user to the group, which is idempotent
+func (c *Client) AddUserToGroup(u *types.User, g *types.Group) (*types.GroupMembership, error) {
+	userId, groupId := aws.ToString(u.UserId), aws.ToString(g.GroupId)
+	if err := c.do(http.MethodPut, "/users/"+url.PathEscape(userId)+"/groups/"+url.PathEscape(groupId), nil, nil); err != nil {
+		return nil, err
+	}
+	return membership(groupId, userId), nil
+}
+
+// RemoveGroupMembership removes the user from the group
+func (c *Client) RemoveGroupMembership(m *types.GroupMembership) error {
+	groupId, userId, ok := strings.Cut(aws.ToString(m.MembershipId), "/")
+	if !ok {
+		return fmt.Errorf("invalid keycloak membership id %q", aws.ToString(m.MembershipId))
+	}
+	return c.do(http.MethodDelete, "/users/"+url.PathEscape(userId)+"/groups/"+url.PathEscape(groupId), nil, nil)
+}
+
+// GetGroupMembers returns the memberships of the group
+func (c *Client) GetGroupMembers(g *types.Group) ([]types.GroupMembership, error) {
+	groupId := aws.ToString(g.GroupId)
+	var res []types.GroupMembership
+	err := c.list("/groups/"+url.PathEscape(groupId)+"/members", url.Values{"briefRepresentation": {"true"}}, "ListGroupMembers", func(b []byte) (int, error) {
+		var page []user
+		if err := json.Unmarshal(b, &page); err != nil {
+			return 0, err
+		}
+		for _, u := range page {
+			res = append(res, *membership(groupId, u.ID))
+		}
+		return len(page), nil
+	})
+	return res, err
+}
+
+// GetGroups returns the top level groups of the realm
+func (c *Client) GetGroups() ([]types.Group, error) {
+	var res []types.Group
+	err := c.list("/groups", url.Values{"briefRepresentation": {"false"}}, "ListGroups", func(b []byte) (int, error) {
+		var page []group
+		if err := json.Unmarshal(b, &page); err != nil {
+			return 0, err
+		}
+		for _, g := range page {
+			res = append(res, toGroup(g))
+		}
+		return len(page), nil
+	})
+	return res, err
+}
+
+// GetUsers returns the users of the realm
+func (c *Client) GetUsers() ([]types.User, error) {
+	var res []types.User
+	err := c.list("/users", url.Values{"briefRepresentation": {"false"}}, "ListUsers", func(b []byte) (int, error) {
+		var page []user
+		if err := json.Unmarshal(b, &page); err != nil {
+			return 0, err
+		}
+		for _, u := range page {
+			res = append(res, toUser(u))
+		}
+		return len(page), nil
+	})
+	return res, err
+}
+
+// FindUserByUserName returns the user with the user name
+func (c *Client) FindUserByUserName(userName string) (*types.User, error) {
+	var page []user
+	q := url.Values{"username": {userName}, "exact": {"true"}, "briefRepresentation": {"false"}}
+	if err := c.do(http.MethodGet, "/users?"+q.Encode(), nil, &page); err != nil {
+		return nil, err
+	}
+	for _, u := range page {
+		// Keycloak lowercases the user names
+		if strings.EqualFold(u.Username, userName) {
+			res := toUser(u)
+			return &res, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+// FindGroupByDisplayName returns the top level group with the name
+func (c *Client) FindGroupByDisplayName(displayName string) (*types.Group, error) {
+	var page []group
+	q := url.Values{"search": {displayName}, "exact": {"true"}, "briefRepresentation": {"false"}}
+	if err := c.do(http.MethodGet, "/groups?"+q.Encode(), nil, &page); err != nil {
+		return nil, err
+	}
+	for _, g := range page {
+		if g.Name == displayName {
+			res := toGroup(g)
+			return &res, nil
+		}
+	}
+	return nil, ErrGroupNotFound
+}
+
+// list gets the pages of the collection at p, decoded by add which
+// returns the number of items of the page
+func (c *Client) list(p string, q url.Values, operation string, add func([]byte) (int, error)) error {
+	pages := paging.Start("keycloak", operation)
+	defer pages.Done()
+	for first := 0; ; first += pageSize {
+		q.Set("first", strconv.Itoa(first))
+		q.Set("max", strconv.Itoa(pageSize))
+		var b json.RawMessage
+		if err := c.do(http.MethodGet, p+"?"+q.Encode(), nil, &b); err != nil {
+			return err
+		}
+		n, err := add(b)
+		if err != nil {
+			return fmt.Errorf("invalid keycloak response of %s: %w", p, err)
+		}
+		next := ""
+		if n == pageSize {
+			next = strconv.Itoa(first + pageSize)
+		}
+		pages.Page(n, next)
+		if next == "" {
+			return nil
+		}
+	}
+}
+
+// create posts the representation to the collection at p and returns
+// the id of the created resource, the last segment of its location
+func (c *Client) create(p string, body interface{}) (string, error) {
+	res, err := c.request(http.MethodPost, p, body)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	loc := res.Header.Get("Location")
+	if loc == "" {
+		return "", fmt.Errorf("keycloak POST %s: no location of the created resource", p)
+	}
+	return path.Base(loc), nil
+}
+
+// do sends the request and decodes the response into out, if not nil
+func (c *Client) do(method, p string, body, out interface{}) error {
+	res, err := c.request(method, p, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if out == nil {
+		_, err = io.Copy(ioutil.Discard, res.Body)
+		return err
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid keycloak response of %s %s: %w", method, p, err)
+	}
+	return nil
+}
+
+// request sends the request, a response which is not 2xx is an error,
+// errConflict for 409
+func (c *Client) request(method, p string, body interface{}) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(c.ctx, method, c.base+p, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	b, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+	if res.StatusCode == http.StatusConflict {
+		return nil, fmt.Errorf("keycloak %s %s: %w: %s", method, p, errConflict, b)
+	}
+	return nil, fmt.Errorf("keycloak %s %s: %s: %s", method, p, res.Status, b)
+}
+
+// membership is the membership of the user in the group, its id is the
+// group id and the user id
+func membership(groupId, userId string) *types.GroupMembership {
+	return &types.GroupMembership{
+		MembershipId: aws.String(groupId + "/" + userId),
+		GroupId:      aws.String(groupId),
+		MemberId:     &types.MemberIdMemberUserId{Value: userId},
+	}
+}
+
+// fromUser returns the Keycloak representation of the user
+func fromUser(u *types.User) user {
+	kc := user{Username: aws.ToString(u.UserName), Enabled: true, Attributes: make(map[string][]string)}
+	if u.Name != nil {
+		kc.FirstName = aws.ToString(u.Name.GivenName)
+		kc.LastName = aws.ToString(u.Name.FamilyName)
+	}
+	for _, e := range u.Emails {
+		if e.Primary || kc.Email == "" {
+			kc.Email = aws.ToString(e.Value)
+		}
+	}
+	if u.DisplayName != nil {
+		kc.Attributes[attributeDisplayName] = []string{aws.ToString(u.DisplayName)}
+	}
+	if u.UserType != nil {
+		kc.Attributes[attributeUserType] = []string{aws.ToString(u.UserType)}
+	}
+	for _, id := range u.ExternalIds {
+		kc.Attributes[attributeExternalId+aws.ToString(id.Issuer)] = []string{aws.ToString(id.Id)}
+	}
+	return kc
+}
+
+// toUser returns the user of the Keycloak representation
+func toUser(kc user) types.User {
+	u := types.User{
+		UserId:   aws.String(kc.ID),
+		UserName: aws.String(kc.Username),
+		Name: &types.Name{
+			GivenName:  aws.String(kc.FirstName),
+			FamilyName: aws.String(kc.LastName),
+		},
+		DisplayName: aws.String(strings.TrimSpace(kc.FirstName + " " + kc.LastName)),
+	}
+	if kc.Email != "" {
+		u.Emails = []types.Email{{Primary: true, Type: aws.String("work"), Value: aws.String(kc.Email)}}
+	}
+	if v := attribute(kc.Attributes, attributeDisplayName); v != "" {
+		u.DisplayName = aws.String(v)
+	}
+	if v := attribute(kc.Attributes, attributeUserType); v != "" {
+		u.UserType = aws.String(v)
+	}
+	for k, v := range kc.Attributes {
+		if strings.HasPrefix(k, attributeExternalId) && len(v) > 0 {
+			u.ExternalIds = append(u.ExternalIds, types.ExternalId{Issuer: aws.String(strings.TrimPrefix(k, attributeExternalId)), Id: aws.String(v[0])})
+		}
+	}
+	return u
+}
+
+// toGroup returns the group of the Keycloak representation
+func toGroup(kc group) types.Group {
+	g := types.Group{GroupId: aws.String(kc.ID), DisplayName: aws.String(kc.Name)}
+	if v := attribute(kc.Attributes, attributeDescription); v != "" {
+		g.Description = aws.String(v)
+	}
+	return g
+}
+
+// attribute returns the first value of the attribute, empty if none
+func attribute(attributes map[string][]string, name string) string {
+	if v := attributes[name]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
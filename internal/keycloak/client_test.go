@@ -0,0 +1,194 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keycloak_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	. "github.com/awslabs/ssosync/internal/keycloak"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// realm is a fake Keycloak realm of the admin REST API
+type realm struct {
+	mu      sync.Mutex
+	users   map[string]map[string]interface{}
+	groups  map[string]map[string]interface{}
+	members map[string]map[string]bool
+	next    int
+}
+
+func newRealm() *realm {
+	return &realm{
+		users:   make(map[string]map[string]interface{}),
+		groups:  make(map[string]map[string]interface{}),
+		members: make(map[string]map[string]bool),
+	}
+}
+
+func (r *realm) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.URL.Path == "/realms/test/protocol/openid-connect/token" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"token","token_type":"bearer","expires_in":300}`))
+		return
+	}
+	if req.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	p := strings.Split(strings.TrimPrefix(req.URL.Path, "/admin/realms/test/"), "/")
+	switch {
+	case req.Method == http.MethodPost && len(p) == 1:
+		var v map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&v)
+		store, key := r.users, "username"
+		if p[0] == "groups" {
+			store, key = r.groups, "name"
+		}
+		for _, e := range store {
+			if e[key] == v[key] {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+		}
+		r.next++
+		id := p[0] + "-" + string(rune('0'+r.next))
+		v["id"] = id
+		store[id] = v
+		w.Header().Set("Location", "http://keycloak/admin/realms/test/"+p[0]+"/"+id)
+		w.WriteHeader(http.StatusCreated)
+	case req.Method == http.MethodGet && len(p) == 1:
+		store, key, q := r.users, "username", req.URL.Query().Get("username")
+		if p[0] == "groups" {
+			store, key, q = r.groups, "name", req.URL.Query().Get("search")
+		}
+		res := []map[string]interface{}{}
+		for _, e := range store {
+			if q == "" || e[key] == q {
+				res = append(res, e)
+			}
+		}
+		if req.URL.Query().Get("first") != "" && req.URL.Query().Get("first") != "0" {
+			res = nil
+		}
+		json.NewEncoder(w).Encode(res)
+	case len(p) == 3 && p[0] == "groups" && p[2] == "members":
+		res := []map[string]interface{}{}
+		for id := range r.members[p[1]] {
+			res = append(res, r.users[id])
+		}
+		json.NewEncoder(w).Encode(res)
+	case len(p) == 4 && p[0] == "users" && p[2] == "groups":
+		if req.Method == http.MethodPut {
+			if r.members[p[3]] == nil {
+				r.members[p[3]] = make(map[string]bool)
+			}
+			r.members[p[3]][p[1]] = true
+		} else {
+			delete(r.members[p[3]], p[1])
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(p) == 2:
+		store := r.users
+		if p[0] == "groups" {
+			store = r.groups
+		}
+		switch req.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(store[p[1]])
+		case http.MethodPut:
+			var v map[string]interface{}
+			json.NewDecoder(req.Body).Decode(&v)
+			store[p[1]] = v
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			delete(store, p[1])
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestClient(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := httptest.NewServer(newRealm())
+	defer srv.Close()
+
+	c, err := New(context.Background(), srv.Client(), Options{URL: srv.URL, Realm: "test", ClientID: "ssosync", ClientSecret: "secret"})
+	assert.NoError(err)
+
+	u, err := c.CreateUser(&types.User{
+		UserName:    aws.String("jane@example.com"),
+		DisplayName: aws.String("Jane D."),
+		Name:        &types.Name{GivenName: aws.String("Jane"), FamilyName: aws.String("Doe")},
+		Emails:      []types.Email{{Primary: true, Value: aws.String("jane@example.com")}},
+		UserType:    aws.String("[managed-by=ssosync]"),
+	})
+	assert.NoError(err)
+	assert.NotEmpty(aws.ToString(u.UserId))
+
+	// a conflict adopts the existing user
+	again, err := c.CreateUser(&types.User{UserName: aws.String("jane@example.com")})
+	assert.NoError(err)
+	assert.Equal(aws.ToString(u.UserId), aws.ToString(again.UserId))
+
+	found, err := c.FindUserByUserName("jane@example.com")
+	assert.NoError(err)
+	assert.Equal("Jane D.", aws.ToString(found.DisplayName))
+	assert.Equal("[managed-by=ssosync]", aws.ToString(found.UserType))
+	assert.Equal("jane@example.com", aws.ToString(found.Emails[0].Value))
+
+	_, err = c.FindUserByUserName("john@example.com")
+	assert.ErrorIs(err, ErrUserNotFound)
+
+	g, err := c.CreateGroup(aws.String("admins"), aws.String("Admins"))
+	assert.NoError(err)
+	assert.NoError(c.UpdateGroup(g, aws.String("All admins")))
+
+	groups, err := c.GetGroups()
+	assert.NoError(err)
+	assert.Len(groups, 1)
+	assert.Equal("All admins", aws.ToString(groups[0].Description))
+
+	m, err := c.AddUserToGroup(u, g)
+	assert.NoError(err)
+	members, err := c.GetGroupMembers(g)
+	assert.NoError(err)
+	assert.Equal([]types.GroupMembership{*m}, members)
+
+	assert.NoError(c.RemoveGroupMembership(m))
+	members, err = c.GetGroupMembers(g)
+	assert.NoError(err)
+	assert.Empty(members)
+
+	assert.NoError(c.DeleteUser(u))
+	users, err := c.GetUsers()
+	assert.NoError(err)
+	assert.Empty(users)
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lock is the distributed lock preventing concurrent sync runs,
+// e.g. a manual run during a scheduled run, held in a DynamoDB item
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultKeyAttribute is the partition key attribute of the lock item
+const DefaultKeyAttribute = "id"
+
+// ErrLocked is returned by Acquire when another run holds the lock
+var ErrLocked = errors.New("the lock is held by another run")
+
+// API is the part of the DynamoDB client used by the lock
+type API interface {
+	PutItem(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	GetItem(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	UpdateItem(context.Context, *dynamodb.UpdateItemInput, ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(context.Context, *dynamodb.DeleteItemInput, ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+// Location is the DynamoDB item of a lock
+type Location struct {
+	Table        string
+	KeyAttribute string
+	Key          string
+}
+
+// Parse parses the location of a lock, dynamodb://<table>/<key> with an
+// optional ?key=<attribute> partition key attribute
+func Parse(uri string) (Location, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "dynamodb" || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return Location{}, fmt.Errorf("invalid lock %q, expected dynamodb://<table>/<key>", uri)
+	}
+	l := Location{Table: u.Host, KeyAttribute: u.Query().Get("key"), Key: strings.Trim(u.Path, "/")}
+	if l.KeyAttribute == "" {
+		l.KeyAttribute = DefaultKeyAttribute
+	}
+	return l, nil
+}
+
+// Lock is a lock held by this run, renewed until released
+type Lock struct {
+	api   API
+	loc   Location
+	owner string
+	ttl   time.Duration
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// Owner returns a unique owner of a lock for this process, with the
+// host name and the process id to tell the holder of a lock
+func Owner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s/%d/%d", host, os.Getpid(), time.Now().UnixNano())
+}
+
+// Open returns the DynamoDB client and the location of the lock at uri
+func Open(cfg aws.Config, uri string) (API, Location, error) {
+	l, err := Parse(uri)
+	if err != nil {
+		return nil, l, err
+	}
+	return dynamodb.NewFromConfig(cfg), l, nil
+}
+
+// Acquire takes the lock for owner, unless another owner holds it and it
+// has not expired, in which case the error is ErrLocked. The lock expires
+// after ttl, it is renewed every third of it until released, so that
+// only the locks of crashed runs expire.
+func Acquire(ctx context.Context, api API, loc Location, owner string, ttl time.Duration) (*Lock, error) {
+	now := time.Now()
+	_, err := api.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(loc.Table),
+		Item: map[string]types.AttributeValue{
+			loc.KeyAttribute: &types.AttributeValueMemberS{Value: loc.Key},
+			"owner":          &types.AttributeValueMemberS{Value: owner},
+			"acquired":       &types.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+			"expires":        expires(now, ttl),
+		},
+		ConditionExpression:      aws.String("attribute_not_exists(#key) OR expires < :now"),
+		ExpressionAttributeNames: map[string]string{"#key": loc.KeyAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return nil, fmt.Errorf("%w: %s", ErrLocked, holder(ctx, api, loc))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot acquire the lock %s/%s: %w", loc.Table, loc.Key, err)
+	}
+
+	l := &Lock{api: api, loc: loc, owner: owner, ttl: ttl, stop: make(chan struct{})}
+	l.done.Add(1)
+	go l.renew(ctx)
+	return l, nil
+}
+
+// Release stops the renewals and releases the lock, unless it expired
+// and was taken by another run meanwhile
+func (l *Lock) Release(ctx context.Context) error {
+	close(l.stop)
+	l.done.Wait()
+
+	_, err := l.api.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(l.loc.Table),
+		Key:                 l.key(),
+		ConditionExpression: aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]string{
+			"#owner": "owner",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":owner": &types.AttributeValueMemberS{Value: l.owner},
+		},
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &ccf) {
+		return fmt.Errorf("cannot release the lock %s/%s: %w", l.loc.Table, l.loc.Key, err)
+	}
+	return nil
+}
+
+// renew extends the expiry of the lock every third of its ttl
+func (l *Lock) renew(ctx context.Context) {
+	defer l.done.Done()
+	t := time.NewTicker(l.ttl / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			_, err := l.api.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+				TableName:           aws.String(l.loc.Table),
+				Key:                 l.key(),
+				UpdateExpression:    aws.String("SET expires = :expires"),
+				ConditionExpression: aws.String("#owner = :owner"),
+				ExpressionAttributeNames: map[string]string{
+					"#owner": "owner",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":expires": expires(now, l.ttl),
+					":owner":   &types.AttributeValueMemberS{Value: l.owner},
+				},
+			})
+			if err != nil {
+				log.WithError(err).Warn("Can't renew the lock, it may expire before the end of the run")
+			}
+		}
+	}
+}
+
+func (l *Lock) key() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		l.loc.KeyAttribute: &types.AttributeValueMemberS{Value: l.loc.Key},
+	}
+}
+
+// expires is the expiry of a lock acquired or renewed at t, in Unix
+// seconds so that it can be the TTL attribute of the table
+func expires(t time.Time, ttl time.Duration) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.Add(ttl).Unix(), 10)}
+}
+
+// holder describes the holder of the lock, for the error of Acquire
+func holder(ctx context.Context, api API, loc Location) string {
+	out, err := api.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(loc.Table),
+		Key:            map[string]types.AttributeValue{loc.KeyAttribute: &types.AttributeValueMemberS{Value: loc.Key}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil || out.Item == nil {
+		return "unknown holder"
+	}
+	var owner, acquired string
+	if v, ok := out.Item["owner"].(*types.AttributeValueMemberS); ok {
+		owner = v.Value
+	}
+	if v, ok := out.Item["acquired"].(*types.AttributeValueMemberS); ok {
+		acquired = v.Value
+	}
+	return fmt.Sprintf("held by %s since %s", owner, acquired)
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lock_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/awslabs/ssosync/internal/lock"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// table is a DynamoDB table of one item, evaluating the conditions of
+// the lock
+type table struct {
+	mu   sync.Mutex
+	item map[string]types.AttributeValue
+}
+
+func (t *table) PutItem(_ context.Context, in *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.item != nil {
+		now := in.ExpressionAttributeValues[":now"].(*types.AttributeValueMemberN).Value
+		if number(t.item["expires"]) >= number(&types.AttributeValueMemberN{Value: now}) {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+	}
+	t.item = in.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (t *table) GetItem(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &dynamodb.GetItemOutput{Item: t.item}, nil
+}
+
+func (t *table) UpdateItem(_ context.Context, in *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.item["expires"] = in.ExpressionAttributeValues[":expires"]
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (t *table) DeleteItem(_ context.Context, in *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.item == nil || t.item["owner"].(*types.AttributeValueMemberS).Value != in.ExpressionAttributeValues[":owner"].(*types.AttributeValueMemberS).Value {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	t.item = nil
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func number(v types.AttributeValue) int64 {
+	n, _ := strconv.ParseInt(v.(*types.AttributeValueMemberN).Value, 10, 64)
+	return n
+}
+
+func TestLock(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	loc, err := Parse("dynamodb://ssosync/lock")
+	assert.NoError(err)
+	assert.Equal(Location{Table: "ssosync", KeyAttribute: DefaultKeyAttribute, Key: "lock"}, loc)
+	_, err = Parse("s3://bucket/lock")
+	assert.Error(err)
+
+	api := &table{}
+	l, err := Acquire(ctx, api, loc, "scheduled", time.Minute)
+	assert.NoError(err)
+
+	_, err = Acquire(ctx, api, loc, "manual", time.Minute)
+	assert.True(errors.Is(err, ErrLocked))
+	assert.Contains(err.Error(), "held by scheduled")
+
+	assert.NoError(l.Release(ctx))
+	l, err = Acquire(ctx, api, loc, "manual", time.Minute)
+	assert.NoError(err)
+	assert.NoError(l.Release(ctx))
+
+	// the lock of a crashed run expires
+	api.item = map[string]types.AttributeValue{
+		"owner":   &types.AttributeValueMemberS{Value: "crashed"},
+		"expires": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10)},
+	}
+	l, err = Acquire(ctx, api, loc, "manual", time.Minute)
+	assert.NoError(err)
+	assert.NoError(l.Release(ctx))
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// AuditJSON, AuditOCSF and AuditCEF are the formats of the change
+	// log entries, the audit trail of the runs
+	AuditJSON = "json"
+	AuditOCSF = "ocsf"
+	AuditCEF  = "cef"
+
+	// PlannedField marks the change log entries of the dry runs, the
+	// changes planned but not applied
+	PlannedField = "planned"
+)
+
+// ocsfVersion is the version of the OCSF schema of the events
+const ocsfVersion = "1.1.0"
+
+// ocsfActivity is the OCSF class and activity of a change type
+type ocsfActivity struct {
+	class    int
+	activity int
+	name     string
+}
+
+// the OCSF Account Change (3001) and Group Management (3006) activities
+var ocsfActivities = map[string]ocsfActivity{
+	"user_create":   {3001, 1, "Create"},
+	"user_update":   {3001, 99, "Update"},
+	"user_delete":   {3001, 6, "Delete"},
+	"group_create":  {3006, 6, "Create"},
+	"group_update":  {3006, 99, "Update"},
+	"group_delete":  {3006, 5, "Delete"},
+	"member_add":    {3006, 3, "Add User"},
+	"member_remove": {3006, 4, "Remove User"},
+}
+
+var ocsfClasses = map[int]string{3001: "Account Change", 3006: "Group Management"}
+
+// AuditFormatter formats the change log entries, marked with the
+// ChangeField, in the Format for SIEM pipelines, e.g. OCSF for Amazon
+// Security Lake or CEF for Splunk and ArcSight, without custom parsers.
+// The other entries are formatted by Formatter.
+type AuditFormatter struct {
+	log.Formatter
+	// Audit is the format of the change entries, AuditJSON, AuditOCSF
+	// or AuditCEF
+	Audit string
+	// Version is the version of ssosync reported as the product version
+	Version string
+
+	json log.JSONFormatter
+}
+
+// Format implements log.Formatter
+func (f *AuditFormatter) Format(e *log.Entry) ([]byte, error) {
+	change, ok := e.Data[ChangeField]
+	if !ok {
+		return f.Formatter.Format(e)
+	}
+	switch f.Audit {
+	case AuditOCSF:
+		return f.ocsf(e, fmt.Sprint(change))
+	case AuditCEF:
+		return f.cef(e, fmt.Sprint(change)), nil
+	default:
+		return f.json.Format(e)
+	}
+}
+
+// field returns the string field name of e, empty if not set
+func field(e *log.Entry, name string) string {
+	if v, ok := e.Data[name]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+func (f *AuditFormatter) ocsf(e *log.Entry, change string) ([]byte, error) {
+	a, ok := ocsfActivities[change]
+	if !ok {
+		a = ocsfActivity{3001, 99, change}
+	}
+	ev := map[string]interface{}{
+		"category_uid":  3,
+		"category_name": "Identity & Access Management",
+		"class_uid":     a.class,
+		"class_name":    ocsfClasses[a.class],
+		"activity_id":   a.activity,
+		"activity_name": a.name,
+		"type_uid":      a.class*100 + a.activity,
+		"time":          e.Time.UnixMilli(),
+		"severity_id":   1,
+		"severity":      "Informational",
+		"status_id":     1,
+		"status":        "Success",
+		"message":       e.Message,
+		"metadata": map[string]interface{}{
+			"version": ocsfVersion,
+			"product": map[string]string{"name": "ssosync", "vendor_name": "ssosync", "version": f.Version},
+		},
+		"unmapped": map[string]string{"change": change},
+	}
+	if _, planned := e.Data[PlannedField]; planned {
+		ev["status_id"] = 99
+		ev["status"] = "Planned"
+	}
+	if name := field(e, "userName"); name != "" {
+		ev["user"] = map[string]string{"name": name}
+	}
+	if name := field(e, "group"); name != "" {
+		ev["group"] = map[string]string{"name": name}
+	}
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+var (
+	cefHeader    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtension = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+func (f *AuditFormatter) cef(e *log.Entry, change string) []byte {
+	outcome := "success"
+	if _, planned := e.Data[PlannedField]; planned {
+		outcome = "planned"
+	}
+	ext := []string{
+		"rt=" + fmt.Sprint(e.Time.UnixMilli()),
+		"act=" + cefExtension.Replace(change),
+		"outcome=" + outcome,
+	}
+	if name := field(e, "userName"); name != "" {
+		ext = append(ext, "duser="+cefExtension.Replace(name))
+	}
+	if name := field(e, "group"); name != "" {
+		ext = append(ext, "cs1Label=group", "cs1="+cefExtension.Replace(name))
+	}
+	return []byte(fmt.Sprintf("CEF:0|ssosync|ssosync|%s|%s|%s|3|%s\n",
+		cefHeader.Replace(f.Version), cefHeader.Replace(change), cefHeader.Replace(e.Message), strings.Join(ext, " ")))
+}
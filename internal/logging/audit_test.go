@@ -0,0 +1,47 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/awslabs/ssosync/internal/logging"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditFormatter(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	l := log.New()
+	l.SetOutput(&buf)
+	f := &AuditFormatter{Formatter: &log.TextFormatter{DisableTimestamp: true}, Audit: AuditOCSF, Version: "2.1.0"}
+	l.SetFormatter(f)
+
+	l.Info("Syncing groups")
+	assert.Contains(buf.String(), `msg="Syncing groups"`)
+
+	buf.Reset()
+	l.WithFields(log.Fields{ChangeField: "member_add", "group": "eng", "userName": "ana@example.com"}).Info("Applied change")
+	var ev map[string]interface{}
+	assert.NoError(json.Unmarshal(buf.Bytes(), &ev))
+	assert.EqualValues(3006, ev["class_uid"])
+	assert.EqualValues(300603, ev["type_uid"])
+	assert.EqualValues(1, ev["status_id"])
+	assert.Equal(map[string]interface{}{"name": "eng"}, ev["group"])
+	assert.Equal(map[string]interface{}{"name": "ana@example.com"}, ev["user"])
+
+	buf.Reset()
+	l.WithFields(log.Fields{ChangeField: "user_delete", PlannedField: true, "userName": "bob@example.com"}).Info("Dry run, would delete user")
+	ev = nil
+	assert.NoError(json.Unmarshal(buf.Bytes(), &ev))
+	assert.EqualValues(300106, ev["type_uid"])
+	assert.Equal("Planned", ev["status"])
+
+	buf.Reset()
+	f.Audit = AuditCEF
+	l.WithFields(log.Fields{ChangeField: "member_remove", "group": "a=b|c", "userName": "ana@example.com"}).Info("Applied change")
+	assert.Regexp(`^CEF:0\|ssosync\|ssosync\|2\.1\.0\|member_remove\|Applied change\|3\|rt=\d+ act=member_remove outcome=success duser=ana@example.com cs1Label=group cs1=a\\=b\|c\n$`, buf.String())
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// LevelChange is the log level logging only the changes applied or
+	// planned, the warnings and the errors
+	LevelChange = "change"
+	// ChangeField is the field marking the log entries of changes, its
+	// value is the type of the change, e.g. user_create
+	ChangeField = "change"
+	// PhaseField is the field of the progress heartbeat entries, which
+	// are also kept
+	PhaseField = "phase"
+)
+
+// ChangesFormatter drops the info, debug and trace entries which are not
+// marked with the ChangeField or the PhaseField, keeping readable the logs
+// of large syncs
+type ChangesFormatter struct {
+	log.Formatter
+}
+
+// Format implements log.Formatter
+func (f *ChangesFormatter) Format(e *log.Entry) ([]byte, error) {
+	_, change := e.Data[ChangeField]
+	_, phase := e.Data[PhaseField]
+	if !change && !phase && e.Level >= log.InfoLevel {
+		return nil, nil
+	}
+	return f.Formatter.Format(e)
+}
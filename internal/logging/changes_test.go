@@ -0,0 +1,30 @@
+package logging_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/awslabs/ssosync/internal/logging"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangesFormatter(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	l := log.New()
+	l.SetOutput(&buf)
+	l.SetFormatter(&ChangesFormatter{Formatter: &log.TextFormatter{DisableTimestamp: true}})
+
+	l.Info("Did nothing, user already added")
+	assert.Empty(buf.String())
+
+	l.WithField(ChangeField, "user_create").Info("Applied change")
+	assert.Contains(buf.String(), "change=user_create")
+
+	buf.Reset()
+	l.Warn("User name collision")
+	assert.Contains(buf.String(), "User name collision")
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logging ...
+package logging
+
+import (
+	"fmt"
+	"regexp"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const redacted = "REDACTED"
+
+// builtinPatterns match credentials which must never end up in the logs
+var builtinPatterns = []*regexp.Regexp{
+	// PEM encoded keys, also when embedded in a JSON string with escaped newlines
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	// the private key field of a Google service account credentials file
+	regexp.MustCompile(`"private_key"\s*:\s*"[^"]*"`),
+	// Google OAuth2 access and refresh tokens
+	regexp.MustCompile(`ya29\.[0-9A-Za-z_\-]+`),
+	regexp.MustCompile(`1//[0-9A-Za-z_\-]{20,}`),
+	// JSON Web Tokens, e.g. the signed assertion of the token exchange
+	regexp.MustCompile(`eyJ[0-9A-Za-z_\-]+\.[0-9A-Za-z_\-]+\.[0-9A-Za-z_\-]+`),
+	// AWS access key ids
+	regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`),
+	// Slack incoming webhook URLs, the path is the secret
+	regexp.MustCompile(`hooks\.slack\.com/services/[0-9A-Za-z/]+`),
+}
+
+// RedactHook is a logrus hook scrubbing credentials and other configured
+// patterns from the message and fields of every log entry
+type RedactHook struct {
+	patterns []*regexp.Regexp
+}
+
+// NewRedactHook returns a hook redacting the built-in credential patterns
+// and the additional regular expressions given
+func NewRedactHook(extra []string) (*RedactHook, error) {
+	patterns := append([]*regexp.Regexp{}, builtinPatterns...)
+	for _, p := range extra {
+		if p == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		patterns = append(patterns, re)
+	}
+
+	return &RedactHook{patterns: patterns}, nil
+}
+
+// Levels implements log.Hook, the hook fires for all levels
+func (h *RedactHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements log.Hook
+func (h *RedactHook) Fire(e *log.Entry) error {
+	e.Message = h.Redact(e.Message)
+
+	// the entry data may be shared with the parent logger, copy before editing
+	data := make(log.Fields, len(e.Data))
+	for k, v := range e.Data {
+		switch t := v.(type) {
+		case string:
+			data[k] = h.Redact(t)
+		case *string:
+			if t != nil {
+				data[k] = h.Redact(*t)
+			} else {
+				data[k] = t
+			}
+		case error:
+			data[k] = h.Redact(t.Error())
+		case fmt.Stringer:
+			data[k] = h.Redact(t.String())
+		default:
+			data[k] = v
+		}
+	}
+	e.Data = data
+
+	return nil
+}
+
+// Redact replaces all occurrences of the configured patterns in s
+func (h *RedactHook) Redact(s string) string {
+	for _, re := range h.patterns {
+		s = re.ReplaceAllString(s, redacted)
+	}
+	return s
+}
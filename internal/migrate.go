@@ -0,0 +1,82 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/targets"
+	"github.com/awslabs/ssosync/pkg/ssosync"
+	log "github.com/sirupsen/logrus"
+)
+
+// DoMigrate adopts the AWS users and groups created by earlier versions
+// of ssosync or by the automatic provisioning, and writes the migrated
+// entities to w, with dryRun the changes are only logged
+func DoMigrate(ctx context.Context, cfg *config.Config, w io.Writer, dryRun bool) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	googleClient, err := newGoogleClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if err := resolveIdentityStore(ctx, cfg); err != nil {
+		return err
+	}
+
+	target, err := targets.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		target = ssosync.DryRun(target)
+	}
+
+	log.WithField("dryRun", dryRun).Info("Migrating the AWS users and groups")
+	opts := Options(cfg)
+	if err := expandLists(ctx, cfg, &opts); err != nil {
+		return err
+	}
+	migrations, err := ssosync.Migrate(googleClient, target, opts)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "kind\tname\tchanges\terror")
+	for _, m := range migrations {
+		if m.Error != "" {
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", m.Kind, m.Name, strings.Join(m.Changes, ", "), m.Error)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{"migrated": len(migrations) - failed, "failed": failed, "dryRun": dryRun}).Info("Migration finished")
+	if failed > 0 {
+		return fmt.Errorf("%d of %d users and groups could not be migrated", failed, len(migrations))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+	"github.com/awslabs/ssosync/pkg/report"
+)
+
+// Email sends the summary of a run with Amazon SES
+type Email struct {
+	client *sesv2.Client
+	from   string
+	to     []string
+}
+
+// NewEmail returns a notifier sending from the SES verified identity
+// from to the addresses to
+func NewEmail(cfg aws.Config, from string, to []string) *Email {
+	return &Email{client: sesv2.NewFromConfig(cfg), from: from, to: to}
+}
+
+// Notify implements Notifier
+func (e *Email) Notify(r *report.Report) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err := e.client.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(e.from),
+		Destination:      &types.Destination{ToAddresses: e.to},
+		Content: &types.EmailContent{
+			Simple: &types.Message{
+				Subject: &types.Content{Data: aws.String(Title(r))},
+				Body: &types.Body{
+					Text: &types.Content{Data: aws.String(emailBodyOf(r))},
+				},
+			},
+		},
+	})
+	return err
+}
+
+// emailBodyOf formats the report as plain text digest
+func emailBodyOf(r *report.Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", Title(r))
+	if r.Error != "" {
+		fmt.Fprintf(&b, "Error: %s\n\n", r.Error)
+	}
+	for _, f := range Fields(r) {
+		fmt.Fprintf(&b, "%-16s %s\n", f.Title+":", f.Value)
+	}
+	fmt.Fprintf(&b, "\nStarted at %s\n%s\n", r.Start.UTC().Format(time.RFC3339), r.String())
+	return b.String()
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify sends the summary of a run to chat tools, webhooks
+// and by email
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/transport"
+	"github.com/awslabs/ssosync/pkg/report"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// OnAlways notifies after every run
+	OnAlways = "always"
+	// OnChanges notifies after runs which changed something or failed
+	OnChanges = "changes"
+	// OnErrors notifies after runs which failed, fully or partially
+	OnErrors = "errors"
+)
+
+// timeout is the maximum duration of sending a notification
+const timeout = 30 * time.Second
+
+// Notifier sends the summary of a run
+type Notifier interface {
+	Notify(*report.Report) error
+}
+
+// New returns the notifiers of the configuration
+func New(cfg *config.Config) ([]Notifier, error) {
+	var notifiers []Notifier
+
+	hc, err := transport.NewClient("notify", nil, transport.Options{
+		Timeout: timeout,
+		Proxy:   cfg.ProxyFor(""),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.SlackWebhook != "" {
+		notifiers = append(notifiers, NewSlack(cfg.SlackWebhook, hc))
+	}
+	if cfg.TeamsWebhook != "" {
+		notifiers = append(notifiers, NewTeams(cfg.TeamsWebhook, hc))
+	}
+	if cfg.NotifyWebhook != "" {
+		w, err := NewWebhook(cfg.NotifyWebhook, cfg.NotifyWebhookTemplate, hc)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, w)
+	}
+	if len(cfg.EmailTo) > 0 {
+		notifiers = append(notifiers, NewEmail(cfg.AWSConfig, cfg.EmailFrom, cfg.EmailTo))
+	}
+
+	return notifiers, nil
+}
+
+// Send sends the summary of the run to the configured notifiers, when
+// it matches cfg.NotifyOn, failures are logged but never fail the run
+func Send(cfg *config.Config, r *report.Report) {
+	if !notify(cfg.NotifyOn, r) {
+		return
+	}
+
+	notifiers, err := New(cfg)
+	if err != nil {
+		log.WithError(err).Error("cannot configure notifications")
+		return
+	}
+	for _, n := range notifiers {
+		if err := n.Notify(r); err != nil {
+			log.WithError(err).Error("cannot send notification")
+		}
+	}
+}
+
+// notify reports whether a run with the report r is notified
+func notify(on string, r *report.Report) bool {
+	switch on {
+	case OnAlways:
+		return true
+	case OnErrors:
+		return r.Result != report.ResultOK
+	default:
+		return r.Result != report.ResultOK || r.Changes() > 0
+	}
+}
+
+// post sends the JSON body to url
+func post(hc *http.Client, url string, body []byte) error {
+	resp, err := hc.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", resp.Request.URL.Host, resp.Status)
+	}
+	return nil
+}
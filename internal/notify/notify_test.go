@@ -0,0 +1,68 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/awslabs/ssosync/pkg/report"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotify(t *testing.T) {
+	assert := assert.New(t)
+
+	r := &report.Report{Result: report.ResultOK}
+	assert.True(notify(OnAlways, r))
+	assert.False(notify(OnChanges, r))
+	assert.False(notify(OnErrors, r))
+
+	r.UsersCreated = 1
+	assert.True(notify(OnChanges, r))
+	assert.False(notify(OnErrors, r))
+
+	r.Result = report.ResultPartial
+	assert.True(notify(OnErrors, r))
+}
+
+func TestSlackMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	m := slackMessageOf(&report.Report{Result: report.ResultError, Error: "access denied"})
+	assert.Equal("danger", m.Attachments[0].Color)
+	assert.Equal("ssosync run failed", m.Attachments[0].Title)
+	assert.Equal("access denied", m.Attachments[0].Text)
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewWebhook("https://example.com", "{{", nil)
+	assert.Error(err)
+
+	w, err := NewWebhook("https://example.com", `{"text":{{json .String}},"changes":{{.Changes}}}`, nil)
+	assert.NoError(err)
+
+	var body bytes.Buffer
+	assert.NoError(w.tmpl.Execute(&body, &report.Report{Result: report.ResultOK, UsersCreated: 2}))
+
+	var got map[string]interface{}
+	assert.NoError(json.Unmarshal(body.Bytes(), &got))
+	assert.Equal(float64(2), got["changes"])
+	assert.Contains(got["text"], "result=ok users_created=2")
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/awslabs/ssosync/pkg/report"
+)
+
+// Slack posts the summary of a run to a Slack incoming webhook
+type Slack struct {
+	url string
+	hc  *http.Client
+}
+
+// NewSlack returns a notifier posting to the Slack incoming webhook url
+func NewSlack(url string, hc *http.Client) *Slack {
+	return &Slack{url: url, hc: hc}
+}
+
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Title  string       `json:"title"`
+	Text   string       `json:"text,omitempty"`
+	Fields []slackField `json:"fields"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// slackColors are the attachment colors of the results
+var slackColors = map[string]string{
+	report.ResultOK:      "good",
+	report.ResultPartial: "warning",
+	report.ResultError:   "danger",
+}
+
+// Notify implements Notifier
+func (s *Slack) Notify(r *report.Report) error {
+	body, err := json.Marshal(slackMessageOf(r))
+	if err != nil {
+		return err
+	}
+	return post(s.hc, s.url, body)
+}
+
+// slackMessageOf formats the report, with the error of a failed run
+func slackMessageOf(r *report.Report) slackMessage {
+	a := slackAttachment{
+		Color: slackColors[r.Result],
+		Title: Title(r),
+		Text:  r.Error,
+	}
+	for _, f := range Fields(r) {
+		a.Fields = append(a.Fields, slackField{Title: f.Title, Value: f.Value, Short: true})
+	}
+	return slackMessage{
+		Text:        r.String(),
+		Attachments: []slackAttachment{a},
+	}
+}
+
+// Field is a counter of a report, as shown in a notification
+type Field struct {
+	Title string
+	Value string
+}
+
+// Title returns the headline of a notification of the report
+func Title(r *report.Report) string {
+	switch r.Result {
+	case report.ResultOK:
+		return fmt.Sprintf("ssosync run succeeded with %d changes", r.Changes())
+	case report.ResultPartial:
+		return fmt.Sprintf("ssosync run completed with %d errors", r.Errors)
+	default:
+		return "ssosync run failed"
+	}
+}
+
+// Fields returns the counters of the report shown in a notification
+func Fields(r *report.Report) []Field {
+	return []Field{
+		{"Users created", fmt.Sprint(r.UsersCreated)},
+		{"Users deleted", fmt.Sprint(r.UsersDeleted)},
+		{"Groups created", fmt.Sprint(r.GroupsCreated)},
+		{"Groups deleted", fmt.Sprint(r.GroupsDeleted)},
+		{"Members added", fmt.Sprint(r.MembershipsAdded)},
+		{"Members removed", fmt.Sprint(r.MembershipsRemoved)},
+		{"Errors", errorsText(r)},
+		{"Duration", r.Duration.Round(time.Second).String()},
+	}
+}
+
+// errorsText returns the number of errors of the report with their
+// causes
+func errorsText(r *report.Report) string {
+	if causes := r.CausesText(); causes != "" {
+		return fmt.Sprintf("%d (%s)", r.Errors, causes)
+	}
+	return fmt.Sprint(r.Errors)
+}
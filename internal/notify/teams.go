@@ -0,0 +1,81 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/awslabs/ssosync/pkg/report"
+)
+
+// Teams posts the summary of a run as adaptive card to a Microsoft
+// Teams incoming webhook or workflow
+type Teams struct {
+	url string
+	hc  *http.Client
+}
+
+// NewTeams returns a notifier posting to the Teams webhook url
+func NewTeams(url string, hc *http.Client) *Teams {
+	return &Teams{url: url, hc: hc}
+}
+
+// teamsColors are the text colors of the results
+var teamsColors = map[string]string{
+	report.ResultOK:      "Good",
+	report.ResultPartial: "Warning",
+	report.ResultError:   "Attention",
+}
+
+// Notify implements Notifier
+func (t *Teams) Notify(r *report.Report) error {
+	body, err := json.Marshal(teamsMessageOf(r))
+	if err != nil {
+		return err
+	}
+	return post(t.hc, t.url, body)
+}
+
+// teamsMessageOf formats the report as message with an adaptive card
+func teamsMessageOf(r *report.Report) map[string]interface{} {
+	facts := []map[string]string{}
+	for _, f := range Fields(r) {
+		facts = append(facts, map[string]string{"title": f.Title, "value": f.Value})
+	}
+
+	body := []map[string]interface{}{
+		{"type": "TextBlock", "text": Title(r), "weight": "Bolder", "size": "Medium", "color": teamsColors[r.Result], "wrap": true},
+	}
+	if r.Error != "" {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": r.Error, "wrap": true})
+	}
+	body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{
+			{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]interface{}{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    body,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+
+	"github.com/awslabs/ssosync/pkg/report"
+)
+
+// funcs are the functions of the webhook body templates
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Webhook posts the summary of a run to any URL, as the JSON object
+// of Summary or rendered with a template
+type Webhook struct {
+	url  string
+	hc   *http.Client
+	tmpl *template.Template
+}
+
+// NewWebhook returns a notifier posting to url, the body is rendered
+// with the Go template text on the *report.Report when not empty
+func NewWebhook(url string, text string, hc *http.Client) (*Webhook, error) {
+	w := &Webhook{url: url, hc: hc}
+	if text != "" {
+		tmpl, err := template.New("webhook").Funcs(funcs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notify webhook template: %w", err)
+		}
+		w.tmpl = tmpl
+	}
+	return w, nil
+}
+
+// Notify implements Notifier
+func (w *Webhook) Notify(r *report.Report) error {
+	if w.tmpl == nil {
+		body, err := json.Marshal(Summary(r))
+		if err != nil {
+			return err
+		}
+		return post(w.hc, w.url, body)
+	}
+
+	var body bytes.Buffer
+	if err := w.tmpl.Execute(&body, r); err != nil {
+		return err
+	}
+	return post(w.hc, w.url, body.Bytes())
+}
+
+// Summary returns the report with the keys of its summary line
+func Summary(r *report.Report) map[string]interface{} {
+	return map[string]interface{}{
+		"result":              r.Result,
+		"error":               r.Error,
+		"start":               r.Start,
+		"duration":            r.Duration.Seconds(),
+		"users_created":       r.UsersCreated,
+		"users_deleted":       r.UsersDeleted,
+		"groups_created":      r.GroupsCreated,
+		"groups_deleted":      r.GroupsDeleted,
+		"memberships_added":   r.MembershipsAdded,
+		"memberships_removed": r.MembershipsRemoved,
+		"errors":              r.Errors,
+		"cause":               r.Cause,
+		"error_causes":        r.ErrorCauses(),
+	}
+}
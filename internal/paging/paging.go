@@ -0,0 +1,162 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package paging instruments the paginated list calls of the Google
+// and AWS APIs, to find which API is the bottleneck of a large sync
+package paging
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Stats are the aggregated pages of the list calls of an operation
+type Stats struct {
+	Service   string `json:"service"`
+	Operation string `json:"operation"`
+	// Calls is the number of listings, e.g. one per group for members
+	Calls int `json:"calls"`
+	Pages int `json:"pages"`
+	Items int `json:"items"`
+	// Duration is the total time spent waiting for pages
+	Duration time.Duration `json:"durationNs"`
+	// Slowest is the latency of the slowest page
+	Slowest time.Duration `json:"slowestPageNs"`
+	// RepeatedTokens counts page tokens returned more than once within
+	// a listing, which indicates that the API restarted the listing
+	RepeatedTokens int `json:"repeatedTokens"`
+}
+
+var (
+	mu    sync.Mutex
+	stats = map[string]*Stats{}
+)
+
+// Tracker records the pages of a single listing
+type Tracker struct {
+	service   string
+	operation string
+	start     time.Time
+	last      time.Time
+	pages     int
+	items     int
+	slowest   time.Duration
+	tokens    map[string]bool
+	repeated  int
+}
+
+// Start returns a tracker of a listing of the operation of service
+func Start(service, operation string) *Tracker {
+	now := time.Now()
+	return &Tracker{
+		service:   service,
+		operation: operation,
+		start:     now,
+		last:      now,
+		tokens:    map[string]bool{},
+	}
+}
+
+// Page records a page with the number of items and the token of the
+// next page returned, logging it at debug level
+func (t *Tracker) Page(items int, nextToken string) {
+	now := time.Now()
+	latency := now.Sub(t.last)
+	t.last = now
+	t.pages++
+	t.items += items
+	if latency > t.slowest {
+		t.slowest = latency
+	}
+	if nextToken != "" {
+		if t.tokens[nextToken] {
+			t.repeated++
+		}
+		t.tokens[nextToken] = true
+	}
+
+	log.WithFields(log.Fields{
+		"service":   t.service,
+		"operation": t.operation,
+		"page":      t.pages,
+		"items":     items,
+		"latency":   latency.Round(time.Millisecond).String(),
+		"more":      nextToken != "",
+	}).Debug("fetched page")
+}
+
+// Done adds the listing to the stats of its operation
+func (t *Tracker) Done() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	key := t.service + "/" + t.operation
+	s, ok := stats[key]
+	if !ok {
+		s = &Stats{Service: t.service, Operation: t.operation}
+		stats[key] = s
+	}
+	s.Calls++
+	s.Pages += t.pages
+	s.Items += t.items
+	s.Duration += time.Since(t.start)
+	s.RepeatedTokens += t.repeated
+	if t.slowest > s.Slowest {
+		s.Slowest = t.slowest
+	}
+}
+
+// Summary returns the stats of all operations, slowest first
+func Summary() []Stats {
+	mu.Lock()
+	defer mu.Unlock()
+
+	res := make([]Stats, 0, len(stats))
+	for _, s := range stats {
+		res = append(res, *s)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Duration > res[j].Duration })
+	return res
+}
+
+// Reset clears the stats, e.g. before the next sync in daemon mode
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	stats = map[string]*Stats{}
+}
+
+// LogSummary logs the stats of all operations at debug level
+func LogSummary() {
+	for _, s := range Summary() {
+		avg := time.Duration(0)
+		if s.Pages > 0 {
+			avg = s.Duration / time.Duration(s.Pages)
+		}
+		log.WithFields(log.Fields{
+			"service":        s.Service,
+			"operation":      s.Operation,
+			"calls":          s.Calls,
+			"pages":          s.Pages,
+			"items":          s.Items,
+			"duration":       s.Duration.Round(time.Millisecond).String(),
+			"avgPage":        avg.Round(time.Millisecond).String(),
+			"slowestPage":    s.Slowest.Round(time.Millisecond).String(),
+			"repeatedTokens": s.RepeatedTokens,
+		}).Debug("paging summary")
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package paging_test
+
+import (
+	"testing"
+
+	. "github.com/awslabs/ssosync/internal/paging"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummary(t *testing.T) {
+	assert := assert.New(t)
+
+	Reset()
+	for i := 0; i < 2; i++ {
+		p := Start("google", "members.list")
+		p.Page(200, "a")
+		p.Page(200, "a")
+		p.Page(10, "")
+		p.Done()
+	}
+
+	s := Summary()
+	assert.Len(s, 1)
+	assert.Equal(2, s[0].Calls)
+	assert.Equal(6, s[0].Pages)
+	assert.Equal(820, s[0].Items)
+	assert.Equal(2, s[0].RepeatedTokens)
+
+	Reset()
+	assert.Empty(Summary())
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pause reads the remote pause flag of the scheduled syncs, an
+// SSM parameter or a DynamoDB item which operators set during incidents
+package pause
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+const (
+	// KindSSM is the flag stored in an SSM parameter
+	KindSSM = "ssm"
+	// KindDynamoDB is the flag stored in a DynamoDB item
+	KindDynamoDB = "dynamodb"
+
+	// DefaultKeyAttribute is the partition key attribute of the DynamoDB
+	// item
+	DefaultKeyAttribute = "id"
+)
+
+// Flag is the location of a pause flag
+type Flag struct {
+	Kind string
+	// Name is the name of the SSM parameter
+	Name string
+	// Table, KeyAttribute and Key identify the DynamoDB item
+	Table        string
+	KeyAttribute string
+	Key          string
+}
+
+// Parse parses the location of a pause flag, ssm:<parameter name>, e.g.
+// ssm:/ssosync/pause, or dynamodb://<table>/<key>[?key=<attribute>]
+func Parse(uri string) (Flag, error) {
+	switch {
+	case strings.HasPrefix(uri, "ssm:"):
+		name := strings.TrimPrefix(uri, "ssm:")
+		if name == "" {
+			return Flag{}, fmt.Errorf("invalid pause flag %q, expected ssm:<parameter name>", uri)
+		}
+		return Flag{Kind: KindSSM, Name: name}, nil
+	case strings.HasPrefix(uri, "dynamodb://"):
+		u, err := url.Parse(uri)
+		if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			return Flag{}, fmt.Errorf("invalid pause flag %q, expected dynamodb://<table>/<key>", uri)
+		}
+		f := Flag{
+			Kind:         KindDynamoDB,
+			Table:        u.Host,
+			KeyAttribute: u.Query().Get("key"),
+			Key:          strings.Trim(u.Path, "/"),
+		}
+		if f.KeyAttribute == "" {
+			f.KeyAttribute = DefaultKeyAttribute
+		}
+		return f, nil
+	default:
+		return Flag{}, fmt.Errorf("invalid pause flag %q, expected ssm:<parameter name> or dynamodb://<table>/<key>", uri)
+	}
+}
+
+// Check returns why the syncs are paused by the flag at uri, empty when
+// they are not. A missing parameter or item does not pause the syncs,
+// a flag which cannot be read is an error.
+func Check(ctx context.Context, cfg aws.Config, uri string) (string, error) {
+	f, err := Parse(uri)
+	if err != nil {
+		return "", err
+	}
+
+	switch f.Kind {
+	case KindSSM:
+		out, err := ssm.NewFromConfig(cfg).GetParameter(ctx, &ssm.GetParameterInput{
+			Name:           aws.String(f.Name),
+			WithDecryption: aws.Bool(true),
+		})
+		var nf *ssmtypes.ParameterNotFound
+		if errors.As(err, &nf) {
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot read the pause flag %s: %w", uri, err)
+		}
+		return Reason(aws.ToString(out.Parameter.Value)), nil
+	default:
+		out, err := dynamodb.NewFromConfig(cfg).GetItem(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String(f.Table),
+			Key: map[string]dbtypes.AttributeValue{
+				f.KeyAttribute: &dbtypes.AttributeValueMemberS{Value: f.Key},
+			},
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return "", fmt.Errorf("cannot read the pause flag %s: %w", uri, err)
+		}
+		return itemReason(out.Item), nil
+	}
+}
+
+// Reason returns why the syncs are paused by the flag value, empty when
+// the value does not pause them: empty, false, no, off or 0. Any other
+// value pauses them, e.g. true or the reason of the pause.
+func Reason(value string) string {
+	v := strings.TrimSpace(value)
+	switch strings.ToLower(v) {
+	case "", "false", "no", "off", "0":
+		return ""
+	case "true", "yes", "on", "1":
+		return "paused"
+	}
+	return v
+}
+
+// itemReason returns why the syncs are paused by the DynamoDB item, its
+// paused attribute, a boolean or a string, and its optional reason
+func itemReason(item map[string]dbtypes.AttributeValue) string {
+	var reason string
+	switch v := item["paused"].(type) {
+	case *dbtypes.AttributeValueMemberBOOL:
+		if v.Value {
+			reason = "paused"
+		}
+	case *dbtypes.AttributeValueMemberS:
+		reason = Reason(v.Value)
+	case *dbtypes.AttributeValueMemberN:
+		reason = Reason(v.Value)
+	}
+	if r, ok := item["reason"].(*dbtypes.AttributeValueMemberS); ok && reason != "" && r.Value != "" {
+		reason = r.Value
+	}
+	return reason
+}
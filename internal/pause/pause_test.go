@@ -0,0 +1,54 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pause_test
+
+import (
+	"testing"
+
+	. "github.com/awslabs/ssosync/internal/pause"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := Parse("ssm:/ssosync/pause")
+	assert.NoError(err)
+	assert.Equal(Flag{Kind: KindSSM, Name: "/ssosync/pause"}, f)
+
+	f, err = Parse("dynamodb://ssosync/pause")
+	assert.NoError(err)
+	assert.Equal(Flag{Kind: KindDynamoDB, Table: "ssosync", KeyAttribute: DefaultKeyAttribute, Key: "pause"}, f)
+
+	f, err = Parse("dynamodb://ssosync/pause?key=pk")
+	assert.NoError(err)
+	assert.Equal("pk", f.KeyAttribute)
+
+	for _, uri := range []string{"ssm:", "dynamodb://ssosync", "s3://bucket/key"} {
+		_, err = Parse(uri)
+		assert.Error(err, uri)
+	}
+}
+
+func TestReason(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, v := range []string{"", "false", "Off", " 0 "} {
+		assert.Empty(Reason(v), v)
+	}
+	assert.Equal("paused", Reason("TRUE"))
+	assert.Equal("INC-1234 identity store outage", Reason("INC-1234 identity store outage"))
+}
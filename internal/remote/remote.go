@@ -0,0 +1,154 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remote reads the lists configured as an S3 URI or an HTTPS URL
+// rather than inline, e.g. lists too large for a Lambda environment
+// variable
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// maxSize is the largest list read, 10 MiB
+const maxSize = 10 << 20
+
+// IsURI reports whether the value is a s3://bucket/key URI or an
+// http(s) URL
+func IsURI(value string) bool {
+	return strings.HasPrefix(value, "s3://") || strings.HasPrefix(value, "https://") || strings.HasPrefix(value, "http://")
+}
+
+// Fetch returns the content of the s3://bucket/key object, read with
+// cfg, or of the http(s) URL, read with hc
+func Fetch(ctx context.Context, cfg aws.Config, hc *http.Client, uri string) ([]byte, error) {
+	var r io.ReadCloser
+	if strings.HasPrefix(uri, "s3://") {
+		u, err := url.Parse(uri)
+		if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			return nil, fmt.Errorf("invalid list location %q, expected s3://bucket/key", uri)
+		}
+		out, err := s3.NewFromConfig(cfg).GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(u.Host),
+			Key:    aws.String(strings.TrimPrefix(u.Path, "/")),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot read list %s: %w", uri, err)
+		}
+		r = out.Body
+	} else {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := hc.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch list %s: %w", uri, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("cannot fetch list %s: %s", uri, resp.Status)
+		}
+		r = resp.Body
+	}
+	defer r.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read list %s: %w", uri, err)
+	}
+	if len(b) > maxSize {
+		return nil, fmt.Errorf("list %s is larger than %d bytes", uri, maxSize)
+	}
+	return b, nil
+}
+
+// Lines returns the entries of a list, one per line or separated by
+// commas, without the blank lines and the # comments
+func Lines(b []byte) []string {
+	return lines(b, ",")
+}
+
+// Queries returns the Google queries of a list, one per line as a query
+// may contain commas, e.g. name:'Doe, John', without the blank lines and
+// the # comments
+func Queries(b []byte) []string {
+	return lines(b, "\n")
+}
+
+// lines returns the entries of a list, one per line or separated by sep
+func lines(b []byte, sep string) []string {
+	var res []string
+	s := bufio.NewScanner(bytes.NewReader(b))
+	s.Buffer(make([]byte, 0, 64*1024), maxSize)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		for _, e := range strings.Split(line, sep) {
+			if e = strings.TrimSpace(e); e != "" {
+				res = append(res, e)
+			}
+		}
+	}
+	return res
+}
+
+// Expand returns the list with the entries holding a URI replaced by the
+// entries of the list read from it, the list itself when there is none
+func Expand(ctx context.Context, cfg aws.Config, hc *http.Client, list []string) ([]string, error) {
+	return expand(ctx, cfg, hc, list, Lines)
+}
+
+// ExpandQueries returns the list of Google queries like Expand, with the
+// queries read one per line
+func ExpandQueries(ctx context.Context, cfg aws.Config, hc *http.Client, list []string) ([]string, error) {
+	return expand(ctx, cfg, hc, list, Queries)
+}
+
+func expand(ctx context.Context, cfg aws.Config, hc *http.Client, list []string, split func([]byte) []string) ([]string, error) {
+	remote := false
+	for _, e := range list {
+		remote = remote || IsURI(e)
+	}
+	if !remote {
+		return list, nil
+	}
+
+	res := make([]string, 0, len(list))
+	for _, e := range list {
+		if !IsURI(e) {
+			res = append(res, e)
+			continue
+		}
+		b, err := Fetch(ctx, cfg, hc, e)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, split(b)...)
+	}
+	return res, nil
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	. "github.com/awslabs/ssosync/internal/remote"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpand(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ignored.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "# contractors\nana@example.com\n\nbo@example.com, cy@example.com # until Q4\n")
+	}))
+	defer srv.Close()
+
+	list := []string{"admin@example.com"}
+	expanded, err := Expand(context.Background(), aws.Config{}, srv.Client(), list)
+	assert.NoError(err)
+	assert.Equal(list, expanded)
+
+	expanded, err = Expand(context.Background(), aws.Config{}, srv.Client(), []string{"admin@example.com", srv.URL + "/ignored.txt"})
+	assert.NoError(err)
+	assert.Equal([]string{"admin@example.com", "ana@example.com", "bo@example.com", "cy@example.com"}, expanded)
+
+	// the queries are read one per line
+	expanded, err = ExpandQueries(context.Background(), aws.Config{}, srv.Client(), []string{srv.URL + "/ignored.txt"})
+	assert.NoError(err)
+	assert.Equal([]string{"ana@example.com", "bo@example.com, cy@example.com"}, expanded)
+
+	_, err = Expand(context.Background(), aws.Config{}, srv.Client(), []string{srv.URL + "/missing.txt"})
+	assert.ErrorContains(err, "404")
+
+	_, err = Fetch(context.Background(), aws.Config{}, srv.Client(), "s3://bucket")
+	assert.ErrorContains(err, "expected s3://bucket/key")
+
+	assert.True(IsURI("s3://bucket/key"))
+	assert.False(IsURI("email:aws-*"))
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/awslabs/ssosync/internal/access"
+	"github.com/awslabs/ssosync/internal/aws"
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/targets"
+	"github.com/awslabs/ssosync/pkg/ssosync"
+	log "github.com/sirupsen/logrus"
+)
+
+// DoAccessReport writes the groups, and with permissionSets the account
+// assignments, of every user of the identity store to w as csv or html
+func DoAccessReport(ctx context.Context, cfg *config.Config, w io.Writer, format string, permissionSets bool) error {
+	if format != "csv" && format != "html" {
+		return fmt.Errorf("report format %q is not one of csv, html", format)
+	}
+
+	if err := resolveIdentityStore(ctx, cfg); err != nil {
+		return err
+	}
+
+	var assignments map[string][]aws.Assignment
+	if permissionSets {
+		if cfg.InstanceArn == "" {
+			instance, err := aws.DiscoverInstance(ctx, cfg.IdentityStoreAWSConfig, cfg.IdentityStoreId)
+			if err != nil {
+				return err
+			}
+			cfg.InstanceArn = awsutils.ToString(instance.InstanceArn)
+		}
+
+		log.Info("Fetching account assignments")
+		var err error
+		assignments, err = aws.ListAssignments(ctx, cfg.IdentityStoreAWSConfig, cfg.InstanceArn)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Info("Fetching users, groups and group members")
+	entries, err := access.Build(aws.NewClient(ctx, cfg.IdentityStoreAWSConfig, cfg.IdentityStoreId), assignments)
+	if err != nil {
+		return err
+	}
+
+	if format == "html" {
+		return access.WriteHTML(w, entries)
+	}
+	return access.WriteCSV(w, entries)
+}
+
+// DoOrphanReport writes the AWS users and groups without a synced Google
+// counterpart to w as csv or text, without changing anything
+func DoOrphanReport(ctx context.Context, cfg *config.Config, w io.Writer, format string) error {
+	if format != "csv" && format != "text" {
+		return fmt.Errorf("report format %q is not one of csv, text", format)
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	googleClient, err := newGoogleClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if err := resolveIdentityStore(ctx, cfg); err != nil {
+		return err
+	}
+
+	log.Info("Comparing AWS users and groups with Google")
+	target, err := targets.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	opts := Options(cfg)
+	if err := expandLists(ctx, cfg, &opts); err != nil {
+		return err
+	}
+	orphans, err := ssosync.FindOrphans(googleClient, target, opts)
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{{"kind", "name", "class", "reason"}}
+	for _, o := range orphans {
+		rows = append(rows, []string{o.Kind, o.Name, o.Class, o.Reason})
+	}
+
+	if format == "csv" {
+		cw := csv.NewWriter(w)
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, r := range rows {
+		fmt.Fprintln(tw, strings.Join(r, "\t"))
+	}
+	return tw.Flush()
+}
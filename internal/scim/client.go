@@ -0,0 +1,197 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scim reads the users and groups of an identity store through
+// the AWS IAM Identity Center SCIM endpoint, which earlier versions of
+// ssosync provisioned them with
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awslabs/ssosync/internal/paging"
+)
+
+// pageSize is the number of users or groups per page, the maximum of
+// the endpoint
+const pageSize = 100
+
+// User is a SCIM user
+type User struct {
+	ID          string  `json:"id"`
+	ExternalID  string  `json:"externalId,omitempty"`
+	UserName    string  `json:"userName"`
+	DisplayName string  `json:"displayName,omitempty"`
+	Active      bool    `json:"active"`
+	Emails      []Email `json:"emails,omitempty"`
+	Meta        Meta    `json:"meta"`
+}
+
+// Email is an email of a SCIM user
+type Email struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary"`
+}
+
+// Group is a SCIM group
+type Group struct {
+	ID          string `json:"id"`
+	ExternalID  string `json:"externalId,omitempty"`
+	DisplayName string `json:"displayName"`
+	Meta        Meta   `json:"meta"`
+}
+
+// Meta are the SCIM metadata of a user or group
+type Meta struct {
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// PrimaryEmail returns the primary email of the user, or its first
+func (u User) PrimaryEmail() string {
+	for _, e := range u.Emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+// Client reads the SCIM endpoint of an identity store
+type Client struct {
+	ctx      context.Context
+	http     *http.Client
+	endpoint string
+	token    string
+}
+
+// New returns the Client of the SCIM endpoint, e.g.
+// https://scim.us-east-1.amazonaws.com/xxxx/scim/v2, authenticated with
+// the access token of the automatic provisioning, hc is the transport
+func New(ctx context.Context, hc *http.Client, endpoint, token string) (*Client, error) {
+	if endpoint == "" || token == "" {
+		return nil, errors.New("scim endpoint and access token are required")
+	}
+	return &Client{
+		ctx:      ctx,
+		http:     hc,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		token:    token,
+	}, nil
+}
+
+// listResponse is a page of a SCIM list
+type listResponse struct {
+	TotalResults int               `json:"totalResults"`
+	StartIndex   int               `json:"startIndex"`
+	Resources    []json.RawMessage `json:"Resources"`
+}
+
+// Users returns the users of the identity store
+func (c *Client) Users() ([]User, error) {
+	var res []User
+	err := c.list("/Users", "ListUsers", func(r json.RawMessage) error {
+		var u User
+		if err := json.Unmarshal(r, &u); err != nil {
+			return err
+		}
+		res = append(res, u)
+		return nil
+	})
+	return res, err
+}
+
+// Groups returns the groups of the identity store, without their members
+func (c *Client) Groups() ([]Group, error) {
+	var res []Group
+	err := c.list("/Groups", "ListGroups", func(r json.RawMessage) error {
+		var g Group
+		if err := json.Unmarshal(r, &g); err != nil {
+			return err
+		}
+		res = append(res, g)
+		return nil
+	})
+	return res, err
+}
+
+// list calls add with every resource of the collection at p, paging with
+// the 1-based startIndex
+func (c *Client) list(p, operation string, add func(json.RawMessage) error) error {
+	pages := paging.Start("scim", operation)
+	defer pages.Done()
+	for start := 1; ; {
+		q := url.Values{}
+		q.Set("startIndex", strconv.Itoa(start))
+		q.Set("count", strconv.Itoa(pageSize))
+		if p == "/Groups" {
+			q.Set("excludedAttributes", "members")
+		}
+		var page listResponse
+		if err := c.get(p+"?"+q.Encode(), &page); err != nil {
+			return err
+		}
+		for _, r := range page.Resources {
+			if err := add(r); err != nil {
+				return fmt.Errorf("invalid scim response of %s: %w", p, err)
+			}
+		}
+		start += len(page.Resources)
+		next := ""
+		if len(page.Resources) > 0 && start <= page.TotalResults {
+			next = strconv.Itoa(start)
+		}
+		pages.Page(len(page.Resources), next)
+		if next == "" {
+			return nil
+		}
+	}
+}
+
+// get fetches p and decodes the response into out, a response which is
+// not 2xx is an error
+func (c *Client) get(p string, out interface{}) error {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, c.endpoint+p, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/scim+json")
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		b, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("scim GET %s: %s: %s", p, res.Status, strings.TrimSpace(string(b)))
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid scim response of GET %s: %w", p, err)
+	}
+	return nil
+}
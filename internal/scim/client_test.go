@@ -0,0 +1,115 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	. "github.com/awslabs/ssosync/internal/scim"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	assert := assert.New(t)
+
+	// 150 users, served in pages of 100
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		start, _ := strconv.Atoi(r.URL.Query().Get("startIndex"))
+		var resources []interface{}
+		total := 1
+		switch r.URL.Path {
+		case "/scim/v2/Users":
+			total = 150
+			for i := start; i < start+100 && i <= total; i++ {
+				resources = append(resources, map[string]interface{}{
+					"id": "u" + strconv.Itoa(i), "userName": "user" + strconv.Itoa(i), "active": true,
+					"emails": []map[string]interface{}{{"value": "user" + strconv.Itoa(i) + "@example.com", "primary": true}},
+				})
+			}
+		case "/scim/v2/Groups":
+			assert.Equal("members", r.URL.Query().Get("excludedAttributes"))
+			resources = append(resources, map[string]interface{}{"id": "g1", "displayName": "Platform", "meta": map[string]string{"created": "2021-03-01T10:00:00Z"}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"totalResults": total, "startIndex": start, "Resources": resources})
+	}))
+	defer srv.Close()
+
+	c, err := New(context.Background(), srv.Client(), srv.URL+"/scim/v2/", "token")
+	assert.NoError(err)
+	users, err := c.Users()
+	assert.NoError(err)
+	assert.Len(users, 150)
+	assert.Equal("user150@example.com", users[149].PrimaryEmail())
+	groups, err := c.Groups()
+	assert.NoError(err)
+	assert.Len(groups, 1)
+	assert.Equal(2021, groups[0].Meta.Created.Year())
+
+	c, _ = New(context.Background(), srv.Client(), srv.URL+"/scim/v2", "wrong")
+	_, err = c.Users()
+	assert.Error(err)
+
+	_, err = New(context.Background(), srv.Client(), "", "token")
+	assert.Error(err)
+}
+
+func TestCompare(t *testing.T) {
+	assert := assert.New(t)
+
+	created := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	users := []User{
+		{ID: "u1", UserName: "ana@example.com", DisplayName: "Ana Silva", Active: true, Emails: []Email{{Value: "ana@example.com", Primary: true}}},
+		{ID: "u2", UserName: "bo@example.com", DisplayName: "Bo", Active: false, Meta: Meta{Created: created}},
+		{ID: "u3", UserName: "cy@example.com", Active: true},
+	}
+	storeUsers := []types.User{
+		{UserId: awsutils.String("u1"), UserName: awsutils.String("ana@example.com"), DisplayName: awsutils.String("Ana Souza"),
+			Emails: []types.Email{{Value: awsutils.String("ana@example.com"), Primary: true}}, UserType: awsutils.String("managed-by=ssosync version=v2.1.0 source=google:1")},
+		{UserId: awsutils.String("u2"), UserName: awsutils.String("bo@example.com"), DisplayName: awsutils.String("Bo")},
+		{UserId: awsutils.String("u4"), UserName: awsutils.String("di@example.com")},
+	}
+	groups := []Group{{ID: "g1", DisplayName: "Platform"}}
+	storeGroups := []types.Group{{GroupId: awsutils.String("g1"), DisplayName: awsutils.String("Platform")}}
+
+	ds := Compare(users, groups, storeUsers, storeGroups)
+	assert.Len(ds, 4)
+
+	assert.Equal(Mismatch, ds[0].Problem)
+	assert.Equal("ana@example.com", ds[0].Name)
+	assert.Contains(ds[0].Detail, `displayName: scim="Ana Silva" identitystore="Ana Souza"`)
+	assert.False(ds[0].PreV2)
+
+	assert.Equal(Inactive, ds[1].Problem)
+	assert.True(ds[1].PreV2)
+	assert.Equal(created, *ds[1].Created)
+
+	assert.Equal(MissingInIdentityStore, ds[2].Problem)
+	assert.Equal("u3", ds[2].ID)
+	assert.Equal(MissingInSCIM, ds[3].Problem)
+	assert.Equal("u4", ds[3].ID)
+}
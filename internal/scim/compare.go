@@ -0,0 +1,157 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/pkg/ssosync"
+)
+
+// The problems of a Discrepancy
+const (
+	// MissingInIdentityStore is an entity listed by SCIM only
+	MissingInIdentityStore = "missing-in-identitystore"
+	// MissingInSCIM is an entity listed by the Identity Store API only
+	MissingInSCIM = "missing-in-scim"
+	// Mismatch is an entity whose attributes differ
+	Mismatch = "mismatch"
+	// Inactive is a user disabled over SCIM, which the Identity Store
+	// API does not tell apart
+	Inactive = "inactive"
+)
+
+// Discrepancy is a difference between what SCIM and the Identity Store
+// API report for a user or group
+type Discrepancy struct {
+	Kind    string `json:"kind"`
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Problem string `json:"problem"`
+	Detail  string `json:"detail,omitempty"`
+	// PreV2 is set for the entities without ssosync provenance, e.g.
+	// provisioned over SCIM by ssosync before v2
+	PreV2 bool `json:"preV2"`
+	// Created is the SCIM creation time of the entity, if listed by SCIM
+	Created *time.Time `json:"created,omitempty"`
+}
+
+// Compare returns the discrepancies between the users and groups listed
+// by SCIM and by the Identity Store API, by kind, name and id
+func Compare(users []User, groups []Group, storeUsers []types.User, storeGroups []types.Group) []Discrepancy {
+	var res []Discrepancy
+
+	byId := make(map[string]types.User, len(storeUsers))
+	for _, u := range storeUsers {
+		byId[awsutils.ToString(u.UserId)] = u
+	}
+	for _, u := range users {
+		created := u.Meta.Created
+		d := Discrepancy{Kind: "user", ID: u.ID, Name: u.UserName, PreV2: true}
+		if !created.IsZero() {
+			d.Created = &created
+		}
+		su, ok := byId[u.ID]
+		delete(byId, u.ID)
+		if !ok {
+			d.Problem = MissingInIdentityStore
+			res = append(res, d)
+			continue
+		}
+		_, provenance := ssosync.UserProvenance(su)
+		d.PreV2 = !provenance
+		if diff := userDiff(u, su); diff != "" {
+			d.Problem, d.Detail = Mismatch, diff
+			res = append(res, d)
+		}
+		if !u.Active {
+			d.Problem, d.Detail = Inactive, ""
+			res = append(res, d)
+		}
+	}
+	for id, su := range byId {
+		_, provenance := ssosync.UserProvenance(su)
+		res = append(res, Discrepancy{Kind: "user", ID: id, Name: awsutils.ToString(su.UserName), Problem: MissingInSCIM, PreV2: !provenance})
+	}
+
+	groupsById := make(map[string]types.Group, len(storeGroups))
+	for _, g := range storeGroups {
+		groupsById[awsutils.ToString(g.GroupId)] = g
+	}
+	for _, g := range groups {
+		created := g.Meta.Created
+		d := Discrepancy{Kind: "group", ID: g.ID, Name: g.DisplayName, PreV2: true}
+		if !created.IsZero() {
+			d.Created = &created
+		}
+		sg, ok := groupsById[g.ID]
+		delete(groupsById, g.ID)
+		if !ok {
+			d.Problem = MissingInIdentityStore
+			res = append(res, d)
+			continue
+		}
+		_, provenance := ssosync.GroupProvenance(sg)
+		d.PreV2 = !provenance
+		if name := awsutils.ToString(sg.DisplayName); name != g.DisplayName {
+			d.Problem, d.Detail = Mismatch, fmt.Sprintf("displayName: scim=%q identitystore=%q", g.DisplayName, name)
+			res = append(res, d)
+		}
+	}
+	for id, sg := range groupsById {
+		_, provenance := ssosync.GroupProvenance(sg)
+		res = append(res, Discrepancy{Kind: "group", ID: id, Name: awsutils.ToString(sg.DisplayName), Problem: MissingInSCIM, PreV2: !provenance})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Kind != res[j].Kind {
+			return res[i].Kind > res[j].Kind
+		}
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		return res[i].ID < res[j].ID
+	})
+	return res
+}
+
+// userDiff describes the attributes of the SCIM user u which differ in
+// the Identity Store user su, empty when none
+func userDiff(u User, su types.User) string {
+	diff := ""
+	add := func(attr, a, b string) {
+		if a == b {
+			return
+		}
+		if diff != "" {
+			diff += ", "
+		}
+		diff += fmt.Sprintf("%s: scim=%q identitystore=%q", attr, a, b)
+	}
+	add("userName", u.UserName, awsutils.ToString(su.UserName))
+	add("displayName", u.DisplayName, awsutils.ToString(su.DisplayName))
+	email := ""
+	for _, e := range su.Emails {
+		if e.Primary || email == "" {
+			email = awsutils.ToString(e.Value)
+		}
+	}
+	add("email", u.PrimaryEmail(), email)
+	return diff
+}
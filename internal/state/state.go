@@ -0,0 +1,235 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package state persists what ssosync needs to remember between runs,
+// in a local file or an S3 object
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// State is what is remembered between runs
+type State struct {
+	// LastRun is the start of the last run
+	LastRun time.Time `json:"last_run"`
+	// LastResult is the result of the last run
+	LastResult string `json:"last_result"`
+	// LastSuccess is the start of the last run which did not fail
+	LastSuccess time.Time `json:"last_success"`
+	// ConsecutiveFailures is the number of failed runs since LastSuccess
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// AlertOpen is set while an alert about the failures is open
+	AlertOpen bool `json:"alert_open"`
+	// RecentChanges are the numbers of changes applied by the last runs
+	// which did not fail, the oldest first
+	RecentChanges []int `json:"recent_changes,omitempty"`
+	// Pending are the destructive changes deferred to a later run, with
+	// the time they were first deferred
+	Pending map[string]time.Time `json:"pending,omitempty"`
+	// Approvals are the pending changes approved by an operator
+	Approvals map[string]Approval `json:"approvals,omitempty"`
+	// Entities are the states of the entities noticed by the last run,
+	// e.g. protected users not deleted, by key
+	Entities map[string]string `json:"entities,omitempty"`
+	// Checkpoint is the first change the last run did not start as its
+	// max runtime elapsed, empty when it completed
+	Checkpoint string `json:"checkpoint,omitempty"`
+}
+
+// Approval records who approved a pending change and when
+type Approval struct {
+	By string    `json:"by"`
+	At time.Time `json:"at"`
+}
+
+// ChangesWindow is the number of runs kept in RecentChanges
+const ChangesWindow = 10
+
+// RecordChanges appends the number of changes of a run to RecentChanges,
+// dropping the oldest beyond ChangesWindow
+func (s *State) RecordChanges(n int) {
+	recent := append(append([]int{}, s.RecentChanges...), n)
+	if len(recent) > ChangesWindow {
+		recent = recent[len(recent)-ChangesWindow:]
+	}
+	s.RecentChanges = recent
+}
+
+// AverageChanges returns the average of RecentChanges and the number of
+// runs it is computed from
+func (s *State) AverageChanges() (float64, int) {
+	if len(s.RecentChanges) == 0 {
+		return 0, 0
+	}
+	sum := 0
+	for _, n := range s.RecentChanges {
+		sum += n
+	}
+	return float64(sum) / float64(len(s.RecentChanges)), len(s.RecentChanges)
+}
+
+// Store loads and saves the State
+type Store interface {
+	// Load returns the saved state, the zero State if none was saved yet
+	Load(context.Context) (*State, error)
+	Save(context.Context, *State) error
+}
+
+// memory is the process wide store used when none is configured
+var memory = &memoryStore{}
+
+// Open returns the store of the uri, a path or s3://bucket/key. When the
+// uri is empty the state is only kept in memory, e.g. in daemon mode.
+func Open(cfg aws.Config, uri string) (Store, error) {
+	switch {
+	case uri == "":
+		return memory, nil
+	case strings.HasPrefix(uri, "s3://"):
+		u, err := url.Parse(uri)
+		if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			return nil, fmt.Errorf("invalid state location %q, expected s3://bucket/key", uri)
+		}
+		return &s3Store{
+			client: s3.NewFromConfig(cfg),
+			bucket: u.Host,
+			key:    strings.TrimPrefix(u.Path, "/"),
+		}, nil
+	default:
+		return &fileStore{path: uri}, nil
+	}
+}
+
+type memoryStore struct {
+	mu    sync.Mutex
+	state State
+}
+
+func (m *memoryStore) Load(context.Context) (*State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.state
+	return &s, nil
+}
+
+func (m *memoryStore) Save(_ context.Context, s *State) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = *s
+	return nil
+}
+
+type fileStore struct {
+	path string
+}
+
+func (f *fileStore) Load(context.Context) (*State, error) {
+	b, err := ioutil.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decode(b)
+}
+
+// Save writes the state to a temporary file first, so that an
+// interrupted run never leaves a truncated state behind
+func (f *fileStore) Save(_ context.Context, s *State) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), ".ssosync-state-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+type s3Store struct {
+	client *s3.Client
+	bucket string
+	key    string
+}
+
+func (s *s3Store) Load(ctx context.Context) (*State, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key),
+	})
+	var nsk *types.NoSuchKey
+	if errors.As(err, &nsk) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot load state from s3://%s/%s: %w", s.bucket, s.key, err)
+	}
+	defer out.Body.Close()
+
+	b, err := ioutil.ReadAll(out.Body)
+	if err != nil {
+		return nil, err
+	}
+	return decode(b)
+}
+
+func (s *s3Store) Save(ctx context.Context, st *State) error {
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.key),
+		Body:        bytes.NewReader(b),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot save state to s3://%s/%s: %w", s.bucket, s.key, err)
+	}
+	return nil
+}
+
+func decode(b []byte) (*State, error) {
+	s := &State{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("cannot decode state: %w", err)
+	}
+	return s, nil
+}
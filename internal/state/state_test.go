@@ -0,0 +1,48 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	. "github.com/awslabs/ssosync/internal/state"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStore(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	store, err := Open(aws.Config{}, filepath.Join(t.TempDir(), "state.json"))
+	assert.NoError(err)
+
+	s, err := store.Load(ctx)
+	assert.NoError(err)
+	assert.Equal(&State{}, s)
+
+	s.ConsecutiveFailures = 2
+	assert.NoError(store.Save(ctx, s))
+
+	s, err = store.Load(ctx)
+	assert.NoError(err)
+	assert.Equal(2, s.ConsecutiveFailures)
+
+	_, err = Open(aws.Config{}, "s3://bucket")
+	assert.Error(err)
+}
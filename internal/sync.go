@@ -17,373 +17,700 @@ package internal
 
 import (
 	"context"
-	awsutils "github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
-	"io/ioutil"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/awslabs/ssosync/internal/alert"
+	"github.com/awslabs/ssosync/internal/anomaly"
 	"github.com/awslabs/ssosync/internal/aws"
 	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/drift"
+	"github.com/awslabs/ssosync/internal/emf"
+	"github.com/awslabs/ssosync/internal/freeze"
 	"github.com/awslabs/ssosync/internal/google"
+	"github.com/awslabs/ssosync/internal/hooks"
+	"github.com/awslabs/ssosync/internal/lock"
+	"github.com/awslabs/ssosync/internal/notify"
+	"github.com/awslabs/ssosync/internal/paging"
+	"github.com/awslabs/ssosync/internal/pause"
+	"github.com/awslabs/ssosync/internal/remote"
+	"github.com/awslabs/ssosync/internal/state"
+	"github.com/awslabs/ssosync/internal/targets"
+	"github.com/awslabs/ssosync/internal/transport"
+	"github.com/awslabs/ssosync/internal/update"
+	"github.com/awslabs/ssosync/internal/xray"
+	"github.com/awslabs/ssosync/pkg/cost"
+	"github.com/awslabs/ssosync/pkg/report"
+	"github.com/awslabs/ssosync/pkg/ssosync"
 	log "github.com/sirupsen/logrus"
 	admin "google.golang.org/api/admin/directory/v1"
 )
 
-// SyncGSuite is the interface for synchronizing users/groups
-type SyncGSuite interface {
-	SyncUsers(string) (*UserSyncResult, error)
-	SyncGroups(string, *UserSyncResult) error
-	RemoveUsers([]*types.User) error
+// DoSync will create a logger and run the sync with the paths
+// given to do the sync. A one line summary of the run is always
+// written to the log output, regardless of the log level.
+func DoSync(ctx context.Context, cfg *config.Config) error {
+	_, err := Sync(ctx, cfg)
+	return err
 }
 
-// SyncGSuite is an object type that will synchronize real users and groups
-type syncGSuite struct {
-	aws    aws.Client
-	google google.Client
-	cfg    *config.Config
-}
+// Sync is DoSync returning the report of the run, which is never nil
+func Sync(ctx context.Context, cfg *config.Config) (rpt *report.Report, err error) {
+	log.Info("Syncing AWS users and groups from Google Workspace SAML Application")
 
-type UserSyncResult struct {
-	index         map[string]*types.User
-	toDelete      []*types.User
-	indexByUserId map[string]*types.User
-}
+	rpt = report.New()
+	paging.Reset()
+	defer func() {
+		paging.LogSummary()
+		logAPIUsage(rpt)
+		logDirectory(rpt)
+		rpt.Finish(err)
+		estimateCost(cfg, rpt)
+		rpt.Print(log.StandardLogger().Out)
+		if cfg.EMFNamespace != "" && !cfg.Plan {
+			if err := emf.Write(log.StandardLogger().Out, rpt, cfg.EMFNamespace, cfg.IdentityStoreId); err != nil {
+				log.WithError(err).Error("cannot write the EMF metrics")
+			}
+		}
+		switch {
+		case cfg.Plan:
+			// a plan is a local preview, it is neither notified nor tracked
+			return
+		case cfg.Audit:
+			drift.Check(cfg, rpt)
+		default:
+			notify.Send(cfg, rpt)
+		}
+		alert.Track(cfg, rpt)
+	}()
 
-// New will create a new SyncGSuite object
-func New(cfg *config.Config, a aws.Client, g google.Client) SyncGSuite {
-	return &syncGSuite{
-		aws:    a,
-		google: g,
-		cfg:    cfg,
+	if err := cfg.Validate(); err != nil {
+		return rpt, err
 	}
-}
 
-// SyncUsers will Sync Google Users to AWS SSO SCIM
-// References:
-// * https://developers.google.com/admin-sdk/directory/v1/guides/search-users
-// query possible values:
-// '' --> empty or not defined
-//  name:'Jane'
-//  email:admin*
-//  isAdmin=true
-//  manager='janesmith@example.com'
-//  orgName=Engineering orgTitle:Manager
-//  EmploymentData.projects:'GeneGnomes'
-func (s *syncGSuite) SyncUsers(query string) (*UserSyncResult, error) {
-	log.Debug("get all users from amazon")
-	usersSyncResult := &UserSyncResult{
-		index:         make(map[string]*types.User),
-		toDelete:      []*types.User{},
-		indexByUserId: make(map[string]*types.User),
-	}
-	awsUsers, err := s.aws.GetUsers()
-	if err != nil {
-		log.Error("Error Getting AWS Users: ", err)
-		return usersSyncResult, err
-	}
-	for _, u := range awsUsers {
-		userToAdd := u
-		usersSyncResult.index[awsutils.ToString(u.UserName)] = &userToAdd
-		usersSyncResult.indexByUserId[awsutils.ToString(u.UserId)] = &userToAdd
+	// a sampled Lambda invocation traces the phases of the run in X-Ray
+	var seg *xray.Segment
+	if cfg.IsLambda {
+		ctx, seg = xray.Start(ctx, "ssosync")
+		defer func() { seg.End(err) }()
 	}
 
-	log.Debug("get deleted users")
-	gcpDeletedUsers, err := s.google.GetDeletedUsers()
-	if err != nil {
-		log.Error("Error Getting Deleted Users from Google: ", err)
-		return usersSyncResult, err
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
 	}
 
-	for _, u := range gcpDeletedUsers {
-		ll := log.WithFields(log.Fields{"email": u.PrimaryEmail})
-		ll.Info("Adding users to deleting from gcpDeletedUsers")
-		userInAWS, isExists := usersSyncResult.index[u.PrimaryEmail]
+	if cfg.PauseFlag != "" {
+		reason, err := pause.Check(ctx, cfg.AWSConfig, cfg.PauseFlag)
+		if err != nil {
+			return rpt, err
+		}
+		if reason != "" {
+			log.WithField("reason", reason).Warn("Syncs are paused, exiting without syncing")
+			rpt.Paused = true
+			return rpt, nil
+		}
+	}
 
-		if isExists == false {
-			ll.Debug("User already deleted")
-			continue
+	if cfg.Lock != "" {
+		l, err := acquireLock(ctx, cfg)
+		if errors.Is(err, lock.ErrLocked) {
+			log.WithError(err).Warn("Another run is in progress, exiting without syncing")
+			rpt.Locked = true
+			return rpt, nil
+		}
+		if err != nil {
+			return rpt, err
 		}
+		defer releaseLock(l)
+	}
 
-		ll.Warn("User added to delete")
-		usersSyncResult.toDelete = append(usersSyncResult.toDelete, userInAWS)
+	if cfg.UpdateCheck {
+		checkUpdate(ctx, cfg)
 	}
 
-	log.Debug("get active google users")
-	googleUsers, err := s.google.GetUsers(query)
+	googleClient, err := newGoogleClient(ctx, cfg)
 	if err != nil {
-		return usersSyncResult, err
+		return rpt, err
+	}
+	var source ssosync.Source = googleClient
+	// the etag of a group does not change with its derived members
+	if cfg.Daemon && cfg.MemberCache && !cfg.DerivedMembership {
+		source = ssosync.CachedMembers(googleClient, memberCache)
+		defer logMemberCache()
 	}
 
-	for _, u := range googleUsers {
-		if s.ignoreUser(u.PrimaryEmail) {
-			continue
+	if err := resolveIdentityStore(ctx, cfg); err != nil {
+		return rpt, err
+	}
+
+	if !cfg.SkipPreflight && cfg.IdentityStoreTarget() {
+		_, pre := xray.Start(ctx, "preflight")
+		err := aws.Preflight(ctx, cfg.IdentityStoreAWSConfig, cfg.IdentityStoreId)
+		pre.End(err)
+		if err != nil {
+			return rpt, err
 		}
+	}
 
-		ll := log.WithFields(log.Fields{"email": u.PrimaryEmail})
-		ll.Debug("finding user")
-		userInAWS, isExists := usersSyncResult.index[u.PrimaryEmail]
-		if isExists == true {
-			if u.Suspended == true {
-				ll.Warn("User added to delete as suspended in Google")
-				usersSyncResult.toDelete = append(usersSyncResult.toDelete, userInAWS)
-			} else {
-				ll.Debug("Did nothing, user already added")
-			}
-		} else {
-			if u.Suspended == true {
-				ll.Debug("Did nothing, as User suspended in Google")
-			} else {
-				userToAdd := &types.User{
-					UserName:    awsutils.String(u.PrimaryEmail),
-					DisplayName: awsutils.String(strings.Join([]string{u.Name.GivenName, u.Name.FamilyName}, " ")),
-					Name: &types.Name{
-						FamilyName: awsutils.String(u.Name.FamilyName),
-						GivenName:  awsutils.String(u.Name.GivenName),
-					},
-					Emails: []types.Email{
-						{
-							Primary: true,
-							Type:    awsutils.String("work"),
-							Value:   awsutils.String(u.PrimaryEmail),
-						},
-					},
-					ExternalIds: []types.ExternalId{
-						{
-							Id:     awsutils.String(u.Id),
-							Issuer: awsutils.String("Google"),
-						},
-					},
-				}
-				ll.Debug("Create user")
-				added, err := s.aws.CreateUser(userToAdd)
-				if err != nil {
-					ll.Error("Can't create user: ", err)
-					//return usersSyncResult, err
-				} else {
-					usersSyncResult.index[u.PrimaryEmail] = added
-					usersSyncResult.indexByUserId[awsutils.ToString(added.UserId)] = added
-				}
-			}
+	opts := Options(cfg)
+	if cfg.MaxRuntime > 0 {
+		opts.Deadline = rpt.Start.Add(cfg.MaxRuntime)
+	}
+	if err := expandLists(ctx, cfg, &opts); err != nil {
+		return rpt, err
+	}
+	if cfg.GroupSettings {
+		if opts.GroupSettings, err = groupSettings(googleClient); err != nil {
+			return rpt, err
 		}
 	}
-	return usersSyncResult, nil
-}
+	if seg != nil {
+		opts.Trace = func(name string) func(error) {
+			_, s := xray.Start(ctx, name)
+			return s.End
+		}
+	}
+	dryRun := cfg.Audit || cfg.Plan
+	if dryRun {
+		// the hooks are not called, as nothing is changed
+		log.Info("Audit mode, the changes are counted but not applied")
+	} else if opts.Hooks, err = hooks.New(ctx, cfg); err != nil {
+		return rpt, err
+	}
+
+	if opts.Frozen, err = frozen(ctx, cfg); err != nil {
+		return rpt, err
+	}
 
-// SyncGroups will sync groups from Google -> AWS SSO
-// References:
-// * https://developers.google.com/admin-sdk/directory/v1/guides/search-groups
-// query possible values:
-// '' --> empty or not defined
-//  name='contact'
-//  email:admin*
-//  memberKey=user@company.com
-//  name:contact* email:contact*
-//  name:Admin* email:aws-*
-//  email:aws-*
-func (s *syncGSuite) SyncGroups(query string, usersSyncResult *UserSyncResult) error {
-	log.Debug("get all groups from amazon")
-	awsGroups, err := s.aws.GetGroups()
+	// the state of the previous run quiets the notices it already logged
+	store, err := state.Open(cfg.AWSConfig, cfg.State)
 	if err != nil {
-		log.Warn("Error Getting AWS Groups")
-		return err
+		return rpt, err
+	}
+	prev, err := store.Load(ctx)
+	if err != nil {
+		return rpt, err
+	}
+	opts.Previous = prev.Entities
+	if prev.Checkpoint != "" {
+		// the run is a new diff, which holds the changes the last run did
+		// not start, the checkpoint is not a position to resume from
+		log.WithField("checkpoint", prev.Checkpoint).Info("The last run stopped at its max runtime, its unstarted changes are part of this diff")
+	}
+	deferring := (cfg.DeferDeletions || cfg.RequireApproval) && !dryRun
+	if deferring {
+		opts.Pending = prev.Pending
+		opts.Approved = make(map[string]bool, len(prev.Approvals))
+		for key := range prev.Approvals {
+			opts.Approved[key] = true
+		}
 	}
 
-	groupsIndex := make(map[string]*types.Group)
-	var groupsToDelete []*types.Group
-	for _, u := range awsGroups {
-		grp := u
-		groupsIndex[awsutils.ToString(u.DisplayName)] = &grp
+	names := cfg.TargetNames()
+	if len(names) > 1 {
+		engines, err := fanOut(ctx, cfg, names, ssosync.SharedSource(source), opts, rpt)
+		rpt.DryRun = dryRun
+		if err != nil || dryRun {
+			return rpt, err
+		}
+		return rpt, saveRun(ctx, store, rpt, engines, deferring)
 	}
 
-	log.WithField("query", query).Debug("get google groups")
-	googleGroups, err := s.google.GetGroups(query)
+	c, err := syncTarget(ctx, cfg, names[0], source, opts)
+	if c == nil {
+		return rpt, err
+	}
+	rpt = c.Report()
+	rpt.DryRun = dryRun
 	if err != nil {
-		return err
+		// the changes deferred before are kept for the next run
+		return rpt, err
+	}
+	if dryRun {
+		return rpt, nil
 	}
+	return rpt, saveRun(ctx, store, rpt, []ssosync.Engine{c}, deferring)
+}
 
-	googleGroupsIndex := make(map[string]*admin.Group)
+// syncTarget runs the engine of the target name, the engine is nil when
+// the run failed before it started
+func syncTarget(ctx context.Context, cfg *config.Config, name string, source ssosync.Source, opts ssosync.Options) (ssosync.Engine, error) {
+	client, err := targets.Open(ctx, cfg, name)
+	if err != nil {
+		return nil, err
+	}
+	target := client
+	dryRun := cfg.Audit || cfg.Plan
+	if dryRun {
+		target = ssosync.DryRun(client)
+	}
 
-	for _, g := range googleGroups {
-		if s.ignoreGroup(g.Email) {
-			continue
+	if cfg.AnomalyFactor > 0 && !dryRun && !cfg.Force {
+		if err := checkAnomaly(ctx, cfg, source, client, opts); err != nil {
+			return nil, err
 		}
-		googleGroupsIndex[g.Name] = g
+	}
 
-		ll := log.WithFields(log.Fields{"group": g.Name})
-		ll.Debug("Check group")
+	c, err := ssosync.New(source, target, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c, c.Run()
+}
 
-		_, isExists := groupsIndex[g.Name]
-		if isExists == true {
-			ll.Debug("Did nothing, group already exists")
-		} else {
-			ll.Debug("Creating group")
-			gg, err := s.aws.CreateGroup(awsutils.String(g.Name), awsutils.String(g.Description))
+// fanOut applies the run to the targets concurrently and merges their
+// reports into rpt, a failing target does not stop the others. It
+// returns the engines of the targets which completed, and an error when
+// none did.
+func fanOut(ctx context.Context, cfg *config.Config, names []string, source ssosync.Source, opts ssosync.Options, rpt *report.Report) ([]ssosync.Engine, error) {
+	log.WithField("targets", strings.Join(names, ",")).Info("Applying the run to the targets concurrently")
+
+	engines := make([]ssosync.Engine, len(names))
+	reports := make([]*report.Report, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			c, err := syncTarget(ctx, cfg, name, source, opts)
+			r := report.New()
+			if c != nil {
+				r = c.Report()
+			}
+			r.Finish(err)
 			if err != nil {
-				ll.Error("Can't create Group in AWS: ", err)
+				log.WithField("target", name).WithError(err).Error("Sync of the target failed")
 			} else {
-				groupsIndex[awsutils.ToString(gg.DisplayName)] = gg
+				engines[i] = c
 			}
-		}
+			log.WithField("target", name).Info("Target synced: ", r)
+			reports[i] = r
+		}(i, name)
 	}
+	wg.Wait()
 
-	for _, g := range awsGroups {
-		_, isExists := googleGroupsIndex[awsutils.ToString(g.DisplayName)]
-		if isExists == false {
-			grp := g
-			log.WithField("group", grp.DisplayName).Info("Group added to delete")
-			groupsToDelete = append(groupsToDelete, &grp)
-			delete(groupsIndex, awsutils.ToString(g.DisplayName))
+	var completed []ssosync.Engine
+	for i, name := range names {
+		rpt.Add(name, reports[i])
+		if engines[i] != nil {
+			completed = append(completed, engines[i])
 		}
 	}
+	if len(completed) == 0 {
+		return nil, fmt.Errorf("the sync of every target failed: %s", reports[0].Error)
+	}
+	return completed, nil
+}
 
-	for _, g := range groupsIndex {
-		val, _ := googleGroupsIndex[awsutils.ToString(g.DisplayName)]
-		err := s.SyncMembershipsForGroup(val, g, usersSyncResult)
-		if err != nil {
-			return err
-		}
+// groupSettings returns the reader of the Google Groups settings of the
+// engine
+func groupSettings(c google.Client) (func(*admin.Group) (ssosync.GroupSettings, error), error) {
+	r, err := google.NewSettingsReader(c)
+	if err != nil {
+		return nil, err
 	}
+	return func(g *admin.Group) (ssosync.GroupSettings, error) {
+		gs, err := r.Get(g.Email)
+		return ssosync.GroupSettings{AllowExternalMembers: gs.AllowExternalMembers, WhoCanJoin: gs.WhoCanJoin}, err
+	}, nil
+}
 
-	for _, g := range groupsToDelete {
-		log.WithField("group", g.DisplayName).Info("Delete group in AWS")
-		err := s.aws.DeleteGroup(g)
-		if err != nil {
-			return err
+// memberCache holds the members of the Google groups between the syncs
+// of daemon mode
+var memberCache = ssosync.NewMemberCache()
+
+// logMemberCache logs the group member lookups of the run served from
+// the cache
+func logMemberCache() {
+	hits, fetches := memberCache.TakeStats()
+	log.WithField("cached", hits).WithField("fetched", fetches).Info("Group members looked up")
+}
+
+// saveRun records the entity states noticed by the run and its
+// checkpoint in the state, and with deferring the changes deferred by
+// its engines, the approvals of the changes applied are dropped
+func saveRun(ctx context.Context, store state.Store, rpt *report.Report, engines []ssosync.Engine, deferring bool) error {
+	s, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	entities := rpt.Entities()
+	changed := 0
+	for key, st := range entities {
+		if s.Entities[key] != st {
+			changed++
 		}
 	}
+	log.WithField("noticed", len(entities)).WithField("changed", changed).Info("Entity states compared with the last run")
+	s.Entities = entities
+	s.Checkpoint = rpt.Checkpoint
+
+	if deferring {
+		pending := make(map[string]time.Time)
+		for _, c := range engines {
+			for key, first := range c.Pending() {
+				pending[key] = first
+			}
+		}
+		s.Pending = pending
+		for key := range s.Approvals {
+			if _, ok := pending[key]; !ok {
+				delete(s.Approvals, key)
+			}
+		}
+	}
+	return store.Save(ctx, s)
+}
 
-	return nil
+// acquireLock takes the lock preventing concurrent runs
+func acquireLock(ctx context.Context, cfg *config.Config) (*lock.Lock, error) {
+	api, loc, err := lock.Open(cfg.AWSConfig, cfg.Lock)
+	if err != nil {
+		return nil, err
+	}
+	l, err := lock.Acquire(ctx, api, loc, lock.Owner(), cfg.LockTTL)
+	if err != nil {
+		return nil, err
+	}
+	log.WithField("lock", cfg.Lock).Debug("Lock acquired")
+	return l, nil
+}
+
+// releaseLock releases the lock at the end of the run, even when the run
+// timed out, the lock then expires if it cannot be released
+func releaseLock(l *lock.Lock) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := l.Release(ctx); err != nil {
+		log.WithError(err).Error("Can't release the lock, it expires after the lock ttl")
+	}
 }
 
-func (s *syncGSuite) SyncMembershipsForGroup(googleGroup *admin.Group, awsGroup *types.Group,
-	usersSyncResult *UserSyncResult) error {
-	ll := log.WithField("group", googleGroup.Name)
+// frozen reports whether a change freeze is in effect. When the
+// calendar cannot be read the run is frozen, as deferring the deletions
+// is safer than applying them during a freeze.
+func frozen(ctx context.Context, cfg *config.Config) (bool, error) {
+	if len(cfg.FreezeWindows) == 0 && cfg.FreezeCalendar == "" {
+		return false, nil
+	}
+	hc, err := transport.NewClient("freeze", nil, transport.Options{
+		Timeout: cfg.HookTimeout,
+		Proxy:   cfg.ProxyFor(""),
+	})
+	if err != nil {
+		return false, err
+	}
+	schedule, err := freeze.Load(ctx, cfg.FreezeWindows, cfg.FreezeCalendar, hc)
+	if err != nil {
+		log.WithError(err).Error("Can't load the freeze calendar, deferring the deletions")
+		return true, nil
+	}
+	if reason := schedule.Frozen(time.Now()); reason != "" {
+		log.WithField("freeze", reason).Warn("Change freeze, the deletions are deferred")
+		return true, nil
+	}
+	return false, nil
+}
 
-	ll.Info("Fetching google groups")
-	groupMembers, err := s.google.GetGroupMembers(googleGroup)
+// checkAnomaly plans the run without applying it, and fails when the
+// number of changes is anomalous compared to the previous runs
+func checkAnomaly(ctx context.Context, cfg *config.Config, source ssosync.Source, target ssosync.Target, opts ssosync.Options) error {
+	store, err := state.Open(cfg.AWSConfig, cfg.State)
 	if err != nil {
-		ll.Info("Can't fetch google groups")
 		return err
 	}
-	memberList := make(map[string]*types.User)
-	for _, m := range groupMembers {
-		if val, ok := usersSyncResult.index[m.Email]; ok {
-			memberList[m.Email] = val
-		}
+	s, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	if _, runs := s.AverageChanges(); runs < anomaly.MinRuns {
+		log.WithField("runs", runs).Debug("Not enough previous runs for the anomaly detection")
+		return nil
 	}
-	ll.Info("Fetching aws groups")
-	awsMembers, err := s.aws.GetGroupMembers(awsGroup)
+
+	log.Info("Planning the changes to check their number")
+	plan, err := ssosync.New(source, ssosync.DryRun(target), opts)
 	if err != nil {
-		ll.Info("Can't fetch AWS groups")
 		return err
 	}
+	if err := plan.Run(); err != nil {
+		return err
+	}
+	return anomaly.Check(s, plan.Report().Changes(), cfg.AnomalyFactor, cfg.AnomalyMinChanges)
+}
 
-	var toDelete []*types.GroupMembership
-	for _, m := range awsMembers {
-		awsMember := m
-		llM := ll.WithField("MembershipId", m.MembershipId).WithField("MemberId", m.MemberId)
-		userId, ok := m.MemberId.(*types.MemberIdMemberUserId)
-		if ok != true {
-			llM.Error("Cast mismatch error")
-		}
-		user, exists := usersSyncResult.indexByUserId[userId.Value]
-		if exists == false {
-			llM.Info("Added for delete")
-			toDelete = append(toDelete, &awsMember)
-		} else {
-			_, has := memberList[awsutils.ToString(user.UserName)]
-			if has == false {
-				llM.Info("Added for delete")
-				toDelete = append(toDelete, &awsMember)
-			}
-			delete(memberList, awsutils.ToString(user.UserName))
+// logAPIUsage logs the Identity Store calls of the run, and the write
+// calls avoided by the diff compared to a naive run
+func logAPIUsage(r *report.Report) {
+	reads := 0
+	for _, s := range paging.Summary() {
+		if s.Service == "aws" {
+			reads += s.Pages
 		}
 	}
+	writes, avoided := r.Changes()+r.Errors, r.WritesAvoided()
+	saved := 0.0
+	if writes+avoided > 0 {
+		saved = 100 * float64(avoided) / float64(writes+avoided)
+	}
+	log.WithFields(log.Fields{
+		"reads":          reads,
+		"writes":         writes,
+		"writesAvoided":  avoided,
+		"usersAvoided":   r.UsersUnchanged,
+		"groupsAvoided":  r.GroupsUnchanged,
+		"membersAvoided": r.MembershipsUnchanged,
+		"writesSaved":    fmt.Sprintf("%.0f%%", saved),
+	}).Info("Identity Store API usage")
+}
 
-	for _, val := range toDelete {
-		err := s.aws.RemoveGroupMembership(val)
-		if err != nil {
-			ll.Error("Can't remove User from the group: ", err)
-			return err
+// logDirectory logs the composition of the Google directory seen by the
+// run
+func logDirectory(r *report.Report) {
+	d := r.Summary().Directory
+	log.WithFields(log.Fields{
+		"users":           d.Users,
+		"suspended":       d.Suspended,
+		"archived":        d.Archived,
+		"groups":          d.Groups,
+		"externalMembers": d.ExternalMembers,
+	}).Info("Google directory composition")
+}
+
+// estimateCost records and logs the estimated cost of the finished run
+func estimateCost(cfg *config.Config, r *report.Report) {
+	u := cost.Usage{
+		SecretsManagerCalls: config.TakeSecretReads(),
+		AWSCalls:            r.Changes() + r.Errors,
+		Duration:            r.Duration,
+	}
+	for _, s := range paging.Summary() {
+		switch s.Service {
+		case "aws":
+			u.AWSCalls += s.Pages
+		case "google":
+			u.GoogleCalls += s.Pages
 		}
 	}
+	if cfg.IsLambda {
+		u.LambdaMemoryMB, _ = strconv.Atoi(os.Getenv("AWS_LAMBDA_FUNCTION_MEMORY_SIZE"))
+	}
 
-	for _, element := range memberList {
-		ll.WithField("", element.UserName).Info("User add")
-		_, err := s.aws.AddUserToGroup(element, awsGroup)
-		if err != nil {
-			ll.Error("Can't add User to the group: ", err)
-			return err
-		}
+	e := cost.Of(u)
+	r.Cost = &e
+	log.WithFields(log.Fields{
+		"secretsManagerCalls": e.SecretsManagerCalls,
+		"awsCalls":            e.AWSCalls,
+		"googleCalls":         e.GoogleCalls,
+		"lambdaGBSeconds":     fmt.Sprintf("%.1f", e.LambdaGBSeconds),
+		"usd":                 fmt.Sprintf("%.6f", e.USD),
+	}).Info("Estimated cost of the run")
+}
+
+// checkUpdate logs when a newer release is available, it never fails
+// and gives up after a few seconds
+func checkUpdate(ctx context.Context, cfg *config.Config) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	hc, err := transport.NewClient("update", nil, transport.Options{Proxy: cfg.ProxyFor("")})
+	if err != nil {
+		log.WithError(err).Debug("Can't check for a newer release")
+		return
 	}
-	return nil
+	update.Check(ctx, hc, cfg.BuildVersion)
 }
 
-// DoSync will create a logger and run the sync with the paths
-// given to do the sync.
-func DoSync(ctx context.Context, cfg *config.Config) error {
-	log.Info("Syncing AWS users and groups from Google Workspace SAML Application")
+// newGoogleClient returns the client of the Google Admin API, the
+// credentials are read from the file unless running in Lambda
+func newGoogleClient(ctx context.Context, cfg *config.Config) (google.Client, error) {
+	creds, err := cfg.ReadGoogleCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	creds := []byte(cfg.GoogleCredentials)
+	hc, err := transport.NewClient("google", nil, transport.Options{
+		Timeout: cfg.GoogleTimeout,
+		Proxy:   cfg.ProxyFor(cfg.GoogleProxy),
+		Retries: cfg.GoogleRetries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	keys, err := google.SplitCredentials(creds)
+	if err != nil {
+		return nil, err
+	}
+	c, err := google.NewClientFromKeys(ctx, cfg.GoogleAdmin, keys, hc, googleScopes(cfg)...)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DerivedMembership {
+		c = google.DerivedMembership(c)
+	}
+	if len(cfg.GroupLabels) > 0 {
+		if c, err = google.Labeled(c, cfg.GoogleCustomerId, cfg.GroupLabels); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.Licenses) == 0 {
+		return c, nil
+	}
 
-	if !cfg.IsLambda {
-		b, err := ioutil.ReadFile(cfg.GoogleCredentials)
+	licenses := make([]google.License, 0, len(cfg.Licenses))
+	for _, l := range cfg.Licenses {
+		license, err := google.ParseLicense(l)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		creds = b
+		licenses = append(licenses, license)
+	}
+	customer := cfg.LicenseCustomer
+	if customer == "" {
+		// the primary domain, of the admin account
+		customer = cfg.GoogleAdmin[strings.LastIndex(cfg.GoogleAdmin, "@")+1:]
 	}
+	return google.Licensed(c, customer, licenses)
+}
 
-	googleClient, err := google.NewClient(ctx, cfg.GoogleAdmin, creds)
-	if err != nil {
-		return err
+// googleScopes returns the configured Google scopes, with the scopes of
+// the license and label filters when enabled
+func googleScopes(cfg *config.Config) []string {
+	var extra []string
+	if len(cfg.Licenses) > 0 {
+		extra = append(extra, google.LicensingScope)
+	}
+	if cfg.GroupSettings {
+		extra = append(extra, google.GroupsSettingsScope)
+	}
+	if len(cfg.GroupLabels) > 0 {
+		extra = append(extra, google.GroupsScope)
+		if cfg.GoogleCustomerId == "" {
+			extra = append(extra, google.CustomerScope)
+		}
+	}
+	if len(extra) == 0 {
+		return cfg.GoogleScopes
 	}
 
-	awsClient := aws.NewClient(
-		cfg.AWSConfig,
-		cfg.IdentityStoreId)
+	scopes := cfg.GoogleScopes
+	if len(scopes) == 0 {
+		scopes = google.DefaultScopes
+	}
+	return append(append([]string{}, scopes...), extra...)
+}
 
-	c := New(cfg, awsClient, googleClient)
+// resolveIdentityStore looks up a missing identity store id, or an
+// instance ARN pasted instead, in the IAM Identity Center instances of
+// the account
+func resolveIdentityStore(ctx context.Context, cfg *config.Config) error {
+	if !cfg.IdentityStoreTarget() || !cfg.DiscoverIdentityStore || (cfg.IdentityStoreId != "" && !strings.HasPrefix(cfg.IdentityStoreId, "arn:")) {
+		return nil
+	}
 
-	syncResult, err := c.SyncUsers(cfg.UserMatch)
+	instance, err := aws.DiscoverInstance(ctx, cfg.IdentityStoreAWSConfig, cfg.IdentityStoreId)
 	if err != nil {
 		return err
 	}
+	cfg.IdentityStoreId = awsutils.ToString(instance.IdentityStoreId)
+	cfg.InstanceArn = awsutils.ToString(instance.InstanceArn)
+	log.WithField("instanceArn", cfg.InstanceArn).WithField("identityStoreId", cfg.IdentityStoreId).Info("Discovered identity store")
+	return nil
+}
 
-	err = c.SyncGroups(cfg.GroupMatch, syncResult)
+// expandLists replaces the entries of the lists of the options holding
+// an S3 URI or an HTTPS URL by the entries read from them, on every run
+// so that a daemon picks up the changes
+func expandLists(ctx context.Context, cfg *config.Config, opts *ssosync.Options) error {
+	hc, err := transport.NewClient("lists", nil, transport.Options{
+		Timeout: cfg.AWSTimeout,
+		Proxy:   cfg.ProxyFor(""),
+	})
 	if err != nil {
 		return err
 	}
 
-	return c.RemoveUsers(syncResult.toDelete)
-}
-
-func (s *syncGSuite) RemoveUsers(usersList []*types.User) error {
-	for _, u := range usersList {
-		err := s.aws.DeleteUser(u)
+	lists := map[string]*[]string{
+		"user match":                  &opts.UserMatch,
+		"group match":                 &opts.GroupMatch,
+		"user exclude match":          &opts.UserExcludeMatch,
+		"group exclude match":         &opts.GroupExcludeMatch,
+		"ignore users":                &opts.IgnoreUsers,
+		"ignore groups":               &opts.IgnoreGroups,
+		"system groups":               &opts.SystemGroups,
+		"unmanaged membership groups": &opts.UnmanagedMembershipGroups,
+		"protected users":             &opts.ProtectedUsers,
+		"protected groups":            &opts.ProtectedGroups,
+		"privileged groups":           &opts.PrivilegedGroups,
+	}
+	for name, l := range lists {
+		expand := remote.Expand
+		if strings.HasSuffix(name, " match") {
+			// the queries may contain commas, they are read one per line
+			expand = remote.ExpandQueries
+		}
+		expanded, err := expand(ctx, cfg.AWSConfig, hc, *l)
 		if err != nil {
-			return err
+			return fmt.Errorf("cannot read the %s: %w", name, err)
 		}
-	}
-	return nil
-}
-
-func (s *syncGSuite) ignoreUser(name string) bool {
-	for _, u := range s.cfg.IgnoreUsers {
-		if u == name {
-			return true
+		if len(expanded) != len(*l) {
+			log.WithField("list", name).WithField("entries", len(expanded)).Debug("Read remote list")
 		}
+		*l = expanded
 	}
-
-	return false
+	return nil
 }
 
-func (s *syncGSuite) ignoreGroup(name string) bool {
-	for _, g := range s.cfg.IgnoreGroups {
-		if g == name {
-			return true
-		}
+// Options returns the engine options of the configuration
+func Options(cfg *config.Config) ssosync.Options {
+	// the shard is validated with the configuration
+	shard, shards, _ := cfg.ShardOf()
+	return ssosync.Options{
+		UserMatch:                 cfg.UserMatch,
+		GroupMatch:                cfg.GroupMatch,
+		UserExcludeMatch:          cfg.UserExcludeMatch,
+		GroupExcludeMatch:         cfg.GroupExcludeMatch,
+		IgnoreUsers:               cfg.IgnoreUsers,
+		IgnoreGroups:              cfg.IgnoreGroups,
+		IgnoreUsersGroup:          cfg.IgnoreUsersGroup,
+		IgnoreGroupsGroup:         cfg.IgnoreGroupsGroup,
+		GroupDescription:          cfg.GroupDescription,
+		Provenance:                cfg.Provenance,
+		Version:                   cfg.BuildVersion,
+		ManagedOnly:               cfg.ManagedOnly,
+		ExcludeSystemGroups:       cfg.ExcludeSystemGroups,
+		SystemGroups:              cfg.SystemGroups,
+		UnmanagedMembershipGroups: cfg.UnmanagedMembershipGroups,
+		ProtectedUsers:            cfg.ProtectedUsers,
+		ProtectedGroups:           cfg.ProtectedGroups,
+		PrivilegedGroups:          cfg.PrivilegedGroups,
+		SkipDeletedUsers:          cfg.SkipDeletedUsers,
+		DeleteAbsentUsers:         cfg.DeleteAbsentUsers,
+		GroupDescriptionTags:      cfg.GroupDescriptionTags,
+		UserNameTemplate:          cfg.UserNameTemplate,
+		UserNameCollision:         cfg.UserNameCollision,
+		EmailPolicy:               cfg.EmailPolicy,
+		GroupNamePolicy:           cfg.GroupNamePolicy,
+		GroupKey:                  cfg.GroupKey,
+		Shard:                     shard,
+		Shards:                    shards,
+		GroupNameMaxLength:        cfg.GroupNameMaxLength,
+		MemberLimit:               cfg.GroupMemberLimit,
+		OverflowGroups:            cfg.OverflowGroups,
+		RemovalChunkSize:          cfg.RemovalChunkSize,
+		RemovalRetries:            cfg.RemovalRetries,
+		RetryFailed:               cfg.RetryFailed,
+		RetryDelay:                cfg.RetryDelay,
+		Verify:                    cfg.Verify,
+		Heartbeat:                 cfg.Heartbeat,
 	}
-
-	return false
 }
@@ -0,0 +1,136 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package targets creates the target of the sync named by the
+// configuration, the AWS Identity Store or an alternate target
+package targets
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/awslabs/ssosync/internal/ad"
+	"github.com/awslabs/ssosync/internal/aws"
+	"github.com/awslabs/ssosync/internal/config"
+	"github.com/awslabs/ssosync/internal/inventory"
+	"github.com/awslabs/ssosync/internal/keycloak"
+	"github.com/awslabs/ssosync/internal/transport"
+	"github.com/awslabs/ssosync/pkg/ssosync"
+)
+
+// Factory creates the target of the configuration
+type Factory func(ctx context.Context, cfg *config.Config) (ssosync.Target, error)
+
+var (
+	mu        sync.Mutex
+	factories = map[string]Factory{
+		config.TargetIdentityStore: identityStore,
+		config.TargetKeycloak:      keycloakRealm,
+		config.TargetManagedAD:     managedAD,
+		config.TargetMemory:        memory,
+	}
+)
+
+// Register makes the target name available to the configuration, e.g.
+// from the init function of the package of an alternate target
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = f
+}
+
+// Names returns the names of the registered targets
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	res := make([]string, 0, len(factories))
+	for name := range factories {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// New creates the first target of the configuration, the Identity Store
+// when none is configured
+func New(ctx context.Context, cfg *config.Config) (ssosync.Target, error) {
+	return Open(ctx, cfg, cfg.TargetNames()[0])
+}
+
+// Open creates the target name of the configuration
+func Open(ctx context.Context, cfg *config.Config, name string) (ssosync.Target, error) {
+	mu.Lock()
+	f, ok := factories[name]
+	mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("target %q is not one of %s", name, strings.Join(Names(), ", "))
+	}
+	return f(ctx, cfg)
+}
+
+// identityStore is the AWS Identity Store of cfg.IdentityStoreId
+func identityStore(ctx context.Context, cfg *config.Config) (ssosync.Target, error) {
+	client := aws.NewClient(ctx, cfg.IdentityStoreAWSConfig, cfg.IdentityStoreId)
+	if cfg.Inventory == "" {
+		return client, nil
+	}
+	return inventory.Open(ctx, cfg.AWSConfig, cfg.Inventory, client, cfg.InventoryRefresh)
+}
+
+// keycloakRealm is the realm cfg.KeycloakRealm of the Keycloak at
+// cfg.KeycloakURL
+func keycloakRealm(ctx context.Context, cfg *config.Config) (ssosync.Target, error) {
+	hc, err := transport.NewClient("keycloak", nil, transport.Options{
+		Timeout: cfg.AWSTimeout,
+		Proxy:   cfg.ProxyFor(""),
+		Retries: cfg.TargetRetries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keycloak.New(ctx, hc, keycloak.Options{
+		URL:          cfg.KeycloakURL,
+		Realm:        cfg.KeycloakRealm,
+		TokenRealm:   cfg.KeycloakTokenRealm,
+		ClientID:     cfg.KeycloakClientID,
+		ClientSecret: cfg.KeycloakClientSecret,
+	})
+}
+
+// managedAD is the AWS Managed Microsoft AD directory cfg.DirectoryId
+func managedAD(ctx context.Context, cfg *config.Config) (ssosync.Target, error) {
+	hc, err := transport.NewClient("ds-data", nil, transport.Options{
+		Timeout: cfg.AWSTimeout,
+		Proxy:   cfg.ProxyFor(""),
+		Retries: cfg.TargetRetries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ad.New(ctx, cfg.AWSConfig, hc, cfg.DirectoryId)
+}
+
+// memoryTarget is kept between the runs of daemon mode, like the state
+// of a real target
+var memoryTarget = ssosync.NewMemoryTarget()
+
+// memory is the in-memory target, to try a configuration without
+// writing to AWS
+func memory(context.Context, *config.Config) (ssosync.Target, error) {
+	return memoryTarget, nil
+}
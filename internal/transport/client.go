@@ -0,0 +1,89 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ProxyDirect disables any proxy, including the one of the environment
+const ProxyDirect = "direct"
+
+// Options configures the HTTP clients of the API clients
+type Options struct {
+	// Timeout limits the time of a single API call, including retries
+	// of the connection but not retries of the API client, 0 disables it
+	Timeout time.Duration
+	// Proxy is the URL of an http, https or socks5 proxy, or ProxyDirect.
+	// When empty HTTPS_PROXY, HTTP_PROXY and NO_PROXY are honored.
+	Proxy string
+	// Retries is the number of retries of the requests failing with a
+	// transient error, see Retry, 0 disables them. The Timeout then
+	// limits each attempt.
+	Retries int
+}
+
+// NewClient returns an http.Client for the given service using base as
+// transport, http.DefaultTransport if nil
+func NewClient(service string, base *http.Transport, opts Options) (*http.Client, error) {
+	if base == nil {
+		base = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	switch opts.Proxy {
+	case "":
+		base.Proxy = http.ProxyFromEnvironment
+	case ProxyDirect:
+		base.Proxy = nil
+	default:
+		u, err := ParseProxy(opts.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		base.Proxy = http.ProxyURL(u)
+	}
+
+	if opts.Retries > 0 {
+		// the timeout is set on the attempts, the API clients wrapping
+		// the transport of the client ignore its timeout
+		return &http.Client{
+			Transport: &Retry{Service: service, Next: NewLogging(service, base), Retries: opts.Retries, Timeout: opts.Timeout},
+		}, nil
+	}
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: NewLogging(service, base),
+	}, nil
+}
+
+// ParseProxy parses and checks the URL of a proxy
+func ParseProxy(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", proxy, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: scheme must be http, https or socks5", proxy)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: missing host", proxy)
+	}
+	return u, nil
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transport ...
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const redacted = "REDACTED"
+
+// sensitiveHeaders are never logged verbatim
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Amz-Security-Token",
+	"X-Vault-Token",
+}
+
+// sensitiveFields are JSON keys and form fields whose values are redacted
+var sensitiveFields = map[string]bool{
+	"access_token":  true,
+	"assertion":     true,
+	"client_secret": true,
+	// Vault returns all secrets below data
+	"data":           true,
+	"id_token":       true,
+	"password":       true,
+	"private_key":    true,
+	"refresh_token":  true,
+	"routing_key":    true,
+	"secretbinary":   true,
+	"secretstring":   true,
+	"secret_key":     true,
+	"security_token": true,
+}
+
+// Logging is an http.RoundTripper that logs sanitized request and
+// response payloads when the trace log level is enabled
+type Logging struct {
+	// Service is used to tell apart the logs of the different API clients
+	Service string
+	// Next is the RoundTripper used to perform the request
+	Next http.RoundTripper
+}
+
+// NewLogging wraps next with request/response trace logging
+func NewLogging(service string, next http.RoundTripper) *Logging {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Logging{
+		Service: service,
+		Next:    next,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (l *Logging) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !log.IsLevelEnabled(log.TraceLevel) {
+		return l.Next.RoundTrip(req)
+	}
+
+	ll := log.WithFields(log.Fields{
+		"service": l.Service,
+		"method":  req.Method,
+		"url":     req.URL.Redacted(),
+	})
+
+	req = req.Clone(req.Context())
+	reqBody, err := drain(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	ll.WithField("headers", sanitizeHeaders(req.Header)).
+		WithField("body", sanitizeBody(req.Header.Get("Content-Type"), reqBody)).
+		Trace("api request")
+
+	start := time.Now()
+	resp, err := l.Next.RoundTrip(req)
+	ll = ll.WithField("duration", time.Since(start).String())
+	if err != nil {
+		ll.WithError(err).Trace("api request failed")
+		return resp, err
+	}
+
+	respBody, err := drain(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	ll.WithField("status", resp.StatusCode).
+		WithField("headers", sanitizeHeaders(resp.Header)).
+		WithField("body", sanitizeBody(resp.Header.Get("Content-Type"), respBody)).
+		Trace("api response")
+
+	return resp, nil
+}
+
+// drain reads the body and replaces it with an in-memory copy so
+// that it can still be consumed by the caller
+func drain(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	b, err := ioutil.ReadAll(*body)
+	if err != nil {
+		return nil, err
+	}
+	if err := (*body).Close(); err != nil {
+		return nil, err
+	}
+	*body = ioutil.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+func sanitizeHeaders(h http.Header) http.Header {
+	c := h.Clone()
+	for _, k := range sensitiveHeaders {
+		if c.Get(k) != "" {
+			c.Set(k, redacted)
+		}
+	}
+	return c
+}
+
+func sanitizeBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err == nil {
+			for k := range form {
+				if sensitiveFields[strings.ToLower(k)] {
+					form.Set(k, redacted)
+				}
+			}
+			return form.Encode()
+		}
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return redacted + " (non-JSON payload)"
+	}
+	b, err := json.Marshal(sanitizeValue(v))
+	if err != nil {
+		return redacted
+	}
+	return string(b)
+}
+
+func sanitizeValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if sensitiveFields[strings.ToLower(k)] {
+				t[k] = redacted
+				continue
+			}
+			t[k] = sanitizeValue(val)
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = sanitizeValue(val)
+		}
+	}
+	return v
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultRetries is the default number of retries of a request
+	DefaultRetries = 5
+	// retryBase is the delay before the first retry, doubled by each
+	// following retry
+	retryBase = time.Second
+	// retryMaxDelay caps the delay between retries without Retry-After
+	retryMaxDelay = 32 * time.Second
+	// rateLimitBody is the size of the 403 bodies read to tell the
+	// rate limits apart from the permission errors
+	rateLimitBody = 4096
+)
+
+// Retry is an http.RoundTripper retrying the requests which failed with
+// a transient error, a 429, 500, 502, 503 or 504 status or a 403 rate
+// limit of the Google APIs, with an exponential backoff honoring
+// Retry-After. A retry which cannot complete before the deadline of the
+// request context is not attempted, the last response is returned.
+type Retry struct {
+	// Service is used to tell apart the logs of the different API clients
+	Service string
+	// Next is the RoundTripper used to perform the request
+	Next http.RoundTripper
+	// Retries is the maximum number of retries of a request
+	Retries int
+	// Timeout limits the time of each attempt, 0 disables it
+	Timeout time.Duration
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Retry) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := r.attempt(req)
+		if err != nil || !retryable(resp) || attempt >= r.Retries {
+			return resp, err
+		}
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			// the body was consumed and cannot be sent again
+			return resp, nil
+		}
+
+		delay := retryAfter(resp, backoff(attempt))
+		if deadline, ok := req.Context().Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			log.WithField("service", r.Service).WithField("status", resp.StatusCode).Debug("Not retrying, the delay exceeds the deadline")
+			return resp, nil
+		}
+		log.WithFields(log.Fields{"service": r.Service, "status": resp.StatusCode, "retry": attempt + 1, "delay": delay}).
+			Warn("Transient API error, retrying")
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// attempt sends req once, within the timeout of an attempt
+func (r *Retry) attempt(req *http.Request) (*http.Response, error) {
+	if r.Timeout <= 0 {
+		return r.Next.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), r.Timeout)
+	resp, err := r.Next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the attempt lasts until its body is read
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody releases the context of an attempt when closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements io.Closer
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// retryable reports whether resp is a transient error
+func retryable(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusForbidden:
+		// the Google APIs report some rate limits with a 403, the body
+		// is read and put back
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, rateLimitBody))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(b), resp.Body), resp.Body}
+		return bytes.Contains(b, []byte("RateLimitExceeded")) || bytes.Contains(b, []byte("rateLimitExceeded"))
+	}
+	return false
+}
+
+// backoff returns the delay before the retry following attempt, with a
+// jitter spreading the retries of concurrent calls
+func backoff(attempt int) time.Duration {
+	d := retryBase << uint(attempt)
+	if d > retryMaxDelay || d <= 0 {
+		d = retryMaxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter returns the delay of the Retry-After header of resp, in
+// seconds or an HTTP date, or def without one
+func retryAfter(resp *http.Response, def time.Duration) time.Duration {
+	h := resp.Header.Get("Retry-After")
+	if h == "" {
+		return def
+	}
+	if s, err := strconv.Atoi(h); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return def
+}
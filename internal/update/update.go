@@ -0,0 +1,150 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package update checks whether a newer release of ssosync is available
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LatestURL is the GitHub API URL of the latest release
+var LatestURL = "https://api.github.com/repos/awslabs/ssosync/releases/latest"
+
+// interval is the minimum time between two checks of a process, e.g.
+// in daemon mode
+const interval = 24 * time.Hour
+
+// Release is a GitHub release
+type Release struct {
+	TagName string `json:"tag_name"`
+	URL     string `json:"html_url"`
+	Body    string `json:"body"`
+}
+
+// Security reports whether the release notes mention a security fix
+func (r Release) Security() bool {
+	body := strings.ToLower(r.Body)
+	return strings.Contains(body, "security") || strings.Contains(body, "cve-")
+}
+
+// Latest returns the latest release
+func Latest(ctx context.Context, hc *http.Client) (Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, LatestURL, nil)
+	if err != nil {
+		return Release{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return Release{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Release{}, fmt.Errorf("github returned %s", resp.Status)
+	}
+
+	var r Release
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return Release{}, fmt.Errorf("cannot decode the latest release: %w", err)
+	}
+	return r, nil
+}
+
+// Newer reports whether the version latest is newer than current, both
+// semantic versions with or without the v prefix. Versions which are not
+// semantic versions, e.g. dev builds, are never older.
+func Newer(latest, current string) bool {
+	l, ok := parse(latest)
+	if !ok {
+		return false
+	}
+	c, ok := parse(current)
+	if !ok {
+		return false
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return false
+}
+
+// parse returns the major, minor and patch of a version, ignoring any
+// pre-release or build suffix
+func parse(v string) ([3]int, bool) {
+	var res [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return res, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return res, false
+		}
+		res[i] = n
+	}
+	return res, true
+}
+
+var (
+	mu          sync.Mutex
+	lastChecked time.Time
+)
+
+// Check logs when a release newer than current is available, at error
+// level when its notes mention a security fix. It checks at most once a
+// day, and failures are only logged at debug level.
+func Check(ctx context.Context, hc *http.Client, current string) {
+	mu.Lock()
+	if time.Since(lastChecked) < interval {
+		mu.Unlock()
+		return
+	}
+	lastChecked = time.Now()
+	mu.Unlock()
+
+	r, err := Latest(ctx, hc)
+	if err != nil {
+		log.WithError(err).Debug("Can't check for a newer release")
+		return
+	}
+	if !Newer(r.TagName, current) {
+		log.WithField("version", current).Debug("ssosync is up to date")
+		return
+	}
+
+	ll := log.WithField("version", current).WithField("latest", r.TagName).WithField("url", r.URL)
+	if r.Security() {
+		ll.Error("A newer ssosync release with security fixes is available, update as soon as possible")
+		return
+	}
+	ll.Warn("A newer ssosync release is available")
+}
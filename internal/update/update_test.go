@@ -0,0 +1,51 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/awslabs/ssosync/internal/update"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewer(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(Newer("v2.1.0", "2.0.9"))
+	assert.True(Newer("v2.0.10", "v2.0.9"))
+	assert.False(Newer("v2.0.9", "v2.0.9"))
+	assert.False(Newer("v2.0.9", "v2.1.0-rc1"))
+	assert.False(Newer("v2.1.0", "dev"))
+}
+
+func TestLatest(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"tag_name":"v2.1.0","html_url":"https://github.com/awslabs/ssosync/releases/tag/v2.1.0","body":"Fixes CVE-2022-0001"}`))
+	}))
+	defer srv.Close()
+	LatestURL = srv.URL
+
+	r, err := Latest(context.Background(), srv.Client())
+	assert.NoError(err)
+	assert.Equal("v2.1.0", r.TagName)
+	assert.True(r.Security())
+}
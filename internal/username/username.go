@@ -0,0 +1,188 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package username ...
+package username
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// DefaultTemplate uses the primary email as AWS user name
+const DefaultTemplate = "{{.Email}}"
+
+// data is the data available in user name templates
+type data struct {
+	// Email is the primary email, e.g. Jane.Doe@example.com
+	Email string
+	// LocalPart is the part of the email before the @, e.g. Jane.Doe
+	LocalPart string
+	// Domain is the part of the email after the @, e.g. example.com
+	Domain string
+	// GivenName is the given name of the user, e.g. Jane
+	GivenName string
+	// FamilyName is the family name of the user, e.g. Doe
+	FamilyName string
+}
+
+var funcs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	// replace is meant for pipelines, e.g. {{.LocalPart | replace "." "_"}}
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+}
+
+// Namer maps Google users to AWS user names
+type Namer struct {
+	tmpl *template.Template
+}
+
+// New parses the user name template, e.g.
+//
+//	{{.Email}}                                default
+//	{{.LocalPart}}                            strip the domain
+//	{{.GivenName | lower}}.{{.FamilyName | lower}}
+//	{{.LocalPart}}@corp.example.com           corporate UPN
+func New(text string) (*Namer, error) {
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New("username").Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user name template: %w", err)
+	}
+	return &Namer{tmpl: tmpl}, nil
+}
+
+// Name returns the AWS user name of the Google user
+func (n *Namer) Name(u *admin.User) (string, error) {
+	d := data{Email: u.PrimaryEmail}
+	if i := strings.LastIndex(u.PrimaryEmail, "@"); i >= 0 {
+		d.LocalPart = u.PrimaryEmail[:i]
+		d.Domain = u.PrimaryEmail[i+1:]
+	}
+	if u.Name != nil {
+		d.GivenName = u.Name.GivenName
+		d.FamilyName = u.Name.FamilyName
+	}
+
+	var b bytes.Buffer
+	if err := n.tmpl.Execute(&b, d); err != nil {
+		return "", fmt.Errorf("cannot render user name of %s: %w", u.PrimaryEmail, err)
+	}
+	name := strings.TrimSpace(b.String())
+	if name == "" {
+		return "", fmt.Errorf("user name of %s is empty", u.PrimaryEmail)
+	}
+	return name, nil
+}
+
+const (
+	// CollisionFail aborts the sync when two users map to the same name
+	CollisionFail = "fail"
+	// CollisionSkip only syncs the oldest of the users mapping to the same name
+	CollisionSkip = "skip"
+	// CollisionSuffix appends a number to the names of the newer users
+	CollisionSuffix = "suffix"
+)
+
+// Collision lists the Google users mapped to the same AWS user name
+type Collision struct {
+	Name string
+	// Emails of the users, the oldest Google account first
+	Emails []string
+}
+
+// Error implements error
+func (c Collision) Error() string {
+	return fmt.Sprintf("user name %q is shared by %s", c.Name, strings.Join(c.Emails, ", "))
+}
+
+// Assign maps the users to AWS user names, resolving collisions with the
+// given policy. The oldest Google account keeps the name, so that a new
+// account can never take over an existing AWS user. Users which cannot be
+// named, or are skipped, are left out of the returned map of primary email
+// to name.
+func (n *Namer) Assign(users []*admin.User, policy string) (map[string]string, []Collision, []error) {
+	var errs []error
+	byName := make(map[string][]*admin.User)
+	for _, u := range users {
+		name, err := n.Name(u)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		byName[name] = append(byName[name], u)
+	}
+
+	taken := make(map[string]bool, len(byName))
+	for name := range byName {
+		taken[name] = true
+	}
+
+	names := make(map[string]string, len(users))
+	var collisions []Collision
+	for name, us := range byName {
+		if len(us) == 1 {
+			names[us[0].PrimaryEmail] = name
+			continue
+		}
+
+		sort.SliceStable(us, func(i, j int) bool {
+			if us[i].CreationTime != us[j].CreationTime {
+				return us[i].CreationTime < us[j].CreationTime
+			}
+			return us[i].Id < us[j].Id
+		})
+		c := Collision{Name: name}
+		for _, u := range us {
+			c.Emails = append(c.Emails, u.PrimaryEmail)
+		}
+		collisions = append(collisions, c)
+
+		names[us[0].PrimaryEmail] = name
+		if policy == CollisionSuffix {
+			for _, u := range us[1:] {
+				names[u.PrimaryEmail] = suffix(name, taken)
+			}
+		}
+	}
+
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i].Name < collisions[j].Name })
+	return names, collisions, errs
+}
+
+// suffix appends the lowest free number, starting at 2, to the name,
+// before the domain when the name is an email address
+func suffix(name string, taken map[string]bool) string {
+	local, domain := name, ""
+	if at := strings.LastIndex(name, "@"); at >= 0 {
+		local, domain = name[:at], name[at:]
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s%d%s", local, i, domain)
+		if !taken[candidate] {
+			taken[candidate] = true
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package username_test
+
+import (
+	"testing"
+
+	. "github.com/awslabs/ssosync/internal/username"
+
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestName(t *testing.T) {
+	assert := assert.New(t)
+
+	u := &admin.User{
+		PrimaryEmail: "Jane.Doe@example.com",
+		Name:         &admin.UserName{GivenName: "Jane", FamilyName: "Doe"},
+	}
+
+	tests := map[string]string{
+		"":               "Jane.Doe@example.com",
+		"{{.LocalPart}}": "Jane.Doe",
+		"{{.GivenName | lower}}.{{.FamilyName | lower}}":    "jane.doe",
+		`{{.LocalPart | replace "." "_"}}@corp.example.com`: "Jane_Doe@corp.example.com",
+	}
+	for tmpl, want := range tests {
+		n, err := New(tmpl)
+		assert.NoError(err)
+		name, err := n.Name(u)
+		assert.NoError(err)
+		assert.Equal(want, name, tmpl)
+	}
+
+	_, err := New("{{.Unknown")
+	assert.Error(err)
+
+	n, _ := New("{{.Unknown}}")
+	_, err = n.Name(u)
+	assert.Error(err)
+}
+
+func TestAssign(t *testing.T) {
+	assert := assert.New(t)
+
+	users := []*admin.User{
+		{Id: "3", PrimaryEmail: "jdoe@b.example.com", CreationTime: "2021-01-01T00:00:00.000Z"},
+		{Id: "1", PrimaryEmail: "jdoe@a.example.com", CreationTime: "2020-01-01T00:00:00.000Z"},
+		{Id: "2", PrimaryEmail: "mmax@a.example.com", CreationTime: "2020-01-01T00:00:00.000Z"},
+	}
+	n, _ := New("{{.LocalPart}}")
+
+	names, collisions, errs := n.Assign(users, CollisionSkip)
+	assert.Empty(errs)
+	assert.Equal(map[string]string{"jdoe@a.example.com": "jdoe", "mmax@a.example.com": "mmax"}, names)
+	assert.Equal([]Collision{{Name: "jdoe", Emails: []string{"jdoe@a.example.com", "jdoe@b.example.com"}}}, collisions)
+
+	names, _, _ = n.Assign(users, CollisionSuffix)
+	assert.Equal("jdoe2", names["jdoe@b.example.com"])
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package xray sends the phases of a run as AWS X-Ray subsegments of the
+// Lambda invocation to the X-Ray daemon, when the invocation is sampled
+package xray
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// traceEnv holds the trace header of the current Lambda invocation
+	traceEnv = "_X_AMZN_TRACE_ID"
+	// daemonEnv holds the address of the X-Ray daemon
+	daemonEnv = "AWS_XRAY_DAEMON_ADDRESS"
+	// defaultDaemon is the address of the X-Ray daemon when not set
+	defaultDaemon = "127.0.0.1:2000"
+	// header precedes every document sent to the daemon
+	header = `{"format": "json", "version": 1}` + "\n"
+)
+
+// parentKey is the context key of the enclosing segment
+type parentKey struct{}
+
+// Segment is a subsegment of the trace, ended with End
+type Segment struct {
+	Name     string
+	ID       string
+	TraceID  string
+	ParentID string
+	start    time.Time
+}
+
+// Enabled reports whether the current Lambda invocation is sampled
+func Enabled() bool {
+	_, _, sampled := traceHeader()
+	return sampled
+}
+
+// Start starts the subsegment name of the segment of ctx, or of the
+// Lambda invocation, nil when not sampled. The returned context is the
+// parent of the nested subsegments.
+func Start(ctx context.Context, name string) (context.Context, *Segment) {
+	root, parent, sampled := traceHeader()
+	if !sampled {
+		return ctx, nil
+	}
+	if p, ok := ctx.Value(parentKey{}).(*Segment); ok && p != nil {
+		parent = p.ID
+	}
+	s := &Segment{Name: name, ID: newID(), TraceID: root, ParentID: parent, start: time.Now()}
+	return context.WithValue(ctx, parentKey{}, s), s
+}
+
+// End sends the subsegment, marked as failed with err, a nil segment is
+// ignored
+func (s *Segment) End(err error) {
+	if s == nil {
+		return
+	}
+	doc := map[string]interface{}{
+		"type":       "subsegment",
+		"name":       s.Name,
+		"id":         s.ID,
+		"trace_id":   s.TraceID,
+		"parent_id":  s.ParentID,
+		"start_time": epoch(s.start),
+		"end_time":   epoch(time.Now()),
+	}
+	if err != nil {
+		doc["fault"] = true
+		doc["cause"] = map[string]interface{}{
+			"exceptions": []map[string]string{{"id": newID(), "message": err.Error()}},
+		}
+	}
+	if err := send(doc); err != nil {
+		log.WithError(err).WithField("segment", s.Name).Debug("Can't send the X-Ray segment")
+	}
+}
+
+// traceHeader parses the trace header of the invocation, e.g.
+// Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1
+func traceHeader() (root, parent string, sampled bool) {
+	for _, part := range strings.Split(os.Getenv(traceEnv), ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "Root":
+			root = kv[1]
+		case "Parent":
+			parent = kv[1]
+		case "Sampled":
+			sampled = kv[1] == "1"
+		}
+	}
+	return root, parent, sampled && root != "" && parent != ""
+}
+
+// send writes the document to the UDP address of the daemon
+func send(doc map[string]interface{}) error {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	conn, err := net.Dial("udp", daemonAddress())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(append([]byte(header), b...))
+	return err
+}
+
+// daemonAddress returns the UDP address of the daemon, which is either
+// host:port or "tcp:host:port udp:host:port"
+func daemonAddress() string {
+	addr := os.Getenv(daemonEnv)
+	if addr == "" {
+		return defaultDaemon
+	}
+	for _, a := range strings.Fields(addr) {
+		if strings.HasPrefix(a, "udp:") {
+			return strings.TrimPrefix(a, "udp:")
+		}
+	}
+	return addr
+}
+
+// newID returns a random 64 bit id in hex
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// epoch returns t in seconds since the epoch
+func epoch(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
@@ -0,0 +1,49 @@
+package xray_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/awslabs/ssosync/internal/xray"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegment(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("_X_AMZN_TRACE_ID", "")
+	_, s := Start(context.Background(), "sync")
+	assert.Nil(s)
+	s.End(nil)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(err)
+	defer conn.Close()
+	t.Setenv("AWS_XRAY_DAEMON_ADDRESS", "tcp:127.0.0.1:2000 udp:"+conn.LocalAddr().String())
+	t.Setenv("_X_AMZN_TRACE_ID", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+	assert.True(Enabled())
+
+	ctx, parent := Start(context.Background(), "sync")
+	_, child := Start(ctx, "google users")
+	assert.Equal("53995c3f42cd8ad8", parent.ParentID)
+	assert.Equal(parent.ID, child.ParentID)
+	child.End(errors.New("boom"))
+
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	assert.NoError(err)
+	lines := strings.SplitN(string(buf[:n]), "\n", 2)
+	assert.Equal(`{"format": "json", "version": 1}`, lines[0])
+	var doc map[string]interface{}
+	assert.NoError(json.Unmarshal([]byte(lines[1]), &doc))
+	assert.Equal("google users", doc["name"])
+	assert.Equal("1-5759e988-bd862e3fe1be46a994272793", doc["trace_id"])
+	assert.Equal(true, doc["fault"])
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cost estimates the AWS cost of a run from its usage, at the
+// us-east-1 list prices, to tune the schedule and the page sizes
+package cost
+
+import "time"
+
+const (
+	// SecretsManagerPerCall is the price of a Secrets Manager API call
+	SecretsManagerPerCall = 0.05 / 10000
+	// LambdaPerGBSecond is the price of a GB-second of an x86 Lambda function
+	LambdaPerGBSecond = 0.0000166667
+	// LambdaPerRequest is the price of a Lambda invocation
+	LambdaPerRequest = 0.20 / 1000000
+)
+
+// Usage is what a run consumed
+type Usage struct {
+	SecretsManagerCalls int
+	// AWSCalls are the Identity Store calls, which are not charged
+	AWSCalls int
+	// GoogleCalls are the Google Admin SDK calls, which are not charged
+	// but count against the Google quota
+	GoogleCalls int
+	Duration    time.Duration
+	// LambdaMemoryMB is the memory of the Lambda function, zero when not
+	// running in Lambda
+	LambdaMemoryMB int
+}
+
+// Estimate is the estimated cost of a run
+type Estimate struct {
+	SecretsManagerCalls int     `json:"secretsManagerCalls"`
+	AWSCalls            int     `json:"awsCalls"`
+	GoogleCalls         int     `json:"googleCalls"`
+	LambdaGBSeconds     float64 `json:"lambdaGBSeconds,omitempty"`
+	USD                 float64 `json:"usd"`
+}
+
+// Of returns the estimated cost of the usage u
+func Of(u Usage) Estimate {
+	e := Estimate{
+		SecretsManagerCalls: u.SecretsManagerCalls,
+		AWSCalls:            u.AWSCalls,
+		GoogleCalls:         u.GoogleCalls,
+		USD:                 float64(u.SecretsManagerCalls) * SecretsManagerPerCall,
+	}
+	if u.LambdaMemoryMB > 0 {
+		e.LambdaGBSeconds = float64(u.LambdaMemoryMB) / 1024 * u.Duration.Seconds()
+		e.USD += e.LambdaGBSeconds*LambdaPerGBSecond + LambdaPerRequest
+	}
+	return e
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cost_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/awslabs/ssosync/pkg/cost"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOf(t *testing.T) {
+	assert := assert.New(t)
+
+	e := Of(Usage{SecretsManagerCalls: 2, AWSCalls: 40, Duration: 10 * time.Second})
+	assert.Zero(e.LambdaGBSeconds)
+	assert.InDelta(2*SecretsManagerPerCall, e.USD, 1e-12)
+
+	e = Of(Usage{SecretsManagerCalls: 2, Duration: 10 * time.Second, LambdaMemoryMB: 512})
+	assert.InDelta(5, e.LambdaGBSeconds, 1e-9)
+	assert.InDelta(2*SecretsManagerPerCall+5*LambdaPerGBSecond+LambdaPerRequest, e.USD, 1e-12)
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// The causes the failures of a run are grouped by
+const (
+	CauseThrottling   = "throttling"
+	CauseQuota        = "quota"
+	CauseAccessDenied = "access-denied"
+	CauseScope        = "scope"
+	CauseNotFound     = "not-found"
+	CauseConflict     = "conflict"
+	CauseValidation   = "validation"
+	CauseTimeout      = "timeout"
+	CauseUnconverged  = "unconverged"
+	CauseOther        = "other"
+)
+
+// Classifier is implemented by the errors which know the cause of the
+// failure, e.g. aws.OperationError and google.QuotaError
+type Classifier interface {
+	error
+	Kind() string
+}
+
+// Cause returns the cause of err, the kind of the first Classifier
+// wrapped by err, or CauseOther
+func Cause(err error) string {
+	var c Classifier
+	if errors.As(err, &c) && c.Kind() != "" {
+		return c.Kind()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return CauseTimeout
+	}
+	return CauseOther
+}
+
+// Fail counts err as an operation which failed without aborting the run,
+// grouped by its cause, it is safe for concurrent use
+func (r *Report) Fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Errors++
+	if r.causes == nil {
+		r.causes = make(map[string]int)
+	}
+	r.causes[Cause(err)]++
+}
+
+// Recover no longer counts err, counted by Fail, as the operation
+// succeeded when retried, it is safe for concurrent use
+func (r *Report) Recover(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.recover(err)
+}
+
+// recover uncounts err, r.mu is held
+func (r *Report) recover(err error) {
+	r.Errors--
+	r.Retried++
+	cause := Cause(err)
+	if r.causes[cause]--; r.causes[cause] <= 0 {
+		delete(r.causes, cause)
+	}
+}
+
+// ErrorCauses returns the number of errors of the run by cause
+func (r *Report) ErrorCauses() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.errorCauses()
+}
+
+// CausesText returns the errors by cause in a sentence, e.g.
+// "throttling 2, other 1", empty without errors
+func (r *Report) CausesText() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	causes := r.errorCauses()
+	res := ""
+	for _, cause := range sortedCauses(causes) {
+		if res != "" {
+			res += ", "
+		}
+		res += fmt.Sprintf("%s %d", cause, causes[cause])
+	}
+	return res
+}
+
+// sortedCauses returns the causes of the counts in order
+func sortedCauses(causes map[string]int) []string {
+	names := make([]string, 0, len(causes))
+	for cause := range causes {
+		names = append(names, cause)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// errorCauses returns the number of errors by cause, the errors counted
+// without one are of CauseOther, r.mu is held
+func (r *Report) errorCauses() map[string]int {
+	if r.Errors == 0 {
+		return nil
+	}
+	res := make(map[string]int, len(r.causes)+1)
+	counted := 0
+	for cause, n := range r.causes {
+		res[cause] = n
+		counted += n
+	}
+	if counted < r.Errors {
+		res[CauseOther] += r.Errors - counted
+	}
+	return res
+}
+
+// causesString returns the errors by cause for the summary line, r.mu
+// is held
+func (r *Report) causesString() string {
+	causes := r.errorCauses()
+	res := ""
+	for _, cause := range sortedCauses(causes) {
+		res += fmt.Sprintf(" errors_%s=%d", cause, causes[cause])
+	}
+	return res
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/awslabs/ssosync/pkg/report"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type throttled struct{}
+
+func (throttled) Error() string { return "rate exceeded" }
+func (throttled) Kind() string  { return CauseThrottling }
+
+func TestErrorCauses(t *testing.T) {
+	assert := assert.New(t)
+
+	r := New()
+	r.Fail(fmt.Errorf("create user: %w", throttled{}))
+	r.Fail(throttled{})
+	r.Fail(errors.New("boom"))
+	r.Inc(&r.Errors)
+	r.Finish(nil)
+
+	assert.Equal(map[string]int{CauseThrottling: 2, CauseOther: 2}, r.ErrorCauses())
+	assert.Equal("other 2, throttling 2", r.CausesText())
+	assert.Contains(r.String(), " errors=4 ")
+	assert.Contains(r.String(), " errors_other=2 errors_throttling=2")
+
+	failed := New()
+	failed.Finish(fmt.Errorf("list users: %w", context.DeadlineExceeded))
+	assert.Equal(CauseTimeout, failed.Summary().Cause)
+
+	r.Add("other", failed)
+	assert.Equal(3, r.ErrorCauses()[CauseThrottling]+r.ErrorCauses()[CauseTimeout])
+	assert.Equal(CauseTimeout, r.Targets[0].Cause)
+	assert.Empty(New().ErrorCauses())
+}
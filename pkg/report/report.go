@@ -0,0 +1,582 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package report ...
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/awslabs/ssosync/pkg/cost"
+)
+
+const (
+	// ResultOK is the result of a run without any error
+	ResultOK = "ok"
+	// ResultPartial is the result of a run which completed, but failed
+	// to apply some changes
+	ResultPartial = "partial"
+	// ResultError is the result of an aborted run
+	ResultError = "error"
+)
+
+// Report ...
+type Report struct {
+	mu sync.Mutex
+
+	Result   string
+	Start    time.Time
+	Duration time.Duration
+	Error    string
+	// Cause is the cause of Error, see Cause
+	Cause string
+
+	// DryRun is set when the changes were counted but not applied
+	DryRun bool
+	// Paused is set when the run exited without syncing as the syncs
+	// are paused
+	Paused bool
+	// Locked is set when the run exited without syncing as another run
+	// holds the lock
+	Locked bool
+
+	UsersCreated       int
+	UsersDeleted       int
+	GroupsCreated      int
+	GroupsDeleted      int
+	MembershipsAdded   int
+	MembershipsRemoved int
+	// UsersUpdated is the number of users whose provenance was recorded
+	UsersUpdated int
+	// GroupsUpdated is the number of group descriptions updated
+	GroupsUpdated int
+	// Errors is the number of operations which failed without
+	// aborting the run
+	Errors int
+	// Deferred is the number of destructive changes deferred by a
+	// change freeze
+	Deferred int
+	// Retried is the number of failed operations which succeeded when
+	// retried at the end of the run
+	Retried int
+	// RiskyGroups is the number of privileged groups whose Google
+	// settings let unvetted users become members
+	RiskyGroups int
+	// Unstarted is the number of changes, and of groups whose members
+	// were not synced, not started as the max runtime of the run elapsed
+	Unstarted int
+	// Checkpoint is the first change not started as the max runtime of
+	// the run elapsed, empty when the run completed
+	Checkpoint string
+
+	// UsersUnchanged, GroupsUnchanged and MembershipsUnchanged are the
+	// users, groups and memberships already in the target, whose write
+	// calls the diff avoided
+	UsersUnchanged       int
+	GroupsUnchanged      int
+	MembershipsUnchanged int
+
+	// Directory is the composition of the Google directory seen by the
+	// run
+	Directory Directory
+
+	// Cost is the estimated cost of the finished run
+	Cost *cost.Estimate
+
+	// entities are the states of the entities noticed by the run, by key
+	entities map[string]string
+	// Targets are the results of the targets of a fan-out run, in the
+	// order of the configuration
+	Targets []TargetResult
+
+	// groupSettings are the settings of the synced groups, by group name
+	groupSettings map[string]GroupSettings
+	// rejected are the problems of the users not created, by user name,
+	// and of the groups not created, by Google group email
+	rejected map[string][]string
+	// causes are the numbers of errors by cause, see Fail
+	causes map[string]int
+	// unconverged are the changes applied but not found by the
+	// verification
+	unconverged []string
+	// removalFailures are the errors of the users which could not be
+	// deleted, by user name
+	removalFailures map[string]string
+	// renamed are the names the users and groups were synced with as
+	// their own names are invalid, by invalid name
+	renamed map[string]string
+}
+
+// Directory is the composition of the Google directory seen by a run:
+// the users and groups matching the queries, and the external members of
+// those groups, whose domain is not one of the users
+type Directory struct {
+	Users           int `json:"users"`
+	Suspended       int `json:"suspended"`
+	Archived        int `json:"archived"`
+	Groups          int `json:"groups"`
+	ExternalMembers int `json:"externalMembers"`
+}
+
+// GroupSettings are the Google settings of a synced group controlling
+// who can become a member, and their risks
+type GroupSettings struct {
+	Group                string   `json:"group"`
+	Email                string   `json:"email"`
+	AllowExternalMembers bool     `json:"allowExternalMembers"`
+	WhoCanJoin           string   `json:"whoCanJoin"`
+	Privileged           bool     `json:"privileged,omitempty"`
+	Risks                []string `json:"risks,omitempty"`
+}
+
+// TargetResult is the result of one target of a fan-out run
+type TargetResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Changes int    `json:"changes"`
+	Errors  int    `json:"errors"`
+	Error   string `json:"error,omitempty"`
+	Cause   string `json:"cause,omitempty"`
+}
+
+// New returns a new Report for a run starting now
+func New() *Report {
+	return &Report{
+		Start: time.Now(),
+	}
+}
+
+// Inc increments the given counter of the report, e.g.
+// r.Inc(&r.UsersCreated), it is safe for concurrent use
+func (r *Report) Inc(counter *int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*counter++
+}
+
+// Stop records the change key not started as the max runtime of the run
+// elapsed, the first one is the checkpoint of the run, which Stop
+// reports. It is safe for concurrent use.
+func (r *Report) Stop(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Unstarted++
+	if r.Checkpoint != "" {
+		return false
+	}
+	r.Checkpoint = key
+	return true
+}
+
+// Notice records the state of the entity key, it is safe for
+// concurrent use
+func (r *Report) Notice(key, state string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.entities == nil {
+		r.entities = make(map[string]string)
+	}
+	r.entities[key] = state
+}
+
+// Reject records the problems of the user name, or of the Google group
+// email, which was not created, it is safe for concurrent use
+func (r *Report) Reject(name string, problems []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.rejected == nil {
+		r.rejected = make(map[string][]string)
+	}
+	r.rejected[name] = problems
+}
+
+// FailRemoval counts err as the failure to delete the user name and
+// records it, it is safe for concurrent use
+func (r *Report) FailRemoval(name string, err error) {
+	r.Fail(err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.removalFailures == nil {
+		r.removalFailures = make(map[string]string)
+	}
+	r.removalFailures[name] = err.Error()
+}
+
+// Unconverged counts the applied change, described by desc, which the
+// verification did not find in the target as an error, it is safe for
+// concurrent use
+func (r *Report) Unconverged(desc string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Errors++
+	if r.causes == nil {
+		r.causes = make(map[string]int)
+	}
+	r.causes[CauseUnconverged]++
+	r.unconverged = append(r.unconverged, desc)
+}
+
+// RecoverRemoval no longer counts err as the failure to delete the user
+// name, which succeeded when retried, it is safe for concurrent use
+func (r *Report) RecoverRemoval(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.recover(err)
+	delete(r.removalFailures, name)
+}
+
+// Add merges the finished report of the target name of a fan-out run
+// into r. A failed target counts as an error of r, so that the run is
+// partial unless every target failed.
+func (r *Report) Add(name string, t *Report) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.UsersCreated += t.UsersCreated
+	r.UsersDeleted += t.UsersDeleted
+	r.GroupsCreated += t.GroupsCreated
+	r.GroupsDeleted += t.GroupsDeleted
+	r.MembershipsAdded += t.MembershipsAdded
+	r.MembershipsRemoved += t.MembershipsRemoved
+	r.UsersUpdated += t.UsersUpdated
+	r.GroupsUpdated += t.GroupsUpdated
+	r.Errors += t.Errors
+	for cause, n := range t.causes {
+		if r.causes == nil {
+			r.causes = make(map[string]int)
+		}
+		r.causes[cause] += n
+	}
+	r.Deferred += t.Deferred
+	r.Retried += t.Retried
+	r.Unstarted += t.Unstarted
+	if r.Checkpoint == "" {
+		r.Checkpoint = t.Checkpoint
+	}
+	r.UsersUnchanged += t.UsersUnchanged
+	r.GroupsUnchanged += t.GroupsUnchanged
+	r.MembershipsUnchanged += t.MembershipsUnchanged
+	if t.Result == ResultError {
+		r.Errors++
+		if r.causes == nil {
+			r.causes = make(map[string]int)
+		}
+		r.causes[t.Cause]++
+	}
+	// the targets read the same directory
+	if t.Directory.Users > r.Directory.Users {
+		r.Directory = t.Directory
+	}
+	if len(t.groupSettings) > len(r.groupSettings) {
+		r.groupSettings = t.groupSettings
+		r.RiskyGroups = t.RiskyGroups
+	}
+
+	for key, st := range t.entities {
+		if r.entities == nil {
+			r.entities = make(map[string]string)
+		}
+		r.entities[key] = st
+	}
+	for name, to := range t.renamed {
+		if r.renamed == nil {
+			r.renamed = make(map[string]string)
+		}
+		r.renamed[name] = to
+	}
+	r.unconverged = append(r.unconverged, t.unconverged...)
+	for name, msg := range t.removalFailures {
+		if r.removalFailures == nil {
+			r.removalFailures = make(map[string]string)
+		}
+		r.removalFailures[name] = msg
+	}
+	for name, problems := range t.rejected {
+		if r.rejected == nil {
+			r.rejected = make(map[string][]string)
+		}
+		r.rejected[name] = problems
+	}
+
+	r.Targets = append(r.Targets, TargetResult{
+		Name:    name,
+		Status:  t.Result,
+		Changes: t.UsersCreated + t.UsersDeleted + t.UsersUpdated + t.GroupsCreated + t.GroupsDeleted + t.GroupsUpdated + t.MembershipsAdded + t.MembershipsRemoved,
+		Errors:  t.Errors,
+		Error:   t.Error,
+		Cause:   t.Cause,
+	})
+}
+
+// AddGroupSettings records the settings of a synced group, it is safe
+// for concurrent use
+func (r *Report) AddGroupSettings(gs GroupSettings) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.groupSettings == nil {
+		r.groupSettings = make(map[string]GroupSettings)
+	}
+	r.groupSettings[gs.Group] = gs
+}
+
+// GroupSettings returns the settings of the synced groups, sorted by
+// group name
+func (r *Report) GroupSettings() []GroupSettings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.sortedSettings()
+}
+
+// sortedSettings returns the group settings sorted by group name, r.mu
+// is held
+func (r *Report) sortedSettings() []GroupSettings {
+	if len(r.groupSettings) == 0 {
+		return nil
+	}
+	res := make([]GroupSettings, 0, len(r.groupSettings))
+	for _, gs := range r.groupSettings {
+		res = append(res, gs)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Group < res[j].Group })
+	return res
+}
+
+// Rename records the name a user or group was synced with, to as name
+// is invalid, it is safe for concurrent use
+func (r *Report) Rename(name, to string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.renamed == nil {
+		r.renamed = make(map[string]string)
+	}
+	r.renamed[name] = to
+}
+
+// Entities returns the states of the entities noticed by the run
+func (r *Report) Entities() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := make(map[string]string, len(r.entities))
+	for k, v := range r.entities {
+		res[k] = v
+	}
+	return res
+}
+
+// Finish records the duration and result of the run
+func (r *Report) Finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Duration = time.Since(r.Start)
+	switch {
+	case err != nil:
+		r.Result = ResultError
+		r.Error = err.Error()
+		r.Cause = Cause(err)
+	case r.Errors > 0, r.Checkpoint != "":
+		r.Result = ResultPartial
+	default:
+		r.Result = ResultOK
+	}
+}
+
+// Changes returns the number of changes made by the run
+func (r *Report) Changes() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.UsersCreated + r.UsersDeleted + r.UsersUpdated + r.GroupsCreated + r.GroupsDeleted + r.GroupsUpdated + r.MembershipsAdded + r.MembershipsRemoved
+}
+
+// WritesAvoided returns the number of write calls a naive run, creating
+// every user, group and membership, would have made in addition
+func (r *Report) WritesAvoided() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.UsersUnchanged + r.GroupsUnchanged + r.MembershipsUnchanged
+}
+
+// Result is the JSON summary of a run, e.g. returned to the invoker of
+// the Lambda function
+type Result struct {
+	Status             string         `json:"status"`
+	DryRun             bool           `json:"dryRun,omitempty"`
+	Paused             bool           `json:"paused,omitempty"`
+	Locked             bool           `json:"locked,omitempty"`
+	Start              time.Time      `json:"start"`
+	Duration           string         `json:"duration"`
+	UsersCreated       int            `json:"usersCreated"`
+	UsersDeleted       int            `json:"usersDeleted"`
+	UsersUpdated       int            `json:"usersUpdated,omitempty"`
+	GroupsCreated      int            `json:"groupsCreated"`
+	GroupsDeleted      int            `json:"groupsDeleted"`
+	GroupsUpdated      int            `json:"groupsUpdated,omitempty"`
+	MembershipsAdded   int            `json:"membershipsAdded"`
+	MembershipsRemoved int            `json:"membershipsRemoved"`
+	Errors             int            `json:"errors"`
+	Deferred           int            `json:"deferred,omitempty"`
+	Retried            int            `json:"retried,omitempty"`
+	RiskyGroups        int            `json:"riskyGroups,omitempty"`
+	WritesAvoided      int            `json:"writesAvoided"`
+	Directory          Directory      `json:"directory"`
+	Cost               *cost.Estimate `json:"cost,omitempty"`
+	Error              string         `json:"error,omitempty"`
+	// Cause is the cause of Error
+	Cause string `json:"cause,omitempty"`
+	// ErrorCauses are the numbers of errors by cause
+	ErrorCauses map[string]int `json:"errorCauses,omitempty"`
+	// Rejected are the problems of the users and groups which were not
+	// created as they violate the Identity Store constraints, by user
+	// name and Google group email
+	Rejected map[string][]string `json:"rejected,omitempty"`
+	// RemovalFailures are the errors of the users which could not be
+	// deleted, by user name
+	RemovalFailures map[string]string `json:"removalFailures,omitempty"`
+	// Unconverged are the changes applied by the run but not found by
+	// its verification
+	Unconverged []string `json:"unconverged,omitempty"`
+	// Renamed are the transformed names the groups with an invalid name
+	// were synced with, by invalid name
+	Renamed map[string]string `json:"renamed,omitempty"`
+	// Targets are the results of the targets of a fan-out run
+	Targets []TargetResult `json:"targets,omitempty"`
+	// GroupSettings are the Google settings of the synced groups, when
+	// read
+	GroupSettings []GroupSettings `json:"groupSettings,omitempty"`
+	// Version is the version of the ssosync build which ran
+	Version string `json:"version,omitempty"`
+	// ContinuationToken is the first change not started by a run which
+	// stopped at its max runtime, empty when the run completed
+	ContinuationToken string `json:"continuationToken,omitempty"`
+}
+
+// Summary returns the Result of the finished run
+func (r *Report) Summary() Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return Result{
+		Status:             r.Result,
+		DryRun:             r.DryRun,
+		Paused:             r.Paused,
+		Locked:             r.Locked,
+		Start:              r.Start,
+		Duration:           r.Duration.Round(time.Millisecond).String(),
+		UsersCreated:       r.UsersCreated,
+		UsersDeleted:       r.UsersDeleted,
+		UsersUpdated:       r.UsersUpdated,
+		GroupsCreated:      r.GroupsCreated,
+		GroupsDeleted:      r.GroupsDeleted,
+		GroupsUpdated:      r.GroupsUpdated,
+		MembershipsAdded:   r.MembershipsAdded,
+		MembershipsRemoved: r.MembershipsRemoved,
+		Errors:             r.Errors,
+		Deferred:           r.Deferred,
+		Retried:            r.Retried,
+		RiskyGroups:        r.RiskyGroups,
+		WritesAvoided:      r.UsersUnchanged + r.GroupsUnchanged + r.MembershipsUnchanged,
+		Directory:          r.Directory,
+		Cost:               r.Cost,
+		Error:              r.Error,
+		Cause:              r.Cause,
+		ErrorCauses:        r.errorCauses(),
+		Rejected:           r.rejected,
+		RemovalFailures:    r.removalFailures,
+		Unconverged:        r.unconverged,
+		Renamed:            r.renamed,
+		Targets:            r.Targets,
+		GroupSettings:      r.sortedSettings(),
+		ContinuationToken:  r.Checkpoint,
+	}
+}
+
+// String returns the machine greppable one line summary of the run
+func (r *Report) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	extra := ""
+	if r.UsersUpdated > 0 {
+		extra += fmt.Sprintf(" users_updated=%d", r.UsersUpdated)
+	}
+	if r.GroupsUpdated > 0 {
+		extra += fmt.Sprintf(" groups_updated=%d", r.GroupsUpdated)
+	}
+	extra += r.causesString()
+	if len(r.unconverged) > 0 {
+		extra += fmt.Sprintf(" unconverged=%d", len(r.unconverged))
+	}
+	if len(r.removalFailures) > 0 {
+		extra += fmt.Sprintf(" users_not_deleted=%d", len(r.removalFailures))
+	}
+	if r.Deferred > 0 {
+		extra += fmt.Sprintf(" deferred=%d", r.Deferred)
+	}
+	if r.Retried > 0 {
+		extra += fmt.Sprintf(" retried=%d", r.Retried)
+	}
+	if r.RiskyGroups > 0 {
+		extra += fmt.Sprintf(" risky_groups=%d", r.RiskyGroups)
+	}
+	if r.Checkpoint != "" {
+		extra += fmt.Sprintf(" unstarted=%d", r.Unstarted)
+	}
+	for _, t := range r.Targets {
+		extra += fmt.Sprintf(" target_%s=%s", t.Name, t.Status)
+	}
+	if r.DryRun {
+		extra += " dry_run=true"
+	}
+	if r.Paused {
+		extra += " paused=true"
+	}
+	if r.Locked {
+		extra += " locked=true"
+	}
+	return fmt.Sprintf("result=%s users_created=%d users_deleted=%d groups_created=%d groups_deleted=%d memberships_added=%d memberships_removed=%d errors=%d duration=%s%s",
+		r.Result,
+		r.UsersCreated,
+		r.UsersDeleted,
+		r.GroupsCreated,
+		r.GroupsDeleted,
+		r.MembershipsAdded,
+		r.MembershipsRemoved,
+		r.Errors,
+		r.Duration.Round(time.Second),
+		extra,
+	)
+}
+
+// Print writes the summary line to w, bypassing the log level
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintln(w, r.String())
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"strconv"
+	"testing"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	log "github.com/sirupsen/logrus"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// directorySizes are the numbers of users of the benchmarked directories
+var directorySizes = []struct {
+	name  string
+	users int
+}{
+	{"10k", 10000},
+	{"100k", 100000},
+	{"500k", 500000},
+}
+
+const (
+	// benchGroupSize is the number of members of each benchmarked group
+	benchGroupSize = 100
+	// benchChurn is one in how many users is new or suspended
+	benchChurn = 100
+)
+
+// staticTarget is a Target of fixed users, groups and memberships, the
+// writes succeed without changing it so that every iteration of a
+// benchmark computes the same diff. Unlike MemoryTarget its lookups do
+// not grow with the directory, so the benchmarks measure the engine.
+type staticTarget struct {
+	users   []types.User
+	groups  []types.Group
+	members map[string][]types.GroupMembership
+}
+
+func (t *staticTarget) CreateUser(u *types.User) (*types.User, error) {
+	c := *u
+	c.UserId = awsutils.String("new-" + awsutils.ToString(u.UserName))
+	return &c, nil
+}
+func (t *staticTarget) DeleteUser(*types.User) error                       { return nil }
+func (t *staticTarget) DeleteGroup(*types.Group) error                     { return nil }
+func (t *staticTarget) UpdateGroup(*types.Group, *string) error            { return nil }
+func (t *staticTarget) UpdateUserType(*types.User, *string) error          { return nil }
+func (t *staticTarget) RemoveGroupMembership(*types.GroupMembership) error { return nil }
+func (t *staticTarget) CreateGroup(name *string, description *string) (*types.Group, error) {
+	return &types.Group{GroupId: awsutils.String("new-" + awsutils.ToString(name)), DisplayName: name, Description: description}, nil
+}
+func (t *staticTarget) AddUserToGroup(u *types.User, g *types.Group) (*types.GroupMembership, error) {
+	return &types.GroupMembership{GroupId: g.GroupId, MemberId: &types.MemberIdMemberUserId{Value: awsutils.ToString(u.UserId)}}, nil
+}
+func (t *staticTarget) GetGroupMembers(g *types.Group) ([]types.GroupMembership, error) {
+	return t.members[awsutils.ToString(g.GroupId)], nil
+}
+func (t *staticTarget) GetGroups() ([]types.Group, error)                   { return t.groups, nil }
+func (t *staticTarget) GetUsers() ([]types.User, error)                     { return t.users, nil }
+func (t *staticTarget) FindUserByUserName(string) (*types.User, error)      { return nil, nil }
+func (t *staticTarget) FindGroupByDisplayName(string) (*types.Group, error) { return nil, nil }
+
+// benchDirectory returns a Google directory of n users in groups of
+// benchGroupSize and its AWS copy, in which one in benchChurn users is
+// missing and as many are suspended in Google
+func benchDirectory(n int) (*memorySource, *staticTarget) {
+	source := &memorySource{members: make(map[string][]*admin.Member)}
+	target := &staticTarget{members: make(map[string][]types.GroupMembership)}
+
+	for i := 0; i < n; i++ {
+		id := strconv.Itoa(i)
+		email := "user" + id + "@example.com"
+		source.users = append(source.users, &admin.User{
+			Id:           id,
+			PrimaryEmail: email,
+			Name:         &admin.UserName{GivenName: "User", FamilyName: id},
+			Suspended:    i%benchChurn == 1,
+		})
+		if i%benchChurn != 0 {
+			target.users = append(target.users, types.User{UserId: awsutils.String("u" + id), UserName: awsutils.String(email)})
+		}
+
+		groupId := strconv.Itoa(i / benchGroupSize)
+		if i%benchGroupSize == 0 {
+			name := "group" + groupId
+			source.groups = append(source.groups, &admin.Group{Id: "g" + groupId, Name: name, Email: name + "@example.com"})
+			target.groups = append(target.groups, types.Group{GroupId: awsutils.String("g" + groupId), DisplayName: awsutils.String(name)})
+		}
+		source.members["g"+groupId] = append(source.members["g"+groupId], &admin.Member{Email: email, Type: "USER"})
+		if i%benchChurn != 0 {
+			target.members["g"+groupId] = append(target.members["g"+groupId], types.GroupMembership{
+				MembershipId: awsutils.String("m" + id),
+				GroupId:      awsutils.String("g" + groupId),
+				MemberId:     &types.MemberIdMemberUserId{Value: "u" + id},
+			})
+		}
+	}
+	return source, target
+}
+
+// quietBenchmark silences the per entity log lines of the engine for the
+// duration of the benchmark
+func quietBenchmark(b *testing.B) {
+	level := log.GetLevel()
+	log.SetLevel(log.ErrorLevel)
+	b.Cleanup(func() { log.SetLevel(level) })
+}
+
+func BenchmarkSyncUsers(b *testing.B) {
+	quietBenchmark(b)
+	for _, size := range directorySizes {
+		b.Run(size.name, func(b *testing.B) {
+			if testing.Short() && size.users > 100000 {
+				b.Skip("skipping the largest directory in short mode")
+			}
+			source, target := benchDirectory(size.users)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s, err := New(source, target, Options{SkipDeletedUsers: true})
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := s.SyncUsers(nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSyncGroups(b *testing.B) {
+	quietBenchmark(b)
+	for _, size := range directorySizes {
+		b.Run(size.name, func(b *testing.B) {
+			if testing.Short() && size.users > 100000 {
+				b.Skip("skipping the largest directory in short mode")
+			}
+			source, target := benchDirectory(size.users)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				s, err := New(source, target, Options{SkipDeletedUsers: true})
+				if err != nil {
+					b.Fatal(err)
+				}
+				users, err := s.SyncUsers(nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+				if err := s.SyncGroups(nil, users); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	quietBenchmark(b)
+	for _, size := range directorySizes {
+		b.Run(size.name, func(b *testing.B) {
+			if testing.Short() && size.users > 100000 {
+				b.Skip("skipping the largest directory in short mode")
+			}
+			source, target := benchDirectory(size.users)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s, err := New(source, target, Options{SkipDeletedUsers: true})
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := s.Run(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// expired reports whether the deadline of the run passed
+func (s *engine) expired() bool {
+	return !s.opts.Deadline.IsZero() && !time.Now().Before(s.opts.Deadline)
+}
+
+// stop records the change key not started as the deadline passed, the
+// first one is logged as the checkpoint of the run
+func (s *engine) stop(key string) {
+	if s.report.Stop(key) {
+		log.WithField("checkpoint", key).Warn("Max runtime elapsed, no further change is started")
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestDeadline(t *testing.T) {
+	assert := assert.New(t)
+
+	source := &memorySource{
+		users: []*admin.User{
+			{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}},
+			{Id: "2", PrimaryEmail: "bo@example.com", Name: &admin.UserName{GivenName: "Bo", FamilyName: "Li"}},
+		},
+		groups: []*admin.Group{
+			{Id: "g1", Name: "Platform", Email: "platform@example.com"},
+		},
+		members: map[string][]*admin.Member{
+			"g1": {{Email: "ana@example.com", Type: "USER"}},
+		},
+	}
+	target := NewMemoryTarget()
+
+	s, err := New(source, target, Options{Deadline: time.Now().Add(time.Hour)})
+	assert.NoError(err)
+	// the deadline passes while the first user is created
+	e := s.(*engine)
+	e.opts.Hooks = []Hook{HookFunc(func(ev Event) error {
+		if ev.Phase == PhasePost {
+			e.opts.Deadline = time.Now()
+		}
+		return nil
+	})}
+	assert.NoError(s.Run())
+
+	users, _ := target.GetUsers()
+	assert.Len(users, 1)
+	groups, _ := target.GetGroups()
+	assert.Empty(groups)
+	assert.Equal("user_create:/bo@example.com", s.Report().Checkpoint)
+	assert.Equal(2, s.Report().Unstarted)
+
+	// the next run applies the remaining changes
+	s, _ = New(source, target, Options{Deadline: time.Now().Add(time.Hour)})
+	assert.NoError(s.Run())
+	assert.Empty(s.Report().Checkpoint)
+	users, _ = target.GetUsers()
+	assert.Len(users, 2)
+	assert.Equal(1, s.Report().MembershipsAdded)
+}
+
+func TestDeadlineRuns(t *testing.T) {
+	assert := assert.New(t)
+
+	source := &memorySource{
+		users: []*admin.User{
+			{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}},
+			{Id: "2", PrimaryEmail: "bo@example.com", Name: &admin.UserName{GivenName: "Bo", FamilyName: "Li"}},
+			{Id: "3", PrimaryEmail: "rui@example.com", Name: &admin.UserName{GivenName: "Rui", FamilyName: "Costa"}},
+		},
+	}
+	target := NewMemoryTarget()
+
+	// each run passes its deadline after its first change
+	run := func() *Report {
+		s, err := New(source, target, Options{Deadline: time.Now().Add(time.Hour)})
+		assert.NoError(err)
+		e := s.(*engine)
+		e.opts.Hooks = []Hook{HookFunc(func(ev Event) error {
+			if ev.Phase == PhasePost {
+				e.opts.Deadline = time.Now()
+			}
+			return nil
+		})}
+		assert.NoError(s.Run())
+		return s.Report()
+	}
+
+	// the second run does not resume from the checkpoint of the first,
+	// its diff starts with the first change the first run did not start
+	first := run()
+	assert.Equal("user_create:/bo@example.com", first.Checkpoint)
+	assert.Equal(2, first.Unstarted)
+	second := run()
+	assert.Equal(1, second.UsersCreated)
+	assert.Equal("user_create:/rui@example.com", second.Checkpoint)
+	assert.Equal(1, second.Unstarted)
+
+	users, _ := target.GetUsers()
+	assert.Len(users, 2)
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"strings"
+
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// composition is the composition of the Google directory seen by a run,
+// recorded in the Directory of the report
+type composition struct {
+	// domains are the domains of the primary emails of the users
+	domains map[string]bool
+	// external are the emails of the external members seen
+	external map[string]bool
+}
+
+// countUsers records the number of Google users, suspended and archived
+func (s *engine) countUsers(users []*admin.User) {
+	d := &s.report.Directory
+	s.composition.domains = make(map[string]bool)
+	for _, u := range users {
+		s.report.Inc(&d.Users)
+		if u.Suspended {
+			s.report.Inc(&d.Suspended)
+		}
+		if u.Archived {
+			s.report.Inc(&d.Archived)
+		}
+		s.composition.domains[domainOf(u.PrimaryEmail)] = true
+	}
+}
+
+// countGroups records the number of Google groups
+func (s *engine) countGroups(groups []*admin.Group) {
+	for range groups {
+		s.report.Inc(&s.report.Directory.Groups)
+	}
+}
+
+// countMembers records the user members of a group outside the domains
+// of the users, counted once across the groups
+func (s *engine) countMembers(members []*admin.Member) {
+	if s.composition.domains == nil {
+		// the users were not listed, e.g. SyncGroups called alone
+		return
+	}
+	for _, m := range members {
+		if m.Type != "USER" || s.composition.domains[domainOf(m.Email)] || s.composition.external[m.Email] {
+			continue
+		}
+		if s.composition.external == nil {
+			s.composition.external = make(map[string]bool)
+		}
+		s.composition.external[m.Email] = true
+		s.report.Inc(&s.report.Directory.ExternalMembers)
+	}
+}
+
+// domainOf returns the lowercased domain of the email
+func domainOf(email string) string {
+	return strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+}
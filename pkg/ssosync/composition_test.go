@@ -0,0 +1,48 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+
+	"github.com/awslabs/ssosync/pkg/report"
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestComposition(t *testing.T) {
+	assert := assert.New(t)
+
+	source := &memorySource{
+		users: []*admin.User{
+			{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}},
+			{Id: "2", PrimaryEmail: "bo@example.com", Name: &admin.UserName{GivenName: "Bo", FamilyName: "Li"}, Suspended: true},
+			{Id: "3", PrimaryEmail: "cy@Example.com", Name: &admin.UserName{GivenName: "Cy", FamilyName: "Ng"}, Archived: true},
+		},
+		groups: []*admin.Group{
+			{Id: "g1", Name: "Platform", Email: "platform@example.com"},
+			{Id: "g2", Name: "Partners", Email: "partners@example.com"},
+		},
+		members: map[string][]*admin.Member{
+			"g1": {{Email: "ana@example.com", Type: "USER"}, {Email: "dev@partner.io", Type: "USER"}},
+			"g2": {{Email: "dev@partner.io", Type: "USER"}, {Email: "ops@partner.io", Type: "USER"}, {Email: "team@other.io", Type: "GROUP"}},
+		},
+	}
+
+	s, err := New(source, NewMemoryTarget(), Options{})
+	assert.NoError(err)
+	assert.NoError(s.Run())
+	assert.Equal(report.Directory{Users: 3, Suspended: 1, Archived: 1, Groups: 2, ExternalMembers: 2}, s.Report().Directory)
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// key identifies the change e across runs
+func (e Event) key() string {
+	return string(e.Type) + ":" + e.GroupName + "/" + e.UserName
+}
+
+// deferred reports whether the destructive change e is deferred, by a
+// change freeze, until it is confirmed by a later run or until it is
+// approved, in which case it is logged and counted but not applied
+func (s *engine) deferred(e Event) bool {
+	if !s.opts.Frozen && !s.opts.DeferDeletions && !s.opts.RequireApproval {
+		return false
+	}
+
+	key := e.key()
+	first, seen := s.opts.Pending[key]
+	if !seen {
+		first = time.Now()
+	}
+	confirmed := !s.opts.DeferDeletions || seen && time.Since(first) >= s.opts.DeletionDelay
+	approved := !s.opts.RequireApproval || s.opts.Approved[key]
+	if !s.opts.Frozen && confirmed && approved {
+		return false
+	}
+	if s.opts.DeferDeletions || s.opts.RequireApproval {
+		s.pending[key] = first
+	}
+
+	reason, state := "Change freeze, deferring the change", "frozen"
+	switch {
+	case s.opts.Frozen:
+	case !confirmed:
+		reason, state = "Deferring the change to a later run", "deferred"
+	default:
+		reason, state = "Change pending approval", "pending-approval"
+	}
+	s.notice(key, state, log.WarnLevel, log.WithField("event", e.Type).WithField("userName", e.UserName).WithField("group", e.GroupName).
+		WithField("since", first.Format(time.RFC3339)), reason)
+	s.report.Inc(&s.report.Deferred)
+	return true
+}
+
+// Pending implements Engine
+func (s *engine) Pending() map[string]time.Time {
+	return s.pending
+}
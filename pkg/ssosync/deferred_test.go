@@ -0,0 +1,44 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeferred(t *testing.T) {
+	assert := assert.New(t)
+
+	e := Event{Type: EventUserDelete, UserName: "jane@example.com"}
+	s, err := New(nil, nil, Options{DeferDeletions: true, DeletionDelay: time.Hour})
+	assert.NoError(err)
+	assert.True(s.(*engine).deferred(e))
+	assert.Contains(s.Pending(), e.key())
+
+	// confirmed by a later run, but before the delay
+	s, _ = New(nil, nil, Options{DeferDeletions: true, DeletionDelay: time.Hour, Pending: map[string]time.Time{e.key(): time.Now().Add(-time.Minute)}})
+	assert.True(s.(*engine).deferred(e))
+
+	s, _ = New(nil, nil, Options{DeferDeletions: true, DeletionDelay: time.Hour, Pending: map[string]time.Time{e.key(): time.Now().Add(-2 * time.Hour)}})
+	assert.False(s.(*engine).deferred(e))
+	assert.Empty(s.Pending())
+
+	s, _ = New(nil, nil, Options{Frozen: true, DeferDeletions: true, Pending: map[string]time.Time{e.key(): time.Now().Add(-2 * time.Hour)}})
+	assert.True(s.(*engine).deferred(e))
+	assert.Equal(1, s.Report().Deferred)
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	log "github.com/sirupsen/logrus"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// GroupUpdater is implemented by the targets which replace the
+// description of an existing group, required by Options.GroupDescription
+// and GroupKeyEmail
+type GroupUpdater interface {
+	UpdateGroup(g *types.Group, description *string) error
+}
+
+var errNoGroupUpdate = errors.New("the target does not update the descriptions of the groups")
+
+// updateGroup replaces the description of the target group g
+func (s *engine) updateGroup(g *types.Group, description *string) error {
+	updater, ok := s.target.(GroupUpdater)
+	if !ok {
+		return errNoGroupUpdate
+	}
+	return updater.UpdateGroup(g, description)
+}
+
+// DefaultGroupDescription is the usual template of the descriptions of
+// the groups without a Google description
+const DefaultGroupDescription = "Synced from Google group {{.Email}} by ssosync"
+
+// parseDescription parses the template of the group descriptions, nil
+// when text is empty
+func parseDescription(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("description").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid group description template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// groupDescription returns the description of the target group of g,
+// the Google description without the tags or else the rendered template,
+// cut to the length the Identity Store allows, nil when empty as the
+// Identity Store rejects empty descriptions
+func (s *engine) groupDescription(g *admin.Group, p groupPolicy) (*string, error) {
+	if p.Description != "" {
+		return truncate(awsutils.String(p.Description)), nil
+	}
+	if s.description == nil {
+		return nil, nil
+	}
+
+	var b bytes.Buffer
+	err := s.description.Execute(&b, struct{ Name, Email, Id string }{g.Name, g.Email, g.Id})
+	if err != nil {
+		return nil, fmt.Errorf("cannot render description of group %s: %w", g.Email, err)
+	}
+	if d := strings.TrimSpace(b.String()); d != "" {
+		return truncate(awsutils.String(d)), nil
+	}
+	return nil, nil
+}
+
+// updateDescription replaces the description of the target group g
+func (s *engine) updateDescription(ll *log.Entry, g *types.Group, description *string) {
+	ll.Debug("Updating group description")
+	event := Event{Type: EventGroupUpdate, GroupName: awsutils.ToString(g.DisplayName)}
+	if !s.before(event) {
+		return
+	}
+	err := s.updateGroup(g, description)
+	if err != nil {
+		ll.Error("Can't update group description in AWS: ", err)
+		s.report.Fail(err)
+		s.retryLater("update group "+event.GroupName, event, err, func() error {
+			if err := s.updateGroup(g, description); err != nil {
+				return err
+			}
+			g.Description = description
+			s.report.Inc(&s.report.GroupsUpdated)
+			return nil
+		})
+		return
+	}
+	s.after(event, nil)
+	g.Description = description
+	s.report.Inc(&s.report.GroupsUpdated)
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestGroupDescription(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpl, err := parseDescription(DefaultGroupDescription)
+	assert.NoError(err)
+	s := &engine{description: tmpl}
+	g := &admin.Group{Name: "Platform", Email: "platform@example.com"}
+
+	d, err := s.groupDescription(g, groupPolicy{Name: g.Name})
+	assert.NoError(err)
+	assert.Equal("Synced from Google group platform@example.com by ssosync", awsutils.ToString(d))
+
+	d, err = s.groupDescription(g, groupPolicy{Name: g.Name, Description: "Platform team"})
+	assert.NoError(err)
+	assert.Equal("Platform team", awsutils.ToString(d))
+
+	s.description = nil
+	d, err = s.groupDescription(g, groupPolicy{Name: g.Name})
+	assert.NoError(err)
+	assert.Nil(d)
+
+	_, err = parseDescription("{{.Email")
+	assert.Error(err)
+}
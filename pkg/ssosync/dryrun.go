@@ -0,0 +1,147 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"strings"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/internal/logging"
+	log "github.com/sirupsen/logrus"
+)
+
+// plannedPrefix prefixes the ids of the users and groups a dry run
+// would have created
+const plannedPrefix = "planned-"
+
+// dryRun is a Target which reads from the wrapped target, but only
+// logs the changes
+type dryRun struct {
+	Target
+}
+
+// DryRun returns a Target reading from target which logs the changes
+// instead of applying them, so that the report of the run counts the
+// drift between the source and the target
+func DryRun(target Target) Target {
+	return &dryRun{Target: target}
+}
+
+// unwrap returns the target wrapped by DryRun, whose capabilities the
+// dry run has, the target itself otherwise
+func unwrap(target Target) Target {
+	if d, ok := target.(*dryRun); ok {
+		return d.Target
+	}
+	return target
+}
+
+// CreateUser returns the user with a planned id instead of creating it
+func (d *dryRun) CreateUser(u *types.User) (*types.User, error) {
+	planned().WithField("userName", awsutils.ToString(u.UserName)).WithField(logging.ChangeField, EventUserCreate).Info("Dry run, would create user")
+	created := *u
+	created.UserId = awsutils.String(plannedPrefix + awsutils.ToString(u.UserName))
+	return &created, nil
+}
+
+// UpdateUserType only logs the update, the target must implement
+// UserTypeUpdater
+func (d *dryRun) UpdateUserType(u *types.User, userType *string) error {
+	if _, ok := d.Target.(UserTypeUpdater); !ok {
+		return errNoUserTypeUpdate
+	}
+	planned().WithField("userName", awsutils.ToString(u.UserName)).WithField(logging.ChangeField, EventUserUpdate).Info("Dry run, would update user type")
+	return nil
+}
+
+// UpdateUser only logs the update, the target must implement UserUpdater
+func (d *dryRun) UpdateUser(u *types.User, attrs *types.User) error {
+	if _, ok := d.Target.(UserUpdater); !ok {
+		return errNoUserUpdate
+	}
+	planned().WithField("userName", awsutils.ToString(u.UserName)).WithField(logging.ChangeField, EventUserUpdate).Info("Dry run, would update user")
+	return nil
+}
+
+// DeleteUser only logs the deletion
+func (d *dryRun) DeleteUser(u *types.User) error {
+	planned().WithField("userName", awsutils.ToString(u.UserName)).WithField(logging.ChangeField, EventUserDelete).Info("Dry run, would delete user")
+	return nil
+}
+
+// CreateGroup returns the group with a planned id instead of creating it
+func (d *dryRun) CreateGroup(name *string, description *string) (*types.Group, error) {
+	planned().WithField("group", awsutils.ToString(name)).WithField(logging.ChangeField, EventGroupCreate).Info("Dry run, would create group")
+	return &types.Group{
+		GroupId:     awsutils.String(plannedPrefix + awsutils.ToString(name)),
+		DisplayName: name,
+		Description: description,
+	}, nil
+}
+
+// UpdateGroup only logs the update, the target must implement
+// GroupUpdater
+func (d *dryRun) UpdateGroup(g *types.Group, description *string) error {
+	if _, ok := d.Target.(GroupUpdater); !ok {
+		return errNoGroupUpdate
+	}
+	planned().WithField("group", awsutils.ToString(g.DisplayName)).WithField("description", awsutils.ToString(description)).WithField(logging.ChangeField, EventGroupUpdate).Info("Dry run, would update group description")
+	return nil
+}
+
+// RenameGroup only logs the rename, the target must implement
+// GroupRenamer
+func (d *dryRun) RenameGroup(g *types.Group, name *string) error {
+	if _, ok := d.Target.(GroupRenamer); !ok {
+		return errNoGroupRename
+	}
+	planned().WithField("group", awsutils.ToString(g.DisplayName)).WithField("name", awsutils.ToString(name)).WithField(logging.ChangeField, EventGroupUpdate).Info("Dry run, would rename group")
+	return nil
+}
+
+// DeleteGroup only logs the deletion
+func (d *dryRun) DeleteGroup(g *types.Group) error {
+	planned().WithField("group", awsutils.ToString(g.DisplayName)).WithField(logging.ChangeField, EventGroupDelete).Info("Dry run, would delete group")
+	return nil
+}
+
+// AddUserToGroup returns the membership instead of adding it
+func (d *dryRun) AddUserToGroup(u *types.User, g *types.Group) (*types.GroupMembership, error) {
+	planned().WithField("userName", awsutils.ToString(u.UserName)).WithField("group", awsutils.ToString(g.DisplayName)).
+		WithField(logging.ChangeField, EventMemberAdd).Info("Dry run, would add user to group")
+	return &types.GroupMembership{GroupId: g.GroupId, MemberId: &types.MemberIdMemberUserId{Value: awsutils.ToString(u.UserId)}}, nil
+}
+
+// RemoveGroupMembership only logs the removal
+func (d *dryRun) RemoveGroupMembership(m *types.GroupMembership) error {
+	planned().WithField("membershipId", awsutils.ToString(m.MembershipId)).WithField(logging.ChangeField, EventMemberRemove).Info("Dry run, would remove group membership")
+	return nil
+}
+
+// GetGroupMembers returns the members of existing groups
+func (d *dryRun) GetGroupMembers(g *types.Group) ([]types.GroupMembership, error) {
+	// a group the dry run would have created has no members yet
+	if strings.HasPrefix(awsutils.ToString(g.GroupId), plannedPrefix) {
+		return nil, nil
+	}
+	return d.Target.GetGroupMembers(g)
+}
+
+// planned returns the logger of the planned changes, marked for the audit
+// formats
+func planned() *log.Entry {
+	return log.WithField(logging.PlannedField, true)
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	log "github.com/sirupsen/logrus"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+const (
+	// EmailSkip skips the users with an invalid primary email, and
+	// reports them
+	EmailSkip = "skip"
+	// EmailTruncate additionally truncates the names of the users longer
+	// than the Identity Store allows
+	EmailTruncate = "truncate"
+	// EmailAlias additionally syncs the users with an invalid primary
+	// email with their first valid alias
+	EmailAlias = "alias"
+
+	// maxEmail, maxLocalPart and maxLabel are the RFC 5321 limits of the
+	// length of an email address, of its local part and of the labels of
+	// its domain
+	maxEmail     = 254
+	maxLocalPart = 64
+	maxLabel     = 63
+)
+
+// emailProblem returns why the email is not accepted, or "" when valid:
+// it must be at most 254 ASCII characters long with a local part of at
+// most 64 and domain labels of at most 63, as the Identity Store does not
+// support internationalized emails
+func emailProblem(email string) string {
+	at := strings.LastIndex(email, "@")
+	switch {
+	case at <= 0 || at == len(email)-1:
+		return fmt.Sprintf("email %q is not an address", email)
+	case utf8.RuneCountInString(email) > maxEmail:
+		return fmt.Sprintf("email %q is longer than %d characters", email, maxEmail)
+	case at > maxLocalPart:
+		return fmt.Sprintf("email %q has a local part longer than %d characters", email, maxLocalPart)
+	case longestLabel(email[at+1:]) > maxLabel:
+		return fmt.Sprintf("email %q has a domain label longer than %d characters", email, maxLabel)
+	}
+	for _, r := range email {
+		if r <= ' ' || r > '~' {
+			return fmt.Sprintf("email %q contains the unsupported character %q", email, r)
+		}
+	}
+	return ""
+}
+
+// longestLabel returns the length of the longest label of the domain
+func longestLabel(domain string) int {
+	longest := 0
+	for _, label := range strings.Split(domain, ".") {
+		if len(label) > longest {
+			longest = len(label)
+		}
+	}
+	return longest
+}
+
+// applyEmailPolicy returns the users whose primary email is valid, with
+// EmailAlias the users mapped to their first valid alias, and the primary
+// emails of those by alias. The other users are logged and reported.
+func (s *engine) applyEmailPolicy(users []*admin.User) ([]*admin.User, map[string]string) {
+	res := make([]*admin.User, 0, len(users))
+	mapped := make(map[string]string)
+	for _, u := range users {
+		problem := emailProblem(u.PrimaryEmail)
+		if problem == "" {
+			res = append(res, u)
+			continue
+		}
+		ll := log.WithField("email", u.PrimaryEmail)
+		if s.opts.EmailPolicy == EmailAlias {
+			if alias := validAlias(u); alias != "" {
+				ll.WithField("alias", alias).Warn("Invalid primary email, syncing the user with its alias")
+				m := *u
+				m.PrimaryEmail = alias
+				mapped[alias] = u.PrimaryEmail
+				res = append(res, &m)
+				continue
+			}
+		}
+		ll.WithField("problem", problem).Error("Invalid primary email, skipping the user")
+		s.report.Reject(u.PrimaryEmail, []string{problem})
+		s.report.Inc(&s.report.Errors)
+	}
+	return res, mapped
+}
+
+// validAlias returns the first alias of u which is a valid email
+func validAlias(u *admin.User) string {
+	for _, a := range append(append([]string{}, u.Aliases...), u.NonEditableAliases...) {
+		if emailProblem(a) == "" {
+			return a
+		}
+	}
+	return ""
+}
+
+// truncateNames truncates the display, given and family names of u to the
+// length the Identity Store allows
+func truncateNames(u *types.User) {
+	u.DisplayName = truncate(u.DisplayName)
+	if u.Name != nil {
+		u.Name.GivenName = truncate(u.Name.GivenName)
+		u.Name.FamilyName = truncate(u.Name.FamilyName)
+	}
+}
+
+// truncate returns s cut to maxAttribute characters
+func truncate(s *string) *string {
+	v := awsutils.ToString(s)
+	if utf8.RuneCountInString(v) <= maxAttribute {
+		return s
+	}
+	return awsutils.String(string([]rune(v)[:maxAttribute]))
+}
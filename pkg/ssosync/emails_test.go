@@ -0,0 +1,59 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"strings"
+	"testing"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/pkg/report"
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestEmailPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(emailProblem("jane@example.com"))
+	assert.NotEmpty(emailProblem("jäne@example.com"))
+	assert.NotEmpty(emailProblem(strings.Repeat("j", 65) + "@example.com"))
+	assert.Empty(emailProblem("jane@" + strings.Repeat("e", 63) + ".com"))
+	assert.Contains(emailProblem("jane@"+strings.Repeat("e", 64)+".com"), "domain label")
+	assert.NotEmpty(emailProblem("jane"))
+
+	users := []*admin.User{
+		{PrimaryEmail: "jane@example.com"},
+		{PrimaryEmail: "jürgen@example.com", Aliases: []string{"juergen@example.com"}},
+		{PrimaryEmail: "zoë@example.com"},
+	}
+	s := &engine{opts: Options{EmailPolicy: EmailAlias}, report: report.New()}
+	res, mapped := s.applyEmailPolicy(users)
+	assert.Len(res, 2)
+	assert.Equal("juergen@example.com", res[1].PrimaryEmail)
+	assert.Equal("jürgen@example.com", users[1].PrimaryEmail)
+	assert.Equal(map[string]string{"juergen@example.com": "jürgen@example.com"}, mapped)
+	assert.Equal(1, s.report.Errors)
+	assert.Contains(s.report.Summary().Rejected, "zoë@example.com")
+
+	s = &engine{opts: Options{EmailPolicy: EmailSkip}, report: report.New()}
+	res, _ = s.applyEmailPolicy(users)
+	assert.Len(res, 1)
+
+	u := &types.User{DisplayName: awsutils.String(strings.Repeat("é", 1100))}
+	truncateNames(u)
+	assert.Equal(1024, len([]rune(*u.DisplayName)))
+}
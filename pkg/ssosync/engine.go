@@ -0,0 +1,1004 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/internal/username"
+	"github.com/awslabs/ssosync/pkg/report"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// engine is an object type that will synchronize real users and groups
+type engine struct {
+	source Source
+	target Target
+	opts   Options
+	report *report.Report
+	namer  *username.Namer
+
+	// deletions are why the target users, by id, are deleted
+	deletions map[string]deletion
+	// memberOf are the target groups of the target users, by id, at the
+	// start of the run
+	memberOf map[string][]string
+	// pending are the changes deferred by this run
+	pending map[string]time.Time
+	// progress is the phase of the run, logged by the heartbeat
+	progress progress
+	// description renders the descriptions of the groups, nil when
+	// Options.GroupDescription is empty
+	description *template.Template
+	// targetGroups are the target groups by name, with the overflow
+	// groups created by the run
+	targetGroups map[string]*types.Group
+	// composition is the Google directory seen by the run
+	composition composition
+	// failures are the operations which failed transiently, retried by
+	// the final pass of the run
+	failures []failure
+	// applied are the changes applied to the target, checked by the
+	// verification
+	applied []Event
+	// ignoreResolved is set once the ignore list groups are resolved
+	ignoreResolved bool
+}
+
+// deletion is why a target user is deleted
+type deletion struct {
+	reason string
+	source *admin.User
+}
+
+// UserSyncResult is the state of the users after SyncUsers, which
+// SyncGroups uses to resolve the group members
+type UserSyncResult struct {
+	index         map[string]*types.User
+	toDelete      []*types.User
+	indexByUserId map[string]*types.User
+	// names maps the Google primary emails to the AWS user names
+	names map[string]string
+}
+
+// userName returns the AWS user name of the Google user with the
+// given email, which is the email itself unless remapped
+func (r *UserSyncResult) userName(email string) string {
+	if name, ok := r.names[email]; ok {
+		return name
+	}
+	return email
+}
+
+// ToDelete returns the AWS users of deleted or suspended Google users,
+// which RemoveUsers deletes
+func (r *UserSyncResult) ToDelete() []*types.User {
+	return r.toDelete
+}
+
+// New will create a new Engine syncing from source to target
+func New(source Source, target Target, opts Options) (Engine, error) {
+	namer, err := username.New(opts.UserNameTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	description, err := parseDescription(opts.GroupDescription)
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts.GroupKey {
+	case "", GroupKeyName, GroupKeyEmail:
+	default:
+		return nil, fmt.Errorf("group key %q is not one of name, email", opts.GroupKey)
+	}
+	if opts.Shards > 1 && (opts.Shard < 1 || opts.Shard > opts.Shards) {
+		return nil, fmt.Errorf("shard %d is not between 1 and %d", opts.Shard, opts.Shards)
+	}
+	if _, ok := unwrap(target).(GroupUpdater); !ok && (opts.GroupDescription != "" || opts.GroupKey == GroupKeyEmail) {
+		return nil, errNoGroupUpdate
+	}
+	if _, ok := unwrap(target).(UserTypeUpdater); !ok && opts.Provenance {
+		return nil, errNoUserTypeUpdate
+	}
+
+	switch opts.UserNameCollision {
+	case "", username.CollisionFail, username.CollisionSkip, username.CollisionSuffix:
+	default:
+		return nil, fmt.Errorf("user name collision policy %q is not one of fail, skip, suffix", opts.UserNameCollision)
+	}
+
+	return &engine{
+		source: source,
+		target: target,
+		opts:   opts,
+		report: report.New(),
+		namer:  namer,
+
+		description: description,
+
+		deletions: make(map[string]deletion),
+		memberOf:  make(map[string][]string),
+		pending:   make(map[string]time.Time),
+
+		targetGroups: make(map[string]*types.Group),
+	}, nil
+}
+
+// Run syncs the users and groups matching the queries of the options
+// and removes the users deleted or suspended in the source
+func (s *engine) Run() error {
+	if s.opts.Heartbeat > 0 {
+		defer s.heartbeat(s.opts.Heartbeat)()
+	}
+	defer s.abandonFailures()
+
+	end := s.span("sync users")
+	syncResult, err := s.SyncUsers(s.opts.UserMatch)
+	end(&err)
+	if err != nil {
+		return err
+	}
+
+	end = s.span("sync groups")
+	err = s.SyncGroups(s.opts.GroupMatch, syncResult)
+	end(&err)
+	if err != nil {
+		return err
+	}
+
+	end = s.span("delete users")
+	err = s.RemoveUsers(syncResult.ToDelete())
+	end(&err)
+	if err != nil {
+		return err
+	}
+
+	if s.expired() {
+		return nil
+	}
+	s.retryFailures()
+	if s.opts.Verify {
+		s.verify()
+	}
+	return nil
+}
+
+// Report returns the statistics of the sync
+func (s *engine) Report() *Report {
+	return s.report
+}
+
+// SyncUsers will Sync Google Users to AWS SSO, the users
+// matching any of the queries are synced
+// References:
+// * https://developers.google.com/admin-sdk/directory/v1/guides/search-users
+// query possible values:
+// '' --> empty or not defined
+//  name:'Jane'
+//  email:admin*
+//  isAdmin=true
+//  manager='janesmith@example.com'
+//  orgName=Engineering orgTitle:Manager
+//  EmploymentData.projects:'GeneGnomes'
+func (s *engine) SyncUsers(queries []string) (*UserSyncResult, error) {
+	usersSyncResult := &UserSyncResult{
+		index:         make(map[string]*types.User),
+		toDelete:      []*types.User{},
+		indexByUserId: make(map[string]*types.User),
+		names:         make(map[string]string),
+	}
+	if err := s.resolveIgnoreLists(); err != nil {
+		return usersSyncResult, err
+	}
+
+	// the inventories are independent, they are fetched concurrently
+	// and diffed once all of them are complete
+	var (
+		awsUsers        []types.User
+		gcpDeletedUsers []*admin.User
+		googleUsers     []*admin.User
+		fetchedUsers    []*admin.User
+		g               errgroup.Group
+	)
+	s.progress.begin("list users", 0)
+	g.Go(func() (err error) {
+		defer s.span("aws users")(&err)
+		log.Debug("get all users from amazon")
+		if awsUsers, err = s.target.GetUsers(); err != nil {
+			log.Error("Error Getting AWS Users: ", err)
+		}
+		return err
+	})
+	if !s.opts.SkipDeletedUsers {
+		g.Go(func() (err error) {
+			defer s.span("google deleted users")(&err)
+			log.Debug("get deleted users")
+			if gcpDeletedUsers, err = s.source.GetDeletedUsers(); err != nil {
+				log.Error("Error Getting Deleted Users from Google: ", err)
+			}
+			return err
+		})
+	}
+	g.Go(func() (err error) {
+		defer s.span("google users")(&err)
+		log.Debug("get active google users")
+		if fetchedUsers, err = s.source.GetUsers(queries...); err != nil {
+			return err
+		}
+		googleUsers, err = s.excludeUsers(fetchedUsers)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return usersSyncResult, err
+	}
+	sortUsers(awsUsers)
+	gcpDeletedUsers = sortGoogleUsers(gcpDeletedUsers)
+	googleUsers = sortGoogleUsers(googleUsers)
+	s.countUsers(googleUsers)
+
+	for _, u := range awsUsers {
+		userToAdd := u
+		usersSyncResult.index[awsutils.ToString(u.UserName)] = &userToAdd
+		usersSyncResult.indexByUserId[awsutils.ToString(u.UserId)] = &userToAdd
+	}
+
+	activeUsers := make([]*admin.User, 0, len(googleUsers))
+	for _, u := range googleUsers {
+		if !s.ignoreUser(u) {
+			activeUsers = append(activeUsers, u)
+		}
+	}
+
+	activeUsers, mapped := s.applyEmailPolicy(activeUsers)
+
+	// user names are assigned upfront, so that two users mapped to the same
+	// name are detected before either is created
+	names, err := s.assignUserNames(activeUsers)
+	if err != nil {
+		return usersSyncResult, err
+	}
+	// the group members of users synced with an alias are listed with
+	// their primary email
+	for alias, primary := range mapped {
+		if name, ok := names[alias]; ok {
+			names[primary] = name
+		}
+	}
+	usersSyncResult.names = names
+
+	// the target user of a deleted user is found by its Google id, or by
+	// its name when it has none and no active user is mapped to the name
+	bySource := make(map[string]*types.User)
+	for i := range awsUsers {
+		if id := googleUserId(awsUsers[i]); id != "" {
+			bySource[id] = &awsUsers[i]
+		}
+	}
+	activeNames := make(map[string]bool, len(names))
+	for _, name := range names {
+		activeNames[name] = true
+	}
+	for _, u := range gcpDeletedUsers {
+		if !s.inShard(u.PrimaryEmail) {
+			continue
+		}
+		ll := log.WithFields(log.Fields{"email": u.PrimaryEmail})
+		ll.Debug("Adding users to deleting from gcpDeletedUsers")
+		userInAWS, isExists := bySource[u.Id]
+		if !isExists {
+			name, err := s.namer.Name(u)
+			if err != nil {
+				ll.Error("Can't map user name: ", err)
+				continue
+			}
+			userInAWS, isExists = usersSyncResult.index[name]
+			if isExists && (googleUserId(*userInAWS) != "" || activeNames[name]) {
+				ll.WithField("userName", name).Debug("User name belongs to another Google user, not deleting it")
+				continue
+			}
+		}
+
+		if isExists == false {
+			ll.Debug("User already deleted")
+			continue
+		}
+
+		name := awsutils.ToString(userInAWS.UserName)
+		s.notice("user:"+name, DeleteReasonDeleted, log.WarnLevel, ll, "User added to delete")
+		usersSyncResult.toDelete = append(usersSyncResult.toDelete, userInAWS)
+		s.deletions[awsutils.ToString(userInAWS.UserId)] = deletion{reason: DeleteReasonDeleted, source: u}
+	}
+
+	if s.opts.DeleteAbsentUsers {
+		// the excluded users are left alone, they are not absent
+		for _, u := range s.absentUsers(awsUsers, fetchedUsers, usersSyncResult.toDelete) {
+			if !s.userInShard(u) {
+				continue
+			}
+			usersSyncResult.toDelete = append(usersSyncResult.toDelete, u)
+			s.deletions[awsutils.ToString(u.UserId)] = deletion{reason: DeleteReasonAbsent}
+		}
+	}
+
+	s.progress.begin("users", len(activeUsers))
+	for _, u := range activeUsers {
+		s.progress.step()
+		ll := log.WithFields(log.Fields{"email": u.PrimaryEmail})
+		name, ok := names[u.PrimaryEmail]
+		if !ok || !s.inShard(u.PrimaryEmail) {
+			continue
+		}
+		ll = ll.WithField("userName", name)
+
+		ll.Debug("finding user")
+		userInAWS, isExists := usersSyncResult.index[name]
+		if isExists == true {
+			if u.Suspended == true {
+				s.notice("user:"+name, DeleteReasonSuspended, log.WarnLevel, ll, "User added to delete as suspended in Google")
+				usersSyncResult.toDelete = append(usersSyncResult.toDelete, userInAWS)
+				s.deletions[awsutils.ToString(userInAWS.UserId)] = deletion{reason: DeleteReasonSuspended, source: u}
+			} else if s.adoptUser(userInAWS) {
+				s.updateProvenance(ll, userInAWS, u)
+			} else {
+				ll.Debug("Did nothing, user already added")
+				s.report.Inc(&s.report.UsersUnchanged)
+			}
+		} else {
+			if u.Suspended == true {
+				ll.Debug("Did nothing, as User suspended in Google")
+			} else {
+				userToAdd := s.targetUser(u, name)
+				if p := s.provenance(sourceId(u.Id)); p != "" {
+					userToAdd.UserType = awsutils.String(p)
+				}
+				if problems := validateUser(userToAdd); len(problems) > 0 {
+					ll.WithField("problems", problems).Error("User violates the Identity Store constraints, not creating it")
+					s.report.Reject(name, problems)
+					s.report.Inc(&s.report.Errors)
+					continue
+				}
+				ll.Debug("Create user")
+				if !s.before(userEvent(EventUserCreate, userToAdd)) {
+					continue
+				}
+				added, err := s.target.CreateUser(userToAdd)
+				if err == nil {
+					s.report.Inc(&s.report.UsersCreated)
+				} else if report.Cause(err) == report.CauseConflict {
+					// only a conflict means that the user already exists
+					if existing, findErr := s.target.FindUserByUserName(name); findErr == nil {
+						ll.Warn("User already exists in AWS, using existing user")
+						added, err = existing, nil
+					}
+				}
+				if err != nil {
+					ll.Error("Can't create user: ", err)
+					s.report.Fail(err)
+					s.retryLater("create user "+name, userEvent(EventUserCreate, userToAdd), err, func() error {
+						if _, err := s.target.CreateUser(userToAdd); err != nil {
+							return err
+						}
+						s.report.Inc(&s.report.UsersCreated)
+						return nil
+					})
+				} else {
+					s.after(userEvent(EventUserCreate, userToAdd), nil)
+					usersSyncResult.index[name] = added
+					usersSyncResult.indexByUserId[awsutils.ToString(added.UserId)] = added
+				}
+			}
+		}
+	}
+	return usersSyncResult, nil
+}
+
+// targetUser returns the target user created from the source user u
+// with the user name
+func (s *engine) targetUser(u *admin.User, name string) *types.User {
+	user := &types.User{
+		UserName:    awsutils.String(name),
+		DisplayName: awsutils.String(strings.Join([]string{u.Name.GivenName, u.Name.FamilyName}, " ")),
+		Name: &types.Name{
+			FamilyName: awsutils.String(u.Name.FamilyName),
+			GivenName:  awsutils.String(u.Name.GivenName),
+		},
+		Emails: []types.Email{
+			{
+				Primary: true,
+				Type:    awsutils.String("work"),
+				Value:   awsutils.String(u.PrimaryEmail),
+			},
+		},
+		ExternalIds: []types.ExternalId{
+			{
+				Id:     awsutils.String(u.Id),
+				Issuer: awsutils.String("Google"),
+			},
+		},
+	}
+	if s.opts.EmailPolicy == EmailTruncate {
+		truncateNames(user)
+	}
+	return user
+}
+
+// SyncGroups will sync groups from Google -> AWS SSO, the groups
+// matching any of the queries are synced
+// References:
+// * https://developers.google.com/admin-sdk/directory/v1/guides/search-groups
+// query possible values:
+// '' --> empty or not defined
+//  name='contact'
+//  email:admin*
+//  memberKey=user@company.com
+//  name:contact* email:contact*
+//  name:Admin* email:aws-*
+//  email:aws-*
+func (s *engine) SyncGroups(queries []string, usersSyncResult *UserSyncResult) error {
+	if err := s.resolveIgnoreLists(); err != nil {
+		return err
+	}
+
+	var (
+		awsGroups    []types.Group
+		googleGroups []*admin.Group
+		g            errgroup.Group
+	)
+	s.progress.begin("list groups", 0)
+	g.Go(func() (err error) {
+		defer s.span("aws groups")(&err)
+		log.Debug("get all groups from amazon")
+		if awsGroups, err = s.target.GetGroups(); err != nil {
+			log.Warn("Error Getting AWS Groups")
+		}
+		return err
+	})
+	g.Go(func() (err error) {
+		defer s.span("google groups")(&err)
+		log.WithField("queries", queries).Debug("get google groups")
+		if googleGroups, err = s.source.GetGroups(queries...); err != nil {
+			return err
+		}
+		googleGroups, err = s.excludeGroups(googleGroups)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	sortGroups(awsGroups)
+	googleGroups = sortGoogleGroups(googleGroups)
+	s.countGroups(googleGroups)
+
+	if s.opts.GroupKey == GroupKeyEmail {
+		if err := s.renameGroups(googleGroups, awsGroups); err != nil {
+			return err
+		}
+	}
+
+	groupsIndex := make(map[string]*types.Group)
+	var groupsToDelete []*types.Group
+	for _, u := range awsGroups {
+		grp := u
+		groupsIndex[awsutils.ToString(u.DisplayName)] = &grp
+		s.targetGroups[awsutils.ToString(u.DisplayName)] = &grp
+	}
+
+	googleGroupsIndex := make(map[string]*admin.Group)
+	skipped := make(map[string]bool)
+
+	s.progress.begin("groups", len(googleGroups))
+	for _, g := range googleGroups {
+		s.progress.step()
+		if s.ignoreGroup(g) {
+			continue
+		}
+		policy := s.groupPolicyOf(g)
+		if policy.Rejected {
+			continue
+		}
+
+		ll := log.WithFields(log.Fields{"group": policy.Name})
+		if !s.inShard(g.Email) {
+			// synced by the run of its shard, neither synced nor deleted here
+			skipped[policy.Name] = true
+			continue
+		}
+		if policy.Skip {
+			s.notice("group:"+policy.Name, "skip", log.InfoLevel, ll, "Group is tagged skip, leaving it alone")
+			skipped[policy.Name] = true
+			continue
+		}
+		if other, ok := googleGroupsIndex[policy.Name]; ok && (s.opts.GroupDescriptionTags || policy.Name != g.Name || other.Name != g.Name) {
+			ll.WithField("email", g.Email).WithField("other", other.Email).Error("Several Google groups map to the group name, skipping it")
+			s.report.Inc(&s.report.Errors)
+			continue
+		}
+		googleGroupsIndex[policy.Name] = g
+		s.auditSettings(ll, g, policy.Name)
+		ll.Debug("Check group")
+		description, err := s.groupDescription(g, policy)
+		if err != nil {
+			ll.Error(err)
+			s.report.Fail(err)
+			continue
+		}
+
+		existing, isExists := groupsIndex[policy.Name]
+		if isExists == true && s.description == nil && s.managedGroup(existing) && s.staleEmail(existing, g) {
+			// the descriptions are not managed, only the provenance is
+			s.updateDescription(ll, existing, withProvenance(awsutils.String(stripProvenance(existing.Description)), s.groupProvenance(g)))
+		} else if isExists == true && s.description != nil && s.managedGroup(existing) && (s.staleDescription(existing, description) || s.staleEmail(existing, g)) {
+			s.updateDescription(ll, existing, withProvenance(description, s.groupProvenance(g)))
+		} else if isExists == true {
+			ll.Debug("Did nothing, group already exists")
+			s.report.Inc(&s.report.GroupsUnchanged)
+		} else if policy.MembershipOnly {
+			s.notice("group:"+policy.Name, "membership-only", log.InfoLevel, ll, "Group is tagged membership-only and does not exist in AWS, not creating it")
+		} else {
+			ll.Debug("Creating group")
+			event := Event{Type: EventGroupCreate, GroupName: policy.Name}
+			if !s.before(event) {
+				continue
+			}
+			groupDesc := withProvenance(description, s.groupProvenance(g))
+			gg, err := s.target.CreateGroup(awsutils.String(policy.Name), groupDesc)
+			if err == nil {
+				s.report.Inc(&s.report.GroupsCreated)
+			} else if report.Cause(err) == report.CauseConflict {
+				// only a conflict means that the group already exists
+				if existing, findErr := s.target.FindGroupByDisplayName(policy.Name); findErr == nil {
+					ll.Warn("Group already exists in AWS, using existing group")
+					gg, err = existing, nil
+				}
+			}
+			if err != nil {
+				ll.Error("Can't create Group in AWS: ", err)
+				s.report.Fail(err)
+				s.retryLater("create group "+policy.Name, event, err, func() error {
+					if _, err := s.target.CreateGroup(awsutils.String(policy.Name), groupDesc); err != nil {
+						return err
+					}
+					s.report.Inc(&s.report.GroupsCreated)
+					return nil
+				})
+			} else {
+				s.after(event, nil)
+				groupsIndex[awsutils.ToString(gg.DisplayName)] = gg
+			}
+		}
+	}
+
+	for _, g := range awsGroups {
+		_, isExists := googleGroupsIndex[awsutils.ToString(g.DisplayName)]
+		if isExists == false {
+			grp := g
+			delete(groupsIndex, awsutils.ToString(g.DisplayName))
+			if skipped[awsutils.ToString(g.DisplayName)] || s.isOverflow(awsutils.ToString(g.DisplayName), googleGroupsIndex) {
+				continue
+			}
+			if !s.inShard(awsutils.ToString(g.DisplayName)) {
+				// a group absent from Google has no email, it is
+				// assigned to a shard by name
+				continue
+			}
+			if s.protectedGroup(awsutils.ToString(g.DisplayName)) {
+				s.notice("group:"+awsutils.ToString(g.DisplayName), "protected", log.WarnLevel, log.WithField("group", grp.DisplayName), "Group is protected, not deleting it although it is not in Google")
+				continue
+			}
+			if !s.managedGroup(&grp) {
+				s.notice("group:"+awsutils.ToString(g.DisplayName), "not-managed", log.WarnLevel, log.WithField("group", grp.DisplayName), "Group was not created by ssosync, not deleting it although it is not in Google")
+				continue
+			}
+			log.WithField("group", grp.DisplayName).Info("Group added to delete")
+			groupsToDelete = append(groupsToDelete, &grp)
+		}
+	}
+
+	s.progress.begin("memberships", len(groupsIndex))
+	for _, name := range sortedGroupNames(groupsIndex) {
+		g := groupsIndex[name]
+		s.progress.step()
+		if s.expired() {
+			// the members of the remaining groups are not even read
+			s.stop("group:" + name)
+			continue
+		}
+		val, _ := googleGroupsIndex[awsutils.ToString(g.DisplayName)]
+		err := s.SyncMembershipsForGroup(val, g, usersSyncResult)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.progress.begin("delete groups", len(groupsToDelete))
+	for _, g := range groupsToDelete {
+		s.progress.step()
+		log.WithField("group", g.DisplayName).Debug("Delete group in AWS")
+		event := Event{Type: EventGroupDelete, GroupName: awsutils.ToString(g.DisplayName)}
+		if s.deferred(event) || !s.before(event) {
+			continue
+		}
+		err := s.target.DeleteGroup(g)
+		s.after(event, err)
+		if err != nil {
+			return err
+		}
+		s.report.Inc(&s.report.GroupsDeleted)
+	}
+
+	return nil
+}
+
+func (s *engine) SyncMembershipsForGroup(googleGroup *admin.Group, awsGroup *types.Group,
+	usersSyncResult *UserSyncResult) error {
+	ll := log.WithField("group", googleGroup.Name)
+
+	ll.Debug("Fetching google groups")
+	groupMembers, err := s.source.GetGroupMembers(googleGroup)
+	if err != nil {
+		ll.Info("Can't fetch google groups")
+		return err
+	}
+	s.countMembers(groupMembers)
+	memberList := make(map[string]*types.User)
+	for _, m := range groupMembers {
+		name := usersSyncResult.userName(m.Email)
+		if val, ok := usersSyncResult.index[name]; ok {
+			memberList[name] = val
+		}
+	}
+	if s.opts.MemberLimit > 0 {
+		return s.syncLimited(ll, awsGroup, memberList, usersSyncResult)
+	}
+	return s.syncGroupMembers(ll, awsGroup, memberList, usersSyncResult)
+}
+
+// syncGroupMembers makes memberList, by user name, the members of the
+// AWS group
+func (s *engine) syncGroupMembers(ll *log.Entry, awsGroup *types.Group, memberList map[string]*types.User,
+	usersSyncResult *UserSyncResult) error {
+	ll.Debug("Fetching aws groups")
+	awsMembers, err := s.target.GetGroupMembers(awsGroup)
+	if err != nil {
+		ll.Info("Can't fetch AWS groups")
+		return err
+	}
+	sortMemberships(awsMembers)
+
+	var toDelete []*types.GroupMembership
+	for _, m := range awsMembers {
+		awsMember := m
+		llM := ll.WithField("MembershipId", m.MembershipId).WithField("MemberId", m.MemberId)
+		userId, ok := m.MemberId.(*types.MemberIdMemberUserId)
+		if ok != true {
+			// not a user, the membership is left alone
+			llM.Error("Cast mismatch error")
+			continue
+		}
+		s.memberOf[userId.Value] = append(s.memberOf[userId.Value], awsutils.ToString(awsGroup.DisplayName))
+		user, exists := usersSyncResult.indexByUserId[userId.Value]
+		if exists == false {
+			llM.Info("Added for delete")
+			toDelete = append(toDelete, &awsMember)
+		} else {
+			_, has := memberList[awsutils.ToString(user.UserName)]
+			if has == false {
+				llM.Info("Added for delete")
+				toDelete = append(toDelete, &awsMember)
+			} else {
+				s.report.Inc(&s.report.MembershipsUnchanged)
+			}
+			delete(memberList, awsutils.ToString(user.UserName))
+		}
+	}
+
+	if len(toDelete) > 0 && s.unmanagedMembership(awsutils.ToString(awsGroup.DisplayName)) {
+		s.notice("group:"+awsutils.ToString(awsGroup.DisplayName), fmt.Sprintf("unmanaged:%d", len(toDelete)), log.InfoLevel,
+			ll.WithField("count", len(toDelete)), "Membership of the group is unmanaged, keeping members not in Google")
+		toDelete = nil
+	}
+	if len(toDelete) > 0 && !s.managedGroup(awsGroup) {
+		s.notice("group:"+awsutils.ToString(awsGroup.DisplayName), fmt.Sprintf("not-managed:%d", len(toDelete)), log.WarnLevel,
+			ll.WithField("count", len(toDelete)), "Group was not created by ssosync, keeping members not in Google")
+		toDelete = nil
+	}
+	if len(toDelete) > 0 && s.protectedGroup(awsutils.ToString(awsGroup.DisplayName)) {
+		s.notice("group:"+awsutils.ToString(awsGroup.DisplayName), fmt.Sprintf("protected:%d", len(toDelete)), log.WarnLevel,
+			ll.WithField("count", len(toDelete)), "Group is protected, keeping members not in Google")
+		toDelete = nil
+	}
+
+	for _, val := range toDelete {
+		event := Event{Type: EventMemberRemove, GroupName: awsutils.ToString(awsGroup.DisplayName)}
+		if userId, ok := val.MemberId.(*types.MemberIdMemberUserId); ok {
+			if user, ok := usersSyncResult.indexByUserId[userId.Value]; ok {
+				event.UserName = awsutils.ToString(user.UserName)
+			}
+		}
+		if s.protectedUser(event.UserName) {
+			s.notice(event.key(), "protected", log.WarnLevel, ll.WithField("userName", event.UserName), "User is protected, not removing it from the group")
+			continue
+		}
+		if s.deferred(event) || !s.before(event) {
+			continue
+		}
+		err := s.target.RemoveGroupMembership(val)
+		s.after(event, err)
+		if err != nil {
+			ll.Error("Can't remove User from the group: ", err)
+			return err
+		}
+		s.report.Inc(&s.report.MembershipsRemoved)
+	}
+
+	for _, name := range sortedUserNames(memberList) {
+		element := memberList[name]
+		ll.WithField("", element.UserName).Debug("User add")
+		event := Event{Type: EventMemberAdd, UserName: awsutils.ToString(element.UserName), GroupName: awsutils.ToString(awsGroup.DisplayName)}
+		if !s.before(event) {
+			continue
+		}
+		_, err := s.target.AddUserToGroup(element, awsGroup)
+		s.after(event, err)
+		if err != nil {
+			ll.Error("Can't add User to the group: ", err)
+			return err
+		}
+		s.report.Inc(&s.report.MembershipsAdded)
+	}
+	return nil
+}
+
+// RemoveUsers deletes the users, a user which cannot be deleted is
+// reported and the removal continues with the next
+func (s *engine) RemoveUsers(usersList []*types.User) error {
+	s.progress.begin("delete users", len(usersList))
+	removal := s.newRemoval(len(usersList))
+	for _, u := range usersList {
+		s.progress.step()
+		removal.step()
+		event := userEvent(EventUserDelete, u)
+		if d, ok := s.deletions[awsutils.ToString(u.UserId)]; ok {
+			event.Reason, event.Source = d.reason, d.source
+		}
+		event.Groups = s.memberOf[awsutils.ToString(u.UserId)]
+		if s.protectedUser(event.UserName) {
+			s.notice("user:"+event.UserName, "protected", log.WarnLevel, log.WithField("userName", event.UserName), "User is protected, not deleting it")
+			continue
+		}
+		if !s.managedUser(u) {
+			s.notice("user:"+event.UserName, "not-managed", log.WarnLevel, log.WithField("userName", event.UserName), "User was not created by ssosync, not deleting it")
+			continue
+		}
+		if s.deferred(event) || !s.before(event) {
+			continue
+		}
+		err := s.deleteUser(u)
+		if err != nil {
+			log.WithField("userName", event.UserName).Error("Can't delete user: ", err)
+			s.report.FailRemoval(event.UserName, err)
+			removal.failed++
+			user := u
+			s.retryRemoval(event, err, func() error {
+				if err := s.deleteUser(user); err != nil {
+					return err
+				}
+				s.report.Inc(&s.report.UsersDeleted)
+				return nil
+			})
+			continue
+		}
+		s.after(event, nil)
+		s.report.Inc(&s.report.UsersDeleted)
+		removal.deleted++
+	}
+	removal.done()
+	return nil
+}
+
+// absentUsers returns the target users synced from Google, i.e. with a
+// Google user id in their provenance or external ids, whose source user
+// is not among the given users and not already scheduled for deletion
+func (s *engine) absentUsers(awsUsers []types.User, googleUsers []*admin.User, scheduled []*types.User) []*types.User {
+	if len(googleUsers) == 0 {
+		// most likely a wrong query rather than an empty directory
+		log.Warn("No Google users found, not deleting the absent users")
+		return nil
+	}
+
+	present := make(map[string]bool, len(googleUsers))
+	for _, u := range googleUsers {
+		present[u.Id] = true
+	}
+	for _, u := range scheduled {
+		present[googleUserId(*u)] = true
+	}
+
+	var res []*types.User
+	for i, u := range awsUsers {
+		id := googleUserId(u)
+		if id == "" || present[id] {
+			continue
+		}
+		s.notice("user:"+awsutils.ToString(u.UserName), DeleteReasonAbsent, log.WarnLevel, log.WithField("userName", awsutils.ToString(u.UserName)), "User added to delete as absent in Google")
+		res = append(res, &awsUsers[i])
+	}
+	return res
+}
+
+// assignUserNames maps the users to AWS user names, applying the
+// configured policy to users mapped to the same name
+func (s *engine) assignUserNames(users []*admin.User) (map[string]string, error) {
+	names, collisions, errs := s.namer.Assign(users, s.opts.UserNameCollision)
+	for _, err := range errs {
+		log.Error("Can't map user name: ", err)
+		s.report.Fail(err)
+	}
+
+	for _, c := range collisions {
+		ll := log.WithField("userName", c.Name).WithField("users", c.Emails)
+		switch s.opts.UserNameCollision {
+		case username.CollisionSuffix:
+			ll.Warn("User name collision, suffixing the names of the newer users")
+		case username.CollisionSkip:
+			ll.Warn("User name collision, skipping the newer users")
+		default:
+			ll.Error("User name collision")
+		}
+	}
+	if len(collisions) > 0 && s.opts.UserNameCollision != username.CollisionSuffix && s.opts.UserNameCollision != username.CollisionSkip {
+		return nil, fmt.Errorf("%d user name collisions, first: %w", len(collisions), collisions[0])
+	}
+
+	return names, nil
+}
+
+// excludeUsers removes the users matching any of the exclude queries,
+// the Google query language has no negation so this is done post-fetch
+func (s *engine) excludeUsers(users []*admin.User) ([]*admin.User, error) {
+	if len(s.opts.UserExcludeMatch) == 0 {
+		return users, nil
+	}
+
+	log.WithField("queries", s.opts.UserExcludeMatch).Debug("get excluded google users")
+	excluded, err := s.source.GetUsers(s.opts.UserExcludeMatch...)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]bool, len(excluded))
+	for _, u := range excluded {
+		index[u.Id] = true
+	}
+
+	res := make([]*admin.User, 0, len(users))
+	for _, u := range users {
+		if index[u.Id] {
+			log.WithField("email", u.PrimaryEmail).Debug("User excluded")
+			continue
+		}
+		res = append(res, u)
+	}
+	return res, nil
+}
+
+// excludeGroups removes the groups matching any of the exclude queries,
+// the groups listed twice, e.g. by queries matching the primary email
+// and an alias, and the system managed groups
+func (s *engine) excludeGroups(groups []*admin.Group) ([]*admin.Group, error) {
+	groups = s.excludeSystemGroups(uniqueGroups(groups))
+	if len(s.opts.GroupExcludeMatch) == 0 {
+		return groups, nil
+	}
+
+	log.WithField("queries", s.opts.GroupExcludeMatch).Debug("get excluded google groups")
+	excluded, err := s.source.GetGroups(s.opts.GroupExcludeMatch...)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]bool, len(excluded))
+	for _, g := range excluded {
+		index[g.Id] = true
+	}
+
+	res := make([]*admin.Group, 0, len(groups))
+	for _, g := range groups {
+		if index[g.Id] {
+			log.WithField("group", g.Name).Debug("Group excluded")
+			continue
+		}
+		res = append(res, g)
+	}
+	return res, nil
+}
+
+// unmanagedMembership reports whether members of the AWS group are only
+// added, never removed, e.g. for break-glass groups maintained by hand
+func (s *engine) unmanagedMembership(name string) bool {
+	return matchAny(s.opts.UnmanagedMembershipGroups, name)
+}
+
+// protectedUser reports whether the AWS user must never be deleted or
+// removed from a group, e.g. a break-glass admin account
+func (s *engine) protectedUser(name string) bool {
+	return name != "" && matchAny(s.opts.ProtectedUsers, name)
+}
+
+// protectedGroup reports whether the AWS group must never be deleted nor
+// have members removed, e.g. an emergency access group
+func (s *engine) protectedGroup(name string) bool {
+	return matchAny(s.opts.ProtectedGroups, name)
+}
+
+// matchAny reports whether name matches any of the shell patterns
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, err := path.Match(p, name); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ignoreUser reports whether the primary email or an alias of the user
+// is ignored
+func (s *engine) ignoreUser(u *admin.User) bool {
+	emails := append(append([]string{u.PrimaryEmail}, u.Aliases...), u.NonEditableAliases...)
+	return matchEmail(s.opts.IgnoreUsers, emails)
+}
+
+// ignoreGroup reports whether the email or an alias of the group is
+// ignored
+func (s *engine) ignoreGroup(g *admin.Group) bool {
+	emails := append(append([]string{g.Email}, g.Aliases...), g.NonEditableAliases...)
+	return matchEmail(s.opts.IgnoreGroups, emails)
+}
+
+// matchEmail reports whether any of the emails is in the list, emails
+// are case insensitive
+func matchEmail(list, emails []string) bool {
+	for _, l := range list {
+		for _, e := range emails {
+			if strings.EqualFold(l, e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// uniqueGroups removes the groups already listed, by id
+func uniqueGroups(groups []*admin.Group) []*admin.Group {
+	seen := make(map[string]bool, len(groups))
+	res := make([]*admin.Group, 0, len(groups))
+	for _, g := range groups {
+		if seen[g.Id] {
+			continue
+		}
+		seen[g.Id] = true
+		res = append(res, g)
+	}
+	return res
+}
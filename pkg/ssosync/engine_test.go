@@ -0,0 +1,135 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestIgnoreAliases(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &engine{opts: Options{
+		IgnoreUsers:  []string{"Old.Name@example.com"},
+		IgnoreGroups: []string{"team@example.com"},
+	}}
+	assert.True(s.ignoreUser(&admin.User{PrimaryEmail: "new.name@example.com", Aliases: []string{"old.name@example.com"}}))
+	assert.False(s.ignoreUser(&admin.User{PrimaryEmail: "other@example.com"}))
+	assert.True(s.ignoreGroup(&admin.Group{Email: "platform@example.com", NonEditableAliases: []string{"team@example.com"}}))
+
+	groups := uniqueGroups([]*admin.Group{{Id: "1"}, {Id: "2"}, {Id: "1"}})
+	assert.Len(groups, 2)
+}
+
+func TestDeletedUserSharingName(t *testing.T) {
+	assert := assert.New(t)
+
+	active := &admin.User{Id: "2", PrimaryEmail: "jdoe@b.example.com", Name: &admin.UserName{GivenName: "John", FamilyName: "Doe"}}
+	deleted := &admin.User{Id: "1", PrimaryEmail: "jdoe@a.example.com", Name: &admin.UserName{GivenName: "Jane", FamilyName: "Doe"}}
+
+	// with or without a recorded Google id, the user of the active user
+	// is not deleted for the deleted user mapped to the same name
+	for _, provenance := range []bool{true, false} {
+		target := NewMemoryTarget()
+		opts := Options{UserNameTemplate: "{{.LocalPart}}", Provenance: provenance}
+		s, err := New(&memorySource{users: []*admin.User{active}}, target, opts)
+		assert.NoError(err)
+		assert.NoError(s.Run())
+
+		s, err = New(&memorySource{users: []*admin.User{active}, deleted: []*admin.User{deleted}}, target, opts)
+		assert.NoError(err)
+		assert.NoError(s.Run())
+		assert.Equal(0, s.Report().UsersDeleted)
+		users, _ := target.GetUsers()
+		assert.Len(users, 1)
+	}
+
+	// the user synced from the deleted user is found by its Google id
+	target := NewMemoryTarget()
+	opts := Options{UserNameTemplate: "{{.LocalPart}}", Provenance: true}
+	s, err := New(&memorySource{users: []*admin.User{deleted}}, target, opts)
+	assert.NoError(err)
+	assert.NoError(s.Run())
+
+	opts.UserNameTemplate = "{{.Email}}"
+	s, err = New(&memorySource{users: []*admin.User{active}, deleted: []*admin.User{deleted}}, target, opts)
+	assert.NoError(err)
+	assert.NoError(s.Run())
+	assert.Equal(1, s.Report().UsersDeleted)
+	users, _ := target.GetUsers()
+	assert.Len(users, 1)
+	assert.Equal("jdoe@b.example.com", awsutils.ToString(users[0].UserName))
+}
+
+// excludingSource returns the excluded users for the exclude query
+type excludingSource struct {
+	*memorySource
+	excluded []*admin.User
+}
+
+func (s *excludingSource) GetUsers(q ...string) ([]*admin.User, error) {
+	if len(q) == 1 && q[0] == "orgUnitPath=/Contractors" {
+		return s.excluded, nil
+	}
+	return s.users, nil
+}
+
+// noExternalIdTarget drops the external ids of the users it creates,
+// like the Identity Store API
+type noExternalIdTarget struct {
+	*MemoryTarget
+}
+
+func (t noExternalIdTarget) CreateUser(u *types.User) (*types.User, error) {
+	created := *u
+	created.ExternalIds = nil
+	return t.MemoryTarget.CreateUser(&created)
+}
+
+func TestDeleteAbsentUsers(t *testing.T) {
+	assert := assert.New(t)
+
+	ana := &admin.User{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}}
+	bo := &admin.User{Id: "2", PrimaryEmail: "bo@example.com", Name: &admin.UserName{GivenName: "Bo", FamilyName: "Li"}}
+	cy := &admin.User{Id: "3", PrimaryEmail: "cy@example.com", Name: &admin.UserName{GivenName: "Cy", FamilyName: "Ng"}}
+
+	// the users are only known as synced from Google by their provenance
+	target := noExternalIdTarget{NewMemoryTarget()}
+	opts := Options{Provenance: true, DeleteAbsentUsers: true}
+	s, err := New(&memorySource{users: []*admin.User{ana, bo, cy}}, target, opts)
+	assert.NoError(err)
+	assert.NoError(s.Run())
+	assert.Equal(3, s.Report().UsersCreated)
+
+	// bo leaves the query and is deleted, cy is excluded and kept
+	opts.UserExcludeMatch = []string{"orgUnitPath=/Contractors"}
+	source := &excludingSource{memorySource: &memorySource{users: []*admin.User{ana, cy}}, excluded: []*admin.User{cy}}
+	s, err = New(source, target, opts)
+	assert.NoError(err)
+	assert.NoError(s.Run())
+	assert.Equal(1, s.Report().UsersDeleted)
+
+	users, _ := target.GetUsers()
+	var names []string
+	for _, u := range users {
+		names = append(names, awsutils.ToString(u.UserName))
+	}
+	assert.ElementsMatch([]string{"ana@example.com", "cy@example.com"}, names)
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	log "github.com/sirupsen/logrus"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+const (
+	// GroupKeyName matches the target groups to the source groups by
+	// display name
+	GroupKeyName = "name"
+	// GroupKeyEmail matches the target groups to the source groups by the
+	// email recorded in their provenance, so that a group renamed in the
+	// source is renamed in the target rather than recreated
+	GroupKeyEmail = "email"
+)
+
+// GroupRenamer is implemented by the targets which rename an existing
+// group, required by GroupKeyEmail
+type GroupRenamer interface {
+	RenameGroup(g *types.Group, name *string) error
+}
+
+var errNoGroupRename = errors.New("the target does not rename groups, required by the group key email")
+
+// groupEmail returns the email of the source group recorded in the
+// provenance, empty unless the groups are keyed by email
+func (s *engine) groupEmail(g *admin.Group) string {
+	if s.opts.GroupKey != GroupKeyEmail {
+		return ""
+	}
+	return strings.ToLower(g.Email)
+}
+
+// groupProvenance returns the provenance of a group written now from the
+// source group g, which is recorded with Options.Provenance and, as the
+// groups are matched by it, with GroupKeyEmail
+func (s *engine) groupProvenance(g *admin.Group) string {
+	if s.opts.GroupKey != GroupKeyEmail {
+		return s.provenance(sourceId(g.Id))
+	}
+	return Provenance{Version: s.opts.Version, Source: sourceId(g.Id), Email: s.groupEmail(g), Synced: time.Now()}.String()
+}
+
+// staleEmail reports whether the provenance of the target group lacks
+// the email of the source group g, with GroupKeyEmail
+func (s *engine) staleEmail(existing *types.Group, g *admin.Group) bool {
+	if s.opts.GroupKey != GroupKeyEmail {
+		return false
+	}
+	p, _ := GroupProvenance(*existing)
+	return p.Email != s.groupEmail(g)
+}
+
+// renameGroups renames the target groups matching a source group by the
+// email, or the id, of their provenance whose display name differs, so
+// that the groups are then matched by name. A failed rename fails the
+// run, as the group would otherwise be recreated and the old one deleted.
+func (s *engine) renameGroups(googleGroups []*admin.Group, awsGroups []types.Group) error {
+	renamer, ok := s.target.(GroupRenamer)
+	if !ok {
+		return errNoGroupRename
+	}
+
+	byEmail := make(map[string]int)
+	bySource := make(map[string]int)
+	names := make(map[string]bool)
+	for i, g := range awsGroups {
+		names[awsutils.ToString(g.DisplayName)] = true
+		p, ok := GroupProvenance(g)
+		if !ok {
+			continue
+		}
+		if p.Email != "" {
+			byEmail[p.Email] = i
+		}
+		if p.Source != "" {
+			bySource[p.Source] = i
+		}
+	}
+
+	for _, g := range googleGroups {
+		if s.ignoreGroup(g) || !s.inShard(g.Email) {
+			continue
+		}
+		policy := s.groupPolicyOf(g)
+		if policy.Rejected || policy.Skip {
+			continue
+		}
+		i, ok := byEmail[s.groupEmail(g)]
+		if !ok {
+			i, ok = bySource[sourceId(g.Id)]
+		}
+		if !ok || awsutils.ToString(awsGroups[i].DisplayName) == policy.Name {
+			continue
+		}
+
+		old := awsutils.ToString(awsGroups[i].DisplayName)
+		ll := log.WithField("group", policy.Name).WithField("from", old).WithField("email", g.Email)
+		if names[policy.Name] {
+			ll.Error("Group renamed in Google, but another AWS group has the name, not renaming it")
+			s.report.Inc(&s.report.Errors)
+			continue
+		}
+
+		ll.Info("Renaming group, renamed in Google")
+		event := Event{Type: EventGroupUpdate, GroupName: policy.Name}
+		if !s.before(event) {
+			continue
+		}
+		err := renamer.RenameGroup(&awsGroups[i], awsutils.String(policy.Name))
+		s.after(event, err)
+		if err != nil {
+			s.report.Fail(err)
+			return err
+		}
+		delete(names, old)
+		names[policy.Name] = true
+		awsGroups[i].DisplayName = awsutils.String(policy.Name)
+		s.report.Inc(&s.report.GroupsUpdated)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestGroupKeyEmail(t *testing.T) {
+	assert := assert.New(t)
+
+	source := &memorySource{
+		users: []*admin.User{
+			{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}},
+		},
+		groups: []*admin.Group{
+			{Id: "g1", Name: "Platform", Email: "Platform@example.com"},
+			{Id: "g2", Name: "Finance", Email: "finance@example.com"},
+		},
+		members: map[string][]*admin.Member{"g1": {{Email: "ana@example.com", Type: "USER"}}},
+	}
+	target := NewMemoryTarget()
+	// created before the groups were keyed by email
+	_, _ = target.CreateGroup(awsutils.String("Finance"), awsutils.String("Finance team"))
+
+	opts := Options{GroupKey: GroupKeyEmail}
+	s, err := New(source, target, opts)
+	assert.NoError(err)
+	assert.NoError(s.Run())
+
+	platform, err := target.FindGroupByDisplayName("Platform")
+	assert.NoError(err)
+	p, ok := GroupProvenance(*platform)
+	assert.True(ok)
+	assert.Equal("platform@example.com", p.Email)
+	finance, _ := target.FindGroupByDisplayName("Finance")
+	p, _ = GroupProvenance(*finance)
+	assert.Equal("finance@example.com", p.Email)
+	assert.Equal("Finance team", stripProvenance(finance.Description))
+
+	source.groups[0].Name = "Platform Engineering"
+	s, _ = New(source, target, opts)
+	assert.NoError(s.Run())
+	assert.Equal(1, s.Report().GroupsUpdated)
+	assert.Equal(0, s.Report().GroupsCreated)
+	assert.Equal(0, s.Report().GroupsDeleted)
+
+	renamed, err := target.FindGroupByDisplayName("Platform Engineering")
+	assert.NoError(err)
+	assert.Equal(platform.GroupId, renamed.GroupId)
+	members, _ := target.GetGroupMembers(renamed)
+	assert.Len(members, 1)
+
+	_, err = New(source, target, Options{GroupKey: "id"})
+	assert.Error(err)
+}
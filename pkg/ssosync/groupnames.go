@@ -0,0 +1,132 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// GroupNameTransform transforms the invalid group names: the emojis
+	// and the characters not allowed are stripped and the names too long
+	// truncated with a hash suffix
+	GroupNameTransform = "transform"
+	// GroupNameSkip skips the groups with an invalid name, and reports
+	// them
+	GroupNameSkip = "skip"
+
+	// maxGroupName is the maximum length of an Identity Store group
+	// display name
+	maxGroupName = maxAttribute
+	// hashSuffix is the length of the hash suffix of a truncated name
+	hashSuffix = 8
+	// minGroupName is the minimum of Options.GroupNameMaxLength, which
+	// leaves room for the hash suffix
+	minGroupName = 16
+)
+
+// groupNameProblems returns why name is not a valid group display name
+// of at most max characters
+func groupNameProblems(name string, max int) []string {
+	var problems []string
+	switch n := utf8.RuneCountInString(name); {
+	case strings.TrimSpace(name) == "":
+		problems = append(problems, "group name is required")
+	case n > max:
+		problems = append(problems, fmt.Sprintf("group name is %d characters long, at most %d are allowed", n, max))
+	}
+	if name != "" && !attributePattern.MatchString(name) {
+		problems = append(problems, fmt.Sprintf("group name %q contains characters which are not allowed", name))
+	}
+	return problems
+}
+
+// transformGroupName returns the valid group display name of at most
+// max characters of the invalid name
+func transformGroupName(name string, max int) string {
+	var b strings.Builder
+	space := false
+	for _, r := range name {
+		switch {
+		case isEmoji(r):
+			continue
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		case !attributePattern.MatchString(string(r)):
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteRune(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:hashSuffix]
+	res := b.String()
+	if res == "" {
+		return "group-" + hash
+	}
+	if runes := []rune(res); len(runes) > max {
+		res = strings.TrimSpace(string(runes[:max-hashSuffix-1])) + "-" + hash
+	}
+	return res
+}
+
+// isEmoji reports whether r is an emoji, or a part of an emoji sequence:
+// a pictograph, a skin tone, a joiner or a variation selector
+func isEmoji(r rune) bool {
+	return unicode.Is(unicode.So, r) ||
+		(r >= 0x1F3FB && r <= 0x1F3FF) ||
+		r == 0x200D || r == 0x20E3 ||
+		(r >= 0xFE00 && r <= 0xFE0F)
+}
+
+// applyGroupNamePolicy validates the target name of the group email,
+// returning the name to sync, empty when the group is skipped
+func (s *engine) applyGroupNamePolicy(email, name string) string {
+	max := s.opts.GroupNameMaxLength
+	switch {
+	case max <= 0 || max > maxGroupName:
+		max = maxGroupName
+	case max < minGroupName:
+		max = minGroupName
+	}
+	problems := groupNameProblems(name, max)
+	if len(problems) == 0 {
+		return name
+	}
+
+	ll := log.WithField("group", name).WithField("email", email)
+	if s.opts.GroupNamePolicy == GroupNameSkip {
+		ll.WithField("problems", problems).Error("Invalid group name, skipping the group")
+		s.report.Reject(email, problems)
+		s.report.Inc(&s.report.Errors)
+		return ""
+	}
+	res := transformGroupName(name, max)
+	s.report.Rename(name, res)
+	s.notice("group-name:"+email, res, log.WarnLevel, ll.WithField("name", res).WithField("problems", problems), "Invalid group name, syncing the group with a transformed name")
+	return res
+}
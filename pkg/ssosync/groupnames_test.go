@@ -0,0 +1,67 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestTransformGroupName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(groupNameProblems("Platform 🚀", maxGroupName))
+	assert.NotEmpty(groupNameProblems("Team\u200bA", maxGroupName))
+	assert.NotEmpty(groupNameProblems(strings.Repeat("a", 20), 16))
+
+	assert.Equal("Team A", transformGroupName("🧑‍💻 Team\u200b  A 🚀", maxGroupName))
+	assert.Equal("group-", transformGroupName("🧑‍💻", maxGroupName)[:6])
+
+	long := transformGroupName(strings.Repeat("a", 20)+"\u200b", 16)
+	assert.Equal(16, utf8.RuneCountInString(long))
+	assert.Equal("aaaaaaa-", long[:8])
+	assert.NotEqual(long, transformGroupName(strings.Repeat("a", 21)+"\u200b", 16))
+}
+
+func TestGroupNamePolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	source := &memorySource{
+		groups: []*admin.Group{
+			{Id: "g1", Name: "Platform\u200b👩‍🚀", Email: "platform@example.com"},
+			{Id: "g2", Name: "Ops", Email: "ops@example.com"},
+		},
+	}
+	target := NewMemoryTarget()
+	s, err := New(source, target, Options{})
+	assert.NoError(err)
+	assert.NoError(s.Run())
+	r := s.Report()
+	assert.Equal(2, r.GroupsCreated)
+	assert.Equal(map[string]string{"Platform\u200b👩‍🚀": "Platform"}, r.Summary().Renamed)
+	_, err = target.FindGroupByDisplayName("Platform")
+	assert.NoError(err)
+
+	s, _ = New(source, NewMemoryTarget(), Options{GroupNamePolicy: GroupNameSkip})
+	assert.NoError(s.Run())
+	r = s.Report()
+	assert.Equal(1, r.GroupsCreated)
+	assert.Equal(1, r.Errors)
+	assert.Contains(r.Summary().Rejected, "platform@example.com")
+}
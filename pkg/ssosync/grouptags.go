@@ -0,0 +1,79 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// groupTagPattern matches the tags of a Google group description, e.g.
+// [ssosync:skip] or [ssosync:name=CustomName]
+var groupTagPattern = regexp.MustCompile(`\[ssosync:([a-z-]+)(?:=([^\]]*))?\]\s*`)
+
+// groupPolicy is the sync behavior of a group set by the tags of its
+// Google description
+type groupPolicy struct {
+	// Name is the target group name
+	Name string
+	// Description is the Google description without the tags
+	Description string
+	// Skip leaves the group alone, it is neither created nor deleted and
+	// its members are not changed
+	Skip bool
+	// MembershipOnly syncs the members of an existing group, which is
+	// never created
+	MembershipOnly bool
+	// Rejected is set when the name is invalid and the group skipped,
+	// see Options.GroupNamePolicy
+	Rejected bool
+}
+
+// groupPolicyOf returns the policy of the Google group g, the tags of
+// the description are only honored with Options.GroupDescriptionTags.
+// The name is valid, see applyGroupNamePolicy.
+func (s *engine) groupPolicyOf(g *admin.Group) groupPolicy {
+	p := groupPolicy{Name: g.Name, Description: g.Description}
+	if s.opts.GroupDescriptionTags {
+		s.applyGroupTags(g, &p)
+	}
+	if p.Name = s.applyGroupNamePolicy(g.Email, p.Name); p.Name == "" {
+		p.Rejected = true
+	}
+	return p
+}
+
+// applyGroupTags sets the policy p of the tags of the description of g
+func (s *engine) applyGroupTags(g *admin.Group, p *groupPolicy) {
+
+	for _, m := range groupTagPattern.FindAllStringSubmatch(g.Description, -1) {
+		switch m[1] {
+		case "skip":
+			p.Skip = true
+		case "membership-only":
+			p.MembershipOnly = true
+		case "name":
+			if name := strings.TrimSpace(m[2]); name != "" {
+				p.Name = name
+			}
+		default:
+			log.WithField("group", g.Name).WithField("tag", m[0]).Warn("Unknown tag in the group description, ignoring it")
+		}
+	}
+	p.Description = strings.TrimSpace(groupTagPattern.ReplaceAllString(g.Description, ""))
+}
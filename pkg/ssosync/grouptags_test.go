@@ -0,0 +1,37 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestGroupPolicyOf(t *testing.T) {
+	assert := assert.New(t)
+
+	g := &admin.Group{Name: "eng", Description: "Engineering [ssosync:membership-only] [ssosync:name=Engineers]"}
+
+	s := &engine{}
+	assert.Equal(groupPolicy{Name: "eng", Description: g.Description}, s.groupPolicyOf(g))
+
+	s.opts.GroupDescriptionTags = true
+	assert.Equal(groupPolicy{Name: "Engineers", Description: "Engineering", MembershipOnly: true}, s.groupPolicyOf(g))
+
+	g.Description = "[ssosync:skip]"
+	assert.Equal(groupPolicy{Name: "eng", Skip: true}, s.groupPolicyOf(g))
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/internal/logging"
+	log "github.com/sirupsen/logrus"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// EventType is the kind of change an Event describes
+type EventType string
+
+const (
+	// EventUserCreate is sent for a user created in the target
+	EventUserCreate EventType = "user_create"
+	// EventUserDelete is sent for a user deleted from the target
+	EventUserDelete EventType = "user_delete"
+	// EventUserUpdate is sent for a user whose provenance is recorded
+	EventUserUpdate EventType = "user_update"
+	// EventGroupCreate is sent for a group created in the target
+	EventGroupCreate EventType = "group_create"
+	// EventGroupUpdate is sent for a group whose description is updated
+	EventGroupUpdate EventType = "group_update"
+	// EventGroupDelete is sent for a group deleted from the target
+	EventGroupDelete EventType = "group_delete"
+	// EventMemberAdd is sent for a user added to a group
+	EventMemberAdd EventType = "member_add"
+	// EventMemberRemove is sent for a user removed from a group
+	EventMemberRemove EventType = "member_remove"
+)
+
+const (
+	// DeleteReasonDeleted is the reason of users deleted in the source
+	DeleteReasonDeleted = "deleted"
+	// DeleteReasonSuspended is the reason of users suspended in the source
+	DeleteReasonSuspended = "suspended"
+	// DeleteReasonAbsent is the reason of users missing from the source
+	DeleteReasonAbsent = "absent"
+)
+
+// Phase is when a hook is called, before or after the change
+type Phase string
+
+const (
+	// PhasePre hooks are called before the change, an error skips it
+	PhasePre Phase = "pre"
+	// PhasePost hooks are called after the change, with its error if any
+	PhasePost Phase = "post"
+)
+
+// Event describes a change of the target
+type Event struct {
+	Type      EventType `json:"type"`
+	Phase     Phase     `json:"phase"`
+	UserName  string    `json:"user_name,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	GroupName string    `json:"group_name,omitempty"`
+	// Reason is why a user is deleted, one of the DeleteReason constants,
+	// only set for user_delete events
+	Reason string `json:"reason,omitempty"`
+	// Groups are the target groups a deleted user was a member of at the
+	// start of the run, only set for user_delete events
+	Groups []string `json:"groups,omitempty"`
+	// Source is the source user whose state triggered the deletion, nil
+	// for users absent from the source
+	Source *admin.User `json:"source,omitempty"`
+	// Error is the error of the change, only set in the post phase
+	Error string `json:"error,omitempty"`
+}
+
+// Hook is called before and after every change of the target. An error
+// returned in the pre phase skips the change, e.g. to hold back the
+// deletion of a user until a ticket is approved, an error returned in the
+// post phase is logged.
+type Hook interface {
+	OnUserCreate(Event) error
+	OnUserDelete(Event) error
+	// OnGroupChange is called for groups created and deleted, and for
+	// members added and removed
+	OnGroupChange(Event) error
+}
+
+// HookFunc handles the events of all types with a single function
+type HookFunc func(Event) error
+
+// OnUserCreate implements Hook
+func (f HookFunc) OnUserCreate(e Event) error { return f(e) }
+
+// OnUserDelete implements Hook
+func (f HookFunc) OnUserDelete(e Event) error { return f(e) }
+
+// OnGroupChange implements Hook
+func (f HookFunc) OnGroupChange(e Event) error { return f(e) }
+
+// call dispatches e to the method of h for its type
+func (e Event) call(h Hook) error {
+	switch e.Type {
+	case EventUserCreate:
+		return h.OnUserCreate(e)
+	case EventUserDelete:
+		return h.OnUserDelete(e)
+	default:
+		return h.OnGroupChange(e)
+	}
+}
+
+// before runs the pre hooks of the change e, it reports false when a
+// hook failed or the max runtime elapsed and the change must be skipped
+func (s *engine) before(e Event) bool {
+	if s.expired() {
+		s.stop(e.key())
+		return false
+	}
+	e.Phase = PhasePre
+	for _, h := range s.opts.Hooks {
+		if err := e.call(h); err != nil {
+			log.WithField("event", e.Type).WithField("userName", e.UserName).WithField("group", e.GroupName).
+				WithError(err).Warn("Hook failed, skipping change")
+			return false
+		}
+	}
+	return true
+}
+
+// after runs the post hooks of the change e with its error
+func (s *engine) after(e Event, err error) {
+	e.Phase = PhasePost
+	if err != nil {
+		e.Error = err.Error()
+	} else if _, planned := s.target.(*dryRun); !planned {
+		// the dry run target logs the planned changes itself
+		log.WithFields(log.Fields{logging.ChangeField: e.Type, "group": e.GroupName, "userName": e.UserName}).Info("Applied change")
+		s.record(e)
+	}
+	for _, h := range s.opts.Hooks {
+		if err := e.call(h); err != nil {
+			log.WithField("event", e.Type).WithError(err).Warn("Hook failed")
+		}
+	}
+}
+
+// userEvent returns the event of type t about the user u
+func userEvent(t EventType, u *types.User) Event {
+	e := Event{Type: t, UserName: awsutils.ToString(u.UserName)}
+	for _, m := range u.Emails {
+		if m.Primary || e.Email == "" {
+			e.Email = awsutils.ToString(m.Value)
+		}
+	}
+	return e
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// resolveIgnoreLists adds the user members of Options.IgnoreUsersGroup
+// to the ignored users and the group members of
+// Options.IgnoreGroupsGroup, and the group itself, to the ignored
+// groups, once per run. The run fails when a group cannot be read, as
+// syncing without its exclusions could change the entities it protects.
+func (s *engine) resolveIgnoreLists() error {
+	if s.ignoreResolved {
+		return nil
+	}
+
+	if s.opts.IgnoreUsersGroup != "" {
+		emails, err := s.memberEmails(s.opts.IgnoreUsersGroup, "USER")
+		if err != nil {
+			return err
+		}
+		log.WithField("group", s.opts.IgnoreUsersGroup).WithField("users", len(emails)).Debug("Ignoring the members of the group")
+		s.opts.IgnoreUsers = append(append([]string{}, s.opts.IgnoreUsers...), emails...)
+	}
+	if s.opts.IgnoreGroupsGroup != "" {
+		emails, err := s.memberEmails(s.opts.IgnoreGroupsGroup, "GROUP")
+		if err != nil {
+			return err
+		}
+		log.WithField("group", s.opts.IgnoreGroupsGroup).WithField("groups", len(emails)).Debug("Ignoring the member groups of the group")
+		s.opts.IgnoreGroups = append(append([]string{s.opts.IgnoreGroupsGroup}, s.opts.IgnoreGroups...), emails...)
+	}
+
+	s.ignoreResolved = true
+	return nil
+}
+
+// memberEmails returns the emails of the members of the given type, USER
+// or GROUP, of the source group with the email
+func (s *engine) memberEmails(email, memberType string) ([]string, error) {
+	groups, err := s.source.GetGroups("email:" + email)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the ignore list group %s: %w", email, err)
+	}
+
+	var res []string
+	found := false
+	for _, g := range groups {
+		if !matchEmail([]string{email}, append(append([]string{g.Email}, g.Aliases...), g.NonEditableAliases...)) {
+			continue
+		}
+		found = true
+		members, err := s.source.GetGroupMembers(g)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the members of the ignore list group %s: %w", email, err)
+		}
+		for _, m := range members {
+			if m.Type == memberType {
+				res = append(res, m.Email)
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("ignore list group %s not found", email)
+	}
+	return res, nil
+}
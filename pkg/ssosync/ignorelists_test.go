@@ -0,0 +1,65 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestIgnoreListGroups(t *testing.T) {
+	assert := assert.New(t)
+
+	source := &memorySource{
+		users: []*admin.User{
+			{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}},
+			{Id: "2", PrimaryEmail: "bo@example.com", Name: &admin.UserName{GivenName: "Bo", FamilyName: "Li"}},
+		},
+		groups: []*admin.Group{
+			{Id: "g1", Name: "Platform", Email: "platform@example.com"},
+			{Id: "g2", Name: "Finance", Email: "finance@example.com"},
+			{Id: "g3", Name: "Ignored users", Email: "ignored-users@example.com"},
+			{Id: "g4", Name: "Ignored groups", Email: "ignored-groups@example.com"},
+		},
+		members: map[string][]*admin.Member{
+			"g1": {{Email: "ana@example.com", Type: "USER"}, {Email: "bo@example.com", Type: "USER"}},
+			"g2": {{Email: "ana@example.com", Type: "USER"}},
+			"g3": {{Email: "bo@example.com", Type: "USER"}, {Email: "finance@example.com", Type: "GROUP"}},
+			"g4": {{Email: "finance@example.com", Type: "GROUP"}, {Email: "ana@example.com", Type: "USER"}},
+		},
+	}
+	target := NewMemoryTarget()
+
+	s, err := New(source, target, Options{IgnoreUsersGroup: "Ignored-Users@example.com", IgnoreGroupsGroup: "ignored-groups@example.com"})
+	assert.NoError(err)
+	assert.NoError(s.Run())
+
+	users, _ := target.GetUsers()
+	assert.Len(users, 1)
+	_, err = target.FindUserByUserName("ana@example.com")
+	assert.NoError(err)
+
+	groups, _ := target.GetGroups()
+	names := []string{}
+	for _, g := range groups {
+		names = append(names, *g.DisplayName)
+	}
+	assert.ElementsMatch([]string{"Platform", "Ignored users"}, names)
+
+	s, _ = New(source, NewMemoryTarget(), Options{IgnoreUsersGroup: "missing@example.com"})
+	assert.ErrorContains(s.Run(), "ignore list group missing@example.com not found")
+}
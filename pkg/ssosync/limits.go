@@ -0,0 +1,163 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	admin "google.golang.org/api/admin/directory/v1"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	log "github.com/sirupsen/logrus"
+)
+
+// overflowPattern is the name of an overflow group, the name of the
+// group it extends followed by its number
+var overflowPattern = regexp.MustCompile(`^(.+)-([0-9]+)$`)
+
+// overflowName returns the name of the overflow group i of the group
+// name, the group itself being number 1
+func overflowName(name string, i int) string {
+	return fmt.Sprintf("%s-%d", name, i)
+}
+
+// syncLimited syncs the members of the AWS group, which are capped by
+// the MemberLimit. A group approaching the limit is noticed, the members
+// above the limit are dropped or, with OverflowGroups, added to the
+// numbered overflow groups of the group.
+func (s *engine) syncLimited(ll *log.Entry, awsGroup *types.Group, memberList map[string]*types.User,
+	usersSyncResult *UserSyncResult) error {
+	name := awsutils.ToString(awsGroup.DisplayName)
+	limit := s.opts.MemberLimit
+	n := len(memberList)
+	ll = ll.WithField("members", n).WithField("limit", limit)
+
+	if n*10 >= limit*9 {
+		s.notice("limit:"+name, fmt.Sprintf("near-limit:%d", n), log.WarnLevel, ll, "Group is approaching the membership limit")
+	}
+
+	names := make([]string, 0, n)
+	for k := range memberList {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	if !s.opts.OverflowGroups {
+		if n <= limit {
+			return s.syncGroupMembers(ll, awsGroup, memberList, usersSyncResult)
+		}
+		ll.Error("Group exceeds the membership limit, adding only the first members by user name")
+		s.report.Inc(&s.report.Errors)
+		return s.syncGroupMembers(ll, awsGroup, pickMembers(memberList, names[:limit]), usersSyncResult)
+	}
+
+	chunks := (n + limit - 1) / limit
+	if chunks == 0 {
+		chunks = 1
+	}
+	for i := 0; i < chunks; i++ {
+		end := (i + 1) * limit
+		if end > n {
+			end = n
+		}
+		members := pickMembers(memberList, names[i*limit:end])
+		if i == 0 {
+			if err := s.syncGroupMembers(ll, awsGroup, members, usersSyncResult); err != nil {
+				return err
+			}
+			continue
+		}
+		g, err := s.overflowGroup(name, i+1)
+		if err != nil {
+			return err
+		}
+		if g == nil {
+			continue
+		}
+		if err := s.syncGroupMembers(ll.WithField("group", g.DisplayName), g, members, usersSyncResult); err != nil {
+			return err
+		}
+	}
+
+	// the overflow groups no longer needed are emptied, not deleted, as
+	// they may still be assigned to accounts
+	for i := chunks + 1; ; i++ {
+		g, ok := s.targetGroups[overflowName(name, i)]
+		if !ok {
+			return nil
+		}
+		if err := s.syncGroupMembers(ll.WithField("group", g.DisplayName), g, map[string]*types.User{}, usersSyncResult); err != nil {
+			return err
+		}
+	}
+}
+
+// overflowGroup returns the overflow group i of the group name, creating
+// it when missing, or nil when its creation was cancelled by a hook
+func (s *engine) overflowGroup(name string, i int) (*types.Group, error) {
+	displayName := overflowName(name, i)
+	if g, ok := s.targetGroups[displayName]; ok {
+		return g, nil
+	}
+
+	ll := log.WithField("group", displayName)
+	ll.Info("Creating overflow group")
+	event := Event{Type: EventGroupCreate, GroupName: displayName}
+	if !s.before(event) {
+		return nil, nil
+	}
+	description := awsutils.String(fmt.Sprintf("Members %d of %s above the membership limit", i, name))
+	g, err := s.target.CreateGroup(awsutils.String(displayName), withProvenance(description, s.provenance("")))
+	s.after(event, err)
+	if err != nil {
+		ll.Error("Can't create overflow group in AWS: ", err)
+		s.report.Fail(err)
+		return nil, nil
+	}
+	s.report.Inc(&s.report.GroupsCreated)
+	s.targetGroups[displayName] = g
+	return g, nil
+}
+
+// isOverflow reports whether the AWS group name is an overflow group of
+// one of the synced Google groups
+func (s *engine) isOverflow(name string, googleGroups map[string]*admin.Group) bool {
+	if !s.opts.OverflowGroups || s.opts.MemberLimit <= 0 {
+		return false
+	}
+	m := overflowPattern.FindStringSubmatch(name)
+	if m == nil {
+		return false
+	}
+	i, err := strconv.Atoi(m[2])
+	if err != nil || i < 2 {
+		return false
+	}
+	_, ok := googleGroups[m[1]]
+	return ok
+}
+
+// pickMembers returns the members of memberList with the given names
+func pickMembers(memberList map[string]*types.User, names []string) map[string]*types.User {
+	res := make(map[string]*types.User, len(names))
+	for _, n := range names {
+		res[n] = memberList[n]
+	}
+	return res
+}
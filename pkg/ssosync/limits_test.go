@@ -0,0 +1,42 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestIsOverflow(t *testing.T) {
+	assert := assert.New(t)
+
+	groups := map[string]*admin.Group{"Engineering": {}, "Team-1": {}}
+	s := &engine{opts: Options{MemberLimit: 100, OverflowGroups: true}}
+	assert.True(s.isOverflow("Engineering-2", groups))
+	assert.True(s.isOverflow("Team-1-3", groups))
+	assert.False(s.isOverflow("Engineering-1", groups))
+	assert.False(s.isOverflow("Engineering", groups))
+	assert.False(s.isOverflow("Sales-2", groups))
+
+	s.opts.OverflowGroups = false
+	assert.False(s.isOverflow("Engineering-2", groups))
+
+	members := pickMembers(map[string]*types.User{"a": {}, "b": {}, "c": {}}, []string{"a", "c"})
+	assert.Len(members, 2)
+	assert.Contains(members, "c")
+}
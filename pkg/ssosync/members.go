@@ -0,0 +1,92 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"sync"
+
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// MemberCache holds the members of the source groups by group id with
+// the etag of the group they were fetched for, across runs, it is safe
+// for concurrent use
+type MemberCache struct {
+	mu      sync.Mutex
+	groups  map[string]cachedMembers
+	hits    int
+	fetches int
+}
+
+// cachedMembers are the members of a group at the etag
+type cachedMembers struct {
+	etag    string
+	members []*admin.Member
+}
+
+// NewMemberCache returns an empty MemberCache
+func NewMemberCache() *MemberCache {
+	return &MemberCache{groups: make(map[string]cachedMembers)}
+}
+
+// TakeStats returns the lookups served from the cache and the lookups
+// fetched from the source since the last call
+func (c *MemberCache) TakeStats() (hits, fetches int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hits, fetches, c.hits, c.fetches = c.hits, c.fetches, 0, 0
+	return hits, fetches
+}
+
+// CachedMembers returns a Source reading from source which returns the
+// members of the groups whose etag did not change since cached, instead
+// of fetching them again
+func CachedMembers(source Source, cache *MemberCache) Source {
+	return &memberCaching{Source: source, cache: cache}
+}
+
+// memberCaching is a Source caching the group members
+type memberCaching struct {
+	Source
+	cache *MemberCache
+}
+
+// GetGroupMembers returns the cached members of g if its etag is the
+// one they were fetched for
+func (m *memberCaching) GetGroupMembers(g *admin.Group) ([]*admin.Member, error) {
+	c := m.cache
+	c.mu.Lock()
+	cached, ok := c.groups[g.Id]
+	if ok && g.Etag != "" && cached.etag == g.Etag {
+		c.hits++
+		c.mu.Unlock()
+		return cached.members, nil
+	}
+	c.mu.Unlock()
+
+	members, err := m.Source.GetGroupMembers(g)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fetches++
+	if g.Etag != "" {
+		c.groups[g.Id] = cachedMembers{etag: g.Etag, members: members}
+	}
+	return members, nil
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// countingSource counts the group member lookups
+type countingSource struct {
+	Source
+	calls int
+}
+
+func (s *countingSource) GetGroupMembers(g *admin.Group) ([]*admin.Member, error) {
+	s.calls++
+	return []*admin.Member{{Email: "user@example.com"}}, nil
+}
+
+func TestCachedMembers(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := NewMemberCache()
+	source := &countingSource{}
+	g := &admin.Group{Id: "1", Etag: "a"}
+
+	for run := 0; run < 2; run++ {
+		members, err := CachedMembers(source, cache).GetGroupMembers(g)
+		assert.NoError(err)
+		assert.Len(members, 1)
+	}
+	assert.Equal(1, source.calls)
+
+	g.Etag = "b"
+	_, _ = CachedMembers(source, cache).GetGroupMembers(g)
+	assert.Equal(2, source.calls)
+
+	hits, fetches := cache.TakeStats()
+	assert.Equal(1, hits)
+	assert.Equal(2, fetches)
+}
@@ -0,0 +1,313 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+)
+
+// MemoryTarget is a Target keeping the users, groups and memberships in
+// memory, e.g. a test double of the Identity Store or a target to try a
+// configuration against. It is safe for concurrent use.
+type MemoryTarget struct {
+	mu     sync.Mutex
+	nextId int
+
+	users       map[string]*types.User
+	groups      map[string]*types.Group
+	memberships map[string]*types.GroupMembership
+}
+
+// NewMemoryTarget returns an empty MemoryTarget
+func NewMemoryTarget() *MemoryTarget {
+	return &MemoryTarget{
+		users:       make(map[string]*types.User),
+		groups:      make(map[string]*types.Group),
+		memberships: make(map[string]*types.GroupMembership),
+	}
+}
+
+// id returns a new id with the prefix, mu must be held
+func (m *MemoryTarget) id(prefix string) *string {
+	m.nextId++
+	return awsutils.String(prefix + strconv.Itoa(m.nextId))
+}
+
+// CreateUser adds the user, adopting an existing user with the same
+// user name like the Identity Store client
+func (m *MemoryTarget) CreateUser(u *types.User) (*types.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.users {
+		if awsutils.ToString(existing.UserName) == awsutils.ToString(u.UserName) {
+			u.UserId = existing.UserId
+			return u, nil
+		}
+	}
+	created := *u
+	created.UserId = m.id("user-")
+	m.users[*created.UserId] = &created
+	u.UserId = created.UserId
+	return u, nil
+}
+
+// DeleteUser removes the user and its memberships
+func (m *MemoryTarget) DeleteUser(u *types.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := awsutils.ToString(u.UserId)
+	if _, ok := m.users[id]; !ok {
+		return fmt.Errorf("user %s not found", id)
+	}
+	delete(m.users, id)
+	for k, ms := range m.memberships {
+		if memberId(ms) == id {
+			delete(m.memberships, k)
+		}
+	}
+	return nil
+}
+
+// UpdateUserType replaces the user type of the user
+func (m *MemoryTarget) UpdateUserType(u *types.User, userType *string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.users[awsutils.ToString(u.UserId)]
+	if !ok {
+		return fmt.Errorf("user %s not found", awsutils.ToString(u.UserId))
+	}
+	existing.UserType = userType
+	return nil
+}
+
+// UpdateUser replaces the user name, display name, name and emails of
+// the user by the ones set in attrs
+func (m *MemoryTarget) UpdateUser(u *types.User, attrs *types.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.users[awsutils.ToString(u.UserId)]
+	if !ok {
+		return fmt.Errorf("user %s not found", awsutils.ToString(u.UserId))
+	}
+	if attrs.UserName != nil {
+		existing.UserName = attrs.UserName
+	}
+	if attrs.DisplayName != nil {
+		existing.DisplayName = attrs.DisplayName
+	}
+	if attrs.Name != nil {
+		existing.Name = attrs.Name
+	}
+	if attrs.Emails != nil {
+		existing.Emails = attrs.Emails
+	}
+	return nil
+}
+
+// CreateGroup adds the group, adopting an existing group with the same
+// display name like the Identity Store client
+func (m *MemoryTarget) CreateGroup(name *string, description *string) (*types.Group, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.groups {
+		if awsutils.ToString(existing.DisplayName) == awsutils.ToString(name) {
+			g := *existing
+			return &g, nil
+		}
+	}
+	g := &types.Group{GroupId: m.id("group-"), DisplayName: name, Description: description}
+	m.groups[*g.GroupId] = g
+	res := *g
+	return &res, nil
+}
+
+// UpdateGroup replaces the description of the group
+func (m *MemoryTarget) UpdateGroup(g *types.Group, description *string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.groups[awsutils.ToString(g.GroupId)]
+	if !ok {
+		return fmt.Errorf("group %s not found", awsutils.ToString(g.GroupId))
+	}
+	existing.Description = description
+	return nil
+}
+
+// RenameGroup replaces the display name of the group
+func (m *MemoryTarget) RenameGroup(g *types.Group, name *string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.groups[awsutils.ToString(g.GroupId)]
+	if !ok {
+		return fmt.Errorf("group %s not found", awsutils.ToString(g.GroupId))
+	}
+	existing.DisplayName = name
+	return nil
+}
+
+// DeleteGroup removes the group and its memberships
+func (m *MemoryTarget) DeleteGroup(g *types.Group) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := awsutils.ToString(g.GroupId)
+	if _, ok := m.groups[id]; !ok {
+		return fmt.Errorf("group %s not found", id)
+	}
+	delete(m.groups, id)
+	for k, ms := range m.memberships {
+		if awsutils.ToString(ms.GroupId) == id {
+			delete(m.memberships, k)
+		}
+	}
+	return nil
+}
+
+// AddUserToGroup adds the membership, adopting an existing one
+func (m *MemoryTarget) AddUserToGroup(u *types.User, g *types.Group) (*types.GroupMembership, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userId, groupId := awsutils.ToString(u.UserId), awsutils.ToString(g.GroupId)
+	if _, ok := m.users[userId]; !ok {
+		return nil, fmt.Errorf("user %s not found", userId)
+	}
+	if _, ok := m.groups[groupId]; !ok {
+		return nil, fmt.Errorf("group %s not found", groupId)
+	}
+	for _, ms := range m.memberships {
+		if awsutils.ToString(ms.GroupId) == groupId && memberId(ms) == userId {
+			res := *ms
+			return &res, nil
+		}
+	}
+	ms := &types.GroupMembership{
+		MembershipId: m.id("membership-"),
+		GroupId:      g.GroupId,
+		MemberId:     &types.MemberIdMemberUserId{Value: userId},
+	}
+	m.memberships[*ms.MembershipId] = ms
+	res := *ms
+	return &res, nil
+}
+
+// RemoveGroupMembership removes the membership
+func (m *MemoryTarget) RemoveGroupMembership(membership *types.GroupMembership) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := awsutils.ToString(membership.MembershipId)
+	if _, ok := m.memberships[id]; !ok {
+		return fmt.Errorf("membership %s not found", id)
+	}
+	delete(m.memberships, id)
+	return nil
+}
+
+// GetGroupMembers returns the memberships of the group
+func (m *MemoryTarget) GetGroupMembers(g *types.Group) ([]types.GroupMembership, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var res []types.GroupMembership
+	for _, ms := range m.memberships {
+		if awsutils.ToString(ms.GroupId) == awsutils.ToString(g.GroupId) {
+			res = append(res, *ms)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return awsutils.ToString(res[i].MembershipId) < awsutils.ToString(res[j].MembershipId)
+	})
+	return res, nil
+}
+
+// GetGroups returns the groups by display name
+func (m *MemoryTarget) GetGroups() ([]types.Group, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := make([]types.Group, 0, len(m.groups))
+	for _, g := range m.groups {
+		res = append(res, *g)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return awsutils.ToString(res[i].DisplayName) < awsutils.ToString(res[j].DisplayName)
+	})
+	return res, nil
+}
+
+// GetUsers returns the users by user name
+func (m *MemoryTarget) GetUsers() ([]types.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := make([]types.User, 0, len(m.users))
+	for _, u := range m.users {
+		res = append(res, *u)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return awsutils.ToString(res[i].UserName) < awsutils.ToString(res[j].UserName)
+	})
+	return res, nil
+}
+
+// FindUserByUserName returns the user with the user name
+func (m *MemoryTarget) FindUserByUserName(userName string) (*types.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if awsutils.ToString(u.UserName) == userName {
+			res := *u
+			return &res, nil
+		}
+	}
+	return nil, fmt.Errorf("user %s not found", userName)
+}
+
+// FindGroupByDisplayName returns the group with the display name
+func (m *MemoryTarget) FindGroupByDisplayName(displayName string) (*types.Group, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, g := range m.groups {
+		if awsutils.ToString(g.DisplayName) == displayName {
+			res := *g
+			return &res, nil
+		}
+	}
+	return nil, fmt.Errorf("group %s not found", displayName)
+}
+
+// memberId returns the user id of the membership
+func memberId(ms *types.GroupMembership) string {
+	if id, ok := ms.MemberId.(*types.MemberIdMemberUserId); ok {
+		return id.Value
+	}
+	return ""
+}
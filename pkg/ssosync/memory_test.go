@@ -0,0 +1,87 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// memorySource is a Source of fixed users and groups
+type memorySource struct {
+	users   []*admin.User
+	deleted []*admin.User
+	groups  []*admin.Group
+	members map[string][]*admin.Member
+}
+
+func (m *memorySource) GetUsers(...string) ([]*admin.User, error)     { return m.users, nil }
+func (m *memorySource) GetDeletedUsers() ([]*admin.User, error)       { return m.deleted, nil }
+func (m *memorySource) GetGroups(q ...string) ([]*admin.Group, error) { return m.groups, nil }
+func (m *memorySource) GetGroupMembers(g *admin.Group) ([]*admin.Member, error) {
+	return m.members[g.Id], nil
+}
+
+func TestMemoryTarget(t *testing.T) {
+	assert := assert.New(t)
+
+	source := &memorySource{
+		users: []*admin.User{
+			{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}},
+			{Id: "2", PrimaryEmail: "bo@example.com", Name: &admin.UserName{GivenName: "Bo", FamilyName: "Li"}},
+		},
+		groups:  []*admin.Group{{Id: "g1", Name: "Platform", Email: "platform@example.com"}},
+		members: map[string][]*admin.Member{"g1": {{Email: "ana@example.com"}}},
+	}
+	target := NewMemoryTarget()
+
+	s, err := New(source, target, Options{Provenance: true, ManagedOnly: true})
+	assert.NoError(err)
+	assert.NoError(s.Run())
+	r := s.Report()
+	assert.Equal(2, r.UsersCreated)
+	assert.Equal(1, r.GroupsCreated)
+	assert.Equal(1, r.MembershipsAdded)
+
+	users, _ := target.GetUsers()
+	assert.Len(users, 2)
+	_, ok := UserProvenance(users[0])
+	assert.True(ok)
+	groups, _ := target.GetGroups()
+	members, _ := target.GetGroupMembers(&groups[0])
+	assert.Len(members, 1)
+
+	// a second run changes nothing, then ana leaves the group and bo is
+	// suspended
+	s, _ = New(source, target, Options{Provenance: true, ManagedOnly: true})
+	assert.NoError(s.Run())
+	assert.Equal(0, s.Report().Changes())
+
+	source.members["g1"] = nil
+	source.users[1].Suspended = true
+	s, _ = New(source, target, Options{Provenance: true, ManagedOnly: true})
+	assert.NoError(s.Run())
+	assert.Equal(1, s.Report().MembershipsRemoved)
+	assert.Equal(1, s.Report().UsersDeleted)
+
+	_, err = target.FindUserByUserName("bo@example.com")
+	assert.Error(err)
+	u, err := target.FindUserByUserName("ana@example.com")
+	assert.NoError(err)
+	assert.Equal("Ana Silva", awsutils.ToString(u.DisplayName))
+}
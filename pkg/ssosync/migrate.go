@@ -0,0 +1,310 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	log "github.com/sirupsen/logrus"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// UserUpdater is implemented by the targets which replace the attributes
+// of an existing user, required by Migrate
+type UserUpdater interface {
+	UpdateUser(u *types.User, attrs *types.User) error
+}
+
+var errNoUserUpdate = errors.New("the target does not update the attributes of the users")
+
+// Migration is the adoption of an existing target entity by Migrate
+type Migration struct {
+	// Kind is user or group
+	Kind string
+	// Name is the user name or group name after the migration
+	Name string
+	// Changes are the attributes normalized, e.g. displayName, and
+	// provenance when it is recorded
+	Changes []string
+	// Error is why the migration failed, empty when it succeeded
+	Error string
+}
+
+// Migrate adopts the target users and groups created by earlier versions
+// of ssosync or by the automatic provisioning (SCIM), without recreating
+// them: the users matching a source user by user name, email or Google
+// external id have their attributes normalized to the ones the sync
+// creates and the Google user id recorded in their provenance, as the
+// Identity Store API does not write external ids, and the groups
+// matching a source group have the provenance recorded in their
+// description. The migrations are returned sorted by kind and name; the
+// entities already up to date are left out. Wrap the target with DryRun
+// to only log the changes.
+func Migrate(source Source, target Target, opts Options) ([]Migration, error) {
+	updater, ok := target.(UserUpdater)
+	if !ok {
+		return nil, errNoUserUpdate
+	}
+	if _, ok := unwrap(target).(UserTypeUpdater); !ok {
+		return nil, errNoUserTypeUpdate
+	}
+	if _, ok := unwrap(target).(GroupUpdater); !ok {
+		return nil, errNoGroupUpdate
+	}
+	e, err := New(source, target, opts)
+	if err != nil {
+		return nil, err
+	}
+	return e.(*engine).migrate(updater)
+}
+
+func (s *engine) migrate(updater UserUpdater) ([]Migration, error) {
+	if err := s.resolveIgnoreLists(); err != nil {
+		return nil, err
+	}
+	users, err := s.migrateUsers(updater)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.migrateGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	res := append(users, groups...)
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Kind != res[j].Kind {
+			return res[i].Kind > res[j].Kind
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res, nil
+}
+
+func (s *engine) migrateUsers(updater UserUpdater) ([]Migration, error) {
+	awsUsers, err := s.target.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	googleUsers, err := s.source.GetUsers(s.opts.UserMatch...)
+	if err != nil {
+		return nil, err
+	}
+	googleUsers, err = s.excludeUsers(googleUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]*admin.User, 0, len(googleUsers))
+	for _, u := range googleUsers {
+		if !u.Suspended && !s.ignoreUser(u) {
+			active = append(active, u)
+		}
+	}
+	names, err := s.assignUserNames(active)
+	if err != nil {
+		return nil, err
+	}
+
+	// earlier versions named the users differently, the users are also
+	// matched by email and by the Google user id
+	byName := make(map[string]*types.User)
+	byEmail := make(map[string]*types.User)
+	bySource := make(map[string]*types.User)
+	for i := range awsUsers {
+		u := &awsUsers[i]
+		byName[awsutils.ToString(u.UserName)] = u
+		for _, e := range u.Emails {
+			byEmail[strings.ToLower(awsutils.ToString(e.Value))] = u
+		}
+		if id := googleExternalId(*u); id != "" {
+			bySource[id] = u
+		}
+		if p, ok := UserProvenance(*u); ok && strings.HasPrefix(p.Source, "google:") {
+			bySource[strings.TrimPrefix(p.Source, "google:")] = u
+		}
+	}
+
+	var res []Migration
+	taken := make(map[*types.User]bool)
+	for _, u := range active {
+		name, ok := names[u.PrimaryEmail]
+		if !ok {
+			continue
+		}
+		existing := byName[name]
+		if existing == nil {
+			existing = bySource[u.Id]
+		}
+		if existing == nil {
+			existing = byEmail[strings.ToLower(u.PrimaryEmail)]
+		}
+		if existing == nil || taken[existing] {
+			continue
+		}
+		taken[existing] = true
+
+		if m, ok := s.migrateUser(updater, existing, u, name); ok {
+			res = append(res, m)
+		}
+	}
+	return res, nil
+}
+
+// migrateUser normalizes the attributes of the target user u matching
+// the source user and records its provenance, false when u is up to date
+func (s *engine) migrateUser(updater UserUpdater, u *types.User, source *admin.User, name string) (Migration, bool) {
+	m := Migration{Kind: "user", Name: name}
+	ll := log.WithField("userName", name).WithField("email", source.PrimaryEmail)
+
+	want := s.targetUser(source, name)
+	attrs := &types.User{}
+	if awsutils.ToString(u.UserName) != name {
+		attrs.UserName = want.UserName
+		m.Changes = append(m.Changes, fmt.Sprintf("userName: %q -> %q", awsutils.ToString(u.UserName), name))
+	}
+	if awsutils.ToString(u.DisplayName) != awsutils.ToString(want.DisplayName) {
+		attrs.DisplayName = want.DisplayName
+		m.Changes = append(m.Changes, fmt.Sprintf("displayName: %q -> %q", awsutils.ToString(u.DisplayName), awsutils.ToString(want.DisplayName)))
+	}
+	if u.Name == nil || awsutils.ToString(u.Name.GivenName) != awsutils.ToString(want.Name.GivenName) ||
+		awsutils.ToString(u.Name.FamilyName) != awsutils.ToString(want.Name.FamilyName) {
+		attrs.Name = want.Name
+		m.Changes = append(m.Changes, "name")
+	}
+	if primaryEmail(u) != strings.ToLower(source.PrimaryEmail) {
+		attrs.Emails = want.Emails
+		m.Changes = append(m.Changes, fmt.Sprintf("email: %q -> %q", primaryEmail(u), source.PrimaryEmail))
+	}
+	p, ok := UserProvenance(*u)
+	adopt := !ok || p.Source != sourceId(source.Id)
+	if adopt {
+		m.Changes = append(m.Changes, "provenance")
+	}
+	if len(m.Changes) == 0 {
+		ll.Debug("User is up to date, nothing to migrate")
+		return m, false
+	}
+
+	ll.WithField("changes", m.Changes).Info("Migrating user")
+	event := userEvent(EventUserUpdate, u)
+	if !s.before(event) {
+		m.Error = "vetoed by a hook"
+		return m, true
+	}
+	var err error
+	if attrs.UserName != nil || attrs.DisplayName != nil || attrs.Name != nil || attrs.Emails != nil {
+		err = updater.UpdateUser(u, attrs)
+	}
+	if err == nil && adopt {
+		err = s.updateUserType(u, awsutils.String(Provenance{Version: s.opts.Version, Source: sourceId(source.Id), Synced: time.Now()}.String()))
+	}
+	s.after(event, err)
+	if err != nil {
+		ll.Error("Can't migrate user: ", err)
+		s.report.Fail(err)
+		m.Error = err.Error()
+		return m, true
+	}
+	s.report.Inc(&s.report.UsersUpdated)
+	return m, true
+}
+
+func (s *engine) migrateGroups() ([]Migration, error) {
+	awsGroups, err := s.target.GetGroups()
+	if err != nil {
+		return nil, err
+	}
+	groupsIndex := make(map[string]*types.Group)
+	for i := range awsGroups {
+		groupsIndex[awsutils.ToString(awsGroups[i].DisplayName)] = &awsGroups[i]
+	}
+
+	googleGroups, err := s.source.GetGroups(s.opts.GroupMatch...)
+	if err != nil {
+		return nil, err
+	}
+	googleGroups, err = s.excludeGroups(googleGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Migration
+	for _, g := range uniqueGroups(googleGroups) {
+		if s.ignoreGroup(g) {
+			continue
+		}
+		policy := s.groupPolicyOf(g)
+		if policy.Rejected || policy.Skip {
+			continue
+		}
+		existing, ok := groupsIndex[policy.Name]
+		if !ok {
+			continue
+		}
+		if _, ok := GroupProvenance(*existing); ok && !s.staleEmail(existing, g) {
+			continue
+		}
+
+		m := Migration{Kind: "group", Name: policy.Name, Changes: []string{"provenance"}}
+		ll := log.WithField("group", policy.Name)
+		description := existing.Description
+		if s.description != nil {
+			if description, err = s.groupDescription(g, policy); err != nil {
+				m.Error = err.Error()
+				res = append(res, m)
+				continue
+			}
+			m.Changes = append(m.Changes, "description")
+		}
+
+		ll.Info("Migrating group")
+		event := Event{Type: EventGroupUpdate, GroupName: policy.Name}
+		if !s.before(event) {
+			m.Error = "vetoed by a hook"
+			res = append(res, m)
+			continue
+		}
+		description = withProvenance(description, Provenance{Version: s.opts.Version, Source: sourceId(g.Id), Email: s.groupEmail(g), Synced: time.Now()}.String())
+		err := s.updateGroup(existing, description)
+		s.after(event, err)
+		if err != nil {
+			ll.Error("Can't migrate group: ", err)
+			s.report.Fail(err)
+			m.Error = err.Error()
+		} else {
+			s.report.Inc(&s.report.GroupsUpdated)
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+// primaryEmail returns the primary email of the target user in lower case
+func primaryEmail(u *types.User) string {
+	var email string
+	for _, e := range u.Emails {
+		if e.Primary || email == "" {
+			email = awsutils.ToString(e.Value)
+		}
+	}
+	return strings.ToLower(email)
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestMigrate(t *testing.T) {
+	assert := assert.New(t)
+
+	source := &memorySource{
+		users: []*admin.User{
+			{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}},
+			{Id: "2", PrimaryEmail: "bo@example.com", Name: &admin.UserName{GivenName: "Bo", FamilyName: "Lee"}},
+			{Id: "3", PrimaryEmail: "cy@example.com", Name: &admin.UserName{GivenName: "Cy", FamilyName: "Ng"}},
+		},
+		groups: []*admin.Group{{Id: "g1", Name: "Platform", Email: "platform@example.com"}},
+	}
+	target := NewMemoryTarget()
+	// created by an earlier version, with another user name convention
+	_, _ = target.CreateUser(&types.User{
+		UserName: awsutils.String("ana"), DisplayName: awsutils.String("Silva, Ana"),
+		Name:   &types.Name{GivenName: awsutils.String("Ana"), FamilyName: awsutils.String("Silva")},
+		Emails: []types.Email{{Value: awsutils.String("Ana@example.com"), Primary: true}},
+	})
+	bo := (&engine{}).targetUser(source.users[1], "bo@example.com")
+	bo.UserType = awsutils.String(Provenance{Source: sourceId("2")}.String())
+	_, _ = target.CreateUser(bo)
+	_, _ = target.CreateGroup(awsutils.String("Platform"), awsutils.String("Platform team"))
+
+	migrations, err := Migrate(source, DryRun(target), Options{})
+	assert.NoError(err)
+	assert.Len(migrations, 2)
+	u, _ := target.FindUserByUserName("ana")
+	assert.NotNil(u)
+
+	migrations, err = Migrate(source, target, Options{Version: "v2.1.0"})
+	assert.NoError(err)
+	if assert.Len(migrations, 2) {
+		assert.Equal(Migration{Kind: "user", Name: "ana@example.com", Changes: []string{
+			`userName: "ana" -> "ana@example.com"`,
+			`displayName: "Silva, Ana" -> "Ana Silva"`,
+			"provenance",
+		}}, migrations[0])
+		assert.Equal("group", migrations[1].Kind)
+		assert.Equal([]string{"provenance"}, migrations[1].Changes)
+	}
+
+	u, err = target.FindUserByUserName("ana@example.com")
+	assert.NoError(err)
+	p, ok := UserProvenance(*u)
+	assert.True(ok)
+	assert.Equal("google:1", p.Source)
+	g, _ := target.FindGroupByDisplayName("Platform")
+	assert.Equal("Platform team", stripProvenance(g.Description))
+	_, ok = GroupProvenance(*g)
+	assert.True(ok)
+
+	migrations, err = Migrate(source, target, Options{})
+	assert.NoError(err)
+	assert.Empty(migrations)
+
+	_, err = Migrate(source, &staticTarget{}, Options{})
+	assert.ErrorIs(err, errNoUserUpdate)
+}
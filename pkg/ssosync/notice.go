@@ -0,0 +1,31 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// notice logs msg about the persisting state of the entity key, e.g. a
+// protected user not deleted, at level unless the previous run noticed the
+// same state, then at debug level, so that each run only logs what changed
+func (s *engine) notice(key, state string, level log.Level, ll *log.Entry, msg string) {
+	s.report.Notice(key, state)
+	if prev, ok := s.opts.Previous[key]; ok && prev == state {
+		ll.Debug(msg)
+		return
+	}
+	ll.Log(level, msg)
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	admin "google.golang.org/api/admin/directory/v1"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// The directories return the users and groups in no particular order, they
+// are sorted before they are processed, so that the logs, the plans and the
+// reports of two runs over the same data are the same.
+
+// sortGoogleUsers returns the Google users sorted by primary email, the
+// users of the source are shared by the targets of a fan-out and are not
+// sorted in place
+func sortGoogleUsers(users []*admin.User) []*admin.User {
+	users = append([]*admin.User(nil), users...)
+	sort.SliceStable(users, func(i, j int) bool {
+		return users[i].PrimaryEmail < users[j].PrimaryEmail
+	})
+	return users
+}
+
+// sortGoogleGroups returns the Google groups sorted by email, like
+// sortGoogleUsers
+func sortGoogleGroups(groups []*admin.Group) []*admin.Group {
+	groups = append([]*admin.Group(nil), groups...)
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Email < groups[j].Email
+	})
+	return groups
+}
+
+// sortUsers sorts the AWS users by user name
+func sortUsers(users []types.User) {
+	sort.SliceStable(users, func(i, j int) bool {
+		return awsutils.ToString(users[i].UserName) < awsutils.ToString(users[j].UserName)
+	})
+}
+
+// sortGroups sorts the AWS groups by display name
+func sortGroups(groups []types.Group) {
+	sort.SliceStable(groups, func(i, j int) bool {
+		return awsutils.ToString(groups[i].DisplayName) < awsutils.ToString(groups[j].DisplayName)
+	})
+}
+
+// sortMemberships sorts the AWS memberships by membership id
+func sortMemberships(memberships []types.GroupMembership) {
+	sort.SliceStable(memberships, func(i, j int) bool {
+		return awsutils.ToString(memberships[i].MembershipId) < awsutils.ToString(memberships[j].MembershipId)
+	})
+}
+
+// sortedGroupNames returns the names of the groups index, sorted
+func sortedGroupNames(index map[string]*types.Group) []string {
+	res := make([]string, 0, len(index))
+	for k := range index {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// sortedUserNames returns the user names of the members, sorted
+func sortedUserNames(members map[string]*types.User) []string {
+	res := make([]string, 0, len(members))
+	for k := range members {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestProcessingOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	source := &memorySource{
+		users: []*admin.User{
+			{Id: "3", PrimaryEmail: "cy@example.com", Name: &admin.UserName{GivenName: "Cy", FamilyName: "Ng"}},
+			{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}},
+			{Id: "2", PrimaryEmail: "bo@example.com", Name: &admin.UserName{GivenName: "Bo", FamilyName: "Li"}},
+		},
+		groups: []*admin.Group{
+			{Id: "g2", Name: "Platform", Email: "platform@example.com"},
+			{Id: "g1", Name: "Finance", Email: "finance@example.com"},
+		},
+		members: map[string][]*admin.Member{
+			"g1": {{Email: "cy@example.com", Type: "USER"}, {Email: "ana@example.com", Type: "USER"}},
+			"g2": {{Email: "bo@example.com", Type: "USER"}, {Email: "cy@example.com", Type: "USER"}, {Email: "ana@example.com", Type: "USER"}},
+		},
+	}
+
+	run := func() []string {
+		var events []string
+		hook := HookFunc(func(e Event) error {
+			if e.Phase == PhasePre {
+				events = append(events, string(e.Type)+" "+e.UserName+" "+e.GroupName)
+			}
+			return nil
+		})
+		s, err := New(source, NewMemoryTarget(), Options{Hooks: []Hook{hook}})
+		assert.NoError(err)
+		assert.NoError(s.Run())
+		return events
+	}
+
+	events := run()
+	assert.Equal([]string{
+		"user_create ana@example.com ",
+		"user_create bo@example.com ",
+		"user_create cy@example.com ",
+		"group_create  Finance",
+		"group_create  Platform",
+		"member_add ana@example.com Finance",
+		"member_add cy@example.com Finance",
+		"member_add ana@example.com Platform",
+		"member_add bo@example.com Platform",
+		"member_add cy@example.com Platform",
+	}, events)
+	for i := 0; i < 5; i++ {
+		assert.Equal(events, run())
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"sort"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+)
+
+const (
+	// OrphanUnmanaged is a target user never created from the source,
+	// e.g. created by hand before ssosync was adopted, which is kept
+	OrphanUnmanaged = "unmanaged"
+	// OrphanOrphaned is a target entity created from the source which no
+	// longer exists there
+	OrphanOrphaned = "orphaned"
+	// OrphanPendingDeletion is a target entity which the next sync deletes
+	OrphanPendingDeletion = "pending deletion"
+)
+
+// Orphan is a target user or group without a synced source counterpart
+type Orphan struct {
+	// Kind is user or group
+	Kind   string
+	Name   string
+	Class  string
+	Reason string
+}
+
+// FindOrphans returns the target users and groups which have no source
+// counterpart matching the options, sorted by kind and name, without
+// changing anything
+func FindOrphans(source Source, target Target, opts Options) ([]Orphan, error) {
+	e, err := New(source, target, opts)
+	if err != nil {
+		return nil, err
+	}
+	return e.(*engine).findOrphans()
+}
+
+func (s *engine) findOrphans() ([]Orphan, error) {
+	var res []Orphan
+	if err := s.resolveIgnoreLists(); err != nil {
+		return nil, err
+	}
+
+	awsUsers, err := s.target.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	active := make(map[string]bool)
+	suspended := make(map[string]bool)
+	googleUsers, err := s.source.GetUsers(s.opts.UserMatch...)
+	if err != nil {
+		return nil, err
+	}
+	fetched := googleUsers
+	googleUsers, err = s.excludeUsers(googleUsers)
+	if err != nil {
+		return nil, err
+	}
+	// the excluded users are left alone, they are not absent
+	excluded := make(map[string]bool)
+	for _, u := range fetched {
+		excluded[u.Id] = true
+	}
+	for _, u := range googleUsers {
+		delete(excluded, u.Id)
+	}
+	for _, u := range googleUsers {
+		if s.ignoreUser(u) {
+			continue
+		}
+		name, err := s.namer.Name(u)
+		if err != nil {
+			return nil, err
+		}
+		if u.Suspended {
+			suspended[name] = true
+		} else {
+			active[name] = true
+		}
+	}
+
+	deleted := make(map[string]bool)
+	googleDeleted, err := s.source.GetDeletedUsers()
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range googleDeleted {
+		if name, err := s.namer.Name(u); err == nil {
+			deleted[name] = true
+		}
+	}
+
+	for _, u := range awsUsers {
+		name := awsutils.ToString(u.UserName)
+		o := Orphan{Kind: "user", Name: name}
+		switch {
+		case active[name]:
+			continue
+		case suspended[name]:
+			o.Class, o.Reason = OrphanPendingDeletion, "suspended in Google"
+		case deleted[name]:
+			o.Class, o.Reason = OrphanPendingDeletion, "deleted in Google"
+		case excluded[googleUserId(u)]:
+			o.Class, o.Reason = OrphanUnmanaged, "excluded from the sync, never deleted"
+		case s.opts.DeleteAbsentUsers && googleUserId(u) != "":
+			o.Class, o.Reason = OrphanPendingDeletion, "absent from the Google users"
+		case googleUserId(u) != "":
+			o.Class, o.Reason = OrphanOrphaned, "no Google user matches the user name"
+		default:
+			o.Class, o.Reason = OrphanUnmanaged, "no Google user or Google id, never deleted"
+		}
+		res = append(res, o)
+	}
+
+	awsGroups, err := s.target.GetGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := s.source.GetGroups()
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool)
+	for _, g := range all {
+		exists[s.groupPolicyOf(g).Name] = true
+	}
+
+	googleGroups, err := s.source.GetGroups(s.opts.GroupMatch...)
+	if err != nil {
+		return nil, err
+	}
+	googleGroups, err = s.excludeGroups(googleGroups)
+	if err != nil {
+		return nil, err
+	}
+	synced := make(map[string]bool)
+	for _, g := range googleGroups {
+		if !s.ignoreGroup(g) {
+			synced[s.groupPolicyOf(g).Name] = true
+		}
+	}
+
+	for _, g := range awsGroups {
+		name := awsutils.ToString(g.DisplayName)
+		switch {
+		case synced[name]:
+		case exists[name]:
+			res = append(res, Orphan{Kind: "group", Name: name, Class: OrphanPendingDeletion, Reason: "Google group is not matched by the group queries"})
+		default:
+			res = append(res, Orphan{Kind: "group", Name: name, Class: OrphanOrphaned, Reason: "no Google group matches the name"})
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Kind != res[j].Kind {
+			return res[i].Kind > res[j].Kind
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res, nil
+}
+
+// hasGoogleExternalId reports whether the user was created from Google
+func hasGoogleExternalId(u types.User) bool {
+	return googleExternalId(u) != ""
+}
+
+// googleExternalId returns the Google user id of the user, if any
+func googleExternalId(u types.User) string {
+	for _, id := range u.ExternalIds {
+		if awsutils.ToString(id.Issuer) == "Google" {
+			return awsutils.ToString(id.Id)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"sync"
+	"time"
+
+	"github.com/awslabs/ssosync/internal/logging"
+	log "github.com/sirupsen/logrus"
+)
+
+// progress is the phase of a run and the items it processed, logged by
+// the heartbeat
+type progress struct {
+	mu    sync.Mutex
+	phase string
+	done  int
+	total int
+	start time.Time
+}
+
+// begin starts the phase processing total items, zero when unknown,
+// e.g. while listing
+func (p *progress) begin(phase string, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.phase, p.done, p.total, p.start = phase, 0, total, time.Now()
+}
+
+// step counts an item of the phase as processed
+func (p *progress) step() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done++
+}
+
+// fields returns the log fields of the phase, with the estimated time
+// left once an item was processed
+func (p *progress) fields() log.Fields {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elapsed := time.Since(p.start)
+	f := log.Fields{logging.PhaseField: p.phase, "elapsed": elapsed.Round(time.Second).String()}
+	if p.total == 0 {
+		return f
+	}
+	f["done"], f["total"] = p.done, p.total
+	if p.done > 0 {
+		f["eta"] = (elapsed / time.Duration(p.done) * time.Duration(p.total-p.done)).Round(time.Second).String()
+	}
+	return f
+}
+
+// heartbeat logs the progress of the run every interval until the
+// returned function is called
+func (s *engine) heartbeat(interval time.Duration) func() {
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				log.WithFields(s.progress.fields()).Info("Sync in progress")
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// span starts the span of the phase name with Options.Trace, the
+// returned function ends it with the error err points to, e.g.
+// defer s.span("name")(&err)
+func (s *engine) span(name string) func(*error) {
+	if s.opts.Trace == nil {
+		return func(*error) {}
+	}
+	end := s.opts.Trace(name)
+	return func(err *error) { end(*err) }
+}
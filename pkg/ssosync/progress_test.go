@@ -0,0 +1,40 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	assert := assert.New(t)
+
+	var p progress
+	p.begin("list users", 0)
+	f := p.fields()
+	assert.Equal("list users", f["phase"])
+	assert.NotContains(f, "total")
+
+	p.begin("users", 4)
+	p.start = time.Now().Add(-10 * time.Second)
+	p.step()
+	f = p.fields()
+	assert.Equal(1, f["done"])
+	assert.Equal(4, f["total"])
+	assert.Equal("30s", f["eta"])
+}
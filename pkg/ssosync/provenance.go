@@ -0,0 +1,251 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	log "github.com/sirupsen/logrus"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// provenancePattern matches the provenance tag at the end of a group
+// description, e.g. [managed-by=ssosync version=v2.1.0 source=google:03x synced=2022-10-14T09:00:00Z]
+var provenancePattern = regexp.MustCompile(`\s*\[(managed-by=ssosync(?: [a-z-]+=[^ \]]*)*)\]$`)
+
+// Provenance describes how an entity was synced, recorded in the user
+// type of the users, as the Identity Store API does not write external
+// ids, and at the end of the group descriptions
+type Provenance struct {
+	// Version is the version of ssosync which last wrote the entity
+	Version string
+	// Source is the id of the source entity, e.g. google:03x
+	Source string
+	// Email is the email of the source group, with GroupKeyEmail
+	Email string
+	// Synced is when the entity was last written by ssosync
+	Synced time.Time
+}
+
+// String returns the structured representation of the provenance,
+// parsed by ParseProvenance
+func (p Provenance) String() string {
+	fields := []string{"managed-by=ssosync"}
+	if p.Version != "" {
+		fields = append(fields, "version="+p.Version)
+	}
+	if p.Source != "" {
+		fields = append(fields, "source="+p.Source)
+	}
+	if p.Email != "" {
+		fields = append(fields, "email="+p.Email)
+	}
+	if !p.Synced.IsZero() {
+		fields = append(fields, "synced="+p.Synced.UTC().Format(time.RFC3339))
+	}
+	return strings.Join(fields, " ")
+}
+
+// ParseProvenance returns the provenance recorded in a group description
+// or in the user type of a user, false when there is none
+func ParseProvenance(text string) (Provenance, bool) {
+	if m := provenancePattern.FindStringSubmatch(text); m != nil {
+		text = m[1]
+	}
+	fields := strings.Fields(text)
+	if len(fields) == 0 || fields[0] != "managed-by=ssosync" {
+		return Provenance{}, false
+	}
+
+	var p Provenance
+	for _, f := range fields[1:] {
+		k, v, _ := strings.Cut(f, "=")
+		switch k {
+		case "version":
+			p.Version = v
+		case "source":
+			p.Source = v
+		case "email":
+			p.Email = v
+		case "synced":
+			p.Synced, _ = time.Parse(time.RFC3339, v)
+		}
+	}
+	return p, true
+}
+
+// UserTypeUpdater is implemented by the targets which replace the user
+// type of an existing user, where the provenance of the users is
+// recorded, required by Options.Provenance
+type UserTypeUpdater interface {
+	UpdateUserType(u *types.User, userType *string) error
+}
+
+var errNoUserTypeUpdate = errors.New("the target does not update the user types, required by the provenance")
+
+// updateUserType replaces the user type of the target user u
+func (s *engine) updateUserType(u *types.User, userType *string) error {
+	updater, ok := s.target.(UserTypeUpdater)
+	if !ok {
+		return errNoUserTypeUpdate
+	}
+	return updater.UpdateUserType(u, userType)
+}
+
+// UserProvenance returns the provenance of the user, false when it was
+// not created nor adopted by ssosync with Options.Provenance
+func UserProvenance(u types.User) (Provenance, bool) {
+	return ParseProvenance(awsutils.ToString(u.UserType))
+}
+
+// googleUserId returns the Google user id the user was synced from,
+// recorded in its provenance or, by earlier versions, as a Google
+// external id, empty when unknown
+func googleUserId(u types.User) string {
+	if id := googleExternalId(u); id != "" {
+		return id
+	}
+	if p, ok := UserProvenance(u); ok && strings.HasPrefix(p.Source, "google:") {
+		return strings.TrimPrefix(p.Source, "google:")
+	}
+	return ""
+}
+
+// GroupProvenance returns the provenance of the group, false when its
+// description has none
+func GroupProvenance(g types.Group) (Provenance, bool) {
+	m := provenancePattern.FindStringSubmatch(awsutils.ToString(g.Description))
+	if m == nil {
+		return Provenance{}, false
+	}
+	return ParseProvenance(m[1])
+}
+
+// provenance returns the provenance of an entity written now from the
+// source entity id, empty when Options.Provenance is not set
+func (s *engine) provenance(source string) string {
+	if !s.opts.Provenance {
+		return ""
+	}
+	return Provenance{Version: s.opts.Version, Source: source, Synced: time.Now()}.String()
+}
+
+// managedUser reports whether the target user may be deleted, with
+// Options.ManagedOnly only the users created by ssosync: with its
+// provenance or, created by earlier versions, with a Google external id
+func (s *engine) managedUser(u *types.User) bool {
+	if !s.opts.ManagedOnly || hasGoogleExternalId(*u) {
+		return true
+	}
+	_, ok := UserProvenance(*u)
+	return ok
+}
+
+// managedGroup reports whether the target group may be deleted, updated
+// or have members removed, with Options.ManagedOnly only the groups
+// whose description has the ssosync provenance
+func (s *engine) managedGroup(g *types.Group) bool {
+	if !s.opts.ManagedOnly {
+		return true
+	}
+	_, ok := GroupProvenance(*g)
+	return ok
+}
+
+// adoptUser reports whether the provenance is recorded in the existing
+// target user, which has none, i.e. when all the users are managed
+// without Options.ManagedOnly, so that they stay managed once it is set
+func (s *engine) adoptUser(u *types.User) bool {
+	if !s.opts.Provenance || s.opts.ManagedOnly {
+		return false
+	}
+	_, ok := UserProvenance(*u)
+	return !ok
+}
+
+// updateProvenance records the provenance in the target user u of the
+// source user
+func (s *engine) updateProvenance(ll *log.Entry, u *types.User, source *admin.User) {
+	ll.Debug("Recording user provenance")
+	event := userEvent(EventUserUpdate, u)
+	if !s.before(event) {
+		return
+	}
+	userType := awsutils.String(s.provenance(sourceId(source.Id)))
+	err := s.updateUserType(u, userType)
+	if err != nil {
+		ll.Error("Can't record the user provenance in AWS: ", err)
+		s.report.Fail(err)
+		s.retryLater("update user "+event.UserName, event, err, func() error {
+			if err := s.updateUserType(u, userType); err != nil {
+				return err
+			}
+			u.UserType = userType
+			s.report.Inc(&s.report.UsersUpdated)
+			return nil
+		})
+		return
+	}
+	s.after(event, nil)
+	u.UserType = userType
+	s.report.Inc(&s.report.UsersUpdated)
+}
+
+// staleDescription reports whether the description of the target group
+// g differs from the description, ignoring the provenance, or lacks the
+// provenance which is recorded
+func (s *engine) staleDescription(g *types.Group, description *string) bool {
+	if stripProvenance(g.Description) != stripProvenance(description) {
+		return true
+	}
+	_, ok := GroupProvenance(*g)
+	return s.opts.Provenance && !ok
+}
+
+// withProvenance appends the provenance tag to the group description,
+// cutting the description to keep the tag within the length the
+// Identity Store allows
+func withProvenance(description *string, provenance string) *string {
+	if provenance == "" {
+		return description
+	}
+	tag := "[" + provenance + "]"
+	d := awsutils.ToString(description)
+	if d == "" {
+		return awsutils.String(tag)
+	}
+	if limit := maxAttribute - utf8.RuneCountInString(tag) - 1; utf8.RuneCountInString(d) > limit {
+		d = string([]rune(d)[:limit])
+	}
+	return awsutils.String(d + " " + tag)
+}
+
+// stripProvenance returns the group description without its provenance
+// tag, which changes on every write
+func stripProvenance(description *string) string {
+	return provenancePattern.ReplaceAllString(awsutils.ToString(description), "")
+}
+
+// sourceId returns the id of the Google entity id in the provenance
+func sourceId(id string) string {
+	return fmt.Sprintf("google:%s", id)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvenance(t *testing.T) {
+	assert := assert.New(t)
+
+	synced := time.Date(2022, 10, 14, 9, 0, 0, 0, time.UTC)
+	p := Provenance{Version: "v2.1.0", Source: sourceId("03x2g7r1"), Synced: synced}
+	assert.Equal("managed-by=ssosync version=v2.1.0 source=google:03x2g7r1 synced=2022-10-14T09:00:00Z", p.String())
+
+	parsed, ok := ParseProvenance(p.String())
+	assert.True(ok)
+	assert.Equal(p, parsed)
+	_, ok = ParseProvenance("Platform team")
+	assert.False(ok)
+
+	d := withProvenance(awsutils.String("Platform team"), p.String())
+	assert.Equal("Platform team", stripProvenance(d))
+	parsed, ok = GroupProvenance(types.Group{Description: d})
+	assert.True(ok)
+	assert.Equal("google:03x2g7r1", parsed.Source)
+
+	long := withProvenance(awsutils.String(strings.Repeat("a", 2000)), p.String())
+	assert.Len(awsutils.ToString(long), maxAttribute)
+	_, ok = GroupProvenance(types.Group{Description: long})
+	assert.True(ok)
+
+	u := types.User{UserType: awsutils.String(p.String())}
+	parsed, ok = UserProvenance(u)
+	assert.True(ok)
+	assert.Equal(synced, parsed.Synced)
+
+	s := &engine{}
+	assert.Empty(s.provenance("google:1"))
+	assert.Equal("Platform team", awsutils.ToString(withProvenance(awsutils.String("Platform team"), "")))
+}
+
+func TestManagedOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	tag := withProvenance(awsutils.String("Platform team"), Provenance{Version: "v2.1.0"}.String())
+	managed := &types.Group{Description: tag}
+	other := &types.Group{Description: awsutils.String("Platform team")}
+	legacy := &types.User{ExternalIds: []types.ExternalId{{Issuer: awsutils.String("Google"), Id: awsutils.String("1")}}}
+
+	s := &engine{opts: Options{ManagedOnly: true, Provenance: true}}
+	assert.True(s.managedGroup(managed))
+	assert.False(s.managedGroup(other))
+	assert.True(s.managedUser(legacy))
+	assert.False(s.managedUser(&types.User{}))
+
+	assert.False(s.staleDescription(managed, awsutils.String("Platform team")))
+	assert.True(s.staleDescription(managed, awsutils.String("Platform")))
+	assert.True(s.staleDescription(other, awsutils.String("Platform team")))
+
+	assert.False(s.adoptUser(&types.User{}))
+
+	s.opts.ManagedOnly = false
+	assert.True(s.managedGroup(other))
+	assert.True(s.managedUser(&types.User{}))
+	assert.True(s.adoptUser(&types.User{}))
+	assert.False(s.adoptUser(&types.User{UserType: tag}))
+}
+
+// basicTarget has only the methods of Target, none of the optional
+// capabilities
+type basicTarget struct {
+	Target
+}
+
+func TestTargetCapabilities(t *testing.T) {
+	assert := assert.New(t)
+
+	target := basicTarget{NewMemoryTarget()}
+	_, err := New(&memorySource{}, target, Options{})
+	assert.NoError(err)
+	_, err = New(&memorySource{}, target, Options{Provenance: true})
+	assert.ErrorIs(err, errNoUserTypeUpdate)
+	_, err = New(&memorySource{}, DryRun(target), Options{GroupDescription: DefaultGroupDescription})
+	assert.ErrorIs(err, errNoGroupUpdate)
+	_, err = New(&memorySource{}, DryRun(NewMemoryTarget()), Options{Provenance: true, GroupDescription: DefaultGroupDescription})
+	assert.NoError(err)
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/pkg/report"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultRemovalChunkSize is the number of users deleted between two
+// progress lines of the removal
+const DefaultRemovalChunkSize = 50
+
+// removalDelay is the delay before the first retry of a failed user
+// deletion, doubled by each following retry
+var removalDelay = time.Second
+
+// removal tracks the progress of RemoveUsers, logged every chunk of
+// users
+type removal struct {
+	chunk, total    int
+	seen            int
+	deleted, failed int
+}
+
+func (s *engine) newRemoval(total int) *removal {
+	chunk := s.opts.RemovalChunkSize
+	if chunk <= 0 {
+		chunk = DefaultRemovalChunkSize
+	}
+	return &removal{chunk: chunk, total: total}
+}
+
+// step counts a user, logging the progress of the previous chunk
+func (r *removal) step() {
+	if r.seen > 0 && r.seen%r.chunk == 0 {
+		r.log()
+	}
+	r.seen++
+}
+
+// done logs the outcome of the removal
+func (r *removal) done() {
+	if r.total > 0 {
+		r.log()
+	}
+}
+
+func (r *removal) log() {
+	ll := log.WithFields(log.Fields{
+		"deleted":   r.deleted,
+		"failed":    r.failed,
+		"remaining": r.total - r.seen,
+	})
+	if r.failed > 0 {
+		ll.Warn("Deleting users, some failed")
+		return
+	}
+	ll.Info("Deleting users")
+}
+
+// deleteUser deletes the user, retrying the transient failures up to
+// RemovalRetries times. A user which is already gone counts as deleted.
+func (s *engine) deleteUser(u *types.User) error {
+	delay := removalDelay
+	for attempt := 0; ; attempt++ {
+		err := s.target.DeleteUser(u)
+		cause := report.Cause(err)
+		switch {
+		case err == nil:
+			return nil
+		case cause == report.CauseNotFound:
+			log.WithField("userId", u.UserId).Debug("User already deleted")
+			return nil
+		case attempt >= s.opts.RemovalRetries || !transient(cause):
+			return err
+		}
+		log.WithField("userId", u.UserId).WithField("delay", delay).WithError(err).Warn("Can't delete user, retrying")
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// transient reports whether a failure of the cause may succeed when
+// retried
+func transient(cause string) bool {
+	switch cause {
+	case report.CauseThrottling, report.CauseTimeout, report.CauseOther:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/awslabs/ssosync/internal/aws"
+	awsmock "github.com/awslabs/ssosync/internal/aws/mock"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/aws/smithy-go"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func apiError(code string) error {
+	return &aws.OperationError{Op: "DeleteUser", Err: &smithy.GenericAPIError{Code: code}}
+}
+
+func TestRemoveUsers(t *testing.T) {
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	removalDelay = 0
+
+	users := []*types.User{
+		{UserId: awsutils.String("u1"), UserName: awsutils.String("ana@example.com")},
+		{UserId: awsutils.String("u2"), UserName: awsutils.String("bo@example.com")},
+		{UserId: awsutils.String("u3"), UserName: awsutils.String("cy@example.com")},
+		{UserId: awsutils.String("u4"), UserName: awsutils.String("di@example.com")},
+	}
+	target := awsmock.NewMockClient(ctrl)
+	gomock.InOrder(
+		target.EXPECT().DeleteUser(users[0]).Return(apiError("AccessDeniedException")),
+		target.EXPECT().DeleteUser(users[1]).Return(apiError("ThrottlingException")),
+		target.EXPECT().DeleteUser(users[1]).Return(nil),
+		target.EXPECT().DeleteUser(users[2]).Return(apiError("ResourceNotFoundException")),
+		target.EXPECT().DeleteUser(users[3]).Return(errors.New("connection reset")).Times(3),
+	)
+
+	s, err := New(nil, target, Options{RemovalChunkSize: 2, RemovalRetries: 2})
+	assert.NoError(err)
+	assert.NoError(s.RemoveUsers(users))
+
+	r := s.Report()
+	assert.Equal(2, r.UsersDeleted)
+	assert.Equal(2, r.Errors)
+	failures := r.Summary().RemovalFailures
+	assert.Len(failures, 2)
+	assert.Contains(failures["ana@example.com"], "AccessDeniedException")
+	assert.Contains(failures, "di@example.com")
+	assert.Equal(map[string]int{"access-denied": 1, "other": 1}, r.ErrorCauses())
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"time"
+
+	"github.com/awslabs/ssosync/pkg/report"
+	log "github.com/sirupsen/logrus"
+)
+
+// failure is an operation which failed transiently during the run,
+// retried once by the final pass
+type failure struct {
+	// desc describes the operation in the logs, e.g. create user name
+	desc string
+	err  error
+	// retry applies the operation again, counting it when it succeeds
+	retry func() error
+	// event is the change whose post event is emitted once the retry is
+	// resolved
+	event Event
+	// userName is the user whose deletion failed, empty for the other
+	// operations
+	userName string
+}
+
+// retryLater queues the operation of the change e, which failed with
+// err, for the final pass of the run when Options.RetryFailed and the
+// failure is transient. The post event of e is emitted once, when the
+// failure is final or once the retry is resolved.
+func (s *engine) retryLater(desc string, e Event, err error, retry func() error) {
+	if s.opts.RetryFailed && transient(report.Cause(err)) {
+		s.failures = append(s.failures, failure{desc: desc, err: err, retry: retry, event: e})
+		return
+	}
+	s.after(e, err)
+}
+
+// retryRemoval queues the deletion of the user of the change e like
+// retryLater
+func (s *engine) retryRemoval(e Event, err error, retry func() error) {
+	if s.opts.RetryFailed && transient(report.Cause(err)) {
+		s.failures = append(s.failures, failure{desc: "delete user " + e.UserName, err: err, retry: retry, event: e, userName: e.UserName})
+		return
+	}
+	s.after(e, err)
+}
+
+// retryFailures retries the queued operations once, after
+// Options.RetryDelay. The operations which succeed are no longer counted
+// as errors, so that the run is only partial when some still fail. No
+// operation is retried once the deadline of the run passed, nor when it
+// passes before the delay ends.
+func (s *engine) retryFailures() {
+	if len(s.failures) == 0 {
+		return
+	}
+	if !s.opts.Deadline.IsZero() && time.Now().Add(s.opts.RetryDelay).After(s.opts.Deadline) {
+		log.WithField("count", len(s.failures)).Warn("Max runtime elapses before the retry delay, the operations which failed are not retried")
+		return
+	}
+	failures := s.failures
+	s.failures = nil
+
+	s.progress.begin("retry failures", len(failures))
+	log.WithField("count", len(failures)).WithField("delay", s.opts.RetryDelay).Info("Retrying the operations which failed")
+	time.Sleep(s.opts.RetryDelay)
+	for i, f := range failures {
+		if s.expired() {
+			s.failures = failures[i:]
+			log.WithField("count", len(s.failures)).Warn("Max runtime elapsed, the remaining operations which failed are not retried")
+			return
+		}
+		s.progress.step()
+		ll := log.WithField("operation", f.desc)
+		err := f.retry()
+		s.after(f.event, err)
+		if err != nil {
+			ll.Error("Operation failed again: ", err)
+			continue
+		}
+		ll.Info("Operation succeeded when retried")
+		if f.userName != "" {
+			s.report.RecoverRemoval(f.userName, f.err)
+		} else {
+			s.report.Recover(f.err)
+		}
+	}
+}
+
+// abandonFailures emits the post events of the operations still queued,
+// not retried as the run ended before the final pass
+func (s *engine) abandonFailures() {
+	for _, f := range s.failures {
+		s.after(f.event, f.err)
+	}
+	s.failures = nil
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/pkg/report"
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// flakyTarget is a MemoryTarget whose first user creation is throttled
+type flakyTarget struct {
+	*MemoryTarget
+	throttled bool
+}
+
+func (t *flakyTarget) CreateUser(u *types.User) (*types.User, error) {
+	if !t.throttled {
+		t.throttled = true
+		return nil, apiError("ThrottlingException")
+	}
+	return t.MemoryTarget.CreateUser(u)
+}
+
+func (t *flakyTarget) FindUserByUserName(name string) (*types.User, error) {
+	return nil, apiError("ResourceNotFoundException")
+}
+
+func TestRetryFailed(t *testing.T) {
+	assert := assert.New(t)
+
+	source := &memorySource{
+		users: []*admin.User{
+			{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}},
+			{Id: "2", PrimaryEmail: "bo@example.com", Name: &admin.UserName{GivenName: "Bo", FamilyName: "Li"}},
+		},
+	}
+
+	s, err := New(source, &flakyTarget{MemoryTarget: NewMemoryTarget()}, Options{RetryFailed: true})
+	assert.NoError(err)
+	assert.NoError(s.Run())
+	r := s.Report()
+	r.Finish(nil)
+	assert.Equal(2, r.UsersCreated)
+	assert.Equal(0, r.Errors)
+	assert.Equal(1, r.Retried)
+	assert.Equal(report.ResultOK, r.Result)
+	assert.Empty(r.ErrorCauses())
+
+	// without the final pass the run is partial
+	s, _ = New(source, &flakyTarget{MemoryTarget: NewMemoryTarget()}, Options{})
+	assert.NoError(s.Run())
+	r = s.Report()
+	r.Finish(nil)
+	assert.Equal(1, r.UsersCreated)
+	assert.Equal(report.ResultPartial, r.Result)
+	assert.Equal(map[string]int{report.CauseThrottling: 1}, r.ErrorCauses())
+}
+
+func TestRetryFailedEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	source := &memorySource{
+		users: []*admin.User{
+			{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}},
+		},
+	}
+
+	// a single post event per operation, once the retry is resolved,
+	// also when the run ends without the final pass
+	for _, retry := range []bool{true, false} {
+		var post []Event
+		hook := HookFunc(func(e Event) error {
+			if e.Phase == PhasePost {
+				post = append(post, e)
+			}
+			return nil
+		})
+		s, err := New(source, &flakyTarget{MemoryTarget: NewMemoryTarget()}, Options{RetryFailed: retry, Hooks: []Hook{hook}})
+		assert.NoError(err)
+		assert.NoError(s.Run())
+		if assert.Len(post, 1) {
+			assert.Equal(EventUserCreate, post[0].Type)
+			assert.Equal(!retry, post[0].Error != "")
+		}
+	}
+}
+
+func TestRetryFailedDeadline(t *testing.T) {
+	assert := assert.New(t)
+
+	source := &memorySource{
+		users: []*admin.User{
+			{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}},
+		},
+	}
+
+	// the delay would end after the deadline, the retry pass is skipped
+	var post []Event
+	hook := HookFunc(func(e Event) error {
+		if e.Phase == PhasePost {
+			post = append(post, e)
+		}
+		return nil
+	})
+	start := time.Now()
+	s, err := New(source, &flakyTarget{MemoryTarget: NewMemoryTarget()}, Options{
+		RetryFailed: true,
+		RetryDelay:  time.Hour,
+		Deadline:    start.Add(time.Minute),
+		Hooks:       []Hook{hook},
+	})
+	assert.NoError(err)
+	assert.NoError(s.Run())
+	assert.Less(time.Since(start), time.Minute)
+	r := s.Report()
+	assert.Equal(0, r.UsersCreated)
+	assert.Equal(0, r.Retried)
+	assert.Equal(1, r.Errors)
+	if assert.Len(post, 1) {
+		assert.NotEmpty(post[0].Error)
+	}
+}
+
+// goneTarget is a MemoryTarget whose user deletions are throttled, the
+// throttled deletion was applied nonetheless
+type goneTarget struct {
+	*MemoryTarget
+	throttled bool
+}
+
+func (t *goneTarget) DeleteUser(u *types.User) error {
+	if !t.throttled {
+		t.throttled = true
+		t.MemoryTarget.DeleteUser(u)
+		return apiError("ThrottlingException")
+	}
+	return apiError("ResourceNotFoundException")
+}
+
+func TestRetryRemovalNotFound(t *testing.T) {
+	assert := assert.New(t)
+
+	ana := &admin.User{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}}
+	target := NewMemoryTarget()
+	opts := Options{Provenance: true, RetryFailed: true}
+	s, err := New(&memorySource{users: []*admin.User{ana}}, target, opts)
+	assert.NoError(err)
+	assert.NoError(s.Run())
+
+	// the retried deletion finds the user already deleted
+	s, err = New(&memorySource{deleted: []*admin.User{ana}}, &goneTarget{MemoryTarget: target}, opts)
+	assert.NoError(err)
+	assert.NoError(s.Run())
+	r := s.Report()
+	r.Finish(nil)
+	assert.Equal(1, r.UsersDeleted)
+	assert.Equal(1, r.Retried)
+	assert.Equal(0, r.Errors)
+	assert.Equal(report.ResultOK, r.Result)
+}
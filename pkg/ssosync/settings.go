@@ -0,0 +1,82 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/awslabs/ssosync/pkg/report"
+	log "github.com/sirupsen/logrus"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// GroupSettings are the settings of a source group controlling who can
+// become a member, read with Options.GroupSettings
+type GroupSettings struct {
+	// AllowExternalMembers is set when users outside of the organization
+	// can be members
+	AllowExternalMembers bool
+	// WhoCanJoin is the Google Groups join policy, e.g. ANYONE_CAN_JOIN
+	WhoCanJoin string
+}
+
+// Risks returns the settings letting unvetted users become members:
+// external-members, anyone-can-join and domain-can-join
+func (gs GroupSettings) Risks() []string {
+	var risks []string
+	if gs.AllowExternalMembers {
+		risks = append(risks, "external-members")
+	}
+	switch gs.WhoCanJoin {
+	case "ANYONE_CAN_JOIN":
+		risks = append(risks, "anyone-can-join")
+	case "ALL_IN_DOMAIN_CAN_JOIN":
+		risks = append(risks, "domain-can-join")
+	}
+	sort.Strings(risks)
+	return risks
+}
+
+// auditSettings records the settings of the Google group synced to the
+// group name, and flags the privileged groups with risky settings
+func (s *engine) auditSettings(ll *log.Entry, g *admin.Group, name string) {
+	if s.opts.GroupSettings == nil {
+		return
+	}
+	gs, err := s.opts.GroupSettings(g)
+	if err != nil {
+		ll.WithError(err).Error("Can't read the Google group settings")
+		s.report.Fail(err)
+		return
+	}
+
+	entry := report.GroupSettings{
+		Group:                name,
+		Email:                g.Email,
+		AllowExternalMembers: gs.AllowExternalMembers,
+		WhoCanJoin:           gs.WhoCanJoin,
+		Privileged:           matchAny(s.opts.PrivilegedGroups, name),
+		Risks:                gs.Risks(),
+	}
+	s.report.AddGroupSettings(entry)
+	if !entry.Privileged || len(entry.Risks) == 0 {
+		return
+	}
+	s.report.Inc(&s.report.RiskyGroups)
+	s.notice("settings:"+name, "risky:"+strings.Join(entry.Risks, ","), log.WarnLevel,
+		ll.WithField("email", g.Email).WithField("risks", entry.Risks),
+		"Privileged group has Google settings letting unvetted users join")
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestGroupSettings(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(GroupSettings{WhoCanJoin: "INVITED_CAN_JOIN"}.Risks())
+	assert.Equal([]string{"anyone-can-join", "external-members"}, GroupSettings{AllowExternalMembers: true, WhoCanJoin: "ANYONE_CAN_JOIN"}.Risks())
+
+	source := &memorySource{
+		groups: []*admin.Group{
+			{Id: "g1", Name: "aws-admins", Email: "aws-admins@example.com"},
+			{Id: "g2", Name: "aws-readers", Email: "aws-readers@example.com"},
+			{Id: "g3", Name: "aws-auditors", Email: "aws-auditors@example.com"},
+		},
+	}
+	settings := map[string]GroupSettings{
+		"aws-admins@example.com":   {WhoCanJoin: "ALL_IN_DOMAIN_CAN_JOIN"},
+		"aws-readers@example.com":  {AllowExternalMembers: true, WhoCanJoin: "ANYONE_CAN_JOIN"},
+		"aws-auditors@example.com": {WhoCanJoin: "INVITED_CAN_JOIN"},
+	}
+	s, err := New(source, NewMemoryTarget(), Options{
+		GroupSettings: func(g *admin.Group) (GroupSettings, error) {
+			return settings[g.Email], nil
+		},
+		PrivilegedGroups: []string{"aws-admins", "aws-auditors"},
+	})
+	assert.NoError(err)
+	assert.NoError(s.Run())
+
+	// only the privileged group with risky settings is flagged
+	r := s.Report()
+	assert.Equal(1, r.RiskyGroups)
+	gs := r.GroupSettings()
+	assert.Len(gs, 3)
+	assert.Equal("aws-admins", gs[0].Group)
+	assert.True(gs[0].Privileged)
+	assert.Equal([]string{"domain-can-join"}, gs[0].Risks)
+	assert.Equal("risky:domain-can-join", r.Entities()["settings:aws-admins"])
+	assert.False(gs[2].Privileged)
+	assert.Len(gs[2].Risks, 2)
+}
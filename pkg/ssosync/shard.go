@@ -0,0 +1,45 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"hash/fnv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// inShard reports whether key, an email or a group name, belongs to the
+// shard of the run. The keys are hashed case insensitively, so that
+// every run assigns a key to the same shard.
+func (s *engine) inShard(key string) bool {
+	if s.opts.Shards <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(key)))
+	return int(h.Sum32()%uint32(s.opts.Shards)) == s.opts.Shard-1
+}
+
+// userInShard reports whether the target user belongs to the shard of
+// the run, by the email of its source user
+func (s *engine) userInShard(u *types.User) bool {
+	if email := primaryEmail(u); email != "" {
+		return s.inShard(email)
+	}
+	return s.inShard(awsutils.ToString(u.UserName))
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestShards(t *testing.T) {
+	assert := assert.New(t)
+
+	source := &memorySource{members: map[string][]*admin.Member{}}
+	for i := 0; i < 20; i++ {
+		email := fmt.Sprintf("user%d@example.com", i)
+		source.users = append(source.users, &admin.User{Id: fmt.Sprint(i), PrimaryEmail: email, Name: &admin.UserName{GivenName: "User", FamilyName: fmt.Sprint(i)}})
+	}
+	for i := 0; i < 6; i++ {
+		id := fmt.Sprintf("g%d", i)
+		source.groups = append(source.groups, &admin.Group{Id: id, Name: fmt.Sprintf("Group %d", i), Email: fmt.Sprintf("group%d@example.com", i)})
+		for j := i; j < 20; j += 3 {
+			source.members[id] = append(source.members[id], &admin.Member{Email: fmt.Sprintf("user%d@example.com", j), Type: "USER"})
+		}
+	}
+
+	full := NewMemoryTarget()
+	s, err := New(source, full, Options{})
+	assert.NoError(err)
+	assert.NoError(s.Run())
+
+	sharded := NewMemoryTarget()
+	// a group absent from Google is deleted by the run of one shard
+	_, err = sharded.CreateGroup(awsutils.String("Stale"), nil)
+	assert.NoError(err)
+	deleted := 0
+	created := 0
+	for run := 0; run < 2; run++ {
+		for i := 1; i <= 3; i++ {
+			s, err := New(source, sharded, Options{Shard: i, Shards: 3})
+			assert.NoError(err)
+			assert.NoError(s.Run())
+			created += s.Report().UsersCreated
+			deleted += s.Report().GroupsDeleted
+		}
+	}
+	// every user is created once, by the run of its shard, and the
+	// members created by a later shard are added by the next runs
+	assert.Equal(20, created)
+	assert.Equal(1, deleted)
+	assert.Equal(snapshot(full), snapshot(sharded))
+
+	_, err = New(source, sharded, Options{Shard: 4, Shards: 3})
+	assert.Error(err)
+}
+
+// snapshot returns the groups of the target with their member names
+func snapshot(m *MemoryTarget) map[string][]string {
+	res := make(map[string][]string)
+	users, _ := m.GetUsers()
+	names := make(map[string]string)
+	for _, u := range users {
+		names[awsutils.ToString(u.UserId)] = awsutils.ToString(u.UserName)
+		res[""] = append(res[""], awsutils.ToString(u.UserName))
+	}
+	groups, _ := m.GetGroups()
+	for i := range groups {
+		name := awsutils.ToString(groups[i].DisplayName)
+		res[name] = []string{}
+		ms, _ := m.GetGroupMembers(&groups[i])
+		for j := range ms {
+			res[name] = append(res[name], names[memberId(&ms[j])])
+		}
+		sort.Strings(res[name])
+	}
+	return res
+}
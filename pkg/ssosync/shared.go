@@ -0,0 +1,93 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"strings"
+	"sync"
+
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// SharedSource returns a Source reading from source once per query, for
+// the engines applying the same run to several targets concurrently. The
+// users, groups and members returned are shared, they must not be
+// modified.
+func SharedSource(source Source) Source {
+	return &sharedSource{source: source, calls: make(map[string]*sharedCall)}
+}
+
+// sharedSource is a Source memoizing the calls of its source
+type sharedSource struct {
+	source Source
+
+	mu    sync.Mutex
+	calls map[string]*sharedCall
+}
+
+// sharedCall is the result of a call, once done
+type sharedCall struct {
+	once    sync.Once
+	users   []*admin.User
+	groups  []*admin.Group
+	members []*admin.Member
+	err     error
+}
+
+// call returns the call of key, made by fn the first time
+func (s *sharedSource) call(key string, fn func(c *sharedCall)) *sharedCall {
+	s.mu.Lock()
+	c, ok := s.calls[key]
+	if !ok {
+		c = &sharedCall{}
+		s.calls[key] = c
+	}
+	s.mu.Unlock()
+
+	c.once.Do(func() { fn(c) })
+	return c
+}
+
+// GetUsers returns the users matching the queries
+func (s *sharedSource) GetUsers(queries ...string) ([]*admin.User, error) {
+	c := s.call("users\x00"+strings.Join(queries, "\x00"), func(c *sharedCall) {
+		c.users, c.err = s.source.GetUsers(queries...)
+	})
+	return c.users, c.err
+}
+
+// GetDeletedUsers returns the deleted users
+func (s *sharedSource) GetDeletedUsers() ([]*admin.User, error) {
+	c := s.call("deleted", func(c *sharedCall) {
+		c.users, c.err = s.source.GetDeletedUsers()
+	})
+	return c.users, c.err
+}
+
+// GetGroups returns the groups matching the queries
+func (s *sharedSource) GetGroups(queries ...string) ([]*admin.Group, error) {
+	c := s.call("groups\x00"+strings.Join(queries, "\x00"), func(c *sharedCall) {
+		c.groups, c.err = s.source.GetGroups(queries...)
+	})
+	return c.groups, c.err
+}
+
+// GetGroupMembers returns the members of the group
+func (s *sharedSource) GetGroupMembers(g *admin.Group) ([]*admin.Member, error) {
+	c := s.call("members\x00"+g.Id, func(c *sharedCall) {
+		c.members, c.err = s.source.GetGroupMembers(g)
+	})
+	return c.members, c.err
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// readCounter counts the reads of its source
+type readCounter struct {
+	Source
+	calls int32
+}
+
+func (c *readCounter) GetUsers(q ...string) ([]*admin.User, error) {
+	atomic.AddInt32(&c.calls, 1)
+	return c.Source.GetUsers(q...)
+}
+
+func (c *readCounter) GetGroupMembers(g *admin.Group) ([]*admin.Member, error) {
+	atomic.AddInt32(&c.calls, 1)
+	return c.Source.GetGroupMembers(g)
+}
+
+func TestSharedSource(t *testing.T) {
+	assert := assert.New(t)
+
+	counting := &readCounter{Source: &memorySource{
+		users:   []*admin.User{{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}}},
+		groups:  []*admin.Group{{Id: "g1", Name: "Platform", Email: "platform@example.com"}},
+		members: map[string][]*admin.Member{"g1": {{Email: "ana@example.com"}}},
+	}}
+	source := SharedSource(counting)
+
+	// the same run applied to two targets concurrently reads the source once
+	targets := []*MemoryTarget{NewMemoryTarget(), NewMemoryTarget()}
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target *MemoryTarget) {
+			defer wg.Done()
+			s, err := New(source, target, Options{})
+			assert.NoError(err)
+			assert.NoError(s.Run())
+			assert.Equal(1, s.Report().MembershipsAdded)
+		}(target)
+	}
+	wg.Wait()
+
+	// as many reads as a single run
+	calls := atomic.LoadInt32(&counting.calls)
+	s, _ := New(SharedSource(counting), NewMemoryTarget(), Options{})
+	assert.NoError(s.Run())
+	assert.Equal(calls, atomic.LoadInt32(&counting.calls)-calls)
+}
+
+func TestSharedSourceOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	// the source returns the users and groups unsorted
+	users := []*admin.User{
+		{Id: "3", PrimaryEmail: "rui@example.com", Name: &admin.UserName{GivenName: "Rui", FamilyName: "Costa"}},
+		{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}},
+		{Id: "2", PrimaryEmail: "joao@example.com", Name: &admin.UserName{GivenName: "Joao", FamilyName: "Sousa"}},
+	}
+	groups := []*admin.Group{
+		{Id: "g2", Name: "Sales", Email: "sales@example.com"},
+		{Id: "g1", Name: "Platform", Email: "platform@example.com"},
+	}
+	source := SharedSource(&memorySource{
+		users:  users,
+		groups: groups,
+		members: map[string][]*admin.Member{
+			"g1": {{Email: "rui@example.com"}, {Email: "ana@example.com"}},
+			"g2": {{Email: "joao@example.com"}},
+		},
+	})
+
+	// the engines of a fan-out sort the shared data concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := New(source, NewMemoryTarget(), Options{})
+			assert.NoError(err)
+			assert.NoError(s.Run())
+			assert.Equal(3, s.Report().MembershipsAdded)
+		}()
+	}
+	wg.Wait()
+
+	// the shared data is left in the order of the source
+	assert.Equal("3", users[0].Id)
+	assert.Equal("g2", groups[0].Id)
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ssosync is the engine reconciling the users, groups and group
+// memberships of a source directory into a target identity store. The
+// ssosync command uses Google Workspace as the source and the AWS
+// IAM Identity Store as the target, other programs can embed the engine
+// with their own implementations of Source and Target.
+package ssosync
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/pkg/report"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// Source is the directory the users and groups are read from, the
+// queries use the Google Admin SDK search syntax
+type Source interface {
+	GetUsers(...string) ([]*admin.User, error)
+	GetDeletedUsers() ([]*admin.User, error)
+	GetGroups(...string) ([]*admin.Group, error)
+	GetGroupMembers(*admin.Group) ([]*admin.Member, error)
+}
+
+// Target is the identity store the users and groups are written to. The
+// optional capabilities, e.g. GroupUpdater, are separate interfaces
+// detected on the target.
+type Target interface {
+	CreateUser(*types.User) (*types.User, error)
+	DeleteUser(*types.User) error
+	DeleteGroup(*types.Group) error
+	CreateGroup(name *string, description *string) (*types.Group, error)
+	AddUserToGroup(*types.User, *types.Group) (*types.GroupMembership, error)
+	RemoveGroupMembership(membership *types.GroupMembership) error
+	GetGroupMembers(*types.Group) ([]types.GroupMembership, error)
+	GetGroups() ([]types.Group, error)
+	GetUsers() ([]types.User, error)
+	FindUserByUserName(string) (*types.User, error)
+	FindGroupByDisplayName(string) (*types.Group, error)
+}
+
+// Engine is the interface for synchronizing users/groups
+type Engine interface {
+	// Run syncs the users and groups matching the queries of the
+	// options and removes the deleted users, the steps below in order
+	Run() error
+	SyncUsers([]string) (*UserSyncResult, error)
+	SyncGroups([]string, *UserSyncResult) error
+	RemoveUsers([]*types.User) error
+	Report() *Report
+	// Pending returns the destructive changes deferred by the run with
+	// Options.DeferDeletions or Options.RequireApproval, to be passed as
+	// Options.Pending to the next
+	Pending() map[string]time.Time
+}
+
+// Options configure an Engine, the zero value syncs all users and groups
+type Options struct {
+	// UserMatch are the user queries, users matching any of them are synced
+	UserMatch []string
+	// GroupMatch are the group queries, groups matching any of them are synced
+	GroupMatch []string
+	// UserExcludeMatch are user queries whose results are not synced
+	UserExcludeMatch []string
+	// GroupExcludeMatch are group queries whose results are not synced
+	GroupExcludeMatch []string
+	// IgnoreUsers are the emails of users which are never synced
+	IgnoreUsers []string
+	// IgnoreGroups are the emails of groups which are never synced
+	IgnoreGroups []string
+	// IgnoreUsersGroup is the email of a source group whose user members
+	// are ignored, in addition to IgnoreUsers
+	IgnoreUsersGroup string
+	// IgnoreGroupsGroup is the email of a source group whose member
+	// groups, and itself, are ignored, in addition to IgnoreGroups
+	IgnoreGroupsGroup string
+	// GroupDescription is the Go template of the description of the
+	// groups without a Google description, with .Name, .Email and .Id of
+	// the Google group. The descriptions of the existing groups are kept
+	// updated when set.
+	GroupDescription string
+	// Provenance records the version, the source id and the time of the
+	// sync in the user type of the created users and at the end of the
+	// group descriptions, see ParseProvenance
+	Provenance bool
+	// Version is the version of ssosync recorded by Provenance
+	Version string
+	// ManagedOnly restricts the deletions, the description updates and
+	// the member removals to the users and groups created by ssosync,
+	// i.e. with its provenance, for the coexistence with other tools
+	ManagedOnly bool
+	// ExcludeSystemGroups excludes the groups which look managed for the
+	// whole organization, e.g. the all-staff target audiences
+	ExcludeSystemGroups bool
+	// SystemGroups are the emails of the groups synced although they look
+	// system managed
+	SystemGroups []string
+	// UnmanagedMembershipGroups are target group names or shell patterns of
+	// groups whose members are never removed
+	UnmanagedMembershipGroups []string
+	// ProtectedUsers are target user names or shell patterns of users which
+	// are never deleted nor removed from a group, overriding everything else
+	ProtectedUsers []string
+	// GroupSettings reads the settings of the source groups, which are
+	// recorded in the report when set
+	GroupSettings func(*admin.Group) (GroupSettings, error)
+	// PrivilegedGroups are target group names or shell patterns of the
+	// groups flagged when their settings let unvetted users join
+	PrivilegedGroups []string
+	// ProtectedGroups are target group names or shell patterns of groups
+	// which are never deleted nor have members removed
+	ProtectedGroups []string
+	// SkipDeletedUsers disables fetching the deleted source users, only
+	// the suspended users are then deleted
+	SkipDeletedUsers bool
+	// DeleteAbsentUsers deletes the target users with a Google external
+	// id which are missing from the source users matching UserMatch
+	DeleteAbsentUsers bool
+	// GroupDescriptionTags honors the tags of the Google group descriptions:
+	// [ssosync:skip], [ssosync:membership-only] and [ssosync:name=Name]
+	GroupDescriptionTags bool
+	// Frozen defers the destructive changes during a change freeze: the
+	// deletions of users and groups and the removals of members are
+	// logged and counted as deferred but not applied
+	Frozen bool
+	// DeferDeletions defers the destructive changes until a later run
+	// confirms them, at least DeletionDelay after the first run
+	DeferDeletions bool
+	// DeletionDelay is the minimum delay of the deferred changes, they
+	// are applied by the next run when zero
+	DeletionDelay time.Duration
+	// RemovalChunkSize is the number of users deleted between two
+	// progress lines of the removal, DefaultRemovalChunkSize when 0
+	RemovalChunkSize int
+	// RemovalRetries is the number of retries of a user deletion failing
+	// with a transient error, e.g. throttling, none when 0
+	RemovalRetries int
+	// RetryFailed retries the operations which failed transiently, e.g.
+	// throttled, once at the end of the run, after RetryDelay
+	RetryFailed bool
+	// RetryDelay is the delay before the final retry pass
+	RetryDelay time.Duration
+	// Verify re-reads the target at the end of the run and reports the
+	// applied changes it does not reflect as errors
+	Verify bool
+	// RequireApproval defers the destructive changes until they are
+	// listed in Approved
+	RequireApproval bool
+	// Pending are the changes deferred by the previous run, with the time
+	// they were first deferred
+	Pending map[string]time.Time
+	// Approved are the keys of the pending changes approved by an operator
+	Approved map[string]bool
+	// UserNameTemplate renders the target user names of the source users,
+	// the primary email when empty
+	UserNameTemplate string
+	// UserNameCollision is the policy applied when the user name template
+	// maps several users to the same name: fail, skip or suffix
+	UserNameCollision string
+	// EmailPolicy is the policy applied to the users with an invalid
+	// primary email: skip, truncate or alias, see EmailSkip
+	EmailPolicy string
+	// GroupNamePolicy is the policy applied to the groups whose name is
+	// not a valid target group name: transform or skip, see
+	// GroupNameTransform, transform when empty
+	GroupNamePolicy string
+	// GroupKey matches the target groups to the source groups by display
+	// name or by email, see GroupKeyEmail, by name when empty
+	GroupKey string
+	// GroupNameMaxLength is the maximum length of the target group
+	// names, the Identity Store limit when 0
+	GroupNameMaxLength int
+	// MemberLimit is the membership count limit of the target groups,
+	// none when 0
+	MemberLimit int
+	// OverflowGroups adds the members above the MemberLimit to the
+	// numbered overflow groups, e.g. group-2, instead of dropping them
+	OverflowGroups bool
+	// Previous are the entity states noticed by the previous run, by key,
+	// the notices repeating them are logged at debug level only
+	Previous map[string]string
+	// Hooks are called before and after every change of the target
+	Hooks []Hook
+	// Trace starts a span of the named phase of the run, e.g. for
+	// tracing, the returned function ends it with the error of the phase
+	Trace func(name string) func(error)
+	// Heartbeat is the interval at which the phase and progress of the
+	// run are logged, never when zero
+	Heartbeat time.Duration
+	// Shard is the shard of the directory synced by the run, from 1 to
+	// Shards. The users and groups are assigned to the shards by the hash
+	// of their email, so that several runs can sync a slice each
+	// concurrently. The whole directory is synced when Shards is 0 or 1.
+	Shard  int
+	Shards int
+	// Deadline is the time after which the run starts no further change,
+	// the changes in flight are completed and the first change not
+	// started is reported as the checkpoint. None when zero.
+	Deadline time.Time
+}
+
+// Report is the statistics of a run, its results and error causes are
+// in package report
+type Report = report.Report
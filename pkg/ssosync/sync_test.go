@@ -0,0 +1,277 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"errors"
+	"testing"
+
+	awsmock "github.com/awslabs/ssosync/internal/aws/mock"
+	googlemock "github.com/awslabs/ssosync/internal/google/mock"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+var (
+	ana      = &admin.User{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}}
+	anaAWS   = types.User{UserId: awsutils.String("u1"), UserName: awsutils.String("ana@example.com")}
+	boAWS    = types.User{UserId: awsutils.String("u2"), UserName: awsutils.String("bo@example.com")}
+	platform = types.Group{GroupId: awsutils.String("g1"), DisplayName: awsutils.String("Platform")}
+)
+
+func suspended(u *admin.User) *admin.User {
+	c := *u
+	c.Suspended = true
+	return &c
+}
+
+func membership(id string, u types.User) types.GroupMembership {
+	return types.GroupMembership{
+		MembershipId: awsutils.String(id),
+		GroupId:      platform.GroupId,
+		MemberId:     &types.MemberIdMemberUserId{Value: awsutils.ToString(u.UserId)},
+	}
+}
+
+// syncedUsers returns the result of a user sync that found the AWS users
+func syncedUsers(users ...types.User) *UserSyncResult {
+	r := &UserSyncResult{
+		index:         make(map[string]*types.User),
+		indexByUserId: make(map[string]*types.User),
+		names:         make(map[string]string),
+	}
+	for _, u := range users {
+		user := u
+		r.index[awsutils.ToString(u.UserName)] = &user
+		r.indexByUserId[awsutils.ToString(u.UserId)] = &user
+	}
+	return r
+}
+
+func TestSyncUsers(t *testing.T) {
+	tests := []struct {
+		name     string
+		aws      []types.User
+		google   []*admin.User
+		deleted  []*admin.User
+		expect   func(target *awsmock.MockClient)
+		created  int
+		errors   int
+		toDelete int
+		err      bool
+	}{
+		{
+			name:   "new user is created",
+			google: []*admin.User{ana},
+			expect: func(target *awsmock.MockClient) {
+				target.EXPECT().CreateUser(gomock.Any()).Return(&anaAWS, nil)
+			},
+			created: 1,
+		},
+		{
+			name:   "existing user is left alone",
+			aws:    []types.User{anaAWS},
+			google: []*admin.User{ana},
+		},
+		{
+			name:     "suspended user is deleted",
+			aws:      []types.User{anaAWS},
+			google:   []*admin.User{suspended(ana)},
+			toDelete: 1,
+		},
+		{
+			name:   "suspended user is not created",
+			google: []*admin.User{suspended(ana)},
+		},
+		{
+			name:     "deleted user is deleted",
+			aws:      []types.User{anaAWS},
+			deleted:  []*admin.User{ana},
+			toDelete: 1,
+		},
+		{
+			name:    "deleted user already gone",
+			deleted: []*admin.User{ana},
+		},
+		{
+			name:   "conflicting user is adopted",
+			google: []*admin.User{ana},
+			expect: func(target *awsmock.MockClient) {
+				target.EXPECT().CreateUser(gomock.Any()).Return(nil, apiError("ConflictException"))
+				target.EXPECT().FindUserByUserName("ana@example.com").Return(&anaAWS, nil)
+			},
+		},
+		{
+			name:   "failed create is an error",
+			google: []*admin.User{ana},
+			expect: func(target *awsmock.MockClient) {
+				target.EXPECT().CreateUser(gomock.Any()).Return(nil, apiError("AccessDeniedException"))
+			},
+			errors: 1,
+		},
+		{
+			name: "failed listing is returned",
+			expect: func(target *awsmock.MockClient) {
+				target.EXPECT().GetUsers().Return(nil, errors.New("ThrottlingException"))
+			},
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			source := googlemock.NewMockClient(ctrl)
+			target := awsmock.NewMockClient(ctrl)
+			if tt.expect != nil {
+				tt.expect(target)
+			}
+			target.EXPECT().GetUsers().Return(tt.aws, nil).AnyTimes()
+			source.EXPECT().GetDeletedUsers().Return(tt.deleted, nil).AnyTimes()
+			source.EXPECT().GetUsers().Return(tt.google, nil).AnyTimes()
+
+			s, err := New(source, target, Options{})
+			assert.NoError(err)
+			res, err := s.SyncUsers(nil)
+			if tt.err {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Len(res.ToDelete(), tt.toDelete)
+			assert.Equal(tt.created, s.Report().UsersCreated)
+			assert.Equal(tt.errors, s.Report().Errors)
+		})
+	}
+}
+
+func TestSyncGroups(t *testing.T) {
+	googlePlatform := &admin.Group{Id: "10", Name: "Platform", Email: "platform@example.com"}
+
+	tests := []struct {
+		name    string
+		aws     []types.Group
+		google  []*admin.Group
+		expect  func(source *googlemock.MockClient, target *awsmock.MockClient)
+		created int
+		deleted int
+		added   int
+		removed int
+		err     bool
+	}{
+		{
+			name:   "missing group is created with its members",
+			google: []*admin.Group{googlePlatform},
+			expect: func(source *googlemock.MockClient, target *awsmock.MockClient) {
+				target.EXPECT().CreateGroup(awsutils.String("Platform"), gomock.Any()).Return(&platform, nil)
+				source.EXPECT().GetGroupMembers(googlePlatform).Return([]*admin.Member{{Email: "ana@example.com"}}, nil)
+				target.EXPECT().GetGroupMembers(&platform).Return(nil, nil)
+				target.EXPECT().AddUserToGroup(&anaAWS, &platform).Return(&types.GroupMembership{}, nil)
+			},
+			created: 1,
+			added:   1,
+		},
+		{
+			name:   "conflicting group is adopted",
+			google: []*admin.Group{googlePlatform},
+			expect: func(source *googlemock.MockClient, target *awsmock.MockClient) {
+				target.EXPECT().CreateGroup(awsutils.String("Platform"), gomock.Any()).Return(nil, apiError("ConflictException"))
+				target.EXPECT().FindGroupByDisplayName("Platform").Return(&platform, nil)
+				source.EXPECT().GetGroupMembers(googlePlatform).Return(nil, nil)
+				target.EXPECT().GetGroupMembers(&platform).Return([]types.GroupMembership{membership("m1", anaAWS)}, nil)
+				target.EXPECT().RemoveGroupMembership(gomock.Any()).Return(nil)
+			},
+			removed: 1,
+		},
+		{
+			name: "group missing in Google is deleted",
+			aws:  []types.Group{platform},
+			expect: func(source *googlemock.MockClient, target *awsmock.MockClient) {
+				target.EXPECT().DeleteGroup(&platform).Return(nil)
+			},
+			deleted: 1,
+		},
+		{
+			name:   "members are reconciled",
+			aws:    []types.Group{platform},
+			google: []*admin.Group{googlePlatform},
+			expect: func(source *googlemock.MockClient, target *awsmock.MockClient) {
+				source.EXPECT().GetGroupMembers(googlePlatform).Return([]*admin.Member{{Email: "ana@example.com"}}, nil)
+				target.EXPECT().GetGroupMembers(gomock.Any()).Return([]types.GroupMembership{membership("m2", boAWS)}, nil)
+				target.EXPECT().RemoveGroupMembership(gomock.Any()).Return(nil)
+				target.EXPECT().AddUserToGroup(&anaAWS, gomock.Any()).Return(&types.GroupMembership{}, nil)
+			},
+			added:   1,
+			removed: 1,
+		},
+		{
+			name:   "member of another kind is left alone",
+			aws:    []types.Group{platform},
+			google: []*admin.Group{googlePlatform},
+			expect: func(source *googlemock.MockClient, target *awsmock.MockClient) {
+				source.EXPECT().GetGroupMembers(googlePlatform).Return([]*admin.Member{{Email: "ana@example.com"}}, nil)
+				target.EXPECT().GetGroupMembers(gomock.Any()).Return([]types.GroupMembership{
+					{MembershipId: awsutils.String("m3"), MemberId: &types.UnknownUnionMember{Tag: "GroupId"}},
+					membership("m1", anaAWS),
+				}, nil)
+			},
+		},
+		{
+			name:   "failed member listing is returned",
+			aws:    []types.Group{platform},
+			google: []*admin.Group{googlePlatform},
+			expect: func(source *googlemock.MockClient, target *awsmock.MockClient) {
+				source.EXPECT().GetGroupMembers(googlePlatform).Return(nil, errors.New("backendError"))
+			},
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			source := googlemock.NewMockClient(ctrl)
+			target := awsmock.NewMockClient(ctrl)
+			tt.expect(source, target)
+			target.EXPECT().GetGroups().Return(tt.aws, nil)
+			source.EXPECT().GetGroups().Return(tt.google, nil)
+
+			s, err := New(source, target, Options{})
+			assert.NoError(err)
+			err = s.SyncGroups(nil, syncedUsers(anaAWS, boAWS))
+			if tt.err {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			r := s.Report()
+			assert.Equal(tt.created, r.GroupsCreated)
+			assert.Equal(tt.deleted, r.GroupsDeleted)
+			assert.Equal(tt.added, r.MembershipsAdded)
+			assert.Equal(tt.removed, r.MembershipsRemoved)
+			assert.Equal(0, r.Errors)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// allStaffNames are the usual names of the groups with every user of the
+// organization, normalized by systemName
+var allStaffNames = map[string]bool{
+	"all":            true,
+	"allstaff":       true,
+	"allusers":       true,
+	"allemployees":   true,
+	"allcompany":     true,
+	"allhands":       true,
+	"everyone":       true,
+	"everybody":      true,
+	"wholecompany":   true,
+	"entirecompany":  true,
+	"allmembers":     true,
+	"allpeople":      true,
+	"allpersonnel":   true,
+	"allcolleagues":  true,
+	"allteammembers": true,
+}
+
+// systemMarkers are the phrases of the names and descriptions of the
+// groups managed by Google Workspace, e.g. the target audiences
+var systemMarkers = []string{
+	"target audience",
+	"dynamic group",
+	"automatically managed",
+	"all users in",
+}
+
+// systemReason returns why the group looks managed by Google Workspace
+// or an administrator for the whole organization, e.g. an all-staff
+// target audience or a dynamic all-users group, empty when it does not
+func systemReason(g *admin.Group) string {
+	local := g.Email
+	if i := strings.LastIndex(local, "@"); i >= 0 {
+		local = local[:i]
+	}
+	if allStaffNames[systemName(local)] || allStaffNames[systemName(g.Name)] {
+		return "all-staff"
+	}
+	text := strings.ToLower(g.Name + " " + g.Description)
+	for _, m := range systemMarkers {
+		if strings.Contains(text, m) {
+			return strings.ReplaceAll(m, " ", "-")
+		}
+	}
+	return ""
+}
+
+// systemName lowercases name and removes its separators, e.g. all-staff
+// and All Staff are allstaff
+func systemName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', '_', '.', ' ', '+':
+			return -1
+		}
+		return r
+	}, strings.ToLower(name))
+}
+
+// excludeSystemGroups removes the groups which look managed for the
+// whole organization, unless listed in SystemGroups, to avoid syncing
+// every user by accident
+func (s *engine) excludeSystemGroups(groups []*admin.Group) []*admin.Group {
+	if !s.opts.ExcludeSystemGroups {
+		return groups
+	}
+	res := make([]*admin.Group, 0, len(groups))
+	for _, g := range groups {
+		reason := systemReason(g)
+		if reason != "" && !matchEmail(s.opts.SystemGroups, append([]string{g.Email}, g.Aliases...)) {
+			s.notice("system:"+g.Email, reason, log.WarnLevel, log.WithField("group", g.Name).WithField("email", g.Email).WithField("reason", reason),
+				"Group looks system managed, not syncing it, list it in the system groups to sync it")
+			continue
+		}
+		res = append(res, g)
+	}
+	return res
+}
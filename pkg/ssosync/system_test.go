@@ -0,0 +1,48 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+
+	"github.com/awslabs/ssosync/pkg/report"
+
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+func TestExcludeSystemGroups(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("all-staff", systemReason(&admin.Group{Email: "all-staff@example.com", Name: "Staff"}))
+	assert.Equal("all-staff", systemReason(&admin.Group{Email: "company@example.com", Name: "All Employees"}))
+	assert.Equal("target-audience", systemReason(&admin.Group{Email: "ta@example.com", Description: "Target audience of the intranet"}))
+	assert.Empty(systemReason(&admin.Group{Email: "all-engineers@example.com", Name: "Engineering"}))
+
+	s := &engine{
+		opts:   Options{ExcludeSystemGroups: true, SystemGroups: []string{"Everyone@example.com"}},
+		report: report.New(),
+	}
+	groups := s.excludeSystemGroups([]*admin.Group{
+		{Email: "all@example.com"},
+		{Email: "everyone@example.com"},
+		{Email: "platform@example.com"},
+	})
+	assert.Len(groups, 2)
+	assert.Equal("everyone@example.com", groups[0].Email)
+
+	s.opts.ExcludeSystemGroups = false
+	assert.Len(s.excludeSystemGroups([]*admin.Group{{Email: "all@example.com"}}), 1)
+}
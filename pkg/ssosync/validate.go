@@ -0,0 +1,84 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+)
+
+const (
+	// maxUserName is the maximum length of an Identity Store user name
+	maxUserName = 128
+	// maxAttribute is the maximum length of the other string attributes
+	maxAttribute = 1024
+)
+
+var (
+	// userNamePattern are the characters of an Identity Store user name,
+	// letters, marks, symbols, numbers and punctuation
+	userNamePattern = regexp.MustCompile(`^[\p{L}\p{M}\p{S}\p{N}\p{P}]+$`)
+	// attributePattern are the characters of the names, which may also
+	// contain spaces, tabs and line breaks
+	attributePattern = regexp.MustCompile(`^[\p{L}\p{M}\p{S}\p{N}\p{P}\t\n\r  　]+$`)
+	// reservedUserNames are refused by the Identity Store
+	reservedUserNames = []string{"Administrator", "AWSAdministrators"}
+)
+
+// validateUser checks the user against the documented constraints of
+// the Identity Store CreateUser call, and returns the problems found
+func validateUser(u *types.User) []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	check := func(field, value string, max int, pattern *regexp.Regexp) {
+		switch n := utf8.RuneCountInString(value); {
+		case n == 0:
+			add("%s is required", field)
+		case n > max:
+			add("%s is %d characters long, at most %d are allowed", field, n, max)
+		case !pattern.MatchString(value):
+			add("%s %q contains characters which are not allowed", field, value)
+		}
+	}
+
+	name := awsutils.ToString(u.UserName)
+	check("user name", name, maxUserName, userNamePattern)
+	for _, r := range reservedUserNames {
+		if strings.EqualFold(name, r) {
+			add("user name %q is reserved", name)
+		}
+	}
+	check("display name", strings.TrimSpace(awsutils.ToString(u.DisplayName)), maxAttribute, attributePattern)
+	if u.Name == nil {
+		add("given name is required")
+		add("family name is required")
+	} else {
+		check("given name", awsutils.ToString(u.Name.GivenName), maxAttribute, attributePattern)
+		check("family name", awsutils.ToString(u.Name.FamilyName), maxAttribute, attributePattern)
+	}
+	for _, e := range u.Emails {
+		if n := utf8.RuneCountInString(awsutils.ToString(e.Value)); n > maxAttribute {
+			add("email is %d characters long, at most %d are allowed", n, maxAttribute)
+		}
+	}
+	return problems
+}
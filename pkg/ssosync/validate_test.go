@@ -0,0 +1,47 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"strings"
+	"testing"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateUser(t *testing.T) {
+	assert := assert.New(t)
+
+	u := &types.User{
+		UserName:    awsutils.String("jane.doe@example.com"),
+		DisplayName: awsutils.String("Jane Doe"),
+		Name:        &types.Name{GivenName: awsutils.String("Jane"), FamilyName: awsutils.String("Doe")},
+	}
+	assert.Empty(validateUser(u))
+
+	u.UserName = awsutils.String("jane doe")
+	u.Name.GivenName = awsutils.String("")
+	u.Name.FamilyName = awsutils.String(strings.Repeat("x", 1025))
+	problems := validateUser(u)
+	assert.Len(problems, 3)
+	assert.Contains(problems[0], "not allowed")
+	assert.Equal("given name is required", problems[1])
+	assert.Contains(problems[2], "1025 characters")
+
+	u = &types.User{UserName: awsutils.String("administrator"), DisplayName: awsutils.String(" ")}
+	assert.Len(validateUser(u), 4)
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	awsutils "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// verifyDelay is the delay before the changes not found by the
+// verification are looked up again, as the Identity Store is eventually
+// consistent
+var verifyDelay = 2 * time.Second
+
+// record keeps the change applied to the target for the verification
+func (s *engine) record(e Event) {
+	if s.opts.Verify {
+		s.applied = append(s.applied, e)
+	}
+}
+
+// verify re-reads the target and reports the applied changes which did
+// not land, looking them up again after verifyDelay
+func (s *engine) verify() {
+	if len(s.applied) == 0 {
+		return
+	}
+	s.progress.begin("verify", len(s.applied))
+	problems, err := s.unconverged(s.applied)
+	if err == nil && len(problems) > 0 {
+		log.WithField("count", len(problems)).WithField("delay", verifyDelay).Debug("Some changes not found in the target yet, verifying them again")
+		time.Sleep(verifyDelay)
+		problems, err = s.unconverged(s.applied)
+	}
+	if err != nil {
+		log.Error("Can't verify the changes: ", err)
+		s.report.Fail(err)
+		return
+	}
+	for _, p := range problems {
+		log.WithField("change", p).Error("Change not found in the target after the run")
+		s.report.Unconverged(p)
+	}
+	if len(problems) == 0 {
+		log.WithField("changes", len(s.applied)).Info("Verified the changes in the target")
+	}
+}
+
+// unconverged returns the applied changes which the target does not
+// reflect
+func (s *engine) unconverged(applied []Event) ([]string, error) {
+	users, err := s.target.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.target.GetGroups()
+	if err != nil {
+		return nil, err
+	}
+	userIds := make(map[string]string, len(users))
+	for _, u := range users {
+		userIds[awsutils.ToString(u.UserName)] = awsutils.ToString(u.UserId)
+	}
+	groupsByName := make(map[string]types.Group, len(groups))
+	for _, g := range groups {
+		groupsByName[awsutils.ToString(g.DisplayName)] = g
+	}
+
+	// the members of the groups whose membership changed, by group name
+	members := make(map[string]map[string]bool)
+	groupMembers := func(name string) (map[string]bool, error) {
+		if m, ok := members[name]; ok {
+			return m, nil
+		}
+		m := make(map[string]bool)
+		if g, ok := groupsByName[name]; ok {
+			ms, err := s.target.GetGroupMembers(&g)
+			if err != nil {
+				return nil, err
+			}
+			for i := range ms {
+				m[memberId(&ms[i])] = true
+			}
+		}
+		members[name] = m
+		return m, nil
+	}
+
+	var problems []string
+	for _, e := range applied {
+		s.progress.step()
+		_, userExists := userIds[e.UserName]
+		_, groupExists := groupsByName[e.GroupName]
+		switch e.Type {
+		case EventUserCreate:
+			if !userExists {
+				problems = append(problems, fmt.Sprintf("user %s was created but does not exist", e.UserName))
+			}
+		case EventUserDelete:
+			if userExists {
+				problems = append(problems, fmt.Sprintf("user %s was deleted but still exists", e.UserName))
+			}
+		case EventGroupCreate:
+			if !groupExists {
+				problems = append(problems, fmt.Sprintf("group %s was created but does not exist", e.GroupName))
+			}
+		case EventGroupDelete:
+			if groupExists {
+				problems = append(problems, fmt.Sprintf("group %s was deleted but still exists", e.GroupName))
+			}
+		case EventMemberAdd, EventMemberRemove:
+			if e.UserName == "" {
+				// a member which is not a known user
+				continue
+			}
+			m, err := groupMembers(e.GroupName)
+			if err != nil {
+				return nil, err
+			}
+			member := userExists && m[userIds[e.UserName]]
+			if e.Type == EventMemberAdd && !member {
+				problems = append(problems, fmt.Sprintf("user %s was added to group %s but is not a member", e.UserName, e.GroupName))
+			}
+			if e.Type == EventMemberRemove && member {
+				problems = append(problems, fmt.Sprintf("user %s was removed from group %s but is still a member", e.UserName, e.GroupName))
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems, nil
+}
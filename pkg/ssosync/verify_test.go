@@ -0,0 +1,64 @@
+// Copyright (c) 2020, Amazon.com, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssosync
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/awslabs/ssosync/pkg/report"
+	"github.com/stretchr/testify/assert"
+	admin "google.golang.org/api/admin/directory/v1"
+)
+
+// lossyTarget is a MemoryTarget acknowledging the memberships of bo
+// without adding them
+type lossyTarget struct {
+	*MemoryTarget
+}
+
+func (t *lossyTarget) AddUserToGroup(u *types.User, g *types.Group) (*types.GroupMembership, error) {
+	if *u.UserName == "bo@example.com" {
+		return &types.GroupMembership{GroupId: g.GroupId}, nil
+	}
+	return t.MemoryTarget.AddUserToGroup(u, g)
+}
+
+func TestVerify(t *testing.T) {
+	assert := assert.New(t)
+	verifyDelay = 0
+
+	source := &memorySource{
+		users: []*admin.User{
+			{Id: "1", PrimaryEmail: "ana@example.com", Name: &admin.UserName{GivenName: "Ana", FamilyName: "Silva"}},
+			{Id: "2", PrimaryEmail: "bo@example.com", Name: &admin.UserName{GivenName: "Bo", FamilyName: "Li"}},
+		},
+		groups:  []*admin.Group{{Id: "g1", Name: "Platform", Email: "platform@example.com"}},
+		members: map[string][]*admin.Member{"g1": {{Email: "ana@example.com"}, {Email: "bo@example.com"}}},
+	}
+
+	s, err := New(source, &lossyTarget{NewMemoryTarget()}, Options{Verify: true})
+	assert.NoError(err)
+	assert.NoError(s.Run())
+	r := s.Report()
+	assert.Equal(2, r.MembershipsAdded)
+	assert.Equal(1, r.Errors)
+	assert.Equal(map[string]int{report.CauseUnconverged: 1}, r.ErrorCauses())
+	assert.Equal([]string{"user bo@example.com was added to group Platform but is not a member"}, r.Summary().Unconverged)
+
+	s, _ = New(source, NewMemoryTarget(), Options{Verify: true})
+	assert.NoError(s.Run())
+	assert.Equal(0, s.Report().Errors)
+}